@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+)
+
+const partSuffix = ".cloudfs-part"
+
+// localProvider stores objects as files below root.
+type localProvider struct {
+	root       string
+	remoteName string // Prefix of placements.remote_path, e.g. "usb:"
+	quota      int64
+}
+
+// newLocalProvider builds the provider from its init config. "location"
+// is the directory given to `cloudfs provider add`.
+func newLocalProvider(config map[string]string) (*localProvider, error) {
+	root := config["location"]
+	if root == "" {
+		return nil, fmt.Errorf("location not configured")
+	}
+	p := &localProvider{root: filepath.Clean(root), remoteName: config["remote"]}
+	if v := config["quota"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid quota: %s", v)
+		}
+		p.quota = n
+	}
+	info, err := os.Stat(p.root)
+	if err != nil {
+		return nil, fmt.Errorf("location not reachable: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("location %s is not a directory", p.root)
+	}
+	return p, nil
+}
+
+func (p *localProvider) ID() string          { return "local" }
+func (p *localProvider) Type() string        { return "local" }
+func (p *localProvider) DisplayName() string { return p.root }
+
+func (p *localProvider) Init(ctx context.Context, config map[string]interface{}) error {
+	return nil
+}
+
+func (p *localProvider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{
+		SupportsDirectUpload: true,
+		ConcurrentUploads:    4,
+	}, nil
+}
+
+// GetUsage reports the summed object sizes against the configured quota.
+func (p *localProvider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	objects, err := p.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	usage := &provider.Usage{TotalBytes: p.quota}
+	for _, obj := range objects {
+		usage.UsedBytes += obj.Size
+	}
+	if usage.TotalBytes > usage.UsedBytes {
+		usage.AvailableBytes = usage.TotalBytes - usage.UsedBytes
+	}
+	return usage, nil
+}
+
+// Upload copies to a temporary file and renames it into place.
+func (p *localProvider) Upload(ctx context.Context, localPath string, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	target := p.path(remotePath)
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return nil, err
+	}
+	part := target + partSuffix
+	size, hash, err := copyFile(ctx, localPath, part, bwlimit.Upload, progress)
+	if err != nil {
+		os.Remove(part)
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+	if err := os.Rename(part, target); err != nil {
+		os.Remove(part)
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+	return &provider.UploadResult{
+		RemotePath:  remotePath,
+		ContentHash: hash,
+		UploadedAt:  time.Now(),
+		Size:        size,
+	}, nil
+}
+
+func (p *localProvider) Download(ctx context.Context, remotePath string, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return nil, err
+	}
+	size, hash, err := copyFile(ctx, p.path(remotePath), localPath, bwlimit.Download, progress)
+	if err != nil {
+		os.Remove(localPath)
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	return &provider.DownloadResult{
+		LocalPath:    localPath,
+		ContentHash:  hash,
+		DownloadedAt: time.Now(),
+		Size:         size,
+	}, nil
+}
+
+// Delete treats a missing file as already deleted.
+func (p *localProvider) Delete(ctx context.Context, remotePath string) error {
+	if err := os.Remove(p.path(remotePath)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (p *localProvider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	f, err := os.Open(p.path(remotePath))
+	if err != nil {
+		return &provider.VerifyResult{IsValid: false, ErrorMessage: "file not found or inaccessible"}, nil
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		return &provider.VerifyResult{IsValid: false, ErrorMessage: "not a file"}, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return &provider.VerifyResult{IsValid: true, ContentHash: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func (p *localProvider) CheckHealth(ctx context.Context) provider.HealthState {
+	if _, err := os.Stat(p.root); err != nil {
+		return provider.HealthStateUnavailable
+	}
+	return provider.HealthStateHealthy
+}
+
+// List walks the directory below prefix, skipping partial uploads.
+func (p *localProvider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	var objects []provider.RemoteObject
+	err := filepath.WalkDir(p.path(prefix), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, partSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(p.root, path)
+		objects = append(objects, provider.RemoteObject{
+			Path:    p.remoteName + filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	return objects, err
+}
+
+// path maps a remote path ("usb:/docs/a.pdf") to a file below root.
+func (p *localProvider) path(remotePath string) string {
+	rel := filepath.FromSlash(strings.TrimPrefix(remotePath, p.remoteName))
+	return filepath.Join(p.root, filepath.Clean(string(filepath.Separator)+rel))
+}
+
+// copyFile copies src to dst, hashing and reporting progress on the way.
+// Reads are throttled by any bandwidth limit the host passed for dir.
+func copyFile(ctx context.Context, src, dst string, dir bwlimit.Direction, progress provider.ProgressFunc) (int64, string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, "", err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, "", err
+	}
+
+	body := bwlimit.Reader(ctx, dir, in)
+	h := sha256.New()
+	buf := make([]byte, 256*1024)
+	var n int64
+	for {
+		if err := ctx.Err(); err != nil {
+			out.Close()
+			return n, "", err
+		}
+		m, readErr := body.Read(buf)
+		if m > 0 {
+			if _, err := out.Write(buf[:m]); err != nil {
+				out.Close()
+				return n, "", err
+			}
+			h.Write(buf[:m])
+			n += int64(m)
+			if progress != nil && info.Size() > 0 {
+				progress(provider.Progress{Bytes: n, Total: info.Size()})
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			out.Close()
+			return n, "", readErr
+		}
+	}
+	if err := out.Close(); err != nil {
+		return n, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,21 @@
+// Package main is the reference CloudFS provider plugin. It stores objects
+// in a local directory (a USB disk, a mounted NAS share) and shows the
+// minimum a plugin has to implement.
+//
+// Install it on PATH, then:
+//
+//	cloudfs provider add usb local /mnt/usb/cloudfs --quota 2T
+package main
+
+import (
+	"context"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/plugin"
+)
+
+func main() {
+	plugin.Main("local", func(ctx context.Context, config map[string]string) (provider.Provider, error) {
+		return newLocalProvider(config)
+	})
+}
@@ -9,18 +9,31 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/cloudfs/cloudfs/internal/core"
 	"github.com/cloudfs/cloudfs/internal/model"
 	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+	"github.com/cloudfs/cloudfs/internal/provider/faulty"
+	"github.com/cloudfs/cloudfs/internal/provider/plugin"
+	"github.com/cloudfs/cloudfs/internal/provider/rclone"
+	"github.com/cloudfs/cloudfs/internal/provider/retry"
+	"github.com/cloudfs/cloudfs/internal/provider/s3"
+	"github.com/cloudfs/cloudfs/internal/provider/sftp"
+	"github.com/cloudfs/cloudfs/internal/provider/webdav"
 	"github.com/cloudfs/cloudfs/internal/tui"
 )
 
@@ -32,6 +45,8 @@ type Engine struct {
 	Placeholder *core.PlaceholderManager
 	Hydration   *core.HydrationController
 	Providers   *provider.DefaultRegistry
+	Bandwidth   *bwlimit.Limiter // Global limit shared by all provider transfers
+	Rclone      []*rclone.Daemon // rclone rcd processes started with --rcd
 	RootDir     string
 	ConfigDir   string
 }
@@ -76,6 +91,9 @@ func InitEngine() (*Engine, error) {
 		return nil, fmt.Errorf("failed to create cache manager: %w", err)
 	}
 
+	// Prompt before evicting when a Put would exceed the cache quota
+	cache.SetPressureHandler(confirmCachePressure)
+
 	// Create placeholder manager
 	placeholder, err := core.NewPlaceholderManager(rootDir)
 	if err != nil {
@@ -85,25 +103,45 @@ func InitEngine() (*Engine, error) {
 	// Create provider registry
 	providers := provider.NewRegistry()
 
-	// Load providers from DB
-	provRows, err := db.DB().QueryContext(ctx, `SELECT name, type, config FROM providers WHERE status = 'active'`)
-	if err == nil {
-		defer provRows.Close()
-		for provRows.Next() {
-			var name, ptype, configJSON string
-			if err := provRows.Scan(&name, &ptype, &configJSON); err == nil {
-				// We need to instantiate the provider.
-				// Since we only support rclone for now, and we don't have the factory here easily,
-				// avoiding full instantiation might be better if we only need metadata.
-				// BUT, RunStatus uses e.Providers.All().
-				// Let's modify RunStatus in engine.go to query DB directly, similar to RunScanProviders.
+	// Global bandwidth limit, overridable per command with --bwlimit
+	schedule, err := core.GetBandwidthLimit(ctx, db.DB())
+	if err != nil {
+		return nil, err
+	}
+	bandwidth := bwlimit.NewLimiter(schedule)
+
+	// With --rcd, rclone providers share one rclone rcd until CloseEngine.
+	// Its bandwidth limit is daemon-wide, so a provider with its own limit
+	// gets a daemon of its own. Daemons only start when used.
+	var daemons []*rclone.Daemon
+	var daemonFor func(cfg map[string]string) *rclone.Daemon
+	if useRcloneDaemon {
+		shared := rclone.NewDaemon("")
+		daemons = append(daemons, shared)
+		daemonFor = func(cfg map[string]string) *rclone.Daemon {
+			if cfg[core.BandwidthConfigKey] == "" {
+				return shared
 			}
+			d := rclone.NewDaemon("")
+			daemons = append(daemons, d)
+			return d
 		}
 	}
 
+	// Load providers from DB
+	if err := loadProviders(ctx, db.DB(), providers, filepath.Join(cfgDir, "temp"), bandwidth, daemonFor); err != nil {
+		return nil, err
+	}
+
 	// Create hydration controller
 	hydration := core.NewHydrationController(index, cache, placeholder, journal, providers, db.DB())
 
+	// Reconcile hydration temp files left by an interrupted run
+	if recovered, err := hydration.RecoverTempFiles(ctx); err == nil && recovered.Changed() && !quiet {
+		fmt.Fprintf(os.Stderr, "Recovered interrupted hydrations: %d resumed, %d rolled back, %d temp file(s) removed\n",
+			recovered.Resumed, recovered.RolledBack, recovered.Removed)
+	}
+
 	return &Engine{
 		Index:       index,
 		Journal:     journal,
@@ -111,11 +149,165 @@ func InitEngine() (*Engine, error) {
 		Placeholder: placeholder,
 		Hydration:   hydration,
 		Providers:   providers,
+		Bandwidth:   bandwidth,
+		Rclone:      daemons,
 		RootDir:     rootDir,
 		ConfigDir:   cfgDir,
 	}, nil
 }
 
+// loadProviders registers every active provider from the index.
+// Providers are keyed by name, matching placements.provider_id.
+// tempDir holds provider working state such as resumable uploads.
+// Transfers are throttled by bandwidth and the provider's own limit.
+// rclone providers run through the daemon from daemonFor when it is not nil.
+func loadProviders(ctx context.Context, db *sql.DB, registry *provider.DefaultRegistry, tempDir string, bandwidth *bwlimit.Limiter, daemonFor func(cfg map[string]string) *rclone.Daemon) error {
+	rows, err := db.QueryContext(ctx, `SELECT id, name, type FROM providers WHERE status = 'active' ORDER BY priority, id`)
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	type row struct {
+		id         int64
+		name, kind string
+	}
+	var list []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.name, &r.kind); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan provider: %w", err)
+		}
+		list = append(list, r)
+	}
+	rows.Close()
+
+	// Provider calls retry transient failures; health changes are recorded
+	health := core.NewHealthManager(db)
+	policy := retry.Policy{
+		OnHealthChange: func(name string, state provider.HealthState) {
+			health.RecordProviderHealth(context.Background(), name, state)
+		},
+	}
+
+	// Fault injectors wrap other providers, so they are built last
+	built := make(map[string]provider.Provider)
+	var wrappers []row
+	for _, r := range list {
+		if r.kind == "faulty" {
+			wrappers = append(wrappers, r)
+			continue
+		}
+		cfg, err := loadProviderConfig(ctx, db, r.id)
+		if err != nil {
+			return err
+		}
+		p, err := newProvider(r.name, r.kind, cfg, tempDir)
+		if err != nil {
+			// Unknown types stay unregistered; commands report them by name.
+			continue
+		}
+		if rp, ok := p.(*rclone.Provider); ok && daemonFor != nil {
+			rp.UseDaemon(daemonFor(cfg))
+		}
+		built[r.name] = p
+		registry.Register(retry.NewProvider(limitProvider(p, cfg, bandwidth), policy))
+	}
+	for _, r := range wrappers {
+		cfg, err := loadProviderConfig(ctx, db, r.id)
+		if err != nil {
+			return err
+		}
+		p, err := newFaultyProvider(r.name, cfg, built)
+		if err != nil {
+			continue
+		}
+		registry.Register(retry.NewProvider(limitProvider(p, cfg, bandwidth), policy))
+	}
+	return nil
+}
+
+// limitProvider attaches the global limiter and the provider's own
+// bwlimit schedule, if any, to p's transfers.
+func limitProvider(p provider.Provider, cfg map[string]string, bandwidth *bwlimit.Limiter) provider.Provider {
+	var own *bwlimit.Limiter
+	if schedule, err := bwlimit.ParseSchedule(cfg[core.BandwidthConfigKey]); err == nil && !schedule.Unlimited() {
+		own = bwlimit.NewLimiter(schedule)
+	}
+	return bwlimit.NewProvider(p, bandwidth, own)
+}
+
+// newFaultyProvider wraps the provider named by cfg["wrap"] in a fault
+// injector. Its placements use the wrapped provider's remote.
+func newFaultyProvider(name string, cfg map[string]string, built map[string]provider.Provider) (provider.Provider, error) {
+	inner, ok := built[cfg["wrap"]]
+	if !ok {
+		return nil, fmt.Errorf("wrapped provider not loaded: %s", cfg["wrap"])
+	}
+	fcfg, err := faulty.ParseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return faulty.NewProvider(name, inner, fcfg)
+}
+
+// loadProviderConfig returns the provider_config key/value pairs for a provider.
+func loadProviderConfig(ctx context.Context, db *sql.DB, providerID int64) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT key, value FROM provider_config WHERE provider_id = ?`, providerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider config: %w", err)
+	}
+	defer rows.Close()
+
+	cfg := make(map[string]string)
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, fmt.Errorf("failed to scan provider config: %w", err)
+		}
+		cfg[k] = v
+	}
+	return cfg, nil
+}
+
+// newProvider constructs a provider instance for a configured type.
+func newProvider(name, provType string, cfg map[string]string, tempDir string) (provider.Provider, error) {
+	remote := cfg["remote"]
+	if remote == "" {
+		remote = name + ":"
+	}
+	switch provType {
+	case "rclone":
+		return rclone.NewProvider(name, name, remote, ""), nil
+	case "s3":
+		s3cfg, err := s3.ParseConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s3cfg.StateDir = filepath.Join(tempDir, "s3")
+		return s3.NewProvider(name, name, remote, s3cfg)
+	case "webdav":
+		davcfg, err := webdav.ParseConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return webdav.NewProvider(name, name, remote, davcfg)
+	case "sftp":
+		sftpcfg, err := sftp.ParseConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return sftp.NewProvider(name, name, remote, sftpcfg)
+	default:
+		// Any other type is served by a cloudfs-provider-<type> plugin
+		path, err := plugin.Find(provType)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported provider type: %s", provType)
+		}
+		return plugin.NewProvider(name, name, provType, path, cfg), nil
+	}
+}
+
 // GetEngine returns the engine, initializing if needed.
 func GetEngine() (*Engine, error) {
 	if engine != nil {
@@ -127,6 +319,17 @@ func GetEngine() (*Engine, error) {
 	return engine, err
 }
 
+// CloseEngine stops what the engine started for this command, such as
+// the --rcd daemons. Safe to call when no engine was created.
+func CloseEngine() {
+	if engine == nil {
+		return
+	}
+	for _, d := range engine.Rclone {
+		d.Close()
+	}
+}
+
 // ConfirmAction prompts the user for confirmation.
 func ConfirmAction(prompt string) bool {
 	fmt.Printf("%s [y/N]: ", prompt)
@@ -280,6 +483,100 @@ func RunVerify() error {
 	return nil
 }
 
+// RunScrub verifies the stalest placements against their providers,
+// recording verified_at. Interrupted or budget-limited runs resume where they stopped.
+func RunScrub(olderThan, budget string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	opts := core.ScrubOptions{}
+	if olderThan != "" {
+		if opts.OlderThan, err = core.ParseAge(olderThan); err != nil {
+			return fmt.Errorf("invalid --older-than value: %w", err)
+		}
+	}
+	if budget != "" {
+		if opts.Budget, err = core.ParseSize(budget); err != nil {
+			return fmt.Errorf("invalid --budget value: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	scrubber := core.NewScrubber(db.DB(), e.Providers, e.Hydration.TempDir())
+
+	if dryRun {
+		items, resumed, err := scrubber.Plan(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if resumed {
+			fmt.Println("Resuming an interrupted scrub run.")
+		}
+		fmt.Printf("Would verify %d placements (stalest first):\n", len(items))
+		for _, item := range items {
+			last := "never"
+			if item.LastVerified != nil {
+				last = item.LastVerified.Format("2006-01-02")
+			}
+			fmt.Printf("  %-16s %-10s  last verified %-10s  %s\n", item.ProviderID, formatBytes(item.Size), last, item.RemotePath)
+		}
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
+
+	start := time.Now()
+	result, err := scrubber.Scrub(ctx, opts, func(item *core.ScrubItem) {
+		if quiet {
+			return
+		}
+		name := item.Path
+		if name == "" {
+			name = item.RemotePath
+		}
+		switch item.Result {
+		case core.ScrubVerified:
+			if verbose {
+				fmt.Printf("✓ %s on %s (%s)\n", name, item.ProviderID, item.Method)
+			}
+		case core.ScrubError:
+			fmt.Printf("⚠️  %s on %s: %s\n", name, item.ProviderID, item.Detail)
+		default:
+			fmt.Printf("✗ %s on %s: %s, placement marked degraded\n", name, item.ProviderID, item.Result)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("scrub failed: %w", err)
+	}
+
+	if result.Resumed && !quiet {
+		fmt.Println("Resumed an interrupted scrub run.")
+	}
+	fmt.Printf("\nScrubbed %d placements in %.1fs: %d verified, %d degraded, %d inconclusive\n",
+		result.Checked, time.Since(start).Seconds(), result.Verified, result.Degraded, result.Errors)
+	if result.Downloaded > 0 {
+		fmt.Printf("Downloaded %s to rehash objects without provider hashes\n", formatBytes(result.Downloaded))
+	}
+	if !result.Complete {
+		fmt.Printf("⚠️  %d placements not checked (interrupted or over the budget). Run 'cloudfs scrub' again to resume.\n", result.Remaining)
+	}
+	if result.Degraded > 0 {
+		fmt.Println("  Degraded placements can be replaced with: cloudfs heal or cloudfs replicate")
+	}
+	return nil
+}
+
 // RunRepair attempts to repair inconsistencies.
 // Per design.txt: repair MAY rebuild placeholders, retry uploads, re-verify placements
 // repair MUST NOT delete remote data, migrate providers, drop versions
@@ -564,158 +861,448 @@ func RunCacheStatus() error {
 	fmt.Printf("Pinned:         %d entries\n", stats.PinnedEntries)
 	fmt.Printf("Stale:          %d entries\n", stats.StaleEntries)
 
-	return nil
-}
+	usage, err := e.Cache.Usage(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cache usage: %w", err)
+	}
 
-// formatBytes formats bytes as human-readable.
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+	quota := "unlimited"
+	if usage.Quota.QuotaBytes > 0 {
+		quota = formatBytes(usage.Quota.QuotaBytes)
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	autoClean := "off"
+	if usage.Quota.AutoClean {
+		autoClean = "on"
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
-
-// --- Snapshot Commands ---
 
-// RunSnapshotCreate creates a new snapshot.
-func RunSnapshotCreate(name, description string) error {
-	if dryRun {
-		fmt.Printf("[DRY-RUN] Would create snapshot: %s\n", name)
-		return nil
+	fmt.Println()
+	fmt.Printf("Quota:          %s\n", quota)
+	fmt.Printf("Used:           %s\n", formatBytes(usage.Used))
+	fmt.Printf("Disk free:      %s (keep %s free)\n", formatBytes(usage.DiskFree), formatBytes(usage.Quota.MinFreeBytes))
+	fmt.Printf("Headroom:       %s\n", formatBytes(usage.Headroom))
+	fmt.Printf("Auto-clean:     %s\n", autoClean)
+	if usage.Headroom == 0 {
+		fmt.Println("\n⚠️  Cache is full. Free space with: cloudfs cache plan --free <size> --apply")
 	}
 
+	return nil
+}
+
+// RunCacheConfig updates the cache quota, low-disk threshold and auto-clean.
+// Empty size strings leave the current value unchanged; "0" removes the limit.
+func RunCacheConfig(quota, minFree string, autoClean *bool) error {
 	e, err := GetEngine()
 	if err != nil {
 		return err
 	}
 
 	ctx := context.Background()
-
-	// Get database for snapshot manager
-	dbPath := filepath.Join(e.ConfigDir, "index.db")
-	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
-	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	q, err := e.Cache.GetQuota(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
-	defer db.Close()
 
-	sm := core.NewSnapshotManager(db.DB(), e.Journal)
+	if quota != "" {
+		if q.QuotaBytes, err = core.ParseSize(quota); err != nil {
+			return fmt.Errorf("invalid --quota value: %w", err)
+		}
+	}
+	if minFree != "" {
+		if q.MinFreeBytes, err = core.ParseSize(minFree); err != nil {
+			return fmt.Errorf("invalid --min-free value: %w", err)
+		}
+	}
+	if autoClean != nil {
+		q.AutoClean = *autoClean
+	}
 
-	snapshot, err := sm.Create(ctx, name, description)
-	if err != nil {
-		return fmt.Errorf("failed to create snapshot: %w", err)
+	if dryRun {
+		fmt.Printf("Would set quota=%s min-free=%s auto-clean=%t\n",
+			formatBytes(q.QuotaBytes), formatBytes(q.MinFreeBytes), q.AutoClean)
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
 	}
 
-	if !quiet {
-		fmt.Printf("✓ Created snapshot: %s\n", snapshot.Name)
-		fmt.Printf("  Created: %s\n", snapshot.CreatedAt.Format("2006-01-02 15:04:05"))
-		if description != "" {
-			fmt.Printf("  Description: %s\n", description)
-		}
+	if err := e.Cache.SetQuota(ctx, q); err != nil {
+		return err
 	}
 
-	return nil
+	fmt.Println("✓ Cache configuration updated")
+	return RunCacheStatus()
 }
 
-// RunSnapshotList lists all snapshots.
-func RunSnapshotList() error {
+// confirmCachePressure asks the user before evicting to make room for a Put.
+func confirmCachePressure(ctx context.Context, p *core.CachePressure, plan *core.EvictionPlan) bool {
+	fmt.Printf("\n⚠️  Cache full: %s incoming, %s headroom\n", formatBytes(p.Incoming), formatBytes(p.Usage.Headroom))
+	fmt.Printf("Evicting %d entries would free %s:\n", len(plan.Selected), formatBytes(plan.Freed))
+	for _, c := range plan.Selected {
+		fmt.Printf("  %-10s  %s\n", formatBytes(c.Size), c.Path)
+	}
+	if dryRun {
+		return false
+	}
+	return ConfirmAction("Evict these entries?")
+}
+
+// RunCachePlan shows the ranked eviction set needed to free space.
+// With apply, the selected entries are evicted after confirmation.
+func RunCachePlan(free string, apply bool) error {
 	e, err := GetEngine()
 	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
+	target, err := core.ParseSize(free)
+	if err != nil {
+		return fmt.Errorf("invalid --free value: %w", err)
+	}
 
-	dbPath := filepath.Join(e.ConfigDir, "index.db")
-	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
-	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	ctx := context.Background()
+	plan, err := e.Cache.PlanEviction(ctx, target, 0)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
-	defer db.Close()
 
-	sm := core.NewSnapshotManager(db.DB(), e.Journal)
+	fmt.Printf("Cache Eviction Plan (free %s)\n", formatBytes(target))
+	fmt.Println("═══════════════════════════════════════")
+	if len(plan.Selected) == 0 {
+		fmt.Println("  No eligible cache entries.")
+	} else {
+		fmt.Println("  #   Score  Size        Last access  Replica     Path")
+		fmt.Println("  ─────────────────────────────────────────────────────────────")
+		for i, c := range plan.Selected {
+			replica := "uploaded"
+			if c.Verified {
+				replica = "verified"
+			}
+			policy := ""
+			if c.Policy != core.CachePolicyNormal {
+				policy = fmt.Sprintf(" [%s]", c.Policy)
+			}
+			fmt.Printf("  %-3d %.2f   %-10s  %-11s  %-10s  %s%s\n",
+				i+1, c.Score, formatBytes(c.Size), c.Entry.LastAccessed.Format("2006-01-02"),
+				replica, c.Path, policy)
+			if verbose {
+				fmt.Printf("        age=%.2f size=%.2f replica=%.2f egress=%.2f latency=%.2f policy=%.2f  ($%.2f to re-download via %s)\n",
+					c.Factors["age"], c.Factors["size"], c.Factors["replica"], c.Factors["egress"],
+					c.Factors["latency"], c.Factors["policy"], c.RehydrateUSD, strings.Join(c.Providers, ", "))
+			}
+		}
+	}
 
-	snapshots, err := sm.List(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to list snapshots: %w", err)
+	fmt.Printf("\nWould free: %s across %d entries\n", formatBytes(plan.Freed), len(plan.Selected))
+	if plan.Shortfall > 0 {
+		fmt.Printf("⚠️  Short by %s: not enough evictable cache\n", formatBytes(plan.Shortfall))
+	}
+	if len(plan.Skipped) > 0 {
+		fmt.Printf("\nProtected (%d):\n", len(plan.Skipped))
+		for _, c := range plan.Skipped {
+			fmt.Printf("  %-10s  %s — %s\n", formatBytes(c.Size), c.Path, c.Reason)
+		}
 	}
 
-	if len(snapshots) == 0 {
-		fmt.Println("No snapshots found.")
+	if !apply || len(plan.Selected) == 0 {
 		return nil
 	}
 
-	fmt.Printf("Snapshots (%d):\n", len(snapshots))
-	fmt.Println("Name                Created              Description")
-	fmt.Println("────────────────────────────────────────────────────────────")
-	for _, s := range snapshots {
-		desc := s.Description
-		if len(desc) > 30 {
-			desc = desc[:27] + "..."
-		}
-		fmt.Printf("%-19s %-20s %s\n",
-			s.Name,
-			s.CreatedAt.Format("2006-01-02 15:04"),
-			desc)
+	if dryRun {
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
+
+	if !ConfirmAction(fmt.Sprintf("\nEvict %d entries (%s)?", len(plan.Selected), formatBytes(plan.Freed))) {
+		fmt.Println("Cancelled.")
+		return nil
 	}
 
+	evicted := 0
+	for _, c := range plan.Selected {
+		if err := e.Cache.Evict(ctx, c.Entry.EntryID, c.Entry.VersionID, true); err != nil {
+			fmt.Printf("✗ %s: %v\n", c.Path, err)
+			continue
+		}
+		evicted++
+	}
+	fmt.Printf("✓ Evicted %d entries\n", evicted)
 	return nil
 }
 
-// RunSnapshotInspect shows snapshot details.
-func RunSnapshotInspect(name string) error {
+// RunCachePolicySet sets the cache policy for a path or glob.
+func RunCachePolicySet(pattern, mode string) error {
 	e, err := GetEngine()
 	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
-
-	dbPath := filepath.Join(e.ConfigDir, "index.db")
-	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
-	db, err := core.OpenEncryptedDB(dbPath, passphrase)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
-	}
-	defer db.Close()
-
-	sm := core.NewSnapshotManager(db.DB(), e.Journal)
-
-	info, err := sm.Inspect(ctx, name)
+	m, err := core.ParseCachePolicyMode(mode)
 	if err != nil {
-		return fmt.Errorf("failed to inspect snapshot: %w", err)
+		return err
 	}
 
-	fmt.Printf("Snapshot: %s\n", info.Snapshot.Name)
-	fmt.Println("═══════════════════════════════════")
-	fmt.Printf("Created:     %s\n", info.Snapshot.CreatedAt.Format("2006-01-02 15:04:05"))
-	if info.Snapshot.Description != "" {
-		fmt.Printf("Description: %s\n", info.Snapshot.Description)
+	if err := e.Cache.SetCachePolicy(context.Background(), pattern, m); err != nil {
+		return err
 	}
-	fmt.Println()
-	fmt.Printf("Entries:     %d\n", info.EntryCount)
-	fmt.Printf("Versions:    %d\n", info.VersionCount)
-	fmt.Printf("Total size:  %s\n", formatBytes(info.TotalSize))
 
+	fmt.Printf("✓ Cache policy for %s: %s\n", pattern, m)
 	return nil
 }
 
-// RunSnapshotRestore restores the index to snapshot state.
-func RunSnapshotRestore(name string) error {
+// RunCachePolicyList lists cache policies.
+func RunCachePolicyList() error {
 	e, err := GetEngine()
 	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
+	policies, err := e.Cache.ListCachePolicies(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if len(policies) == 0 {
+		fmt.Println("No cache policies. All paths use 'normal'.")
+		return nil
+	}
+
+	fmt.Println("Cache Policies")
+	fmt.Println("══════════════")
+	for _, p := range policies {
+		fmt.Printf("  %-14s %s\n", p.Mode, p.Pattern)
+	}
+	return nil
+}
+
+// RunCachePolicyRemove removes the cache policy for a path or glob.
+func RunCachePolicyRemove(pattern string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	if err := e.Cache.RemoveCachePolicy(context.Background(), pattern); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Removed cache policy: %s\n", pattern)
+	return nil
+}
+
+// formatBytes formats bytes as human-readable.
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// transferProgress records a transfer for 'cloudfs tui' and, when stdout
+// is a terminal, draws a progress line for it. finish clears the line and
+// must be called once the transfer ends.
+func transferProgress(ctx context.Context, db *core.EncryptedDB, direction, name, providerName string, total int64) (progress provider.ProgressFunc, finish func()) {
+	transfer := core.NewTransferTracker(db.DB()).Start(ctx, direction, name, providerName, total)
+	info, _ := os.Stdout.Stat()
+	draw := !quiet && info != nil && info.Mode()&os.ModeCharDevice != 0
+
+	start := time.Now()
+	var mu sync.Mutex
+	var drawn time.Time
+	progress = func(p provider.Progress) {
+		transfer.Update(p)
+		if !draw {
+			return
+		}
+		if p.Total == 0 {
+			p.Total = total
+		}
+		// Native providers report bytes only
+		if elapsed := time.Since(start).Seconds(); p.Speed == 0 && elapsed > 0 {
+			p.Speed = float64(p.Bytes) / elapsed
+		}
+		if p.ETA == 0 && p.Speed > 0 && p.Total > p.Bytes {
+			p.ETA = time.Duration(float64(p.Total-p.Bytes) / p.Speed * float64(time.Second))
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Since(drawn) < 100*time.Millisecond && p.Bytes < p.Total {
+			return
+		}
+		drawn = time.Now()
+		fmt.Printf("\r\033[K  %s", formatProgress(name, p))
+	}
+	finish = func() {
+		transfer.Finish()
+		mu.Lock()
+		defer mu.Unlock()
+		if !drawn.IsZero() {
+			fmt.Print("\r\033[K")
+		}
+	}
+	return progress, finish
+}
+
+// formatProgress renders a transfer as a one-line progress bar.
+func formatProgress(name string, p provider.Progress) string {
+	const width = 24
+	if len(name) > 32 {
+		name = "…" + name[len(name)-31:]
+	}
+	line := fmt.Sprintf("%s  %s", name, formatBytes(p.Bytes))
+	if p.Total > 0 {
+		filled := int(p.Fraction() * width)
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+		line = fmt.Sprintf("%s [%s] %3.0f%%  %s / %s", name, bar, p.Fraction()*100, formatBytes(p.Bytes), formatBytes(p.Total))
+	}
+	if p.Speed > 0 {
+		line += fmt.Sprintf("  %s/s", formatBytes(int64(p.Speed)))
+	}
+	if p.ETA > 0 && p.Bytes < p.Total {
+		line += "  ETA " + p.ETA.Round(time.Second).String()
+	}
+	return line
+}
+
+// --- Snapshot Commands ---
+
+// RunSnapshotCreate creates a new snapshot.
+func RunSnapshotCreate(name, description string) error {
+	if dryRun {
+		fmt.Printf("[DRY-RUN] Would create snapshot: %s\n", name)
+		return nil
+	}
+
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	// Get database for snapshot manager
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	sm := core.NewSnapshotManager(db.DB(), e.Journal)
+
+	snapshot, err := sm.Create(ctx, name, description)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	if !quiet {
+		fmt.Printf("✓ Created snapshot: %s\n", snapshot.Name)
+		fmt.Printf("  Created: %s\n", snapshot.CreatedAt.Format("2006-01-02 15:04:05"))
+		if description != "" {
+			fmt.Printf("  Description: %s\n", description)
+		}
+	}
+
+	return nil
+}
+
+// RunSnapshotList lists all snapshots.
+func RunSnapshotList() error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	sm := core.NewSnapshotManager(db.DB(), e.Journal)
+
+	snapshots, err := sm.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots found.")
+		return nil
+	}
+
+	fmt.Printf("Snapshots (%d):\n", len(snapshots))
+	fmt.Println("Name                Created              Description")
+	fmt.Println("────────────────────────────────────────────────────────────")
+	for _, s := range snapshots {
+		desc := s.Description
+		if len(desc) > 30 {
+			desc = desc[:27] + "..."
+		}
+		fmt.Printf("%-19s %-20s %s\n",
+			s.Name,
+			s.CreatedAt.Format("2006-01-02 15:04"),
+			desc)
+	}
+
+	return nil
+}
+
+// RunSnapshotInspect shows snapshot details.
+func RunSnapshotInspect(name string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	sm := core.NewSnapshotManager(db.DB(), e.Journal)
+
+	info, err := sm.Inspect(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to inspect snapshot: %w", err)
+	}
+
+	fmt.Printf("Snapshot: %s\n", info.Snapshot.Name)
+	fmt.Println("═══════════════════════════════════")
+	fmt.Printf("Created:     %s\n", info.Snapshot.CreatedAt.Format("2006-01-02 15:04:05"))
+	if info.Snapshot.Description != "" {
+		fmt.Printf("Description: %s\n", info.Snapshot.Description)
+	}
+	fmt.Println()
+	fmt.Printf("Entries:     %d\n", info.EntryCount)
+	fmt.Printf("Versions:    %d\n", info.VersionCount)
+	fmt.Printf("Total size:  %s\n", formatBytes(info.TotalSize))
+
+	return nil
+}
+
+// RunSnapshotRestore restores the index to snapshot state.
+func RunSnapshotRestore(name string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
 
 	dbPath := filepath.Join(e.ConfigDir, "index.db")
 	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
@@ -846,6 +1433,9 @@ func RunTrashList() error {
 	fmt.Println("────────────────────────────────────────────────────────────────")
 	for _, item := range items {
 		path := item.OriginalName
+		if item.Members > 1 {
+			path = fmt.Sprintf("%s/ (+%d)", path, item.Members-1)
+		}
 		if len(path) > 28 {
 			path = "..." + path[len(path)-25:]
 		}
@@ -859,10 +1449,16 @@ func RunTrashList() error {
 	return nil
 }
 
-// RunTrashRestore restores an entry from trash.
-func RunTrashRestore(path string) error {
+// RunTrashRestore restores an entry or directory group from trash.
+// With to, the entry is restored to an alternate path; with renameOnConflict
+// an existing entry at the target does not block the restore.
+func RunTrashRestore(path, to string, renameOnConflict bool) error {
+	target := path
+	if to != "" {
+		target = to
+	}
 	if dryRun {
-		fmt.Printf("[DRY-RUN] Would restore from trash: %s\n", path)
+		fmt.Printf("[DRY-RUN] Would restore from trash: %s → %s\n", path, target)
 		return nil
 	}
 
@@ -892,14 +1488,73 @@ func RunTrashRestore(path string) error {
 		return fmt.Errorf("not found in trash: %s", path)
 	}
 
-	if err := tm.Restore(ctx, entry.ID); err != nil {
+	result, err := tm.RestoreTo(ctx, entry.ID, core.RestoreOptions{To: to, RenameOnConflict: renameOnConflict})
+	if err != nil {
 		return fmt.Errorf("failed to restore: %w", err)
 	}
 
-	fmt.Printf("✓ Restored from trash: %s\n", path)
+	for _, dir := range result.CreatedDirs {
+		fmt.Printf("  + Recreated directory: %s\n", dir)
+	}
+
+	// Recreate local directories and placeholders at the restored location
+	var created int
+	for _, re := range result.Entries {
+		localPath := filepath.Join(e.RootDir, filepath.FromSlash(re.Path))
+		if re.Type == model.EntryTypeDirectory {
+			os.MkdirAll(localPath, 0755)
+			continue
+		}
+		if re.VersionID == nil {
+			continue
+		}
+		if _, err := os.Stat(localPath); err == nil {
+			continue
+		}
+		if _, err := os.Stat(localPath + core.PlaceholderSuffix); err == nil {
+			continue
+		}
+		if err := restorePlaceholder(ctx, e, db, re, localPath); err != nil {
+			fmt.Printf("  ⚠️  Failed to create placeholder for %s: %v\n", re.Path, err)
+			continue
+		}
+		created++
+	}
+
+	if result.Renamed {
+		fmt.Printf("✓ Restored from trash: %s → %s (renamed to avoid conflict)\n", path, result.Path)
+	} else if result.Path != path {
+		fmt.Printf("✓ Restored from trash: %s → %s\n", path, result.Path)
+	} else {
+		fmt.Printf("✓ Restored from trash: %s\n", path)
+	}
+	if len(result.Entries) > 1 {
+		fmt.Printf("  %d entries restored, %d placeholders created\n", len(result.Entries), created)
+	}
 	return nil
 }
 
+// restorePlaceholder writes a placeholder for a restored file entry.
+func restorePlaceholder(ctx context.Context, e *Engine, db *core.EncryptedDB, re core.RestoredEntry, localPath string) error {
+	entry, err := e.Index.GetEntry(ctx, re.EntryID)
+	if err != nil {
+		return err
+	}
+	version, err := e.Index.GetVersion(ctx, *re.VersionID)
+	if err != nil {
+		return err
+	}
+
+	var providerID, remotePath string
+	db.DB().QueryRowContext(ctx, `
+		SELECT provider_id, remote_path FROM placements
+		WHERE version_id = ? AND state IN ('uploaded', 'verified')
+		ORDER BY state DESC LIMIT 1
+	`, version.ID).Scan(&providerID, &remotePath)
+
+	return e.Placeholder.CreatePlaceholder(ctx, entry, version, filepath.Dir(localPath), providerID, remotePath)
+}
+
 // RunTrashPurge permanently deletes all entries in trash.
 func RunTrashPurge(force bool) error {
 	e, err := GetEngine()
@@ -918,6 +1573,8 @@ func RunTrashPurge(force bool) error {
 	defer db.Close()
 
 	tm := core.NewTrashManager(db.DB(), e.Journal)
+	dc := core.NewDeleteCoordinator(db.DB(), e.Journal, e.Providers)
+	tm.SetDeleteCoordinator(dc)
 
 	// Show preview
 	preview, err := tm.GetPurgePreview(ctx, false)
@@ -941,6 +1598,17 @@ func RunTrashPurge(force bool) error {
 		}
 	}
 
+	// Per-provider remote deletion preview
+	placements, err := tm.PurgePlacements(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to collect placements: %w", err)
+	}
+	if len(placements) > 0 {
+		if err := printDeletePreview(ctx, dc, placements, core.DeleteSourceTrashPurge); err != nil {
+			return err
+		}
+	}
+
 	if dryRun {
 		fmt.Println("\n[DRY-RUN] No changes made.")
 		return nil
@@ -957,65 +1625,54 @@ func RunTrashPurge(force bool) error {
 		fmt.Println("\n⚠️  Force mode: skipping confirmation...")
 	}
 
-	// Delete from cloud providers FIRST via DeleteCoordinator
-	deleteCoordinator := core.NewDeleteCoordinator(db.DB(), e.Journal)
-
-	// Get all trash entries for cloud deletion
-	trashEntries, err := tm.List(ctx)
+	// Delete provider data, then purge from database
+	result, err := tm.PurgeAll(ctx, true)
 	if err != nil {
-		return fmt.Errorf("failed to list trash entries: %w", err)
+		return fmt.Errorf("failed to purge: %w", err)
 	}
 
-	var totalDeleted, totalFailed int
-	for _, entry := range trashEntries {
-		// Get placements for this entry
-		placements, err := deleteCoordinator.GetPlacementsForEntry(ctx, entry.Entry.OriginalEntryID)
-		if err != nil {
-			fmt.Printf("  Warning: failed to get placements for %s: %v\n", entry.Entry.OriginalPath, err)
-			continue
-		}
+	if result.Deleted+result.Failed > 0 {
+		fmt.Printf("\nCloud deletion: %d succeeded, %d failed\n", result.Deleted, result.Failed)
+	}
+	for _, err := range result.Errors {
+		fmt.Printf("  ⚠️  %v\n", err)
+	}
+	if result.Failed > 0 {
+		fmt.Println("  Failed deletions are queued. Retry with: cloudfs trash retry")
+	}
 
-		if len(placements) > 0 {
-			req := &core.DeleteRequest{
-				Placements: placements,
-				DryRun:     false,
-				Source:     core.DeleteSourceTrashPurge,
-			}
+	fmt.Printf("✓ Purged %d entries from trash\n", result.Purged)
+	return nil
+}
 
-			result, err := deleteCoordinator.Execute(ctx, req, true)
-			if err != nil {
-				fmt.Printf("  Warning: cloud deletion failed for %s: %v\n", entry.Entry.OriginalPath, err)
-			} else {
-				totalDeleted += result.Deleted
-				totalFailed += result.Failed
-				if result.Deleted > 0 {
-					fmt.Printf("  ✓ Deleted %d cloud copies of %s\n", result.Deleted, entry.Entry.OriginalPath)
-				}
-				if result.Failed > 0 {
-					fmt.Printf("  ⚠️  %d cloud deletions failed for %s\n", result.Failed, entry.Entry.OriginalPath)
-				}
-			}
-		}
+// printDeletePreview prints one remote deletion preview per provider.
+func printDeletePreview(ctx context.Context, dc *core.DeleteCoordinator, placements []core.PlacementRef, source core.DeleteSource) error {
+	previews, err := dc.PreviewByProvider(ctx, &core.DeleteRequest{Placements: placements, Source: source})
+	if err != nil {
+		return fmt.Errorf("failed to preview deletion: %w", err)
 	}
 
-	if totalDeleted > 0 {
-		fmt.Printf("\nCloud deletion: %d succeeded, %d failed\n", totalDeleted, totalFailed)
+	names := make([]string, 0, len(previews))
+	for name := range previews {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	// Now purge from database
-	count, err := tm.PurgeAll(ctx, true)
-	if err != nil {
-		return fmt.Errorf("failed to purge: %w", err)
+	fmt.Println("\nProvider data to delete:")
+	for _, name := range names {
+		p := previews[name]
+		fmt.Printf("  %-16s %4d objects  %s\n", name, len(p.Files), formatBytes(p.TotalSize))
+		if verbose {
+			for _, f := range p.Files {
+				fmt.Printf("      %s\n", f.Path)
+			}
+		}
 	}
-
-	fmt.Printf("✓ Purged %d entries from trash\n", count)
 	return nil
 }
 
-// --- Search Commands ---
-
-// RunSearch performs an index-only search.
-func RunSearch(query, entryType, classification string) error {
+// RunTrashRetry retries remote deletions that failed during purge.
+func RunTrashRetry(force bool) error {
 	e, err := GetEngine()
 	if err != nil {
 		return err
@@ -1031,7 +1688,265 @@ func RunSearch(query, entryType, classification string) error {
 	}
 	defer db.Close()
 
-	sm := core.NewSearchManager(db.DB())
+	dc := core.NewDeleteCoordinator(db.DB(), e.Journal, e.Providers)
+	pending, err := dc.ListPending(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		fmt.Println("No failed deletions pending.")
+		return nil
+	}
+
+	fmt.Printf("Pending deletions (%d):\n", len(pending))
+	for _, pd := range pending {
+		fmt.Printf("  %-16s %-10s  attempts=%d  %s\n", pd.ProviderID, formatBytes(pd.Size), pd.Attempts, pd.RemotePath)
+		if verbose && pd.LastError != "" {
+			fmt.Printf("      last error: %s\n", pd.LastError)
+		}
+	}
+
+	if dryRun {
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
+
+	if !force && !ConfirmAction("Retry deleting these objects?") {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+
+	result, err := dc.RetryPending(ctx, true)
+	if err != nil {
+		return err
+	}
+	for _, err := range result.Errors {
+		fmt.Printf("  ⚠️  %v\n", err)
+	}
+	fmt.Printf("✓ Deleted %d, %d still pending\n", result.Deleted, result.Failed)
+	return nil
+}
+
+// RunTrashReclaim purges just enough trash to free bytes on a provider.
+func RunTrashReclaim(providerID, bytes string, force bool) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	target, err := core.ParseSize(bytes)
+	if err != nil {
+		return fmt.Errorf("invalid --bytes value: %w", err)
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	return reclaimTrash(ctx, e, db, providerID, target, force)
+}
+
+// offerTrashReclaim prompts to reclaim trash when uploading incoming bytes
+// would take a provider past its soft limit.
+func offerTrashReclaim(ctx context.Context, e *Engine, db *core.EncryptedDB, providerID string, incoming int64) error {
+	prov, ok := e.Providers.Get(providerID)
+	if !ok {
+		return nil
+	}
+
+	pressure, err := core.SoftLimitPressure(ctx, db.DB(), prov, incoming)
+	if err != nil || pressure == nil || pressure.Over == 0 {
+		return err
+	}
+
+	fmt.Printf("⚠️  %s is near its soft limit: %s used + %s to push > %s\n",
+		providerID, formatBytes(pressure.Used), formatBytes(pressure.Incoming), formatBytes(pressure.SoftLimit))
+	return reclaimTrash(ctx, e, db, providerID, pressure.Over, false)
+}
+
+// reclaimTrash previews and purges the ranked trash set for a provider.
+func reclaimTrash(ctx context.Context, e *Engine, db *core.EncryptedDB, providerID string, target int64, force bool) error {
+	tm := core.NewTrashManager(db.DB(), e.Journal)
+	tm.SetDeleteCoordinator(core.NewDeleteCoordinator(db.DB(), e.Journal, e.Providers))
+
+	plan, err := tm.PlanReclaim(ctx, providerID, target)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Selected) == 0 {
+		fmt.Printf("No trash holds data on %s. Nothing to reclaim.\n", providerID)
+		return nil
+	}
+
+	fmt.Printf("\nTrash Reclaim Plan (%s on %s)\n", formatBytes(target), providerID)
+	fmt.Println("═══════════════════════════════════════")
+	fmt.Println("  #   Score  Size        Days  Copies  Path")
+	fmt.Println("  ─────────────────────────────────────────────────────────────")
+	for i, c := range plan.Selected {
+		fmt.Printf("  %-3d %.2f   %-10s  %-4d  %-6d  %s\n",
+			i+1, c.Score, formatBytes(c.Size), c.DaysInTrash, c.Replicas, c.Path)
+		if verbose {
+			fmt.Printf("        age=%.2f size=%.2f replica=%.2f  (%s across all providers)\n",
+				c.Factors["age"], c.Factors["size"], c.Factors["replica"], formatBytes(c.TotalSize))
+		}
+	}
+
+	fmt.Printf("\nWould free: %s on %s across %d entries\n", formatBytes(plan.Freed), providerID, len(plan.Selected))
+	if plan.Shortfall > 0 {
+		fmt.Printf("⚠️  Short by %s: not enough trash on %s\n", formatBytes(plan.Shortfall), providerID)
+	}
+
+	if dryRun {
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
+
+	if !force {
+		fmt.Println("\n⚠️  WARNING: This is IRREVERSIBLE. Provider data will be deleted.")
+		if !ConfirmAction("Permanently purge these trash entries?") {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	result, err := tm.Reclaim(ctx, plan, true)
+	if err != nil {
+		return fmt.Errorf("failed to reclaim: %w", err)
+	}
+	for _, err := range result.Errors {
+		fmt.Printf("  ⚠️  %v\n", err)
+	}
+	if result.Failed > 0 {
+		fmt.Println("  Failed deletions are queued. Retry with: cloudfs trash retry")
+	}
+	fmt.Printf("✓ Purged %d entries (%d cloud objects deleted)\n", result.Purged, result.Deleted)
+	return nil
+}
+
+// --- Garbage Collection ---
+
+// RunGC lists remote objects on a provider that no placement references
+// and deletes them after confirmation.
+func RunGC(providerID, minAge string, force bool) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	grace := core.DefaultGCGracePeriod
+	if minAge != "" {
+		if grace, err = core.ParseAge(minAge); err != nil {
+			return fmt.Errorf("invalid --min-age value: %w", err)
+		}
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	dc := core.NewDeleteCoordinator(db.DB(), e.Journal, e.Providers)
+	gc := core.NewGarbageCollector(db.DB(), dc, e.Providers)
+
+	if !quiet {
+		fmt.Printf("Listing objects on %s...\n", providerID)
+	}
+	report, err := gc.FindOrphans(ctx, providerID, grace)
+	if errors.Is(err, core.ErrNotDedicated) {
+		return fmt.Errorf("%w\nEvery object CloudFS did not write would be reported as an orphan.\nIf the remote holds only CloudFS data, run: cloudfs provider dedicate %s", err, providerID)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nGarbage Collection: %s\n", providerID)
+	fmt.Println("═══════════════════════════════════════")
+	fmt.Printf("  Objects scanned:   %d\n", report.Scanned)
+	fmt.Printf("  Referenced:        %d\n", report.Referenced)
+	if report.Recent > 0 {
+		fmt.Printf("  Too recent:        %d (newer than %s, skipped)\n", report.Recent, grace)
+	}
+	fmt.Printf("  Orphaned:          %d (%s)\n", len(report.Orphans), formatBytes(report.TotalSize))
+
+	if len(report.Orphans) == 0 {
+		fmt.Println("\n✓ No orphaned objects found.")
+		return nil
+	}
+
+	fmt.Println("\n  Size        Age    Path")
+	fmt.Println("  ─────────────────────────────────────────────────────────────")
+	for _, o := range report.Orphans {
+		age := "?"
+		if !o.ModTime.IsZero() {
+			age = fmt.Sprintf("%dd", int(o.Age.Hours()/24))
+		}
+		note := ""
+		if o.Pending {
+			note = "  (pending retry)"
+		}
+		fmt.Printf("  %-10s  %-5s  %s%s\n", formatBytes(o.Size), age, o.RemotePath, note)
+	}
+
+	if dryRun {
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
+
+	if !force {
+		fmt.Println("\n⚠️  WARNING: This is IRREVERSIBLE. These objects will be deleted from the provider.")
+		if !ConfirmAction(fmt.Sprintf("Delete %d orphaned objects from %s?", len(report.Orphans), providerID)) {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	result, err := gc.Collect(ctx, report, true)
+	if err != nil {
+		return fmt.Errorf("failed to collect orphans: %w", err)
+	}
+	for _, err := range result.Errors {
+		fmt.Printf("  ⚠️  %v\n", err)
+	}
+	if result.Failed > 0 {
+		fmt.Println("  Failed deletions are queued. Retry with: cloudfs trash retry")
+	}
+	fmt.Printf("✓ Deleted %d orphaned objects\n", result.Deleted)
+	return nil
+}
+
+// --- Search Commands ---
+
+// RunSearch performs an index-only search.
+func RunSearch(query, entryType, classification string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	sm := core.NewSearchManager(db.DB())
 
 	// If no query and no filters, show stats
 	if query == "" && entryType == "" && classification == "" {
@@ -1082,24 +1997,454 @@ func RunSearch(query, entryType, classification string) error {
 		if len(name) > 28 {
 			name = name[:25] + "..."
 		}
-		class := r.Entry.Classification
-		if class == "" {
-			class = "-"
+		class := r.Entry.Classification
+		if class == "" {
+			class = "-"
+		}
+		fmt.Printf("%-29s %-6s %-16s %s\n",
+			name,
+			r.Entry.Type,
+			class,
+			formatBytes(r.Entry.LogicalSize))
+	}
+
+	return nil
+}
+
+// --- Health Commands ---
+
+// RunHealth shows overall health status.
+func RunHealth() error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	hm := core.NewHealthManager(db.DB())
+
+	health, err := hm.GetOverallHealth(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get health: %w", err)
+	}
+
+	scoreDesc := core.GetHealthScoreDescription(health.AverageScore)
+
+	fmt.Println("Repository Health")
+	fmt.Println("═════════════════")
+	fmt.Printf("Overall Score:  %.1f%% (%s)\n", health.AverageScore*100, scoreDesc)
+	fmt.Println()
+	fmt.Printf("Total Entries:  %d\n", health.TotalEntries)
+	fmt.Printf("  Healthy:      %d\n", health.HealthyEntries)
+	fmt.Printf("  Warning:      %d\n", health.WarningEntries)
+	fmt.Printf("  Critical:     %d\n", health.CriticalEntries)
+	fmt.Println()
+	fmt.Printf("Low Replication: %d entries\n", health.LowReplicationCount)
+	fmt.Printf("Unverified:      %d entries (30+ days)\n", health.UnverifiedCount)
+
+	// Show critical entries if any
+	if health.CriticalEntries > 0 && verbose {
+		critical, _ := hm.GetCriticalEntries(ctx, 0.5, 5)
+		if len(critical) > 0 {
+			fmt.Println("\nCritical Entries:")
+			for _, c := range critical {
+				fmt.Printf("  • %s: %s\n", c.EntryName, c.Issues[0])
+			}
+		}
+	}
+
+	return nil
+}
+
+// RunHeal creates an extra replica for every entry whose health score is
+// below threshold, after previewing the full plan.
+func RunHeal(threshold float64, force bool) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	healer := core.NewHealer(db.DB(), core.NewHealthManager(db.DB()), core.NewPlacementPlanner(db.DB()),
+		e.Cache, e.Journal, e.Providers, e.Hydration.TempDir())
+
+	plan, err := healer.Plan(ctx, threshold)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Actions) == 0 && len(plan.Unhealable) == 0 {
+		fmt.Printf("✓ No entries below health %.2f. Nothing to heal.\n", threshold)
+		return nil
+	}
+
+	fmt.Printf("Heal Plan (health below %.2f)\n", threshold)
+	fmt.Println("═══════════════════════════════════════")
+	for _, a := range plan.Actions {
+		source := "cache"
+		if a.Source.Kind == "remote" {
+			source = a.Source.ProviderID
+		}
+		fmt.Printf("  %.2f  %-10s  %s\n", a.Score, formatBytes(a.Size), a.Path)
+		fmt.Printf("        %s → %s (%s)\n", source, a.Target, a.Reason)
+		if verbose && len(a.Issues) > 0 {
+			fmt.Printf("        issues: %s\n", strings.Join(a.Issues, "; "))
+		}
+	}
+
+	if len(plan.Unhealable) > 0 {
+		fmt.Printf("\n⚠️  Cannot heal %d entries:\n", len(plan.Unhealable))
+		for _, a := range plan.Unhealable {
+			fmt.Printf("  %.2f  %s: %s\n", a.Score, a.Path, a.Reason)
+		}
+	}
+
+	if len(plan.Actions) == 0 {
+		return nil
+	}
+
+	fmt.Printf("\nWould create %d replicas (%s)\n", len(plan.Actions), formatBytes(plan.TotalBytes))
+
+	if dryRun {
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
+
+	if !force && !ConfirmAction(fmt.Sprintf("Create %d new replicas?", len(plan.Actions))) {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+
+	result, err := healer.Execute(ctx, plan, true)
+	if err != nil {
+		return fmt.Errorf("failed to heal: %w", err)
+	}
+	for _, err := range result.Errors {
+		fmt.Printf("  ✗ %v\n", err)
+	}
+	fmt.Printf("✓ Created %d replicas (%s), %d failed\n", result.Healed, formatBytes(result.Bytes), result.Failed)
+	return nil
+}
+
+// RunReplicate compares verified copies with the replication policies and
+// creates or trims replicas, after previewing the full plan.
+func RunReplicate(force bool) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	replicator := newReplicator(db.DB(), e)
+	policies, err := replicator.ListPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	if len(policies) == 0 {
+		fmt.Println("No replication policies. Set one with 'cloudfs replicate policy set'.")
+		return nil
+	}
+
+	plan, err := replicator.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Entries) == 0 {
+		fmt.Printf("✓ All %d entries meet their replication policy.\n", plan.Compliant)
+		return nil
+	}
+
+	fmt.Println("Replication Plan")
+	fmt.Println("═══════════════════════════════════════")
+	for _, s := range plan.Entries {
+		fmt.Printf("  %d/%d  %-10s  %s\n", s.Current, s.Required, formatBytes(s.Size), s.Path)
+		for _, a := range s.Creates {
+			source := "cache"
+			if a.Source.Kind == "remote" {
+				source = a.Source.ProviderID
+			}
+			fmt.Printf("        + %s → %s (%s)\n", source, a.Target, a.Reason)
+		}
+		for _, t := range s.Trims {
+			fmt.Printf("        - %s: %s\n", t.ProviderID, t.RemotePath)
+		}
+		if s.Reason != "" {
+			fmt.Printf("        ⚠️  %s\n", s.Reason)
+		}
+		if verbose {
+			fmt.Printf("        policy: %s (%s)\n", s.Policy.Pattern, s.Policy)
+		}
+	}
+	fmt.Printf("\n%d entries compliant\n", plan.Compliant)
+
+	if plan.Creates == 0 && plan.Trims == 0 {
+		return nil
+	}
+
+	fmt.Printf("Would create %d replicas (%s) and trim %d (%s)\n",
+		plan.Creates, formatBytes(plan.CreateBytes), plan.Trims, formatBytes(plan.TrimBytes))
+
+	if dryRun {
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
+
+	prompt := fmt.Sprintf("Create %d replicas?", plan.Creates)
+	if plan.Trims > 0 {
+		prompt = fmt.Sprintf("Create %d replicas and permanently delete %d excess copies?", plan.Creates, plan.Trims)
+	}
+	if !force && !ConfirmAction(prompt) {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+
+	result, err := replicator.Execute(ctx, plan, true)
+	if err != nil {
+		return fmt.Errorf("failed to replicate: %w", err)
+	}
+	for _, err := range result.Errors {
+		fmt.Printf("  ✗ %v\n", err)
+	}
+	fmt.Printf("✓ Created %d replicas (%s), trimmed %d, %d failed\n",
+		result.Created, formatBytes(result.Bytes), result.Trimmed, result.Failed)
+	return nil
+}
+
+// RunReplicationPolicySet sets the replication policy for a path or glob.
+func RunReplicationPolicySet(pattern string, copies int, distinctTypes bool, prefer []string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	db, err := core.OpenEncryptedDB(filepath.Join(e.ConfigDir, "index.db"), os.Getenv("CLOUDFS_PASSPHRASE"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	policy := core.ReplicationPolicy{
+		Pattern:       pattern,
+		MinCopies:     copies,
+		DistinctTypes: distinctTypes,
+		Preferred:     prefer,
+	}
+	if err := newReplicator(db.DB(), e).SetPolicy(context.Background(), policy); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Replication policy for %s: %s\n", pattern, policy)
+	return nil
+}
+
+// RunReplicationPolicyList lists replication policies.
+func RunReplicationPolicyList() error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	db, err := core.OpenEncryptedDB(filepath.Join(e.ConfigDir, "index.db"), os.Getenv("CLOUDFS_PASSPHRASE"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	policies, err := newReplicator(db.DB(), e).ListPolicies(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if len(policies) == 0 {
+		fmt.Println("No replication policies. 'cloudfs replicate' leaves all entries alone.")
+		return nil
+	}
+
+	fmt.Println("Replication Policies")
+	fmt.Println("════════════════════")
+	for _, p := range policies {
+		fmt.Printf("  %-24s %s\n", p.Pattern, p)
+	}
+	return nil
+}
+
+// RunReplicationPolicyRemove removes the replication policy for a path or glob.
+func RunReplicationPolicyRemove(pattern string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	db, err := core.OpenEncryptedDB(filepath.Join(e.ConfigDir, "index.db"), os.Getenv("CLOUDFS_PASSPHRASE"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := newReplicator(db.DB(), e).RemovePolicy(context.Background(), pattern); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Removed replication policy: %s\n", pattern)
+	return nil
+}
+
+// newReplicator wires a replicator to the engine's components.
+func newReplicator(db *sql.DB, e *Engine) *core.Replicator {
+	return core.NewReplicator(db, core.NewPlacementPlanner(db), e.Cache, e.Journal,
+		core.NewDeleteCoordinator(db, e.Journal, e.Providers), e.Providers, e.Hydration.TempDir())
+}
+
+// RunHealthEntry shows health for a specific entry.
+func RunHealthEntry(path string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	hm := core.NewHealthManager(db.DB())
+
+	health, err := hm.GetHealthByPath(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to get health: %w", err)
+	}
+
+	scoreDesc := core.GetHealthScoreDescription(health.HealthScore)
+
+	fmt.Printf("Health: %s\n", path)
+	fmt.Println("═══════════════════════════════════")
+	fmt.Printf("Score:        %.1f%% (%s)\n", health.HealthScore*100, scoreDesc)
+	fmt.Printf("Replications: %d\n", health.ReplicationCount)
+	if health.LastVerified != nil {
+		fmt.Printf("Last Verified: %s (%d days ago)\n",
+			health.LastVerified.Format("2006-01-02"),
+			health.VerificationAge)
+	} else {
+		fmt.Println("Last Verified: Never")
+	}
+
+	if len(health.Deductions) > 0 {
+		fmt.Println("\nDeductions:")
+		printHealthDeductions(health.Deductions)
+	}
+
+	if len(health.Recommendations) > 0 {
+		fmt.Println("\nRecommendations:")
+		for _, rec := range health.Recommendations {
+			fmt.Printf("  → %s\n", rec)
+		}
+	}
+
+	return nil
+}
+
+// printHealthDeductions lists each deduction with its factor and impact.
+func printHealthDeductions(deductions []core.HealthDeduction) {
+	for _, d := range deductions {
+		fmt.Printf("  ⚠️  -%3.0f%%  %-12s %s\n", d.Amount*100, d.Factor, d.Reason)
+	}
+}
+
+// RunHealthWeights shows or updates the health scoring weights.
+func RunHealthWeights(set []string, reset bool) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	db, err := core.OpenEncryptedDB(filepath.Join(e.ConfigDir, "index.db"), os.Getenv("CLOUDFS_PASSPHRASE"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	hm := core.NewHealthManager(db.DB())
+
+	if reset {
+		if err := hm.ResetWeights(ctx); err != nil {
+			return err
+		}
+		fmt.Println("✓ Health weights reset to defaults")
+	}
+
+	weights, err := hm.GetWeights(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(set) > 0 {
+		for _, kv := range set {
+			factor, value, ok := strings.Cut(kv, "=")
+			if !ok {
+				return fmt.Errorf("invalid weight %q, expected factor=value", kv)
+			}
+			w, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid weight for %s: %w", factor, err)
+			}
+			if err := weights.Set(strings.TrimSpace(factor), w); err != nil {
+				return err
+			}
+		}
+		if err := hm.SetWeights(ctx, weights); err != nil {
+			return err
 		}
-		fmt.Printf("%-29s %-6s %-16s %s\n",
-			name,
-			r.Entry.Type,
-			class,
-			formatBytes(r.Entry.LogicalSize))
+		fmt.Println("✓ Health weights updated")
 	}
 
+	fmt.Println("Health Weights")
+	fmt.Println("══════════════")
+	fmt.Printf("  %-14s %.2f\n", core.HealthFactorReplication, weights.Replication)
+	fmt.Printf("  %-14s %.2f\n", core.HealthFactorVerification, weights.Verification)
+	fmt.Printf("  %-14s %.2f\n", core.HealthFactorParity, weights.Parity)
+	fmt.Printf("  %-14s %.2f\n", core.HealthFactorProvider, weights.Provider)
+	fmt.Printf("  %-14s %.2f\n", core.HealthFactorPlacement, weights.Placement)
+	fmt.Printf("  %-14s %.2f\n", core.HealthFactorIndex, weights.Index)
 	return nil
 }
 
-// --- Health Commands ---
-
-// RunHealth shows overall health status.
-func RunHealth() error {
+// RunHealthRecord records a health and usage history sample.
+func RunHealthRecord() error {
 	e, err := GetEngine()
 	if err != nil {
 		return err
@@ -1107,104 +2452,165 @@ func RunHealth() error {
 
 	ctx := context.Background()
 
-	dbPath := filepath.Join(e.ConfigDir, "index.db")
-	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
-	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	db, err := core.OpenEncryptedDB(filepath.Join(e.ConfigDir, "index.db"), os.Getenv("CLOUDFS_PASSPHRASE"))
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 	defer db.Close()
 
-	hm := core.NewHealthManager(db.DB())
+	if dryRun {
+		fmt.Println("Would record overall health, provider usage and cache size")
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
 
-	health, err := hm.GetOverallHealth(ctx)
+	record, err := core.NewHistoryManager(db.DB(), e.Providers, e.Cache).Record(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get health: %w", err)
+		return err
 	}
 
-	scoreDesc := core.GetHealthScoreDescription(health.AverageScore)
-
-	fmt.Println("Repository Health")
-	fmt.Println("═════════════════")
-	fmt.Printf("Overall Score:  %.1f%% (%s)\n", health.AverageScore*100, scoreDesc)
-	fmt.Println()
-	fmt.Printf("Total Entries:  %d\n", health.TotalEntries)
-	fmt.Printf("  Healthy:      %d\n", health.HealthyEntries)
-	fmt.Printf("  Warning:      %d\n", health.WarningEntries)
-	fmt.Printf("  Critical:     %d\n", health.CriticalEntries)
-	fmt.Println()
-	fmt.Printf("Low Replication: %d entries\n", health.LowReplicationCount)
-	fmt.Printf("Unverified:      %d entries (30+ days)\n", health.UnverifiedCount)
-
-	// Show critical entries if any
-	if health.CriticalEntries > 0 && verbose {
-		critical, _ := hm.GetCriticalEntries(ctx, 5)
-		if len(critical) > 0 {
-			fmt.Println("\nCritical Entries:")
-			for _, c := range critical {
-				fmt.Printf("  • %s: %s\n", c.EntryName, c.Issues[0])
-			}
+	fmt.Printf("✓ Health: %.1f%% across %d entries (%d critical)\n",
+		record.Health.AverageScore*100, record.Health.TotalEntries, record.Health.Critical)
+	for _, u := range record.Usage {
+		if u.TotalBytes > 0 {
+			fmt.Printf("✓ %-10s %s / %s\n", u.Name, formatBytes(u.UsedBytes), formatBytes(u.TotalBytes))
+		} else {
+			fmt.Printf("✓ %-10s %s\n", u.Name, formatBytes(u.UsedBytes))
 		}
 	}
+	for name, err := range record.Skipped {
+		fmt.Printf("⚠️  %-10s usage not recorded: %v\n", name, err)
+	}
 
 	return nil
 }
 
-// RunHealthEntry shows health for a specific entry.
-func RunHealthEntry(path string) error {
+// RunHealthHistory shows the recorded health trend for the repository, or
+// the scrub measurements for a single path.
+func RunHealthHistory(path, since string) error {
 	e, err := GetEngine()
 	if err != nil {
 		return err
 	}
 
+	window, err := core.ParseAge(since)
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+	from := time.Now().Add(-window)
+
 	ctx := context.Background()
 
-	dbPath := filepath.Join(e.ConfigDir, "index.db")
-	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
-	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	db, err := core.OpenEncryptedDB(filepath.Join(e.ConfigDir, "index.db"), os.Getenv("CLOUDFS_PASSPHRASE"))
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 	defer db.Close()
 
-	hm := core.NewHealthManager(db.DB())
+	hist := core.NewHistoryManager(db.DB(), nil, nil)
 
-	health, err := hm.GetHealthByPath(ctx, path)
-	if err != nil {
-		return fmt.Errorf("failed to get health: %w", err)
+	if path != "" {
+		entry, err := e.Index.GetEntryByPath(ctx, path)
+		if err != nil {
+			return fmt.Errorf("entry not found: %s", path)
+		}
+		metrics, err := hist.EntryHealthHistory(ctx, entry.ID, from)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Health History: %s (last %s)\n", path, since)
+		fmt.Println("═══════════════════════════════════")
+		if len(metrics) == 0 {
+			fmt.Println("No measurements recorded. Run 'cloudfs scrub' to record them.")
+			return nil
+		}
+		scores := make([]float64, len(metrics))
+		for i, m := range metrics {
+			scores[i] = m.OverallScore
+		}
+		fmt.Printf("Trend: %s\n\n", sparkline(scores, 0, 1))
+		fmt.Printf("%-17s %7s %8s %9s %7s\n", "MEASURED", "SCORE", "REPLICAS", "VERIFIED", "PARITY")
+		fmt.Println("─────────────────────────────────────────────────────")
+		for _, m := range metrics {
+			verified := "never"
+			if m.VerificationAgeDays != nil {
+				verified = fmt.Sprintf("%dd ago", *m.VerificationAgeDays)
+			}
+			parity := "no"
+			if m.ParityAvailable {
+				parity = "yes"
+			}
+			fmt.Printf("%-17s %6.1f%% %8d %9s %7s\n",
+				m.MeasuredAt.Local().Format("2006-01-02 15:04"), m.OverallScore*100,
+				m.ReplicationCount, verified, parity)
+		}
+		return nil
 	}
 
-	scoreDesc := core.GetHealthScoreDescription(health.HealthScore)
+	snapshots, err := hist.HealthHistory(ctx, from)
+	if err != nil {
+		return err
+	}
 
-	fmt.Printf("Health: %s\n", path)
+	fmt.Printf("Health History (last %s)\n", since)
 	fmt.Println("═══════════════════════════════════")
-	fmt.Printf("Score:        %.1f%% (%s)\n", health.HealthScore*100, scoreDesc)
-	fmt.Printf("Replications: %d\n", health.ReplicationCount)
-	if health.LastVerified != nil {
-		fmt.Printf("Last Verified: %s (%d days ago)\n",
-			health.LastVerified.Format("2006-01-02"),
-			health.VerificationAge)
-	} else {
-		fmt.Println("Last Verified: Never")
+	if len(snapshots) == 0 {
+		fmt.Println("No history recorded. Run 'cloudfs health record' periodically to build it.")
+		return nil
 	}
 
-	if len(health.Issues) > 0 {
-		fmt.Println("\nIssues:")
-		for _, issue := range health.Issues {
-			fmt.Printf("  ⚠️  %s\n", issue)
-		}
+	scores := make([]float64, len(snapshots))
+	for i, s := range snapshots {
+		scores[i] = s.AverageScore
 	}
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	fmt.Printf("Trend: %s  %.1f%% → %.1f%% (%+.1f)\n\n", sparkline(scores, 0, 1),
+		first.AverageScore*100, last.AverageScore*100, (last.AverageScore-first.AverageScore)*100)
 
-	if len(health.Recommendations) > 0 {
-		fmt.Println("\nRecommendations:")
-		for _, rec := range health.Recommendations {
-			fmt.Printf("  → %s\n", rec)
-		}
+	fmt.Printf("%-17s %7s %8s %8s %8s %8s\n", "MEASURED", "SCORE", "ENTRIES", "HEALTHY", "WARNING", "CRITICAL")
+	fmt.Println("──────────────────────────────────────────────────────────────")
+	for _, s := range snapshots {
+		fmt.Printf("%-17s %6.1f%% %8d %8d %8d %8d\n",
+			s.MeasuredAt.Local().Format("2006-01-02 15:04"), s.AverageScore*100,
+			s.TotalEntries, s.Healthy, s.Warning, s.Critical)
 	}
 
 	return nil
 }
 
+// sparkline renders values as a row of block characters scaled between lo
+// and hi. When lo == hi the range is taken from the values themselves.
+func sparkline(values []float64, lo, hi float64) string {
+	const blocks = "▁▂▃▄▅▆▇█"
+	runes := []rune(blocks)
+	if lo == hi {
+		for i, v := range values {
+			if i == 0 || v < lo {
+				lo = v
+			}
+			if i == 0 || v > hi {
+				hi = v
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		idx := 0
+		if hi > lo {
+			idx = int((v - lo) / (hi - lo) * float64(len(runes)-1))
+		}
+		if idx < 0 {
+			idx = 0
+		} else if idx >= len(runes) {
+			idx = len(runes) - 1
+		}
+		b.WriteRune(runes[idx])
+	}
+	return b.String()
+}
+
 // --- Archive Commands ---
 
 // RunArchiveCreate creates a cold archive with dry-run support.
@@ -1776,6 +3182,17 @@ func RunExplain(path string, archiveOnly, healthOnly bool) error {
 		fmt.Println("  Not in cache")
 	}
 
+	// Hydration state
+	if hs := explanation.HydrationState; hs != nil {
+		fmt.Println("\n💧 Hydration State")
+		fmt.Println("──────────────────")
+		fmt.Printf("State:   %s\n", hs.State)
+		if hs.State == "partial" || len(hs.ChunksPresent) > 0 {
+			fmt.Printf("Chunks:  %d/%d present (%s)\n", len(hs.ChunksPresent), hs.ChunksTotal, formatBytes(hs.BytesPresent))
+			fmt.Printf("Present: %s\n", formatChunkList(hs.ChunksPresent))
+		}
+	}
+
 	// Archive state
 	fmt.Println("\n🗄️ Archive State")
 	fmt.Println("────────────────")
@@ -1812,6 +3229,13 @@ func RunExplain(path string, archiveOnly, healthOnly bool) error {
 		}
 	}
 
+	// Replication state
+	if explanation.ReplicationState != nil {
+		fmt.Println("\n🔁 Replication")
+		fmt.Println("──────────────")
+		printReplicationState(explanation.ReplicationState)
+	}
+
 	// Pending operations
 	if len(explanation.PendingOps) > 0 {
 		fmt.Println("\n⏳ Pending Operations")
@@ -1888,15 +3312,18 @@ func printHealthExplanation(exp *core.EntryExplanation) error {
 		fmt.Println("Last Verified: Never")
 	}
 
-	if len(h.Issues) > 0 {
+	if len(h.Deductions) > 0 {
 		fmt.Println("\nIssues Found:")
-		for _, issue := range h.Issues {
-			fmt.Printf("  ⚠️  %s\n", issue)
-		}
+		printHealthDeductions(h.Deductions)
 	} else {
 		fmt.Println("\n✓ No issues found")
 	}
 
+	if exp.ReplicationState != nil {
+		fmt.Println()
+		printReplicationState(exp.ReplicationState)
+	}
+
 	if len(h.Recommendations) > 0 {
 		fmt.Println("\nRecommendations:")
 		for _, rec := range h.Recommendations {
@@ -1907,6 +3334,28 @@ func printHealthExplanation(exp *core.EntryExplanation) error {
 	return nil
 }
 
+func printReplicationState(r *core.ReplicationStateInfo) {
+	if r.Policy != nil {
+		fmt.Printf("Policy:      %s (%s)\n", r.Policy.Pattern, r.Policy)
+	} else {
+		fmt.Println("Policy:      none (set one with 'cloudfs replicate policy set')")
+	}
+	mark := "✓"
+	if r.Current < r.Required {
+		mark = "⚠️"
+	}
+	fmt.Printf("Copies:      %d of %d required %s\n", r.Current, r.Required, mark)
+	if len(r.Verified) > 0 {
+		fmt.Printf("Verified:    %s\n", strings.Join(r.Verified, ", "))
+	}
+	if len(r.Unverified) > 0 {
+		fmt.Printf("Unverified:  %s\n", strings.Join(r.Unverified, ", "))
+	}
+	if len(r.Degraded) > 0 {
+		fmt.Printf("Degraded:    %s\n", strings.Join(r.Degraded, ", "))
+	}
+}
+
 // --- Scan Commands ---
 
 // RunScanIndex scans the index for consistency.
@@ -1965,8 +3414,9 @@ func RunScanCache() error {
 	return nil
 }
 
-// RunScanProviders scans provider state.
-func RunScanProviders() error {
+// RunScanProviders scans provider state and reconciles placements against
+// each provider's remote tree. Mismatched placements are marked degraded.
+func RunScanProviders(providerID string, hash, jsonOutput bool) error {
 	e, err := GetEngine()
 	if err != nil {
 		return err
@@ -1982,17 +3432,92 @@ func RunScanProviders() error {
 	}
 	defer db.Close()
 
-	scanner := core.NewScanner(db.DB(), e.Cache.CacheDir(), e.Placeholder.RootDir())
+	opts := core.ReconcileOptions{Hash: hash, DryRun: dryRun}
+	if providerID != "" {
+		opts.Providers = []string{providerID}
+	}
+	report, err := core.NewReconciler(db.DB(), e.Providers).Reconcile(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("scan failed: %w", err)
+	}
+
+	if jsonOutput {
+		output, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(output))
+		return nil
+	}
 
+	scanner := core.NewScanner(db.DB(), e.Cache.CacheDir(), e.Placeholder.RootDir())
 	result, err := scanner.ScanProviders(ctx)
 	if err != nil {
 		return fmt.Errorf("scan failed: %w", err)
 	}
-
 	printScanResult(result)
+	printReconcileReport(report)
 	return nil
 }
 
+// printReconcileReport prints per-provider reconciliation results and findings.
+func printReconcileReport(report *core.ReconcileReport) {
+	fmt.Println("\nRemote Reconciliation")
+	fmt.Println("═══════════════════════════════════════")
+	for _, p := range report.Providers {
+		if p.Error != "" {
+			fmt.Printf("✗ %-16s %s\n", p.ProviderID, p.Error)
+			continue
+		}
+		icon := "✓"
+		if p.Mismatched > 0 {
+			icon = "✗"
+		} else if p.Untracked > 0 {
+			icon = "⚠️"
+		}
+		untracked := fmt.Sprintf("%d untracked", p.Untracked)
+		if !p.Dedicated {
+			untracked = "untracked not checked (shared remote)"
+		}
+		fmt.Printf("%s %-16s %d placements, %d listed: %d ok, %d mismatched, %s\n",
+			icon, p.ProviderID, p.Placements, p.Listed, p.OK, p.Mismatched, untracked)
+	}
+
+	if len(report.Findings) == 0 {
+		fmt.Println("\n✓ All placements match provider state.")
+		return
+	}
+
+	fmt.Println()
+	for _, f := range report.Findings {
+		icon := "⚠️"
+		if f.Severity == "error" {
+			icon = "✗"
+		}
+		target := f.RemotePath
+		if f.EntryPath != "" {
+			target = fmt.Sprintf("%s (%s)", f.EntryPath, f.RemotePath)
+		}
+		fmt.Printf("%s [%s] %s: %s\n", icon, f.ProviderID, f.Kind, target)
+		switch f.Kind {
+		case core.FindingSizeMismatch:
+			fmt.Printf("    expected %s, found %s\n", formatBytes(f.ExpectedSize), formatBytes(f.ActualSize))
+		case core.FindingHashMismatch:
+			fmt.Printf("    expected %s, found %s\n", f.ExpectedHash, f.ActualHash)
+		}
+		if f.Detail != "" {
+			fmt.Printf("    %s\n", f.Detail)
+		}
+		if f.Degraded {
+			fmt.Println("    placement marked degraded")
+		}
+		if f.Command != "" {
+			fmt.Printf("    → %s: %s\n", f.Action, f.Command)
+		}
+	}
+
+	if report.DryRun {
+		fmt.Println("\n[DRY-RUN] No placements marked degraded.")
+	}
+}
+
 func printScanResult(result *core.ScanResult) {
 	fmt.Printf("Scan: %s\n", result.ScanType)
 	fmt.Println("═══════════════════════════════════════")
@@ -2375,11 +3900,14 @@ func RunLs(path string) error {
 	query := `
 		SELECT e.id, e.name, e.entry_type, e.logical_size, 
 		       COALESCE(c.state, 'none') as cache_state,
+		       COALESCE(h.current_state, 'placeholder') as hydration_state,
 		       (SELECT COUNT(*) FROM placements p JOIN versions v ON p.version_id = v.id WHERE v.entry_id = e.id) as placements
 		FROM entries e
 		LEFT JOIN cache_entries c ON e.id = c.entry_id
+		LEFT JOIN hydration_state h ON e.id = h.entry_id
 		LEFT JOIN trash t ON e.id = t.original_entry_id
 		WHERE t.id IS NULL
+		  AND e.id NOT IN (SELECT entry_id FROM trash_items)
 	`
 	if path != "" {
 		query += ` AND e.name LIKE ?`
@@ -2404,28 +3932,30 @@ func RunLs(path string) error {
 	count := 0
 	for rows.Next() {
 		var id int64
-		var name, entryType, cacheState string
+		var name, entryType, cacheState, hydrationState string
 		var size int64
 		var placements int
 
-		rows.Scan(&id, &name, &entryType, &size, &cacheState, &placements)
+		rows.Scan(&id, &name, &entryType, &size, &cacheState, &hydrationState, &placements)
 
 		typeIcon := "📄"
 		if entryType == "folder" {
 			typeIcon = "📁"
 		}
 
-		cacheIcon := "○"
+		cacheIcon, cacheLabel := "○", "cached "
 		if cacheState == "valid" {
 			cacheIcon = "●"
+		} else if hydrationState == "partial" {
+			cacheIcon, cacheLabel = "◐", "partial"
 		}
 
 		if len(name) > 28 {
 			name = name[:25] + "..."
 		}
 
-		fmt.Printf("%s    %-28s %-10s %s cached  %d\n",
-			typeIcon, name, formatBytes(size), cacheIcon, placements)
+		fmt.Printf("%s    %-28s %-10s %s %s %d\n",
+			typeIcon, name, formatBytes(size), cacheIcon, cacheLabel, placements)
 		count++
 	}
 
@@ -2456,7 +3986,7 @@ func RunPush() error {
 
 	// Find entries without placements
 	rows, err := db.DB().QueryContext(ctx, `
-		SELECT e.id, e.name, v.id as version_id, v.content_hash
+		SELECT e.id, e.name, v.id as version_id, v.content_hash, v.size
 		FROM entries e
 		JOIN versions v ON e.id = v.entry_id AND v.state = 'active'
 		LEFT JOIN placements p ON v.id = p.version_id
@@ -2471,16 +4001,20 @@ func RunPush() error {
 		Name        string
 		VersionID   int64
 		ContentHash string
+		Size        int64
 	}
+	var pendingBytes int64
 	for rows.Next() {
 		var p struct {
 			EntryID     int64
 			Name        string
 			VersionID   int64
 			ContentHash string
+			Size        int64
 		}
-		rows.Scan(&p.EntryID, &p.Name, &p.VersionID, &p.ContentHash)
+		rows.Scan(&p.EntryID, &p.Name, &p.VersionID, &p.ContentHash, &p.Size)
 		pending = append(pending, p)
+		pendingBytes += p.Size
 	}
 	rows.Close()
 
@@ -2517,6 +4051,13 @@ func RunPush() error {
 		return fmt.Errorf("no active providers. Use 'cloudfs provider add'")
 	}
 
+	// Offer to reclaim trash on providers this push would take past their soft limit
+	for _, prov := range providers {
+		if err := offerTrashReclaim(ctx, e, db, prov.Name, pendingBytes); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+	}
+
 	// Push each entry to each provider
 	for _, entry := range pending {
 		for _, prov := range providers {
@@ -2548,12 +4089,20 @@ func RunPush() error {
 				remotePath = prov.Name + ":"
 			}
 
-			// Execute rclone copyto (to preserve filename, since cache file is named 'data')
 			remoteFile := remotePath + "/" + entry.Name
-			cmd := exec.CommandContext(ctx, "rclone", "copyto", srcPath, remoteFile)
-			output, err := cmd.CombinedOutput()
+			// Upload through the provider interface, which retries transient
+			// failures; copyto keeps the filename (cache files are named 'data')
+			p, ok := e.Providers.Get(prov.Name)
+			if !ok {
+				e.Journal.RollbackOperation(ctx, opID, "provider not registered")
+				fmt.Printf("✗ Failed to push %s to %s: provider not registered\n", entry.Name, prov.Name)
+				continue
+			}
+			progress, finish := transferProgress(ctx, db, core.TransferUpload, entry.Name, prov.Name, entry.Size)
+			_, err = p.Upload(ctx, srcPath, remoteFile, progress)
+			finish()
 			if err != nil {
-				e.Journal.RollbackOperation(ctx, opID, fmt.Sprintf("rclone failed: %s", string(output)))
+				e.Journal.RollbackOperation(ctx, opID, err.Error())
 				fmt.Printf("✗ Failed to push %s to %s: %v\n", entry.Name, prov.Name, err)
 				continue
 			}
@@ -2568,121 +4117,308 @@ func RunPush() error {
 				continue
 			}
 
-			e.Journal.CommitOperation(ctx, opID)
-			e.Journal.SyncOperation(ctx, opID)
+			e.Journal.CommitOperation(ctx, opID)
+			e.Journal.SyncOperation(ctx, opID)
+
+			fmt.Printf("✓ Pushed %s → %s\n", entry.Name, prov.Name)
+		}
+	}
+
+	fmt.Println("\nPush complete.")
+	return nil
+}
+
+// RunHydrate downloads and hydrates a file, directory or glob pattern.
+// Batch targets print a pre-flight summary and require confirmation.
+func RunHydrate(path string, force bool) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	plan, err := e.Hydration.PlanHydration(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	matched := len(plan.Targets) + len(plan.Unavailable)
+	if matched == 0 {
+		return fmt.Errorf("no files match: %s", path)
+	}
+	if len(plan.Targets) == 0 {
+		return fmt.Errorf("no provider placement found for: %s", path)
+	}
+
+	batch := core.IsGlobPattern(path) || matched > 1
+	if batch || dryRun {
+		printHydrationPlan(plan)
+	}
+
+	if !plan.Fits() {
+		return fmt.Errorf("insufficient cache space: need %s, %s free",
+			formatBytes(plan.CacheRequired), formatBytes(plan.CacheFree))
+	}
+
+	if dryRun {
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
+
+	if batch && !force {
+		if !ConfirmAction(fmt.Sprintf("\nHydrate %d file(s)?", plan.TotalFiles)) {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+		fmt.Println()
+	}
+
+	var hydrated, failed int
+	for _, t := range plan.Targets {
+		if err := hydrateTarget(ctx, e, db, t); err != nil {
+			if !batch {
+				return err
+			}
+			fmt.Printf("✗ %s: %v\n", t.Path, err)
+			failed++
+			continue
+		}
+		hydrated++
+	}
+
+	if batch {
+		fmt.Printf("\n✓ Hydrated %d file(s)", hydrated)
+		if failed > 0 {
+			fmt.Printf(", %d failed", failed)
+		}
+		fmt.Println()
+		if failed > 0 {
+			return fmt.Errorf("%d file(s) failed to hydrate", failed)
+		}
+	}
+	return nil
+}
+
+// RunHydrateRange fetches only part of a file into cache.
+// Either a byte range (e.g. "-10M" for the tail) or a chunk list is accepted.
+func RunHydrateRange(path, rangeExpr, chunkExpr string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	plan, err := e.Hydration.PlanHydration(ctx, path)
+	if err != nil {
+		return err
+	}
+	if core.IsGlobPattern(path) || len(plan.Targets)+len(plan.Unavailable) != 1 {
+		return fmt.Errorf("--range and --chunks require a single file target")
+	}
+	if len(plan.Targets) == 0 {
+		return fmt.Errorf("no provider placement found for: %s", path)
+	}
+	t := plan.Targets[0]
+
+	var chunks []int
+	if rangeExpr != "" {
+		r, err := core.ParseByteRange(rangeExpr, t.Size)
+		if err != nil {
+			return err
+		}
+		chunks = core.ChunksForRange(r, core.DefaultChunkSize)
+	} else {
+		if chunks, err = core.ParseChunkList(chunkExpr); err != nil {
+			return err
+		}
+	}
+
+	total := core.ChunkCount(t.Size, core.DefaultChunkSize)
+	fmt.Printf("Partial hydration: %s\n", t.Path)
+	fmt.Printf("  Provider:   %s\n", t.ProviderID)
+	fmt.Printf("  Chunks:     %s of %d (%s each)\n", formatChunkList(chunks), total, formatBytes(core.DefaultChunkSize))
+
+	if dryRun {
+		fmt.Println("\n[DRY-RUN] No changes made.")
+		return nil
+	}
 
-			fmt.Printf("✓ Pushed %s → %s\n", entry.Name, prov.Name)
-		}
+	result, err := e.Hydration.HydrateRange(ctx, t.EntryID, chunks, nil)
+	if errors.Is(err, core.ErrRangeUnsupported) {
+		fmt.Printf("  %s cannot fetch ranges; hydrating the whole file\n\n", t.ProviderID)
+		return RunHydrate(path, false)
+	}
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("\nPush complete.")
+	if result.Complete {
+		fmt.Printf("✓ All %d chunks present; hydrated: %s\n", result.ChunksTotal, t.Path)
+		return nil
+	}
+
+	fmt.Printf("✓ Fetched %d chunk(s), %s\n", result.ChunksFetched, formatBytes(result.BytesLoaded))
+	fmt.Printf("  Present:    %d/%d chunks (%s)\n", result.ChunksPresent, result.ChunksTotal, formatBytes(result.BytesPresent))
+	fmt.Printf("  Cache file: %s\n", result.PartialPath)
 	return nil
 }
 
-// RunHydrate downloads and hydrates a file.
-func RunHydrate(path string) error {
-	e, err := GetEngine()
-	if err != nil {
-		return err
+// formatChunkList renders sorted chunk indexes compactly, e.g. "0,3,5-7".
+func formatChunkList(chunks []int) string {
+	var parts []string
+	for i := 0; i < len(chunks); {
+		j := i
+		for j+1 < len(chunks) && chunks[j+1] == chunks[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, fmt.Sprintf("%d", chunks[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", chunks[i], chunks[j]))
+		}
+		i = j + 1
 	}
+	return strings.Join(parts, ",")
+}
 
-	ctx := context.Background()
+// printHydrationPlan prints the pre-flight summary for a hydration batch.
+func printHydrationPlan(plan *core.HydrationPlan) {
+	fmt.Printf("Hydration Plan: %s\n", plan.Pattern)
+	fmt.Println("═══════════════════════════════════════")
+	fmt.Printf("  Files:            %d (%s)\n", plan.TotalFiles, formatBytes(plan.TotalBytes))
+	if plan.CachedFiles > 0 {
+		fmt.Printf("  Already cached:   %d\n", plan.CachedFiles)
+	}
+	fmt.Printf("  To download:      %s\n", formatBytes(plan.CacheRequired))
 
-	dbPath := filepath.Join(e.ConfigDir, "index.db")
-	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
-	db, err := core.OpenEncryptedDB(dbPath, passphrase)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+	if len(plan.Providers) > 0 {
+		fmt.Println("\nProviders:")
+		for _, p := range plan.Providers {
+			fmt.Printf("  %-16s %4d file(s)  %10s  $%.2f\n",
+				p.Provider, p.Files, formatBytes(p.Bytes), p.EgressCost)
+		}
 	}
-	defer db.Close()
 
-	// Find entry
-	var entryID int64
-	var entryName string
-	err = db.DB().QueryRowContext(ctx, `SELECT id, name FROM entries WHERE name = ?`, filepath.Base(path)).Scan(&entryID, &entryName)
-	if err != nil {
-		return fmt.Errorf("entry not found: %s", path)
+	fmt.Printf("\nEstimated egress:   $%.2f\n", plan.EgressCost)
+	fmt.Printf("Cache required:     %s\n", formatBytes(plan.CacheRequired))
+	fmt.Printf("Cache free:         %s\n", formatBytes(plan.CacheFree))
+	if !plan.Fits() {
+		fmt.Println("⚠️  Not enough free space in cache")
 	}
 
-	// Get placement info
-	var remotePath string
-	var providerID int64
-	var providerName string
-	err = db.DB().QueryRowContext(ctx, `
-		SELECT p.remote_path, pr.id, pr.name
-		FROM placements p
-		JOIN versions v ON p.version_id = v.id
-		JOIN providers pr ON p.provider_id = pr.name
-		WHERE v.entry_id = ? AND v.state = 'active'
-		LIMIT 1
-	`, entryID).Scan(&remotePath, &providerID, &providerName)
-	if err != nil {
-		return fmt.Errorf("no provider placement found for: %s", path)
+	if len(plan.Unavailable) > 0 {
+		fmt.Printf("\n⚠️  %d file(s) have no provider placement and will be skipped:\n", len(plan.Unavailable))
+		for _, p := range plan.Unavailable {
+			fmt.Printf("  - %s\n", p)
+		}
 	}
+}
 
-	fmt.Printf("Hydrating %s from %s...\n", entryName, providerName)
+// hydrateTarget downloads a single planned target into cache and swaps it into place.
+func hydrateTarget(ctx context.Context, e *Engine, db *core.EncryptedDB, t *core.HydrationTarget) error {
+	localPath := filepath.Join(e.RootDir, filepath.FromSlash(t.Path))
 
-	// Journal the operation
-	payload := fmt.Sprintf(`{"entry_id":%d,"provider":"%s"}`, entryID, providerName)
-	opID, _ := e.Journal.BeginOperation(ctx, "hydrate", payload)
+	if t.Cached {
+		cachePath, _ := e.Cache.Get(ctx, t.EntryID, t.VersionID)
+		if cachePath == "" {
+			// Entries cached before cache paths were versioned
+			cachePath = filepath.Join(e.Cache.CacheDir(), "entries", fmt.Sprintf("%d", t.EntryID), t.Name)
+		}
+		if _, err := os.Stat(cachePath); err == nil {
+			if err := copyFile(cachePath, localPath); err != nil {
+				return fmt.Errorf("failed to hydrate to local: %w", err)
+			}
+			os.Remove(localPath + ".cloudfs")
+			fmt.Printf("✓ Hydrated: %s (from cache)\n", t.Path)
+			return nil
+		}
+	}
 
-	// Create cache directory
-	cacheDir := filepath.Join(e.Cache.CacheDir(), "entries", fmt.Sprintf("%d", entryID))
-	os.MkdirAll(cacheDir, 0700)
-	cachePath := filepath.Join(cacheDir, entryName)
+	// Make room first: a download the cache would reject still costs egress
+	if err := e.Cache.EnsureSpace(ctx, t.EntryID, "", t.Size); err != nil {
+		return fmt.Errorf("cannot hydrate %s: %w", t.Path, err)
+	}
+
+	fmt.Printf("Hydrating %s from %s...\n", t.Path, t.ProviderID)
 
-	// Download via rclone
-	cmd := exec.CommandContext(ctx, "rclone", "copy", remotePath, cacheDir)
-	output, err := cmd.CombinedOutput()
+	// Stage the download under .cloudfs/temp (same filesystem as the cache)
+	tempPath, err := e.Hydration.NewTempPath(t.EntryID, t.VersionID)
 	if err != nil {
-		e.Journal.RollbackOperation(ctx, opID, fmt.Sprintf("rclone failed: %s", string(output)))
-		return fmt.Errorf("download failed: %w - %s", err, string(output))
+		return err
 	}
 
-	// Verify hash
-	var expectedHash string
-	db.DB().QueryRowContext(ctx, `
-		SELECT content_hash FROM versions WHERE entry_id = ? AND state = 'active'
-	`, entryID).Scan(&expectedHash)
+	// Journal the operation
+	payload, _ := json.Marshal(map[string]interface{}{
+		"entry_id":    t.EntryID,
+		"version_id":  t.VersionID,
+		"provider":    t.ProviderID,
+		"remote_path": t.RemotePath,
+		"temp_path":   tempPath,
+	})
+	opID, _ := e.Journal.BeginOperation(ctx, "hydrate", string(payload))
+
+	// Download through the provider, which retries transient failures
+	prov, ok := e.Providers.Get(t.ProviderID)
+	if !ok {
+		e.Journal.RollbackOperation(ctx, opID, "provider not registered")
+		return fmt.Errorf("provider not registered: %s", t.ProviderID)
+	}
+	progress, finish := transferProgress(ctx, db, core.TransferDownload, t.Path, t.ProviderID, t.Size)
+	_, err = prov.Download(ctx, t.RemotePath, tempPath, progress)
+	finish()
+	if err != nil {
+		os.Remove(tempPath)
+		e.Journal.RollbackOperation(ctx, opID, err.Error())
+		return fmt.Errorf("download failed: %w", err)
+	}
 
-	if expectedHash != "" {
-		actualHash, err := calculateFileHash(cachePath)
+	// Verify hash
+	if t.ContentHash != "" {
+		actualHash, err := calculateFileHash(tempPath)
 		if err != nil {
+			os.Remove(tempPath)
 			e.Journal.RollbackOperation(ctx, opID, "hash calculation failed")
 			return fmt.Errorf("failed to verify: %w", err)
 		}
-		if actualHash != expectedHash {
+		if actualHash != t.ContentHash {
+			os.Remove(tempPath)
 			e.Journal.RollbackOperation(ctx, opID, "hash mismatch")
-			os.Remove(cachePath)
 			return fmt.Errorf("hash verification failed")
 		}
 	}
 
+	// Move verified download into cache (enforces quota and low-disk threshold)
+	cacheEntry, err := e.Cache.Put(ctx, t.EntryID, t.VersionID, tempPath)
+	if err != nil {
+		os.Remove(tempPath)
+		e.Journal.RollbackOperation(ctx, opID, err.Error())
+		return fmt.Errorf("failed to move download into cache: %w", err)
+	}
+
 	// Atomic swap: copy cache to local path
-	localPath := filepath.Join(e.RootDir, entryName)
-	if err := copyFile(cachePath, localPath); err != nil {
+	os.MkdirAll(filepath.Dir(localPath), 0755)
+	if err := copyFile(cacheEntry.CachePath, localPath); err != nil {
 		e.Journal.RollbackOperation(ctx, opID, err.Error())
 		return fmt.Errorf("failed to hydrate to local: %w", err)
 	}
 
 	// Remove placeholder if exists
-	placeholderPath := localPath + ".cloudfs"
-	os.Remove(placeholderPath)
-
-	_, err = db.DB().ExecContext(ctx, `
-		INSERT OR REPLACE INTO cache_entries (entry_id, version_id, cache_path, state, pinned, last_accessed)
-		VALUES (?, (SELECT id FROM versions WHERE entry_id = ? AND state = 'active'), 
-		        ?, 'valid', 0, datetime('now'))
-	`, entryID, entryID, cachePath)
-	if err != nil {
-		e.Journal.RollbackOperation(ctx, opID, err.Error())
-		return fmt.Errorf("failed to update cache state: %w", err)
-	}
+	os.Remove(localPath + ".cloudfs")
 
 	e.Journal.CommitOperation(ctx, opID)
 	e.Journal.SyncOperation(ctx, opID)
 
-	fmt.Printf("✓ Hydrated: %s\n", entryName)
+	fmt.Printf("✓ Hydrated: %s\n", t.Path)
 	return nil
 }
 
@@ -2938,10 +4674,118 @@ func RunCacheClear() error {
 	return nil
 }
 
+// --- Bandwidth Commands ---
+
+// applyBandwidthLimit overrides the global bandwidth limit for the rest of
+// the command. Provider limits still apply; the tightest one wins.
+func applyBandwidthLimit(value string) error {
+	if value == "" {
+		return nil
+	}
+	schedule, err := bwlimit.ParseSchedule(value)
+	if err != nil {
+		return fmt.Errorf("invalid --bwlimit value: %w", err)
+	}
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+	e.Bandwidth.SetSchedule(schedule)
+	return nil
+}
+
+// RunBandwidthLimit sets the global limit, or a provider's own with
+// providerName, and shows the configured limits. An empty value only shows.
+func RunBandwidthLimit(value, providerName string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if value != "" {
+		schedule, err := bwlimit.ParseSchedule(value)
+		if err != nil {
+			return err
+		}
+		target := "global"
+		if providerName != "" {
+			target = providerName
+		}
+		if dryRun {
+			fmt.Printf("Would set %s bandwidth limit to %s\n", target, schedule)
+			fmt.Println("\n[DRY-RUN] No changes made.")
+			return nil
+		}
+		if providerName != "" {
+			err = core.SetProviderBandwidthLimit(ctx, db.DB(), providerName, schedule)
+		} else {
+			err = core.SetBandwidthLimit(ctx, db.DB(), schedule)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ Set %s bandwidth limit: %s\n", target, schedule)
+		fmt.Println()
+	}
+
+	global, err := core.GetBandwidthLimit(ctx, db.DB())
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	fmt.Println("Bandwidth Limits (upload:download per second)")
+	fmt.Println("═══════════════════════════════════════")
+	fmt.Printf("  %-16s %-32s now %s\n", "global", global, global.At(now))
+
+	rows, err := db.DB().QueryContext(ctx, `
+		SELECT p.name, c.value FROM providers p
+		JOIN provider_config c ON c.provider_id = p.id AND c.key = ?
+		ORDER BY p.name
+	`, core.BandwidthConfigKey)
+	if err != nil {
+		return fmt.Errorf("failed to list provider limits: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return fmt.Errorf("failed to scan provider limit: %w", err)
+		}
+		schedule, err := bwlimit.ParseSchedule(value)
+		if err != nil {
+			fmt.Printf("  %-16s ⚠️  invalid: %s\n", name, value)
+			continue
+		}
+		fmt.Printf("  %-16s %-32s now %s\n", name, schedule, schedule.At(now))
+	}
+	return rows.Err()
+}
+
 // --- Provider Commands ---
 
+// ProviderAddOptions holds optional provider settings.
+type ProviderAddOptions struct {
+	EgressCost float64           // USD per GB downloaded
+	LatencyMs  int               // Typical request latency
+	Dedicated  bool              // Remote holds only CloudFS data (enables gc)
+	SoftLimit  int64             // Usage that triggers trash reclaim prompts (0 = none)
+	HardLimit  int64             // Usage that must never be exceeded (0 = none)
+	Config     map[string]string // Type-specific provider_config entries (e.g. s3 endpoint)
+}
+
 // RunProviderAdd adds a new storage provider.
-func RunProviderAdd(name, provType, remote string) error {
+func RunProviderAdd(name, provType, remote string, opts ProviderAddOptions) error {
 	e, err := GetEngine()
 	if err != nil {
 		return err
@@ -2957,38 +4801,182 @@ func RunProviderAdd(name, provType, remote string) error {
 	}
 	defer db.Close()
 
-	// Validate provider type
-	if provType != "rclone" {
-		return fmt.Errorf("unsupported provider type: %s (only 'rclone' supported)", provType)
+	var bandwidth string
+	if value := opts.Config[core.BandwidthConfigKey]; value != "" {
+		schedule, err := bwlimit.ParseSchedule(value)
+		if err != nil {
+			return fmt.Errorf("invalid --bwlimit value: %w", err)
+		}
+		bandwidth = schedule.String()
+	}
+
+	config := map[string]string{"remote": remote}
+	switch provType {
+	case "rclone":
+		// Check remote exists via rclone
+		cmd := exec.CommandContext(ctx, "rclone", "lsd", remote)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("rclone remote check failed: %s\nIs '%s' configured in rclone?", string(output), strings.Split(remote, ":")[0])
+		}
+	case "s3", "webdav", "sftp":
+		// Placements on native providers are addressed as "<name>:<path>"
+		for k, v := range opts.Config {
+			config[k] = v
+		}
+		if provType == "s3" {
+			// remote is "bucket[/prefix]"
+			config["bucket"], config["prefix"], _ = strings.Cut(strings.Trim(remote, "/"), "/")
+		} else if provType == "sftp" {
+			// remote is "[user@]host[:port][/path]"
+			hostPath := remote
+			if user, rest, ok := strings.Cut(remote, "@"); ok {
+				config["username"], hostPath = user, rest
+			}
+			config["host"], config["root"], _ = strings.Cut(hostPath, "/")
+		} else {
+			// remote is the base collection URL
+			config["url"] = remote
+		}
+		config["remote"] = name + ":"
+		p, err := newProvider(name, provType, config, filepath.Join(e.ConfigDir, "temp"))
+		if err != nil {
+			return err
+		}
+		if err := p.Init(ctx, nil); err != nil {
+			return fmt.Errorf("%s check failed: %w", provType, err)
+		}
+	case "faulty":
+		for k, v := range opts.Config {
+			config[k] = v
+		}
+		wrapped := config["wrap"]
+		if wrapped == "" {
+			return fmt.Errorf("faulty providers need --wrap <provider>")
+		}
+		var wrappedID int64
+		var wrappedType string
+		err := db.DB().QueryRowContext(ctx, `SELECT id, type FROM providers WHERE name = ?`, wrapped).Scan(&wrappedID, &wrappedType)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("provider not found: %s", wrapped)
+		} else if err != nil {
+			return fmt.Errorf("failed to look up provider: %w", err)
+		}
+		if wrappedType == "faulty" {
+			return fmt.Errorf("cannot wrap %s: it is already a faulty provider", wrapped)
+		}
+		// Objects live in the wrapped provider, so share its remote
+		wrappedCfg, err := loadProviderConfig(ctx, db.DB(), wrappedID)
+		if err != nil {
+			return err
+		}
+		config["remote"] = wrappedCfg["remote"]
+		remote = "wrapping " + wrapped
+		if _, err := faulty.ParseConfig(config); err != nil {
+			return err
+		}
+	default:
+		if _, err := plugin.Find(provType); err != nil {
+			return fmt.Errorf("unsupported provider type: %s (supported: rclone, s3, webdav, sftp, faulty, or a %s%s plugin on PATH)", provType, plugin.ExecutablePrefix, provType)
+		}
+		// The plugin interprets remote itself
+		for k, v := range opts.Config {
+			config[k] = v
+		}
+		config["location"] = remote
+		config["remote"] = name + ":"
+		p, err := newProvider(name, provType, config, filepath.Join(e.ConfigDir, "temp"))
+		if err != nil {
+			return err
+		}
+		defer p.(*plugin.Provider).Close()
+		if err := p.Init(ctx, nil); err != nil {
+			return fmt.Errorf("%s plugin check failed: %w", provType, err)
+		}
 	}
 
-	// Check remote exists via rclone
-	cmd := exec.CommandContext(ctx, "rclone", "lsd", remote)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("rclone remote check failed: %s\nIs '%s' configured in rclone?", string(output), strings.Split(remote, ":")[0])
+	// Every type honours the bandwidth limit
+	delete(config, core.BandwidthConfigKey)
+	if bandwidth != "" {
+		config[core.BandwidthConfigKey] = bandwidth
 	}
 
 	// Insert provider
+	var softLimit, hardLimit sql.NullInt64
+	if opts.SoftLimit > 0 {
+		softLimit = sql.NullInt64{Int64: opts.SoftLimit, Valid: true}
+	}
+	if opts.HardLimit > 0 {
+		hardLimit = sql.NullInt64{Int64: opts.HardLimit, Valid: true}
+	}
 	result, err := db.DB().ExecContext(ctx, `
-		INSERT INTO providers (name, type, status, priority)
-		VALUES (?, ?, 'active', 1)
-	`, name, provType)
+		INSERT INTO providers (name, type, status, priority, soft_limit, hard_limit)
+		VALUES (?, ?, 'active', 1, ?, ?)
+	`, name, provType, softLimit, hardLimit)
 	if err != nil {
 		return fmt.Errorf("failed to add provider: %w", err)
 	}
 
 	providerID, _ := result.LastInsertId()
 
-	// Store remote config
-	db.DB().ExecContext(ctx, `
-		INSERT INTO provider_config (provider_id, key, value)
-		VALUES (?, 'remote', ?)
-	`, providerID, remote)
+	// Store remote and type-specific config
+	for key, value := range config {
+		if value == "" {
+			continue
+		}
+		db.DB().ExecContext(ctx, `
+			INSERT INTO provider_config (provider_id, key, value)
+			VALUES (?, ?, ?)
+		`, providerID, key, value)
+	}
+
+	if opts.EgressCost > 0 {
+		db.DB().ExecContext(ctx, `
+			INSERT INTO provider_config (provider_id, key, value)
+			VALUES (?, ?, ?)
+		`, providerID, core.EgressCostConfigKey, strconv.FormatFloat(opts.EgressCost, 'f', -1, 64))
+	}
+	if opts.LatencyMs > 0 {
+		db.DB().ExecContext(ctx, `
+			INSERT INTO provider_config (provider_id, key, value)
+			VALUES (?, ?, ?)
+		`, providerID, core.LatencyConfigKey, strconv.Itoa(opts.LatencyMs))
+	}
+	if opts.Dedicated {
+		if err := core.SetDedicated(ctx, db.DB(), name, true); err != nil {
+			return err
+		}
+	}
 
 	fmt.Printf("✓ Added provider: %s (%s)\n", name, remote)
 	return nil
 }
 
+// RunProviderDedicate marks or unmarks a provider's remote as dedicated to CloudFS.
+func RunProviderDedicate(name string, dedicated bool) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	dbPath := filepath.Join(e.ConfigDir, "index.db")
+	passphrase := os.Getenv("CLOUDFS_PASSPHRASE")
+	db, err := core.OpenEncryptedDB(dbPath, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := core.SetDedicated(context.Background(), db.DB(), name, dedicated); err != nil {
+		return err
+	}
+	if dedicated {
+		fmt.Printf("✓ %s is dedicated to CloudFS; 'cloudfs gc' may scan it\n", name)
+	} else {
+		fmt.Printf("✓ %s is shared; 'cloudfs gc' will not scan it\n", name)
+	}
+	return nil
+}
+
 // RunProviderList lists configured providers.
 func RunProviderList() error {
 	e, err := GetEngine()
@@ -3103,16 +5091,181 @@ func RunProviderStatus(name string) error {
 
 	// Check connectivity
 	fmt.Print("\nConnectivity: ")
-	cmd := exec.CommandContext(ctx, "rclone", "lsd", remote)
-	if err := cmd.Run(); err != nil {
+	state := provider.HealthStateHealthy
+	if ptype == "rclone" {
+		cmd := exec.CommandContext(ctx, "rclone", "lsd", remote)
+		if err := cmd.Run(); err != nil {
+			state = provider.HealthStateUnavailable
+		}
+	} else if p, ok := e.Providers.Get(name); ok {
+		state = p.CheckHealth(ctx)
+	} else {
+		state = provider.HealthStateUnavailable
+	}
+	if state == provider.HealthStateUnavailable {
 		fmt.Println("✗ FAILED")
 	} else {
 		fmt.Println("✓ OK")
 	}
+	if err := core.NewHealthManager(db.DB()).RecordProviderHealth(ctx, name, state); err != nil && verbose {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+
+	return nil
+}
+
+// RunProviderUsage shows live provider usage against limits, or with history
+// the recorded trend and a forecast of when each limit is reached.
+func RunProviderUsage(name string, history bool, since string) error {
+	e, err := GetEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	db, err := core.OpenEncryptedDB(filepath.Join(e.ConfigDir, "index.db"), os.Getenv("CLOUDFS_PASSPHRASE"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	names := []string{name}
+	if name == "" {
+		names = nil
+		rows, err := db.DB().QueryContext(ctx, `SELECT name FROM providers ORDER BY priority, name`)
+		if err != nil {
+			return fmt.Errorf("failed to list providers: %w", err)
+		}
+		for rows.Next() {
+			var n string
+			rows.Scan(&n)
+			names = append(names, n)
+		}
+		rows.Close()
+	} else {
+		var exists int
+		if err := db.DB().QueryRowContext(ctx, `SELECT 1 FROM providers WHERE name = ?`, name).Scan(&exists); err != nil {
+			return fmt.Errorf("provider not found: %s", name)
+		}
+	}
+
+	if !history {
+		fmt.Println("Provider Usage")
+		fmt.Println("═══════════════════════════════════════")
+		for _, n := range names {
+			var soft, hard sql.NullInt64
+			db.DB().QueryRowContext(ctx, `SELECT soft_limit, hard_limit FROM providers WHERE name = ?`, n).Scan(&soft, &hard)
+
+			prov, ok := e.Providers.Get(n)
+			if !ok {
+				fmt.Printf("⚠️  %-10s not registered\n", n)
+				continue
+			}
+			usage, err := prov.GetUsage(ctx)
+			if err != nil {
+				fmt.Printf("✗ %-10s %v\n", n, err)
+				continue
+			}
+			line := fmt.Sprintf("%-10s %s", n, formatBytes(usage.UsedBytes))
+			if usage.TotalBytes > 0 {
+				line += fmt.Sprintf(" / %s", formatBytes(usage.TotalBytes))
+			}
+			mark := "✓"
+			if hard.Valid && hard.Int64 > 0 && usage.UsedBytes >= hard.Int64 {
+				mark = "✗"
+				line += fmt.Sprintf("  (hard limit %s reached)", formatBytes(hard.Int64))
+			} else if soft.Valid && soft.Int64 > 0 && usage.UsedBytes >= soft.Int64 {
+				mark = "⚠️ "
+				line += fmt.Sprintf("  (soft limit %s reached)", formatBytes(soft.Int64))
+			}
+			fmt.Printf("%s %s\n", mark, line)
+		}
+		return nil
+	}
+
+	window, err := core.ParseAge(since)
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+	from := time.Now().Add(-window)
+	hist := core.NewHistoryManager(db.DB(), nil, nil)
+
+	fmt.Printf("Provider Usage History (last %s)\n", since)
+	fmt.Println("═══════════════════════════════════════")
+	for _, n := range names {
+		if err := printUsageForecast(ctx, hist, core.UsageScopeProvider, n, from); err != nil {
+			return err
+		}
+	}
+	if name == "" {
+		if err := printUsageForecast(ctx, hist, core.UsageScopeCache, core.UsageScopeCache, from); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printUsageForecast renders one provider's (or the cache's) usage trend and
+// limit forecast.
+func printUsageForecast(ctx context.Context, hist *core.HistoryManager, scope, name string, from time.Time) error {
+	samples, err := hist.UsageHistory(ctx, scope, name, from)
+	if err != nil {
+		return err
+	}
+	f, err := hist.Forecast(ctx, scope, name, from)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%s\n", name)
+	fmt.Println("───────────────────────────────────────")
+	if len(samples) == 0 {
+		fmt.Println("  No usage recorded. Run 'cloudfs health record' periodically to build it.")
+		return nil
+	}
 
+	used := make([]float64, len(samples))
+	for i, s := range samples {
+		used[i] = float64(s.UsedBytes)
+	}
+	fmt.Printf("  Trend:      %s  (%d samples since %s)\n", sparkline(used, 0, 0), f.Samples, f.Since.Local().Format("2006-01-02"))
+	fmt.Printf("  Current:    %s\n", formatBytes(f.Current))
+	if f.Samples < 2 {
+		fmt.Println("  Growth:     n/a (need at least 2 samples)")
+	} else if f.GrowthPerDay < 0 {
+		fmt.Printf("  Growth:     -%s/day\n", formatBytes(int64(-f.GrowthPerDay)))
+	} else {
+		fmt.Printf("  Growth:     %s/day\n", formatBytes(int64(f.GrowthPerDay)))
+	}
+
+	printLimitForecast("Soft limit", f.SoftLimit, f.SoftLimitAt, f.Current)
+	printLimitForecast("Hard limit", f.HardLimit, f.HardLimitAt, f.Current)
+	printLimitForecast("Capacity", f.TotalBytes, f.FullAt, f.Current)
 	return nil
 }
 
+// printLimitForecast prints when a limit is (or was) reached.
+func printLimitForecast(label string, limit int64, at *time.Time, current int64) {
+	if limit <= 0 {
+		return
+	}
+	switch {
+	case current >= limit:
+		fmt.Printf("  %-11s %s  ✗ reached\n", label+":", formatBytes(limit))
+	case at == nil:
+		fmt.Printf("  %-11s %s  ✓ not reached at current growth\n", label+":", formatBytes(limit))
+	default:
+		days := int(time.Until(*at).Hours() / 24)
+		mark := "✓"
+		if days < 30 {
+			mark = "⚠️ "
+		}
+		fmt.Printf("  %-11s %s  %s ~%s (in %d days)\n", label+":", formatBytes(limit), mark, at.Local().Format("2006-01-02"), days)
+	}
+}
+
 // RunProviderRemove removes a provider with safety checks.
 func RunProviderRemove(name string) error {
 	e, err := GetEngine()
@@ -6,9 +6,13 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/cloudfs/cloudfs/internal/core"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +22,8 @@ var (
 	quiet     bool
 	configDir string
 	dryRun    bool
+
+	useRcloneDaemon bool
 )
 
 // rootCmd is the base command for CloudFS.
@@ -42,7 +48,9 @@ Atomic unit: version`,
 
 // Execute runs the root command.
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	CloseEngine()
+	return err
 }
 
 func init() {
@@ -51,6 +59,7 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress non-essential output")
 	rootCmd.PersistentFlags().StringVar(&configDir, "config", "", "Use alternate config directory")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without doing it")
+	rootCmd.PersistentFlags().BoolVar(&useRcloneDaemon, "rcd", false, "Run rclone providers through a private rclone rcd for this command (one more per provider with its own bwlimit)")
 
 	// Add command groups
 	rootCmd.AddCommand(initCmd)
@@ -65,13 +74,17 @@ func init() {
 	rootCmd.AddCommand(cacheCmd)
 	rootCmd.AddCommand(providerCmd)
 	rootCmd.AddCommand(pushCmd)
+	rootCmd.AddCommand(bwlimitCmd)
 	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(scrubCmd)
 	rootCmd.AddCommand(repairCmd)
 	rootCmd.AddCommand(journalCmd)
 	rootCmd.AddCommand(snapshotCmd)
 	rootCmd.AddCommand(trashCmd)
 	rootCmd.AddCommand(searchCmd)
 	rootCmd.AddCommand(healthCmd)
+	rootCmd.AddCommand(healCmd)
+	rootCmd.AddCommand(replicateCmd)
 	rootCmd.AddCommand(archiveCmd)
 	rootCmd.AddCommand(requestCmd)
 	rootCmd.AddCommand(explainCmd)
@@ -79,6 +92,7 @@ func init() {
 	rootCmd.AddCommand(diagnosticsCmd)
 	rootCmd.AddCommand(overviewCmd)
 	rootCmd.AddCommand(destroyCmd)
+	rootCmd.AddCommand(gcCmd)
 	rootCmd.AddCommand(tuiCmd)
 }
 
@@ -163,18 +177,53 @@ var lsCmd = &cobra.Command{
 }
 
 var hydrateCmd = &cobra.Command{
-	Use:   "hydrate <path>",
+	Use:   "hydrate <path|dir|glob>",
 	Short: "Download and hydrate file(s)",
 	Long: `Download and hydrate file(s) from the provider.
 
 Hydration is triggered ONLY by explicit user commands.
-Downloads write to cache, then atomically swap placeholder after hash verification.`,
+Downloads write to cache, then atomically swap placeholder after hash verification.
+
+Targets may be a file, a directory (all files beneath it) or a glob
+resolved against the index. "**" matches any number of directories.
+Batch targets print a summary (files, bytes, providers, estimated
+egress cost, cache space) and ask for confirmation.
+
+Partial hydration (--range or --chunks) fetches only the needed chunks
+of a large file into cache. The file stays a placeholder until every
+chunk is present.
+
+Example:
+  cloudfs hydrate 'photos/2024/**/*.jpg'
+  cloudfs hydrate --dry-run photos/2024
+  cloudfs hydrate --range=-10M server.log
+  cloudfs hydrate --chunks 0,12-15 disk.img`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return RunHydrate(args[0])
+		force, _ := cmd.Flags().GetBool("force")
+		rangeExpr, _ := cmd.Flags().GetString("range")
+		chunks, _ := cmd.Flags().GetString("chunks")
+		if rangeExpr != "" && chunks != "" {
+			return fmt.Errorf("--range and --chunks are mutually exclusive")
+		}
+		bw, _ := cmd.Flags().GetString("bwlimit")
+		if err := applyBandwidthLimit(bw); err != nil {
+			return err
+		}
+		if rangeExpr != "" || chunks != "" {
+			return RunHydrateRange(args[0], rangeExpr, chunks)
+		}
+		return RunHydrate(args[0], force)
 	},
 }
 
+func init() {
+	hydrateCmd.Flags().Bool("force", false, "Skip confirmation prompt")
+	hydrateCmd.Flags().String("range", "", "Byte range to fetch: START-END, START- or -N (tail); K/M/G suffixes allowed")
+	hydrateCmd.Flags().String("chunks", "", "Chunk indexes to fetch, e.g. 0,3,5-7")
+	hydrateCmd.Flags().String("bwlimit", "", "Bandwidth limit for this hydration, e.g. 4M (see 'cloudfs bwlimit')")
+}
+
 var dehydrateCmd = &cobra.Command{
 	Use:   "dehydrate <path>",
 	Short: "Remove local data, keep placeholder",
@@ -218,7 +267,7 @@ var cacheListCmd = &cobra.Command{
 
 var cacheStatusCmd = &cobra.Command{
 	Use:   "status",
-	Short: "Show cache disk usage",
+	Short: "Show cache disk usage, quota and headroom",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return RunCacheStatus()
 	},
@@ -241,6 +290,97 @@ var cacheClearCmd = &cobra.Command{
 	},
 }
 
+var cachePlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show ranked eviction set to free space",
+	Long: `Rank cache entries for eviction and show the set needed to free space.
+
+Entries are scored by last access, size, remote replica verification,
+provider egress cost and latency, and per-path cache policies.
+Pinned entries, 'keep' policies and entries without a remote replica
+are never selected. Nothing is removed unless --apply is given and
+confirmed.
+
+Example:
+  cloudfs cache plan --free 20G
+  cloudfs cache plan --free 5G --apply`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		free, _ := cmd.Flags().GetString("free")
+		apply, _ := cmd.Flags().GetBool("apply")
+		return RunCachePlan(free, apply)
+	},
+}
+
+var cacheConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Set cache quota, low-disk threshold and auto-clean",
+	Long: `Configure cache limits. They are checked before every cache write.
+
+When a write would exceed the quota or leave less than --min-free on the
+cache volume, CloudFS shows the ranked eviction set and asks before
+evicting. With --auto-clean the eviction happens without a prompt.
+A size of 0 removes the limit.
+
+Example:
+  cloudfs cache config --quota 50G --min-free 5G
+  cloudfs cache config --auto-clean=true`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		quota, _ := cmd.Flags().GetString("quota")
+		minFree, _ := cmd.Flags().GetString("min-free")
+		var autoClean *bool
+		if cmd.Flags().Changed("auto-clean") {
+			v, _ := cmd.Flags().GetBool("auto-clean")
+			autoClean = &v
+		}
+		return RunCacheConfig(quota, minFree, autoClean)
+	},
+}
+
+var cachePolicyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Per-path cache policies (keep, prefer-keep, normal, prefer-evict)",
+}
+
+var cachePolicySetCmd = &cobra.Command{
+	Use:   "set <path|glob> <keep|prefer-keep|normal|prefer-evict>",
+	Short: "Set cache policy for a path",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunCachePolicySet(args[0], args[1])
+	},
+}
+
+var cachePolicyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cache policies",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunCachePolicyList()
+	},
+}
+
+var cachePolicyRmCmd = &cobra.Command{
+	Use:   "rm <path|glob>",
+	Short: "Remove a cache policy",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunCachePolicyRemove(args[0])
+	},
+}
+
+func init() {
+	cachePlanCmd.Flags().String("free", "", "Bytes to free, e.g. 20G")
+	cachePlanCmd.MarkFlagRequired("free")
+	cachePlanCmd.Flags().Bool("apply", false, "Evict the planned entries after confirmation")
+
+	cacheConfigCmd.Flags().String("quota", "", "Maximum cache size, e.g. 50G (0 = unlimited)")
+	cacheConfigCmd.Flags().String("min-free", "", "Free space to keep on the cache volume, e.g. 5G")
+	cacheConfigCmd.Flags().Bool("auto-clean", false, "Evict ranked entries without prompting when full")
+
+	cachePolicyCmd.AddCommand(cachePolicySetCmd)
+	cachePolicyCmd.AddCommand(cachePolicyListCmd)
+	cachePolicyCmd.AddCommand(cachePolicyRmCmd)
+}
+
 // Provider subcommands
 var providerCmd = &cobra.Command{
 	Use:   "provider",
@@ -248,18 +388,117 @@ var providerCmd = &cobra.Command{
 }
 
 var providerAddCmd = &cobra.Command{
-	Use:   "add <name> <type> <remote>",
+	Use:   "add <name> <type> [remote]",
 	Short: "Add a new storage provider",
 	Long: `Add a new storage provider.
 
+Types:
+  rclone  remote is an rclone remote path, e.g. gdrive:backup
+  s3      remote is bucket[/prefix] on any S3-compatible service;
+          credentials default to AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY
+  webdav  remote is the base collection URL; the password defaults
+          to CLOUDFS_WEBDAV_PASSWORD
+  sftp    remote is [user@]host[:port][/path], the path relative to the
+          login directory (use // for an absolute path); authenticates with
+          --key-file or --agent and checks the host against known_hosts
+  <other> served by a cloudfs-provider-<other> plugin on PATH, which
+          interprets remote itself; pass plugin settings with --config
+          (see docs/provider_plugins.md)
+  faulty  takes no remote; wraps the provider named by --wrap and injects
+          faults set with --config, e.g. error_rate=0.2, hang_rate=0.01,
+          truncate_rate, corrupt_rate, quota_rate, quota_bytes, latency=200ms,
+          ops=upload,download and seed=42 for a reproducible pattern
+
 Example:
-  cloudfs provider add google rclone gdrive:backup`,
-	Args:  cobra.ExactArgs(3),
+  cloudfs provider add google rclone gdrive:backup
+  cloudfs provider add s3cold rclone s3:archive --egress-cost 0.09
+  cloudfs provider add wasabi s3 my-bucket/cloudfs --endpoint https://s3.wasabisys.com --quota 1T
+  cloudfs provider add minio s3 backups --endpoint http://localhost:9000 --path-style
+  cloudfs provider add nextcloud webdav https://cloud.example.com/remote.php/dav/files/me/cloudfs --user me
+  cloudfs provider add box sftp u123@u123.your-storagebox.de:23/cloudfs --key-file ~/.ssh/id_ed25519
+  cloudfs provider add usb local /mnt/usb/cloudfs --quota 2T
+  cloudfs provider add flaky faulty --wrap box --config error_rate=0.2 --config seed=42`,
+	Args:  cobra.RangeArgs(2, 3),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return RunProviderAdd(args[0], args[1], args[2])
+		remote := ""
+		if len(args) == 3 {
+			remote = args[2]
+		} else if args[1] != "faulty" {
+			return fmt.Errorf("%s providers need a remote", args[1])
+		}
+		var opts ProviderAddOptions
+		opts.EgressCost, _ = cmd.Flags().GetFloat64("egress-cost")
+		opts.LatencyMs, _ = cmd.Flags().GetInt("latency-ms")
+		opts.Dedicated, _ = cmd.Flags().GetBool("dedicated")
+		for flag, dst := range map[string]*int64{"soft-limit": &opts.SoftLimit, "hard-limit": &opts.HardLimit} {
+			if v, _ := cmd.Flags().GetString(flag); v != "" {
+				n, err := core.ParseSize(v)
+				if err != nil {
+					return fmt.Errorf("invalid --%s value: %w", flag, err)
+				}
+				*dst = n
+			}
+		}
+		opts.Config = make(map[string]string)
+		for flag, key := range map[string]string{
+			"endpoint": "endpoint", "region": "region", "access-key": "access_key", "secret-key": "secret_key",
+			"user": "username", "password": "password", "key-file": "key_file", "known-hosts": "known_hosts",
+			"wrap": "wrap", "bwlimit": "bwlimit",
+		} {
+			if v, _ := cmd.Flags().GetString(flag); v != "" {
+				opts.Config[key] = v
+			}
+		}
+		if pathStyle, _ := cmd.Flags().GetBool("path-style"); pathStyle {
+			opts.Config["path_style"] = "true"
+		}
+		if useAgent, _ := cmd.Flags().GetBool("agent"); useAgent {
+			opts.Config["use_agent"] = "true"
+		}
+		pluginConfig, _ := cmd.Flags().GetStringArray("config")
+		for _, kv := range pluginConfig {
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid --config value %q (want key=value)", kv)
+			}
+			opts.Config[key] = value
+		}
+		for flag, key := range map[string]string{"quota": "quota", "part-size": "part_size"} {
+			if v, _ := cmd.Flags().GetString(flag); v != "" {
+				n, err := core.ParseSize(v)
+				if err != nil {
+					return fmt.Errorf("invalid --%s value: %w", flag, err)
+				}
+				opts.Config[key] = strconv.FormatInt(n, 10)
+			}
+		}
+		return RunProviderAdd(args[0], args[1], remote, opts)
 	},
 }
 
+func init() {
+	providerAddCmd.Flags().Float64("egress-cost", 0, "Egress cost in USD per GB (used for hydration estimates)")
+	providerAddCmd.Flags().Int("latency-ms", 0, "Typical request latency in ms (used for cache eviction ranking)")
+	providerAddCmd.Flags().String("soft-limit", "", "Usage that triggers a trash reclaim prompt on push, e.g. 900G")
+	providerAddCmd.Flags().String("hard-limit", "", "Usage that must never be exceeded, e.g. 1T")
+	providerAddCmd.Flags().String("endpoint", "", "s3: service endpoint URL (default AWS for --region)")
+	providerAddCmd.Flags().String("region", "", "s3: signing region (default us-east-1)")
+	providerAddCmd.Flags().Bool("path-style", false, "s3: address the bucket in the URL path (MinIO)")
+	providerAddCmd.Flags().String("access-key", "", "s3: access key ID (default $AWS_ACCESS_KEY_ID)")
+	providerAddCmd.Flags().String("secret-key", "", "s3: secret access key (default $AWS_SECRET_ACCESS_KEY)")
+	providerAddCmd.Flags().String("user", "", "webdav/sftp: user name")
+	providerAddCmd.Flags().String("password", "", "webdav: password (default $CLOUDFS_WEBDAV_PASSWORD)")
+	providerAddCmd.Flags().String("key-file", "", "sftp: private key (passphrase from $CLOUDFS_SFTP_KEY_PASSPHRASE)")
+	providerAddCmd.Flags().Bool("agent", false, "sftp: authenticate with keys from ssh-agent")
+	providerAddCmd.Flags().String("known-hosts", "", "sftp: known_hosts file (default ~/.ssh/known_hosts)")
+	providerAddCmd.Flags().StringArray("config", nil, "plugin/faulty: provider setting as key=value (repeatable)")
+	providerAddCmd.Flags().String("wrap", "", "faulty: name of the provider to inject faults into")
+	providerAddCmd.Flags().String("bwlimit", "", "Bandwidth limit of this provider, e.g. 2M or '08:00,512k 18:00,off'")
+	providerAddCmd.Flags().String("quota", "", "s3/webdav/sftp/plugin: capacity reported as total usage, e.g. 1T")
+	providerAddCmd.Flags().String("part-size", "", "s3/webdav: upload part size (s3 default 8M, at least 5M; webdav default 10M)")
+	providerAddCmd.Flags().Bool("dedicated", false, "The remote holds only CloudFS data, so 'cloudfs gc' may scan it")
+}
+
 var providerListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List configured providers",
@@ -277,6 +516,35 @@ var providerStatusCmd = &cobra.Command{
 	},
 }
 
+var providerUsageCmd = &cobra.Command{
+	Use:   "usage [name]",
+	Short: "Show provider usage and growth forecast",
+	Long: `Show provider usage against soft and hard limits.
+
+With --history, shows the usage recorded by 'cloudfs health record',
+the growth rate over the window and a linear forecast of when each
+provider reaches its soft limit, hard limit and capacity.
+
+Example:
+  cloudfs provider usage
+  cloudfs provider usage gdrive --history --since 90d`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		history, _ := cmd.Flags().GetBool("history")
+		since, _ := cmd.Flags().GetString("since")
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		return RunProviderUsage(name, history, since)
+	},
+}
+
+func init() {
+	providerUsageCmd.Flags().Bool("history", false, "Show recorded usage trend and limit forecast")
+	providerUsageCmd.Flags().String("since", "30d", "History window, e.g. 30d")
+}
+
 var providerRemoveCmd = &cobra.Command{
 	Use:   "remove <name>",
 	Short: "Remove provider (with safety checks)",
@@ -286,15 +554,89 @@ var providerRemoveCmd = &cobra.Command{
 	},
 }
 
+var providerDedicateCmd = &cobra.Command{
+	Use:   "dedicate <name>",
+	Short: "Mark a provider's remote as holding only CloudFS data",
+	Long: `Mark a provider's remote as dedicated to CloudFS.
+
+'cloudfs gc' lists the whole remote and treats every object without a
+placement as an orphan, so it only runs on dedicated remotes. Do not mark
+a remote that also holds other files: gc would offer to delete them.
+
+Example:
+  cloudfs provider dedicate b2
+  cloudfs provider dedicate gdrive --off`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		off, _ := cmd.Flags().GetBool("off")
+		return RunProviderDedicate(args[0], !off)
+	},
+}
+
+func init() {
+	providerDedicateCmd.Flags().Bool("off", false, "Remove the mark")
+}
+
 // Commit operations
 var pushCmd = &cobra.Command{
 	Use:   "push",
 	Short: "Push pending changes to providers",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		bw, _ := cmd.Flags().GetString("bwlimit")
+		if err := applyBandwidthLimit(bw); err != nil {
+			return err
+		}
 		return RunPush()
 	},
 }
 
+func init() {
+	pushCmd.Flags().String("bwlimit", "", "Bandwidth limit for this push, e.g. 1M or '08:00,512k 18:00,off' (see 'cloudfs bwlimit')")
+}
+
+var bwlimitCmd = &cobra.Command{
+	Use:   "bwlimit [schedule]",
+	Short: "Show or set bandwidth limits for provider transfers",
+	Long: `Show or set bandwidth limits for provider transfers.
+
+The global limit applies to all providers together; a provider limit
+(--provider) applies to that provider's transfers only. When both apply,
+the tighter one wins. push, hydrate and archive accept --bwlimit to
+override the global limit for one run.
+
+Schedules use rclone's --bwlimit syntax. Sizes are per second, in
+b, k, M or G (a bare number is KiB); "off" removes the limit.
+
+  1M                              1 MiB/s both ways
+  10M:off                         limit uploads only (upload:download)
+  08:00,512k 18:00,10M 23:00,off  daily timetable
+  Mon-08:00,512k Sat-00:00,off    weekly timetable
+
+The rclone provider hands the schedule to rclone; native providers
+throttle their own streams.
+
+Example:
+  cloudfs bwlimit
+  cloudfs bwlimit '08:00,1M:4M 19:00,off'
+  cloudfs bwlimit 512k --provider gdrive
+  cloudfs bwlimit off`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		providerName, _ := cmd.Flags().GetString("provider")
+		value := ""
+		if len(args) == 1 {
+			value = args[0]
+		} else if providerName != "" {
+			return fmt.Errorf("--provider needs a schedule")
+		}
+		return RunBandwidthLimit(value, providerName)
+	},
+}
+
+func init() {
+	bwlimitCmd.Flags().String("provider", "", "Set the limit of this provider instead of the global one")
+}
+
 var verifyCmd = &cobra.Command{
 	Use:   "verify",
 	Short: "Verify index integrity",
@@ -303,6 +645,35 @@ var verifyCmd = &cobra.Command{
 	},
 }
 
+var scrubCmd = &cobra.Command{
+	Use:   "scrub",
+	Short: "Verify placements against providers",
+	Long: `Verify stored objects on providers, stalest placements first.
+
+Each placement is checked with the provider's SHA-256 when available,
+otherwise the object is downloaded and rehashed. Verified placements get
+a fresh verified_at; missing or corrupt ones are marked DEGRADED.
+
+Provider errors are inconclusive and leave the placement untouched.
+Objects too large for what is left of --budget are skipped. A run
+stopped by Ctrl-C or with skipped objects resumes on the next invocation
+without re-checking placements it already completed.
+
+Example:
+  cloudfs scrub
+  cloudfs scrub --older-than 30d --budget 10G`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetString("older-than")
+		budget, _ := cmd.Flags().GetString("budget")
+		return RunScrub(olderThan, budget)
+	},
+}
+
+func init() {
+	scrubCmd.Flags().String("older-than", "", "Only placements not verified within this age, e.g. 30d")
+	scrubCmd.Flags().String("budget", "", "Maximum bytes to download for rehashing, e.g. 10G")
+}
+
 var repairCmd = &cobra.Command{
 	Use:   "repair",
 	Short: "Attempt to repair inconsistencies",
@@ -361,12 +732,17 @@ func init() {
 	cacheCmd.AddCommand(cacheStatusCmd)
 	cacheCmd.AddCommand(cacheEvictCmd)
 	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cachePlanCmd)
+	cacheCmd.AddCommand(cacheConfigCmd)
+	cacheCmd.AddCommand(cachePolicyCmd)
 
 	// Add provider subcommands
 	providerCmd.AddCommand(providerAddCmd)
 	providerCmd.AddCommand(providerListCmd)
 	providerCmd.AddCommand(providerStatusCmd)
+	providerCmd.AddCommand(providerUsageCmd)
 	providerCmd.AddCommand(providerRemoveCmd)
+	providerCmd.AddCommand(providerDedicateCmd)
 
 	// Add journal subcommands
 	journalCmd.AddCommand(journalListCmd)
@@ -448,6 +824,8 @@ func init() {
 	trashCmd.AddCommand(trashListCmd)
 	trashCmd.AddCommand(trashRestoreCmd)
 	trashCmd.AddCommand(trashPurgeCmd)
+	trashCmd.AddCommand(trashRetryCmd)
+	trashCmd.AddCommand(trashReclaimCmd)
 }
 
 // Trash commands
@@ -470,10 +848,19 @@ var trashListCmd = &cobra.Command{
 
 var trashRestoreCmd = &cobra.Command{
 	Use:   "restore <path>",
-	Short: "Restore an entry from trash",
-	Args:  cobra.ExactArgs(1),
+	Short: "Restore an entry or directory from trash",
+	Long: `Restore an entry from trash. Directories are restored with their
+whole subtree. Missing parent directories and placeholders are recreated.
+
+Example:
+  cloudfs trash restore photos/2023
+  cloudfs trash restore report.pdf --to archive/report-old.pdf
+  cloudfs trash restore notes.txt --rename-on-conflict`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return RunTrashRestore(args[0])
+		to, _ := cmd.Flags().GetString("to")
+		rename, _ := cmd.Flags().GetBool("rename-on-conflict")
+		return RunTrashRestore(args[0], to, rename)
 	},
 }
 
@@ -491,8 +878,48 @@ Use --force to skip confirmation prompt.`,
 	},
 }
 
+var trashRetryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "Retry provider deletions that failed during purge",
+	Long: `Retry remote deletions that failed during a previous purge.
+
+Failed deletions are tracked per object until a retry succeeds.
+Use --dry-run to list them without deleting.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		return RunTrashRetry(force)
+	},
+}
+
+var trashReclaimCmd = &cobra.Command{
+	Use:   "reclaim",
+	Short: "Purge just enough trash to free space on a provider",
+	Long: `Rank trash entries by age, size and replica count and purge just
+enough of them to free the requested bytes on a provider.
+
+The ranked set is previewed before anything is deleted.
+
+Example:
+  cloudfs trash reclaim --provider gdrive --bytes 50G
+  cloudfs trash reclaim --provider b2 --bytes 10G --dry-run`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		providerID, _ := cmd.Flags().GetString("provider")
+		bytes, _ := cmd.Flags().GetString("bytes")
+		force, _ := cmd.Flags().GetBool("force")
+		return RunTrashReclaim(providerID, bytes, force)
+	},
+}
+
 func init() {
+	trashRestoreCmd.Flags().String("to", "", "Restore to a different path")
+	trashRestoreCmd.Flags().Bool("rename-on-conflict", false, "Pick a free name if the target already exists")
+	trashReclaimCmd.Flags().String("provider", "", "Provider to free space on")
+	trashReclaimCmd.Flags().String("bytes", "", "Bytes to free, e.g. 50G")
+	trashReclaimCmd.MarkFlagRequired("provider")
+	trashReclaimCmd.MarkFlagRequired("bytes")
+	trashReclaimCmd.Flags().Bool("force", false, "Skip confirmation prompt (dangerous)")
 	trashPurgeCmd.Flags().Bool("force", false, "Skip confirmation prompt (dangerous)")
+	trashRetryCmd.Flags().Bool("force", false, "Skip confirmation prompt")
 }
 
 // Search command
@@ -527,7 +954,10 @@ var healthCmd = &cobra.Command{
 	Long: `Show health scoring for the repository or a specific entry.
 
 Health scoring is OBSERVATIONAL only. No automatic remediation.
-Scores are computed from replication count and verification age.`,
+Scores are computed from replication count, verification age, PAR2
+parity, provider health (recorded by 'cloudfs provider status'),
+placement states and index consistency. Each deduction is explained
+for a single path; see 'cloudfs health weights' to tune the factors.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) > 0 {
@@ -537,6 +967,177 @@ Scores are computed from replication count and verification age.`,
 	},
 }
 
+var healthWeightsCmd = &cobra.Command{
+	Use:   "weights",
+	Short: "Show or set health scoring weights",
+	Long: `Show or set the weight of each health scoring factor.
+
+A weight scales the factor's deductions: 1.0 is the default impact,
+2.0 doubles it and 0 ignores the factor.
+
+Factors: replication, verification, parity, provider, placement, index
+
+Example:
+  cloudfs health weights
+  cloudfs health weights --set provider=2,index=0.5
+  cloudfs health weights --reset`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set, _ := cmd.Flags().GetStringSlice("set")
+		reset, _ := cmd.Flags().GetBool("reset")
+		return RunHealthWeights(set, reset)
+	},
+}
+
+func init() {
+	healthWeightsCmd.Flags().StringSlice("set", nil, "Weights to change, e.g. provider=2,index=0.5")
+	healthWeightsCmd.Flags().Bool("reset", false, "Restore default weights")
+	healthCmd.AddCommand(healthWeightsCmd)
+}
+
+var healthRecordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Record current health and usage history",
+	Long: `Record a history sample of overall health, the live usage of every
+configured provider and the cache size.
+
+Run it periodically (e.g. from cron) to build the trends shown by
+'cloudfs health history' and 'cloudfs provider usage --history'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunHealthRecord()
+	},
+}
+
+var healthHistoryCmd = &cobra.Command{
+	Use:   "history [path]",
+	Short: "Show health trend over time",
+	Long: `Show recorded health over time.
+
+Without a path, shows the snapshots stored by 'cloudfs health record'.
+With a path, shows the per-entry measurements stored by 'cloudfs scrub'.
+
+Example:
+  cloudfs health history
+  cloudfs health history --since 90d
+  cloudfs health history docs/thesis.pdf`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		since, _ := cmd.Flags().GetString("since")
+		path := ""
+		if len(args) > 0 {
+			path = args[0]
+		}
+		return RunHealthHistory(path, since)
+	},
+}
+
+func init() {
+	healthHistoryCmd.Flags().String("since", "30d", "How far back to show, e.g. 30d")
+	healthCmd.AddCommand(healthRecordCmd)
+	healthCmd.AddCommand(healthHistoryCmd)
+}
+
+var healCmd = &cobra.Command{
+	Use:   "heal",
+	Short: "Add a replica for entries with low health",
+	Long: `Last-resort replication (design Sections 7 and 18).
+
+Every entry whose health score is below --threshold gets one extra
+replica on a provider that does not already hold it. Data is copied from
+the local cache when possible, otherwise from the healthiest remote copy,
+and is hash-verified before upload.
+
+The full plan is shown before anything is copied. Existing placements
+are never moved or deleted.
+
+Example:
+  cloudfs heal --dry-run
+  cloudfs heal --threshold 0.6`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		threshold, _ := cmd.Flags().GetFloat64("threshold")
+		force, _ := cmd.Flags().GetBool("force")
+		return RunHeal(threshold, force)
+	},
+}
+
+func init() {
+	healCmd.Flags().Float64("threshold", core.DefaultHealThreshold, "Heal entries with a health score below this (0.0-1.0)")
+	healCmd.Flags().Bool("force", false, "Skip confirmation prompt")
+}
+
+var replicateCmd = &cobra.Command{
+	Use:   "replicate",
+	Short: "Create or trim replicas to match replication policies",
+	Long: `Enforce replication policies (design Sections 7 and 8).
+
+A policy sets the minimum number of copies for a path or glob, whether
+those copies must sit on distinct provider types, and which providers
+to fill first. Only verified placements count as copies; run
+'cloudfs scrub' to verify new uploads.
+
+Missing copies are copied from the local cache or the healthiest remote
+copy and hash-verified before upload. Copies beyond the policy are
+trimmed through the delete coordinator, keeping preferred providers.
+Entries not covered by any policy are never touched.
+
+Example:
+  cloudfs replicate policy set documents --copies 2 --distinct-types
+  cloudfs replicate policy set media --copies 1 --prefer b2
+  cloudfs replicate --dry-run
+  cloudfs explain documents/report.pdf`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		return RunReplicate(force)
+	},
+}
+
+var replicatePolicyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Per-path replication policies",
+}
+
+var replicatePolicySetCmd = &cobra.Command{
+	Use:   "set <path|glob>",
+	Short: "Set replication policy for a path",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		copies, _ := cmd.Flags().GetInt("copies")
+		distinct, _ := cmd.Flags().GetBool("distinct-types")
+		prefer, _ := cmd.Flags().GetStringSlice("prefer")
+		return RunReplicationPolicySet(args[0], copies, distinct, prefer)
+	},
+}
+
+var replicatePolicyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List replication policies",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunReplicationPolicyList()
+	},
+}
+
+var replicatePolicyRmCmd = &cobra.Command{
+	Use:   "rm <path|glob>",
+	Short: "Remove a replication policy",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return RunReplicationPolicyRemove(args[0])
+	},
+}
+
+func init() {
+	replicateCmd.Flags().Bool("force", false, "Skip confirmation prompt (dangerous when trimming)")
+	replicatePolicySetCmd.Flags().Int("copies", 2, "Minimum number of verified copies")
+	replicatePolicySetCmd.Flags().Bool("distinct-types", false, "Require copies on different provider types")
+	replicatePolicySetCmd.Flags().StringSlice("prefer", nil, "Providers to fill first and trim last, e.g. gdrive,b2")
+
+	replicateCmd.AddCommand(replicatePolicyCmd)
+	replicatePolicyCmd.AddCommand(replicatePolicySetCmd)
+	replicatePolicyCmd.AddCommand(replicatePolicyListCmd)
+	replicatePolicyCmd.AddCommand(replicatePolicyRmCmd)
+}
+
 // Archive commands
 var archiveCmd = &cobra.Command{
 	Use:   "archive",
@@ -546,6 +1147,10 @@ var archiveCmd = &cobra.Command{
 Archives use 7z compression + PAR2 error correction.
 Archives are immutable once created.
 Original data is preserved (never deleted during archival).`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		bw, _ := cmd.Flags().GetString("bwlimit")
+		return applyBandwidthLimit(bw)
+	},
 }
 
 var archiveCreateCmd = &cobra.Command{
@@ -588,6 +1193,7 @@ Original archive is PRESERVED (never deleted).`,
 }
 
 func init() {
+	archiveCmd.PersistentFlags().String("bwlimit", "", "Bandwidth limit for provider transfers in this run (see 'cloudfs bwlimit')")
 	archiveCmd.AddCommand(archiveCreateCmd)
 	archiveCmd.AddCommand(archiveInspectCmd)
 	archiveCmd.AddCommand(archiveListCmd)
@@ -675,10 +1281,10 @@ func init() {
 var scanCmd = &cobra.Command{
 	Use:   "scan",
 	Short: "Non-destructive consistency scanners",
-	Long: `Run read-only scans to check consistency.
+	Long: `Run scans to check consistency.
 
-All scans are READ-ONLY with NO auto-fix.
-Report-only output.`,
+Scans never auto-fix. The provider scan only marks mismatched
+placements DEGRADED; all other scans are READ-ONLY.`,
 }
 
 var scanIndexCmd = &cobra.Command{
@@ -699,12 +1305,33 @@ var scanCacheCmd = &cobra.Command{
 
 var scanProvidersCmd = &cobra.Command{
 	Use:   "providers",
-	Short: "Scan provider state",
+	Short: "Reconcile placements against provider state",
+	Long: `List each provider's remote tree and check every placement for
+existence and size (and content hash with --hash).
+
+Per design Section 5, mismatched placements are marked DEGRADED.
+Nothing is deleted or re-uploaded. On remotes marked dedicated ('cloudfs
+provider dedicate'), objects missing from the index are reported for
+review with 'cloudfs gc'.
+
+Example:
+  cloudfs scan providers
+  cloudfs scan providers --provider gdrive --hash
+  cloudfs scan providers --json > findings.json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return RunScanProviders()
+		providerID, _ := cmd.Flags().GetString("provider")
+		hash, _ := cmd.Flags().GetBool("hash")
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		return RunScanProviders(providerID, hash, jsonOutput)
 	},
 }
 
+func init() {
+	scanProvidersCmd.Flags().String("provider", "", "Scan a single provider")
+	scanProvidersCmd.Flags().Bool("hash", false, "Also compare content hashes (slower)")
+	scanProvidersCmd.Flags().Bool("json", false, "Output findings as JSON")
+}
+
 // Diagnostics command
 var diagnosticsCmd = &cobra.Command{
 	Use:   "diagnostics",
@@ -768,6 +1395,38 @@ func init() {
 	destroyCmd.Flags().Bool("force", false, "Skip confirmation prompts")
 }
 
+// Garbage collection
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Find and delete orphaned objects on a provider",
+	Long: `List every object on the provider's remote and diff them against the
+index. Objects that no placement references (left behind by failed pushes
+or rolled-back operations) are shown with sizes and ages.
+
+Only remotes that hold nothing but CloudFS data can be collected; mark
+them with 'cloudfs provider dedicate <name>' or 'provider add --dedicated'.
+
+Objects newer than --min-age are skipped, since a push may still be
+recording them. Deletion requires confirmation.
+
+Example:
+  cloudfs gc --provider gdrive --dry-run
+  cloudfs gc --provider b2 --min-age 7d`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		providerID, _ := cmd.Flags().GetString("provider")
+		minAge, _ := cmd.Flags().GetString("min-age")
+		force, _ := cmd.Flags().GetBool("force")
+		return RunGC(providerID, minAge, force)
+	},
+}
+
+func init() {
+	gcCmd.Flags().String("provider", "", "Provider to collect")
+	gcCmd.MarkFlagRequired("provider")
+	gcCmd.Flags().String("min-age", "", "Skip objects newer than this, e.g. 12h or 7d (default 24h)")
+	gcCmd.Flags().Bool("force", false, "Skip confirmation prompt (dangerous)")
+}
+
 // TUI command
 var tuiCmd = &cobra.Command{
 	Use:   "tui",
@@ -0,0 +1,72 @@
+// Package core provides bandwidth limit settings for CloudFS.
+// Limits keep large pushes from saturating the uplink.
+//
+// INVARIANTS:
+// - The global schedule lives in index_meta, per-provider ones in provider_config
+// - Only schedules that parse are stored; "off" clears the limit
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+)
+
+const (
+	// metaBandwidthLimit holds the global bandwidth schedule in index_meta.
+	metaBandwidthLimit = "bwlimit"
+	// BandwidthConfigKey holds a provider's own schedule in provider_config.
+	BandwidthConfigKey = "bwlimit"
+)
+
+// GetBandwidthLimit returns the global bandwidth schedule.
+func GetBandwidthLimit(ctx context.Context, db *sql.DB) (*bwlimit.Schedule, error) {
+	var value string
+	err := db.QueryRowContext(ctx, `SELECT value FROM index_meta WHERE key = ?`, metaBandwidthLimit).Scan(&value)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to read bandwidth limit: %w", err)
+	}
+	return bwlimit.ParseSchedule(value)
+}
+
+// SetBandwidthLimit stores the global bandwidth schedule.
+func SetBandwidthLimit(ctx context.Context, db *sql.DB, schedule *bwlimit.Schedule) error {
+	var err error
+	if schedule.Unlimited() {
+		_, err = db.ExecContext(ctx, `DELETE FROM index_meta WHERE key = ?`, metaBandwidthLimit)
+	} else {
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO index_meta (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value
+		`, metaBandwidthLimit, schedule.String())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store bandwidth limit: %w", err)
+	}
+	return nil
+}
+
+// SetProviderBandwidthLimit stores a provider's own bandwidth schedule.
+func SetProviderBandwidthLimit(ctx context.Context, db *sql.DB, name string, schedule *bwlimit.Schedule) error {
+	var providerID int64
+	if err := db.QueryRowContext(ctx, `SELECT id FROM providers WHERE name = ?`, name).Scan(&providerID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("provider not found: %s", name)
+		}
+		return fmt.Errorf("failed to look up provider: %w", err)
+	}
+
+	_, err := db.ExecContext(ctx, `DELETE FROM provider_config WHERE provider_id = ? AND key = ?`, providerID, BandwidthConfigKey)
+	if err == nil && !schedule.Unlimited() {
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO provider_config (provider_id, key, value) VALUES (?, ?, ?)
+		`, providerID, BandwidthConfigKey, schedule.String())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store bandwidth limit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+)
+
+func TestBandwidthLimit_GetSet(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	got, err := GetBandwidthLimit(ctx, db.DB())
+	if err != nil || !got.Unlimited() {
+		t.Fatalf("expected no limit by default, got %v (%v)", got, err)
+	}
+
+	sched, _ := bwlimit.ParseSchedule("08:00,512k 18:00,off")
+	if err := SetBandwidthLimit(ctx, db.DB(), sched); err != nil {
+		t.Fatalf("SetBandwidthLimit failed: %v", err)
+	}
+	got, err = GetBandwidthLimit(ctx, db.DB())
+	if err != nil || got.String() != "08:00,512k 18:00,off" {
+		t.Fatalf("expected stored timetable, got %v (%v)", got, err)
+	}
+
+	if err := SetBandwidthLimit(ctx, db.DB(), nil); err != nil {
+		t.Fatalf("clearing limit failed: %v", err)
+	}
+	if got, _ := GetBandwidthLimit(ctx, db.DB()); !got.Unlimited() {
+		t.Errorf("expected limit cleared, got %v", got)
+	}
+
+	db.DB().ExecContext(ctx, `INSERT INTO providers (name, type) VALUES ('gdrive', 'rclone')`)
+	one, _ := bwlimit.ParseSchedule("1M")
+	if err := SetProviderBandwidthLimit(ctx, db.DB(), "gdrive", one); err != nil {
+		t.Fatalf("SetProviderBandwidthLimit failed: %v", err)
+	}
+	// A second call replaces the first row
+	two, _ := bwlimit.ParseSchedule("2M")
+	if err := SetProviderBandwidthLimit(ctx, db.DB(), "gdrive", two); err != nil {
+		t.Fatalf("SetProviderBandwidthLimit failed: %v", err)
+	}
+	var values []string
+	rows, _ := db.DB().QueryContext(ctx, `
+		SELECT pc.value FROM provider_config pc JOIN providers p ON p.id = pc.provider_id
+		WHERE p.name = 'gdrive' AND pc.key = ?
+	`, BandwidthConfigKey)
+	for rows.Next() {
+		var v string
+		rows.Scan(&v)
+		values = append(values, v)
+	}
+	rows.Close()
+	if len(values) != 1 || values[0] != "2M" {
+		t.Errorf("expected one provider limit 2M, got %v", values)
+	}
+
+	if err := SetProviderBandwidthLimit(ctx, db.DB(), "missing", one); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
@@ -24,9 +24,10 @@ import (
 // SQLite is the SOURCE OF TRUTH for cache state.
 // metadata.json is derived/disposable.
 type CacheManager struct {
-	db       *sql.DB
-	cacheDir string
-	mu       sync.RWMutex
+	db         *sql.DB
+	cacheDir   string
+	onPressure CachePressureFunc // Prompt used when the cache is full
+	mu         sync.RWMutex
 }
 
 // NewCacheManager creates a new cache manager.
@@ -101,6 +102,15 @@ func (cm *CacheManager) updateLastAccessed(ctx context.Context, entryID, version
 // Put adds a file to the cache.
 // The caller must have already written the data to dataPath.
 func (cm *CacheManager) Put(ctx context.Context, entryID, versionID int64, dataPath string) (*model.CacheEntry, error) {
+	// Enforce quota and low-disk threshold before taking the write lock
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat data file: %w", err)
+	}
+	if err := cm.EnsureSpace(ctx, entryID, dataPath, info.Size()); err != nil {
+		return nil, err
+	}
+
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -304,64 +314,49 @@ func (cm *CacheManager) Stats(ctx context.Context) (*CacheStats, error) {
 	}
 
 	// Calculate actual disk usage
-	stats.DiskUsage = cm.calculateDiskUsage()
+	stats.DiskUsage = cm.calculateDiskUsage(ctx)
+	stats.DiskAvailable, _ = DiskFree(cm.cacheDir)
 
 	return stats, nil
 }
 
 // calculateDiskUsage walks the cache directory to calculate total size.
-func (cm *CacheManager) calculateDiskUsage() int64 {
+// Partial files are sparse, so they count by their hydrated chunks.
+func (cm *CacheManager) calculateDiskUsage(ctx context.Context) int64 {
 	var total int64
 	filepath.Walk(cm.cacheDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
-		if !info.IsDir() {
+		if !info.IsDir() && info.Name() != partialFileName {
 			total += info.Size()
 		}
 		return nil
 	})
-	return total
+
+	var chunks int64
+	cm.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(size), 0) FROM hydrated_chunks`).Scan(&chunks)
+	return total + chunks
 }
 
 // GetEvictionCandidates returns entries ranked for eviction.
-// Ranking algorithm (design.txt Section 12):
+// Ranking algorithm (design.txt Section 12), see ScoreEvictionCandidates:
 // 1. last_accessed (oldest first)
-// 2. cache_policy (deletable before pinned)
-// 3. hydration_cost (cheap-to-redownload first)
+// 2. cache_policy (per-path policies; pinned and 'keep' never returned)
+// 3. hydration_cost (size, egress cost and latency of the cheapest replica)
+// Entries without a remote replica are never returned.
 func (cm *CacheManager) GetEvictionCandidates(ctx context.Context, limit int) ([]*model.CacheEntry, error) {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
-	query := `
-		SELECT id, entry_id, version_id, cache_path, cached_at, last_accessed, pinned, state
-		FROM cache_entries
-		WHERE pinned = 0 AND state != 'pending_eviction'
-		ORDER BY last_accessed ASC
-		LIMIT ?
-	`
-	rows, err := cm.db.QueryContext(ctx, query, limit)
+	candidates, err := cm.ScoreEvictionCandidates(ctx, DefaultEvictionWeights)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get eviction candidates: %w", err)
 	}
-	defer rows.Close()
 
 	var entries []*model.CacheEntry
-	for rows.Next() {
-		var entry model.CacheEntry
-		var cachedAt, lastAccessed string
-		var pinned int
-		err := rows.Scan(
-			&entry.ID, &entry.EntryID, &entry.VersionID,
-			&entry.CachePath, &cachedAt, &lastAccessed,
-			&pinned, &entry.State)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan cache entry: %w", err)
+	for _, c := range candidates {
+		if !c.Eligible || len(entries) >= limit {
+			break
 		}
-		entry.CachedAt, _ = time.Parse(time.RFC3339, cachedAt)
-		entry.LastAccessed, _ = time.Parse(time.RFC3339, lastAccessed)
-		entry.Pinned = pinned == 1
-		entries = append(entries, &entry)
+		entries = append(entries, c.Entry)
 	}
 
 	return entries, nil
@@ -0,0 +1,415 @@
+// Package core provides cache eviction ranking for CloudFS.
+// Based on design.txt Section 12: Rank cache entries by last accessed,
+// cache policy and hydration cost.
+//
+// INVARIANTS:
+// - Ranking is READ-ONLY; eviction still requires explicit confirmation
+// - NEVER evict the only copy (a remote replica must exist)
+// - Pinned entries and 'keep' policies are never candidates
+// - Scores are explainable (every factor is reported)
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+)
+
+// LatencyConfigKey is the provider_config key holding typical request latency in ms.
+const LatencyConfigKey = "latency_ms"
+
+// CachePolicyMode controls how eagerly matching paths are evicted.
+type CachePolicyMode string
+
+const (
+	CachePolicyKeep        CachePolicyMode = "keep"         // Never an eviction candidate
+	CachePolicyPreferKeep  CachePolicyMode = "prefer-keep"  // Evicted last
+	CachePolicyNormal      CachePolicyMode = "normal"       // Default
+	CachePolicyPreferEvict CachePolicyMode = "prefer-evict" // Evicted first
+)
+
+// ParseCachePolicyMode validates a cache policy mode.
+func ParseCachePolicyMode(s string) (CachePolicyMode, error) {
+	switch m := CachePolicyMode(s); m {
+	case CachePolicyKeep, CachePolicyPreferKeep, CachePolicyNormal, CachePolicyPreferEvict:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid cache policy %q (keep, prefer-keep, normal, prefer-evict)", s)
+}
+
+// CachePolicy is a per-path cache policy stored in the policies table.
+type CachePolicy struct {
+	Pattern string          `json:"pattern"`
+	Mode    CachePolicyMode `json:"mode"`
+}
+
+// EvictionWeights weights each factor of the eviction score.
+type EvictionWeights struct {
+	Age     float64 // Time since last access
+	Size    float64 // Bytes freed
+	Replica float64 // Remote replica verified
+	Egress  float64 // Cheap to re-download
+	Latency float64 // Fast to re-download
+	Policy  float64 // Per-path cache policy
+}
+
+// DefaultEvictionWeights favours stale, large, safely replicated files.
+var DefaultEvictionWeights = EvictionWeights{
+	Age:     0.35,
+	Size:    0.15,
+	Replica: 0.15,
+	Egress:  0.10,
+	Latency: 0.05,
+	Policy:  0.20,
+}
+
+// EvictionCandidate is a scored cache entry.
+type EvictionCandidate struct {
+	Entry        *model.CacheEntry
+	Path         string
+	Size         int64
+	Score        float64 // 0.0 (keep) to 1.0 (evict first)
+	Factors      map[string]float64
+	Providers    []string
+	Verified     bool    // At least one verified remote replica
+	RehydrateUSD float64 // Cheapest egress cost to re-download
+	LatencyMs    int
+	Policy       CachePolicyMode
+	Eligible     bool
+	Reason       string // Why an entry is not eligible
+}
+
+// EvictionPlan is the ranked set of entries needed to free a number of bytes.
+type EvictionPlan struct {
+	Target    int64
+	Selected  []*EvictionCandidate
+	Freed     int64
+	Shortfall int64
+	Skipped   []*EvictionCandidate // Ineligible entries (only copy, keep policy)
+}
+
+// replicaInfo summarizes remote copies of a cached version.
+type replicaInfo struct {
+	providers []string
+	verified  bool
+	costPerGB float64
+	latencyMs int
+}
+
+// ScoreEvictionCandidates scores every unpinned cache entry.
+// Eligible candidates come first, ordered by descending score.
+func (cm *CacheManager) ScoreEvictionCandidates(ctx context.Context, weights EvictionWeights) ([]*EvictionCandidate, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	rows, err := cm.db.QueryContext(ctx, `
+		SELECT c.id, c.entry_id, c.version_id, c.cache_path, c.cached_at, c.last_accessed,
+		       c.pinned, c.state, COALESCE(v.size, 0)
+		FROM cache_entries c
+		LEFT JOIN versions v ON c.version_id = v.id
+		WHERE c.pinned = 0 AND c.state != 'pending_eviction'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entries: %w", err)
+	}
+
+	var candidates []*EvictionCandidate
+	for rows.Next() {
+		var entry model.CacheEntry
+		var cachedAt, lastAccessed string
+		var pinned int
+		var size int64
+		if err := rows.Scan(&entry.ID, &entry.EntryID, &entry.VersionID, &entry.CachePath,
+			&cachedAt, &lastAccessed, &pinned, &entry.State, &size); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan cache entry: %w", err)
+		}
+		entry.CachedAt = parseDBTime(cachedAt)
+		entry.LastAccessed = parseDBTime(lastAccessed)
+		if info, err := os.Stat(entry.CachePath); err == nil && !info.IsDir() {
+			size = info.Size()
+		}
+		candidates = append(candidates, &EvictionCandidate{Entry: &entry, Size: size})
+	}
+	rows.Close()
+
+	paths, err := listIndexedPaths(ctx, cm.db)
+	if err != nil {
+		return nil, err
+	}
+	pathByID := make(map[int64]string, len(paths))
+	for _, p := range paths {
+		pathByID[p.id] = p.path
+	}
+
+	policies, err := cm.listCachePolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, c := range candidates {
+		c.Path = pathByID[c.Entry.EntryID]
+		c.Policy = matchCachePolicy(policies, c.Path)
+
+		replicas := cm.replicaInfo(ctx, c.Entry.VersionID)
+		c.Providers = replicas.providers
+		c.Verified = replicas.verified
+		c.LatencyMs = replicas.latencyMs
+		c.RehydrateUSD = float64(c.Size) / (1 << 30) * replicas.costPerGB
+
+		switch {
+		case len(replicas.providers) == 0:
+			c.Reason = "only copy (no remote replica)"
+		case c.Policy == CachePolicyKeep:
+			c.Reason = "cache policy: keep"
+		default:
+			c.Eligible = true
+		}
+
+		c.Factors = map[string]float64{
+			"age":     ageFactor(daysSince(now, c.Entry.LastAccessed)),
+			"size":    sizeFactor(c.Size),
+			"replica": 0.5,
+			"egress":  1 / (1 + 10*c.RehydrateUSD),
+			"latency": 1 / (1 + float64(c.LatencyMs)/500),
+			"policy":  0.5,
+		}
+		if c.Verified {
+			c.Factors["replica"] = 1.0
+		}
+		switch c.Policy {
+		case CachePolicyPreferEvict:
+			c.Factors["policy"] = 1.0
+		case CachePolicyPreferKeep:
+			c.Factors["policy"] = 0.0
+		}
+
+		total := weights.Age + weights.Size + weights.Replica + weights.Egress + weights.Latency + weights.Policy
+		if total > 0 {
+			c.Score = (weights.Age*c.Factors["age"] +
+				weights.Size*c.Factors["size"] +
+				weights.Replica*c.Factors["replica"] +
+				weights.Egress*c.Factors["egress"] +
+				weights.Latency*c.Factors["latency"] +
+				weights.Policy*c.Factors["policy"]) / total
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Eligible != candidates[j].Eligible {
+			return candidates[i].Eligible
+		}
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	return candidates, nil
+}
+
+// daysSince returns the days from t to now, never negative.
+func daysSince(now, t time.Time) float64 {
+	days := now.Sub(t).Hours() / 24
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// ageFactor scores an age in days from 0 towards 1, reaching 0.5 at a month.
+func ageFactor(days float64) float64 {
+	return days / (days + 30)
+}
+
+// sizeFactor scores a byte count from 0 towards 1, reaching 0.5 at 1 GiB.
+func sizeFactor(size int64) float64 {
+	return float64(size) / (float64(size) + (1 << 30))
+}
+
+// PlanEviction selects the highest-scoring candidates until bytesToFree is reached.
+// keepEntryID (0 for none) is never selected, e.g. the entry being written.
+// Nothing is removed; callers evict the selected entries after confirmation.
+func (cm *CacheManager) PlanEviction(ctx context.Context, bytesToFree int64, keepEntryID int64) (*EvictionPlan, error) {
+	candidates, err := cm.ScoreEvictionCandidates(ctx, DefaultEvictionWeights)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &EvictionPlan{Target: bytesToFree}
+	for _, c := range candidates {
+		if !c.Eligible {
+			plan.Skipped = append(plan.Skipped, c)
+			continue
+		}
+		if c.Entry.EntryID == keepEntryID || plan.Freed >= bytesToFree {
+			continue
+		}
+		plan.Selected = append(plan.Selected, c)
+		plan.Freed += c.Size
+	}
+	if plan.Freed < bytesToFree {
+		plan.Shortfall = bytesToFree - plan.Freed
+	}
+	return plan, nil
+}
+
+// replicaInfo returns remote placements for a version and the cheapest way to re-download it.
+func (cm *CacheManager) replicaInfo(ctx context.Context, versionID int64) replicaInfo {
+	info := replicaInfo{costPerGB: math.Inf(1)}
+
+	rows, err := cm.db.QueryContext(ctx, `
+		SELECT p.provider_id, p.state,
+		       (SELECT pc.value FROM provider_config pc JOIN providers pr ON pc.provider_id = pr.id
+		        WHERE pr.name = p.provider_id AND pc.key = ?),
+		       (SELECT pc.value FROM provider_config pc JOIN providers pr ON pc.provider_id = pr.id
+		        WHERE pr.name = p.provider_id AND pc.key = ?)
+		FROM placements p
+		WHERE p.version_id = ? AND p.state IN ('uploaded', 'verified')
+	`, EgressCostConfigKey, LatencyConfigKey, versionID)
+	if err != nil {
+		info.costPerGB = 0
+		return info
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, state string
+		var cost, latency sql.NullString
+		if err := rows.Scan(&name, &state, &cost, &latency); err != nil {
+			continue
+		}
+		info.providers = append(info.providers, name)
+		if state == "verified" {
+			info.verified = true
+		}
+		c, _ := strconv.ParseFloat(cost.String, 64)
+		l, _ := strconv.Atoi(latency.String)
+		if c < info.costPerGB {
+			info.costPerGB = c
+			info.latencyMs = l
+		}
+	}
+	if math.IsInf(info.costPerGB, 1) {
+		info.costPerGB = 0
+	}
+	return info
+}
+
+// SetCachePolicy creates or replaces the cache policy for a path pattern.
+func (cm *CacheManager) SetCachePolicy(ctx context.Context, pattern string, mode CachePolicyMode) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	pattern = strings.Join(splitPath(pattern), "/")
+	config, _ := json.Marshal(CachePolicy{Pattern: pattern, Mode: mode})
+
+	// More specific patterns take precedence
+	_, err := cm.db.ExecContext(ctx, `
+		INSERT INTO policies (name, policy_type, config, priority)
+		VALUES (?, 'cache', ?, ?)
+		ON CONFLICT(name) DO UPDATE SET config = excluded.config, priority = excluded.priority
+	`, "cache:"+pattern, string(config), patternPriority(pattern))
+	if err != nil {
+		return fmt.Errorf("failed to set cache policy: %w", err)
+	}
+	return nil
+}
+
+// RemoveCachePolicy deletes the cache policy for a path pattern.
+func (cm *CacheManager) RemoveCachePolicy(ctx context.Context, pattern string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	pattern = strings.Join(splitPath(pattern), "/")
+	result, err := cm.db.ExecContext(ctx, `
+		DELETE FROM policies WHERE name = ? AND policy_type = 'cache'
+	`, "cache:"+pattern)
+	if err != nil {
+		return fmt.Errorf("failed to remove cache policy: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no cache policy for: %s", pattern)
+	}
+	return nil
+}
+
+// ListCachePolicies returns all cache policies, most specific first.
+func (cm *CacheManager) ListCachePolicies(ctx context.Context) ([]CachePolicy, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.listCachePolicies(ctx)
+}
+
+func (cm *CacheManager) listCachePolicies(ctx context.Context) ([]CachePolicy, error) {
+	rows, err := cm.db.QueryContext(ctx, `
+		SELECT config FROM policies WHERE policy_type = 'cache'
+		ORDER BY priority DESC, name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []CachePolicy
+	for rows.Next() {
+		var config string
+		if err := rows.Scan(&config); err != nil {
+			return nil, fmt.Errorf("failed to scan cache policy: %w", err)
+		}
+		var p CachePolicy
+		if json.Unmarshal([]byte(config), &p) == nil {
+			policies = append(policies, p)
+		}
+	}
+	// Rows written before patternPriority may carry another ranking
+	sort.SliceStable(policies, func(i, j int) bool {
+		return patternPriority(policies[i].Pattern) > patternPriority(policies[j].Pattern)
+	})
+	return policies, nil
+}
+
+// literalPatternPriority ranks literal paths above every glob.
+const literalPatternPriority = 1 << 16
+
+// patternPriority ranks a policy pattern by specificity: literal paths
+// before globs, then by the number of path segments without wildcards.
+func patternPriority(pattern string) int {
+	literal := 0
+	for _, segment := range splitPath(pattern) {
+		if !IsGlobPattern(segment) {
+			literal++
+		}
+	}
+	if !IsGlobPattern(pattern) {
+		return literalPatternPriority + literal
+	}
+	return literal
+}
+
+// matchCachePolicy returns the mode of the first (most specific) policy
+// matching path. A plain pattern also matches everything beneath it.
+func matchCachePolicy(policies []CachePolicy, path string) CachePolicyMode {
+	for _, p := range policies {
+		if MatchGlob(p.Pattern, path) || (!IsGlobPattern(p.Pattern) && strings.HasPrefix(path, p.Pattern+"/")) {
+			return p.Mode
+		}
+	}
+	return CachePolicyNormal
+}
+
+// parseDBTime parses timestamps written either as RFC3339 or by SQLite datetime().
+func parseDBTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	t, _ := time.Parse("2006-01-02 15:04:05", s)
+	return t
+}
@@ -0,0 +1,195 @@
+// Package core provides cache quota enforcement for CloudFS.
+// Based on design.txt Section 12: If cache disk is full, prompt the user;
+// if auto-clean is enabled, evict ranked candidates.
+//
+// INVARIANTS:
+// - Checked before EVERY Put
+// - NO eviction without either auto-clean or user confirmation
+// - Eviction order comes from PlanEviction (never the only copy)
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Cache quota settings stored in index_meta.
+const (
+	metaCacheQuota     = "cache_quota_bytes"
+	metaCacheMinFree   = "cache_min_free_bytes"
+	metaCacheAutoClean = "cache_auto_clean"
+)
+
+// ErrCacheFull is returned when a Put would exceed the quota or low-disk threshold.
+var ErrCacheFull = errors.New("cache quota exceeded")
+
+// CacheQuota configures cache limits. Zero values mean "no limit".
+type CacheQuota struct {
+	QuotaBytes   int64 // Maximum bytes the cache may use
+	MinFreeBytes int64 // Low-disk threshold on the cache volume
+	AutoClean    bool  // Evict ranked candidates without prompting
+}
+
+// CacheUsage describes current cache usage against the quota.
+type CacheUsage struct {
+	Quota    CacheQuota
+	Used     int64
+	DiskFree int64
+	Headroom int64 // Bytes that can be added before a limit is hit
+}
+
+// CachePressure describes a Put that does not fit.
+type CachePressure struct {
+	EntryID  int64
+	Incoming int64
+	Usage    *CacheUsage
+	Needed   int64 // Bytes to free for the Put to fit
+}
+
+// CachePressureFunc is asked whether the planned eviction may proceed.
+// It is only consulted when auto-clean is disabled.
+type CachePressureFunc func(ctx context.Context, p *CachePressure, plan *EvictionPlan) bool
+
+// SetPressureHandler installs the prompt used when the cache is full.
+func (cm *CacheManager) SetPressureHandler(fn CachePressureFunc) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.onPressure = fn
+}
+
+// GetQuota returns the configured cache quota.
+func (cm *CacheManager) GetQuota(ctx context.Context) (*CacheQuota, error) {
+	q := &CacheQuota{}
+	rows, err := cm.db.QueryContext(ctx, `
+		SELECT key, value FROM index_meta WHERE key IN (?, ?, ?)
+	`, metaCacheQuota, metaCacheMinFree, metaCacheAutoClean)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache quota: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan cache quota: %w", err)
+		}
+		switch key {
+		case metaCacheQuota:
+			q.QuotaBytes, _ = strconv.ParseInt(value, 10, 64)
+		case metaCacheMinFree:
+			q.MinFreeBytes, _ = strconv.ParseInt(value, 10, 64)
+		case metaCacheAutoClean:
+			q.AutoClean = value == "1"
+		}
+	}
+	return q, nil
+}
+
+// SetQuota stores the cache quota.
+func (cm *CacheManager) SetQuota(ctx context.Context, q *CacheQuota) error {
+	autoClean := "0"
+	if q.AutoClean {
+		autoClean = "1"
+	}
+	for key, value := range map[string]string{
+		metaCacheQuota:     strconv.FormatInt(q.QuotaBytes, 10),
+		metaCacheMinFree:   strconv.FormatInt(q.MinFreeBytes, 10),
+		metaCacheAutoClean: autoClean,
+	} {
+		_, err := cm.db.ExecContext(ctx, `
+			INSERT INTO index_meta (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value
+		`, key, value)
+		if err != nil {
+			return fmt.Errorf("failed to store cache quota: %w", err)
+		}
+	}
+	return nil
+}
+
+// Usage returns cache usage, free disk and headroom against the quota.
+func (cm *CacheManager) Usage(ctx context.Context) (*CacheUsage, error) {
+	q, err := cm.GetQuota(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cm.mu.RLock()
+	used := cm.calculateDiskUsage(ctx)
+	cm.mu.RUnlock()
+
+	u := &CacheUsage{Quota: *q, Used: used}
+	u.DiskFree, _ = DiskFree(cm.cacheDir)
+
+	u.Headroom = u.DiskFree - q.MinFreeBytes
+	if q.QuotaBytes > 0 && q.QuotaBytes-used < u.Headroom {
+		u.Headroom = q.QuotaBytes - used
+	}
+	if u.Headroom < 0 {
+		u.Headroom = 0
+	}
+	return u, nil
+}
+
+// EnsureSpace makes room for incoming bytes, evicting ranked candidates
+// if auto-clean is enabled or the pressure handler approves.
+// Returns ErrCacheFull if the bytes still do not fit.
+func (cm *CacheManager) EnsureSpace(ctx context.Context, entryID int64, dataPath string, incoming int64) error {
+	usage, err := cm.Usage(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Data already on the cache volume does not consume more disk when moved in.
+	diskNeed := incoming
+	if dataPath != "" && sameFilesystem(dataPath, cm.cacheDir) {
+		diskNeed = 0
+	}
+
+	// Data already inside the cache (a completed partial file) is already in Used.
+	quotaNeed := incoming
+	if dataPath != "" && strings.HasPrefix(filepath.Clean(dataPath), filepath.Clean(cm.cacheDir)+string(filepath.Separator)) {
+		quotaNeed = 0
+	}
+
+	needed := diskNeed - (usage.DiskFree - usage.Quota.MinFreeBytes)
+	if q := usage.Quota.QuotaBytes; q > 0 {
+		if over := usage.Used + quotaNeed - q; over > needed {
+			needed = over
+		}
+	}
+	if needed <= 0 {
+		return nil
+	}
+
+	// Never evict the entry being written
+	plan, err := cm.PlanEviction(ctx, needed, entryID)
+	if err != nil {
+		return err
+	}
+
+	pressure := &CachePressure{EntryID: entryID, Incoming: incoming, Usage: usage, Needed: needed}
+	if plan.Freed < needed {
+		return fmt.Errorf("%w: need %d bytes, only %d evictable", ErrCacheFull, needed, plan.Freed)
+	}
+
+	cm.mu.RLock()
+	onPressure := cm.onPressure
+	cm.mu.RUnlock()
+
+	if !usage.Quota.AutoClean && (onPressure == nil || !onPressure(ctx, pressure, plan)) {
+		return fmt.Errorf("%w: need %d bytes", ErrCacheFull, needed)
+	}
+
+	for _, c := range plan.Selected {
+		if err := cm.Evict(ctx, c.Entry.EntryID, c.Entry.VersionID, true); err != nil {
+			return fmt.Errorf("failed to evict %s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
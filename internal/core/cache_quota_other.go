@@ -0,0 +1,9 @@
+//go:build !unix
+
+package core
+
+// sameFilesystem reports false: devices cannot be compared on this
+// platform, so moved-in data is counted against free disk.
+func sameFilesystem(a, b string) bool {
+	return false
+}
@@ -3,6 +3,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -409,6 +410,230 @@ func TestCacheManager_Stats(t *testing.T) {
 	}
 }
 
+func TestCacheManager_PlanEviction(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	cm, err := NewCacheManager(db.DB(), filepath.Join(tmpDir, "cache"))
+	if err != nil {
+		t.Fatalf("failed to create cache manager: %v", err)
+	}
+
+	// name -> (days since access, placement state or "" for none)
+	add := func(name string, days int, placement string) {
+		entry := &model.Entry{Name: name, Type: model.EntryTypeFile, LogicalSize: 1024}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: name, Size: 1024, State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+
+		src := filepath.Join(tmpDir, name)
+		os.WriteFile(src, make([]byte, 1024), 0644)
+		if _, err := cm.Put(ctx, entry.ID, v.ID, src); err != nil {
+			t.Fatalf("failed to put in cache: %v", err)
+		}
+		accessed := time.Now().AddDate(0, 0, -days).Format(time.RFC3339)
+		db.DB().ExecContext(ctx, `UPDATE cache_entries SET last_accessed = ? WHERE entry_id = ?`, accessed, entry.ID)
+		if placement != "" {
+			db.DB().ExecContext(ctx, `
+				INSERT INTO placements (version_id, provider_id, remote_path, state)
+				VALUES (?, 'gdrive', ?, ?)
+			`, v.ID, "gdrive:"+name, placement)
+		}
+	}
+
+	add("old.bin", 90, "verified")
+	add("recent.bin", 1, "uploaded")
+	add("only-copy.bin", 365, "")
+	add("kept.bin", 365, "verified")
+	add("preferred.bin", 1, "verified")
+
+	cm.SetCachePolicy(ctx, "kept.bin", CachePolicyKeep)
+	cm.SetCachePolicy(ctx, "*.bin", CachePolicyNormal)
+	cm.SetCachePolicy(ctx, "preferred.bin", CachePolicyPreferEvict)
+
+	plan, err := cm.PlanEviction(ctx, 10*1024, 0)
+	if err != nil {
+		t.Fatalf("failed to plan eviction: %v", err)
+	}
+
+	if len(plan.Selected) != 3 {
+		t.Fatalf("expected 3 eligible entries, got %d", len(plan.Selected))
+	}
+	if plan.Selected[0].Path != "old.bin" {
+		t.Errorf("expected old.bin ranked first, got %s", plan.Selected[0].Path)
+	}
+	if plan.Selected[2].Path != "recent.bin" {
+		t.Errorf("expected recent.bin ranked last, got %s", plan.Selected[2].Path)
+	}
+	if plan.Shortfall != 7*1024 {
+		t.Errorf("expected shortfall of 7 KB, got %d", plan.Shortfall)
+	}
+
+	skipped := map[string]bool{}
+	for _, c := range plan.Skipped {
+		skipped[c.Path] = true
+	}
+	if !skipped["only-copy.bin"] || !skipped["kept.bin"] {
+		t.Errorf("only copy and keep-policy entries must be protected, got %v", skipped)
+	}
+
+	// A small target stops at the best candidate
+	plan, _ = cm.PlanEviction(ctx, 1, 0)
+	if len(plan.Selected) != 1 || plan.Shortfall != 0 {
+		t.Errorf("expected a single selection, got %d (shortfall %d)", len(plan.Selected), plan.Shortfall)
+	}
+}
+
+func TestPatternPriority(t *testing.T) {
+	// Each pattern is more specific than the next
+	ordered := []string{"photos/2024/a.jpg", "photos/2024", "a.jpg", "photos/2024/*.jpg", "photos/**/*.jpg", "**/*.jpg"}
+	for i := 1; i < len(ordered); i++ {
+		if patternPriority(ordered[i-1]) <= patternPriority(ordered[i]) {
+			t.Errorf("expected %q to outrank %q", ordered[i-1], ordered[i])
+		}
+	}
+
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	cm, err := NewCacheManager(db.DB(), filepath.Join(tmpDir, "cache"))
+	if err != nil {
+		t.Fatalf("failed to create cache manager: %v", err)
+	}
+
+	// A longer glob must not outrank a shorter literal path
+	cm.SetCachePolicy(ctx, "**/*.jpg", CachePolicyPreferEvict)
+	cm.SetCachePolicy(ctx, "a.jpg", CachePolicyKeep)
+	policies, err := cm.ListCachePolicies(ctx)
+	if err != nil {
+		t.Fatalf("failed to list policies: %v", err)
+	}
+	if mode := matchCachePolicy(policies, "a.jpg"); mode != CachePolicyKeep {
+		t.Errorf("expected the literal policy to win, got %s", mode)
+	}
+}
+
+func TestCacheManager_Quota(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	cm, err := NewCacheManager(db.DB(), filepath.Join(tmpDir, "cache"))
+	if err != nil {
+		t.Fatalf("failed to create cache manager: %v", err)
+	}
+
+	put := func(name string, days int) error {
+		entry := &model.Entry{Name: name, Type: model.EntryTypeFile, LogicalSize: 1024}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: name, Size: 1024, State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+		db.DB().ExecContext(ctx, `
+			INSERT INTO placements (version_id, provider_id, remote_path, state)
+			VALUES (?, 'gdrive', ?, 'verified')
+		`, v.ID, "gdrive:"+name)
+
+		src := filepath.Join(tmpDir, name)
+		os.WriteFile(src, make([]byte, 1024), 0644)
+		if _, err := cm.Put(ctx, entry.ID, v.ID, src); err != nil {
+			return err
+		}
+		accessed := time.Now().AddDate(0, 0, -days).Format(time.RFC3339)
+		db.DB().ExecContext(ctx, `UPDATE cache_entries SET last_accessed = ? WHERE entry_id = ?`, accessed, entry.ID)
+		return nil
+	}
+
+	if err := cm.SetQuota(ctx, &CacheQuota{QuotaBytes: 2 * 1024}); err != nil {
+		t.Fatalf("failed to set quota: %v", err)
+	}
+	put("a.bin", 30)
+	put("b.bin", 1)
+
+	usage, err := cm.Usage(ctx)
+	if err != nil {
+		t.Fatalf("failed to get usage: %v", err)
+	}
+	if usage.Used != 2*1024 || usage.Headroom != 0 {
+		t.Errorf("expected full cache, got used=%d headroom=%d", usage.Used, usage.Headroom)
+	}
+
+	// No auto-clean and no prompt: refuse
+	if err := put("c.bin", 0); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("expected ErrCacheFull, got %v", err)
+	}
+
+	// Declined prompt: refuse
+	var prompted int
+	cm.SetPressureHandler(func(ctx context.Context, p *CachePressure, plan *EvictionPlan) bool {
+		prompted++
+		return false
+	})
+	if err := put("d.bin", 0); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("expected ErrCacheFull after declined prompt, got %v", err)
+	}
+	if prompted != 1 {
+		t.Errorf("expected one prompt, got %d", prompted)
+	}
+
+	// Auto-clean evicts the least recently used entry without prompting
+	cm.SetQuota(ctx, &CacheQuota{QuotaBytes: 2 * 1024, AutoClean: true})
+	if err := put("e.bin", 0); err != nil {
+		t.Fatalf("expected auto-clean to make room: %v", err)
+	}
+	if prompted != 1 {
+		t.Errorf("auto-clean must not prompt, got %d prompts", prompted)
+	}
+
+	var remaining int
+	db.DB().QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM cache_entries ce JOIN entries e ON e.id = ce.entry_id WHERE e.name = 'a.bin'
+	`).Scan(&remaining)
+	if remaining != 0 {
+		t.Error("expected a.bin to be evicted")
+	}
+
+	// The entry being rehydrated ranks first but must be skipped, not block eviction
+	var bID int64
+	db.DB().QueryRowContext(ctx, `SELECT id FROM entries WHERE name = 'b.bin'`).Scan(&bID)
+	db.DB().ExecContext(ctx, `UPDATE cache_entries SET last_accessed = ? WHERE entry_id = ?`,
+		time.Now().AddDate(0, 0, -60).Format(time.RFC3339), bID)
+	if err := cm.EnsureSpace(ctx, bID, "", 1024); err != nil {
+		t.Fatalf("expected another entry to be evicted for the hydrating one: %v", err)
+	}
+	db.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM cache_entries WHERE entry_id = ?`, bID).Scan(&remaining)
+	if remaining != 1 {
+		t.Error("the hydrating entry must not be evicted")
+	}
+}
+
 func TestPlaceholderManager_CreateAndRead(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
 	if err != nil {
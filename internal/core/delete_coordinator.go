@@ -6,14 +6,19 @@
 // - All deletes journaled BEFORE execution
 // - Post-deletion verification required
 // - Partial failures downgrade placement state to 'degraded'
+// - Failed deletions are queued in pending_deletes until a retry succeeds
+// - An object still referenced by another placement is NEVER deleted
 package core
 
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"os/exec"
 	"sync"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
 )
 
 // DeleteSource typed enum for delete origins
@@ -22,7 +27,9 @@ type DeleteSource int
 const (
 	DeleteSourceTrashPurge DeleteSource = iota
 	DeleteSourceDestroy
-	DeleteSourceProviderRemove // Only with --delete-data flag
+	DeleteSourceProviderRemove  // Only with --delete-data flag
+	DeleteSourceGC              // Orphaned objects found by garbage collection
+	DeleteSourceReplicationTrim // Copies beyond a replication policy
 )
 
 func (s DeleteSource) String() string {
@@ -33,6 +40,10 @@ func (s DeleteSource) String() string {
 		return "destroy"
 	case DeleteSourceProviderRemove:
 		return "provider_remove"
+	case DeleteSourceGC:
+		return "gc"
+	case DeleteSourceReplicationTrim:
+		return "replication_trim"
 	default:
 		return "unknown"
 	}
@@ -77,20 +88,37 @@ type DeleteResult struct {
 	Failed  int
 	Errors  []error
 	// Partial failures downgrade placement state to 'degraded'
+	// and are queued in pending_deletes for retry.
+}
+
+// PendingDelete is a remote deletion that failed and awaits retry.
+type PendingDelete struct {
+	ID          int64
+	ProviderID  string
+	RemotePath  string
+	Size        int64
+	Source      string
+	Attempts    int
+	LastError   string
+	CreatedAt   time.Time
+	LastAttempt time.Time
 }
 
 // DeleteCoordinator centralizes ALL cloud deletion operations.
 type DeleteCoordinator struct {
-	db      *sql.DB
-	journal *JournalManager
-	mu      sync.Mutex
+	db       *sql.DB
+	journal  *JournalManager
+	registry provider.Registry
+	mu       sync.Mutex
 }
 
 // NewDeleteCoordinator creates a new delete coordinator.
-func NewDeleteCoordinator(db *sql.DB, journal *JournalManager) *DeleteCoordinator {
+// Providers are resolved from registry by placements.provider_id.
+func NewDeleteCoordinator(db *sql.DB, journal *JournalManager, registry provider.Registry) *DeleteCoordinator {
 	return &DeleteCoordinator{
-		db:      db,
-		journal: journal,
+		db:       db,
+		journal:  journal,
+		registry: registry,
 	}
 }
 
@@ -116,7 +144,26 @@ func (dc *DeleteCoordinator) Preview(ctx context.Context, req *DeleteRequest) (*
 	return preview, nil
 }
 
+// PreviewByProvider splits a request into one DeletePreview per provider.
+func (dc *DeleteCoordinator) PreviewByProvider(ctx context.Context, req *DeleteRequest) (map[string]*DeletePreview, error) {
+	grouped := make(map[string][]PlacementRef)
+	for _, p := range req.Placements {
+		grouped[p.ProviderID] = append(grouped[p.ProviderID], p)
+	}
+
+	previews := make(map[string]*DeletePreview, len(grouped))
+	for providerID, placements := range grouped {
+		preview, err := dc.Preview(ctx, &DeleteRequest{Placements: placements, Source: req.Source})
+		if err != nil {
+			return nil, err
+		}
+		previews[providerID] = preview
+	}
+	return previews, nil
+}
+
 // Execute performs the actual deletion with confirmation requirement.
+// Each object is journaled separately; failures are queued for retry.
 func (dc *DeleteCoordinator) Execute(ctx context.Context, req *DeleteRequest, confirmed bool) (*DeleteResult, error) {
 	if !confirmed {
 		return nil, fmt.Errorf("deletion requires explicit confirmation")
@@ -134,38 +181,187 @@ func (dc *DeleteCoordinator) Execute(ctx context.Context, req *DeleteRequest, co
 	}
 
 	for _, p := range req.Placements {
-		// RemotePath already contains full rclone path like "remote:/path/to/file"
-		// Just clean leading slash after the colon if needed
-		remotePath := p.RemotePath
-		
-		// Execute rclone deletefile
-		cmd := exec.CommandContext(ctx, "rclone", "deletefile", remotePath)
-		if output, err := cmd.CombinedOutput(); err != nil {
+		if err := dc.deleteOne(ctx, p, req.Source.String()); err != nil {
 			result.Failed++
-			result.Errors = append(result.Errors, fmt.Errorf("failed to delete %s from %s: %w (%s)", remotePath, p.ProviderID, err, string(output)))
-			dc.downgradePlacement(ctx, p.PlacementID)
+			result.Errors = append(result.Errors, err)
 			continue
 		}
+		result.Deleted++
+	}
 
-		// Verify deletion
-		cmd = exec.CommandContext(ctx, "rclone", "ls", remotePath)
-		output, _ := cmd.CombinedOutput()
-		if len(output) > 0 {
-			result.Failed++
-			result.Errors = append(result.Errors, fmt.Errorf("verification failed: %s still exists on %s", remotePath, p.ProviderID))
+	return result, nil
+}
+
+// deleteOne deletes a single remote object under its own journal entry.
+func (dc *DeleteCoordinator) deleteOne(ctx context.Context, p PlacementRef, source string) error {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"placement_id": p.PlacementID,
+		"version_id":   p.VersionID,
+		"provider":     p.ProviderID,
+		"remote_path":  p.RemotePath,
+		"source":       source,
+	})
+	opID, err := dc.journal.BeginOperation(ctx, "remote_delete", string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to begin journal: %w", err)
+	}
+
+	fail := func(err error) error {
+		dc.journal.RollbackOperation(ctx, opID, err.Error())
+		if p.PlacementID != 0 {
 			dc.downgradePlacement(ctx, p.PlacementID)
-			continue
 		}
+		dc.recordFailure(ctx, p, source, err)
+		return err
+	}
 
-		// Remove from placements table
-		_, err := dc.db.ExecContext(ctx, `DELETE FROM placements WHERE id = ?`, p.PlacementID)
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("warning: failed to remove placement record: %w", err))
+	// Versions and same-named files share remote paths, so the object
+	// goes only with the last placement that uses it
+	shared, err := dc.sharedObject(ctx, p)
+	if err != nil {
+		dc.journal.RollbackOperation(ctx, opID, err.Error())
+		return err
+	}
+	if shared {
+		if _, err := dc.db.ExecContext(ctx, `DELETE FROM placements WHERE id = ?`, p.PlacementID); err != nil {
+			dc.journal.RollbackOperation(ctx, opID, err.Error())
+			return fmt.Errorf("failed to remove placement record: %w", err)
 		}
+		dc.db.ExecContext(ctx, `
+			DELETE FROM pending_deletes WHERE provider_id = ? AND remote_path = ?
+		`, p.ProviderID, p.RemotePath)
+		dc.journal.CommitOperation(ctx, opID)
+		dc.journal.SyncOperation(ctx, opID)
+		return nil
+	}
 
-		result.Deleted++
+	var prov provider.Provider
+	ok := false
+	if dc.registry != nil {
+		prov, ok = dc.registry.Get(p.ProviderID)
+	}
+	if !ok {
+		return fail(fmt.Errorf("failed to delete %s: provider %s not available", p.RemotePath, p.ProviderID))
+	}
+
+	if err := prov.Delete(ctx, p.RemotePath); err != nil {
+		return fail(fmt.Errorf("failed to delete %s from %s: %w", p.RemotePath, p.ProviderID, err))
+	}
+
+	// Verify deletion
+	if vr, err := prov.Verify(ctx, p.RemotePath); err == nil && vr.IsValid {
+		return fail(fmt.Errorf("verification failed: %s still exists on %s", p.RemotePath, p.ProviderID))
 	}
 
+	// Remove from placements table and retry queue
+	if p.PlacementID != 0 {
+		if _, err := dc.db.ExecContext(ctx, `DELETE FROM placements WHERE id = ?`, p.PlacementID); err != nil {
+			dc.journal.RollbackOperation(ctx, opID, err.Error())
+			return fmt.Errorf("failed to remove placement record: %w", err)
+		}
+	}
+	dc.db.ExecContext(ctx, `
+		DELETE FROM pending_deletes WHERE provider_id = ? AND remote_path = ?
+	`, p.ProviderID, p.RemotePath)
+
+	dc.journal.CommitOperation(ctx, opID)
+	dc.journal.SyncOperation(ctx, opID)
+	return nil
+}
+
+// sharedObject reports whether a live placement other than p references
+// p's remote object. Paths are compared canonically, as gc does.
+func (dc *DeleteCoordinator) sharedObject(ctx context.Context, p PlacementRef) (bool, error) {
+	rows, err := dc.db.QueryContext(ctx, `
+		SELECT p.remote_path FROM placements p
+		LEFT JOIN chunks c ON c.id = p.chunk_id
+		JOIN versions v ON v.id = COALESCE(p.version_id, c.version_id)
+		JOIN entries e ON e.id = v.entry_id
+		WHERE p.provider_id = ? AND p.id != ?
+	`, p.ProviderID, p.PlacementID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check placements: %w", err)
+	}
+	defer rows.Close()
+
+	key := canonicalRemotePath(p.RemotePath)
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return false, fmt.Errorf("failed to scan placement: %w", err)
+		}
+		if canonicalRemotePath(path) == key {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// recordFailure queues a failed deletion for retry.
+func (dc *DeleteCoordinator) recordFailure(ctx context.Context, p PlacementRef, source string, cause error) {
+	dc.db.ExecContext(ctx, `
+		INSERT INTO pending_deletes (provider_id, remote_path, size, source, last_error)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(provider_id, remote_path) DO UPDATE SET
+			attempts = attempts + 1,
+			last_error = excluded.last_error,
+			last_attempt = datetime('now')
+	`, p.ProviderID, p.RemotePath, p.Size, source, cause.Error())
+}
+
+// ListPending returns remote deletions awaiting retry.
+func (dc *DeleteCoordinator) ListPending(ctx context.Context) ([]PendingDelete, error) {
+	rows, err := dc.db.QueryContext(ctx, `
+		SELECT id, provider_id, remote_path, size, source, attempts,
+		       COALESCE(last_error, ''), created_at, last_attempt
+		FROM pending_deletes
+		ORDER BY provider_id, created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending deletes: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []PendingDelete
+	for rows.Next() {
+		var pd PendingDelete
+		var createdAt, lastAttempt string
+		if err := rows.Scan(&pd.ID, &pd.ProviderID, &pd.RemotePath, &pd.Size, &pd.Source,
+			&pd.Attempts, &pd.LastError, &createdAt, &lastAttempt); err != nil {
+			return nil, fmt.Errorf("failed to scan pending delete: %w", err)
+		}
+		pd.CreatedAt = parseDBTime(createdAt)
+		pd.LastAttempt = parseDBTime(lastAttempt)
+		pending = append(pending, pd)
+	}
+	return pending, nil
+}
+
+// RetryPending retries every queued deletion.
+// Successful deletions leave the queue; failures bump their attempt count.
+func (dc *DeleteCoordinator) RetryPending(ctx context.Context, confirmed bool) (*DeleteResult, error) {
+	if !confirmed {
+		return nil, fmt.Errorf("deletion requires explicit confirmation")
+	}
+
+	pending, err := dc.ListPending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	result := &DeleteResult{Errors: make([]error, 0)}
+	for _, pd := range pending {
+		ref := PlacementRef{ProviderID: pd.ProviderID, RemotePath: pd.RemotePath, Size: pd.Size}
+		if err := dc.deleteOne(ctx, ref, pd.Source); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		result.Deleted++
+	}
 	return result, nil
 }
 
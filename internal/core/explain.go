@@ -53,12 +53,18 @@ type EntryExplanation struct {
 	
 	// Cache State
 	CacheState      *CacheStateInfo
+
+	// Hydration State
+	HydrationState  *HydrationStateInfo
 	
 	// Archive State
 	ArchiveState    *ArchiveStateInfo
 	
 	// Health Info
 	HealthInfo      *HealthStateInfo
+
+	// Replication State
+	ReplicationState *ReplicationStateInfo
 	
 	// Pending Operations
 	PendingOps      []PendingOpInfo
@@ -96,6 +102,15 @@ type CacheStateInfo struct {
 	State        string
 }
 
+// HydrationStateInfo describes hydration state, including partial chunks.
+type HydrationStateInfo struct {
+	State         string // placeholder, hydrating, hydrated, partial
+	Progress      int    // 0-100
+	ChunksPresent []int
+	ChunksTotal   int
+	BytesPresent  int64
+}
+
 // ArchiveStateInfo describes archive state.
 type ArchiveStateInfo struct {
 	IsArchived    bool
@@ -114,10 +129,21 @@ type HealthStateInfo struct {
 	ScoreDescription string
 	ReplicationCount int
 	LastVerified     *time.Time
+	Deductions       []HealthDeduction
 	Issues           []string
 	Recommendations  []string
 }
 
+// ReplicationStateInfo compares current copies with the replication policy.
+type ReplicationStateInfo struct {
+	Policy     *ReplicationPolicy // nil when no policy covers the entry
+	Required   int
+	Current    int // Verified copies counted by the policy
+	Verified   []string
+	Unverified []string
+	Degraded   []string
+}
+
 // TrashStateInfo describes trash state.
 type TrashStateInfo struct {
 	DeletedAt      time.Time
@@ -172,12 +198,18 @@ func (e *Explainer) Explain(ctx context.Context, path string) (*EntryExplanation
 	// Get cache state
 	exp.CacheState = e.getCacheState(ctx, entryID)
 
+	// Get hydration state
+	exp.HydrationState = e.getHydrationState(ctx, entryID)
+
 	// Get archive state
 	exp.ArchiveState = e.getArchiveState(ctx, entryID)
 
 	// Get health info
 	exp.HealthInfo = e.getHealthInfo(ctx, entryID)
 
+	// Get replication state
+	exp.ReplicationState = e.getReplicationState(ctx, entryID)
+
 	// Get pending operations
 	exp.PendingOps = e.getPendingOps(ctx, entryID)
 
@@ -301,6 +333,43 @@ func (e *Explainer) getCacheState(ctx context.Context, entryID int64) *CacheStat
 	return &state
 }
 
+func (e *Explainer) getHydrationState(ctx context.Context, entryID int64) *HydrationStateInfo {
+	state := &HydrationStateInfo{State: "placeholder"}
+	e.db.QueryRowContext(ctx, `
+		SELECT current_state, hydration_progress FROM hydration_state WHERE entry_id = ?
+	`, entryID).Scan(&state.State, &state.Progress)
+
+	var size int64
+	err := e.db.QueryRowContext(ctx, `
+		SELECT size FROM versions WHERE entry_id = ? AND state = 'active'
+	`, entryID).Scan(&size)
+	if err != nil {
+		return state
+	}
+	state.ChunksTotal = ChunkCount(size, DefaultChunkSize)
+
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT hc.chunk_index, hc.size
+		FROM hydrated_chunks hc
+		JOIN versions v ON hc.version_id = v.id
+		WHERE v.entry_id = ? AND v.state = 'active'
+		ORDER BY hc.chunk_index
+	`, entryID)
+	if err != nil {
+		return state
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idx int
+		var n int64
+		rows.Scan(&idx, &n)
+		state.ChunksPresent = append(state.ChunksPresent, idx)
+		state.BytesPresent += n
+	}
+
+	return state
+}
+
 func (e *Explainer) getArchiveState(ctx context.Context, entryID int64) *ArchiveStateInfo {
 	var state ArchiveStateInfo
 	var createdAt string
@@ -330,56 +399,66 @@ func (e *Explainer) getArchiveState(ctx context.Context, entryID int64) *Archive
 }
 
 func (e *Explainer) getHealthInfo(ctx context.Context, entryID int64) *HealthStateInfo {
+	hm := NewHealthManager(e.db)
+	h, err := hm.scoreEntry(ctx, hm.scoringModel(ctx), entryID, "")
+	if err != nil {
+		return nil
+	}
+
 	health := &HealthStateInfo{
-		Issues:          []string{},
-		Recommendations: []string{},
+		Score:            h.HealthScore,
+		ScoreDescription: GetHealthScoreDescription(h.HealthScore),
+		ReplicationCount: h.ReplicationCount,
+		LastVerified:     h.LastVerified,
+		Deductions:       h.Deductions,
+		Issues:           []string{},
+		Recommendations:  []string{},
 	}
+	health.Issues = append(health.Issues, h.Issues...)
+	health.Recommendations = append(health.Recommendations, h.Recommendations...)
 
-	// Count placements
-	e.db.QueryRowContext(ctx, `
-		SELECT COUNT(p.id)
-		FROM placements p
-		JOIN versions v ON p.version_id = v.id
-		WHERE v.entry_id = ? AND v.state = 'active'
-	`, entryID).Scan(&health.ReplicationCount)
+	return health
+}
 
-	// Get last verified
-	var lastVerified sql.NullString
-	e.db.QueryRowContext(ctx, `
-		SELECT MAX(p.last_verified)
-		FROM placements p
-		JOIN versions v ON p.version_id = v.id
-		WHERE v.entry_id = ? AND v.state = 'active'
-	`, entryID).Scan(&lastVerified)
-	if lastVerified.Valid {
-		t, _ := time.Parse(time.RFC3339, lastVerified.String)
-		health.LastVerified = &t
+func (e *Explainer) getReplicationState(ctx context.Context, entryID int64) *ReplicationStateInfo {
+	policies, err := listReplicationPolicies(ctx, e.db)
+	if err != nil {
+		return nil
+	}
+	paths, err := entryPaths(ctx, e.db)
+	if err != nil {
+		return nil
+	}
+	types, err := providerTypes(ctx, e.db)
+	if err != nil {
+		return nil
 	}
 
-	// Calculate score
-	health.Score = 1.0
-	if health.ReplicationCount == 0 {
-		health.Score = 0.2
-		health.Issues = append(health.Issues, "No provider placements")
-		health.Recommendations = append(health.Recommendations, "Run 'cloudfs push' to upload")
-	} else if health.ReplicationCount < 2 {
-		health.Score = 0.7
-		health.Issues = append(health.Issues, "Low replication (1 copy)")
-		health.Recommendations = append(health.Recommendations, "Add second provider for redundancy")
+	state := &ReplicationStateInfo{Policy: matchReplicationPolicy(policies, paths[entryID])}
+	policy := state.Policy
+	if policy == nil {
+		// Without a policy, report copies against the implicit single copy
+		policy = &ReplicationPolicy{MinCopies: 1}
 	}
 
-	if health.LastVerified == nil {
-		health.Score -= 0.3
-		if health.Score < 0 {
-			health.Score = 0
+	status, err := replicationStatus(ctx, e.db, entryID, paths[entryID], policy, types)
+	if err != nil || status == nil {
+		return nil
+	}
+	state.Required = status.Required
+	state.Current = status.Current
+	for _, c := range status.Copies {
+		switch c.State {
+		case "verified":
+			state.Verified = append(state.Verified, c.ProviderID)
+		case "pending", "uploaded":
+			state.Unverified = append(state.Unverified, c.ProviderID)
+		default:
+			state.Degraded = append(state.Degraded, c.ProviderID)
 		}
-		health.Issues = append(health.Issues, "Never verified with provider")
-		health.Recommendations = append(health.Recommendations, "Run 'cloudfs verify'")
 	}
 
-	health.ScoreDescription = GetHealthScoreDescription(health.Score)
-
-	return health
+	return state
 }
 
 func (e *Explainer) getPendingOps(ctx context.Context, entryID int64) []PendingOpInfo {
@@ -414,8 +493,11 @@ func (e *Explainer) getTrashState(ctx context.Context, entryID int64) (bool, *Tr
 
 	err := e.db.QueryRowContext(ctx, `
 		SELECT deleted_at, auto_purge_after
-		FROM trash WHERE original_entry_id = ?
-	`, entryID).Scan(&deletedAt, &autoPurge)
+		FROM trash
+		WHERE original_entry_id = ?
+		   OR id IN (SELECT trash_id FROM trash_items WHERE entry_id = ?)
+		LIMIT 1
+	`, entryID, entryID).Scan(&deletedAt, &autoPurge)
 	if err != nil {
 		return false, nil
 	}
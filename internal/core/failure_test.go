@@ -4,11 +4,16 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/faulty"
 )
 
 // TestJournalCrashDuringIndexMutation simulates a crash during index mutation.
@@ -345,3 +350,249 @@ func TestNoAutoHydrationOnAccess(t *testing.T) {
 		t.Error("real file should not exist - no auto-hydration allowed")
 	}
 }
+
+// TestHydrationTempRecovery simulates a crash mid-hydration.
+// Complete temp downloads are resumed; incomplete and orphaned ones are removed.
+func TestHydrationTempRecovery(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-failure-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	ctx := context.Background()
+
+	im, err := NewIndexManager(dbPath, "")
+	if err != nil {
+		t.Fatalf("failed to create index manager: %v", err)
+	}
+	defer im.Close()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	jm := NewJournalManager(db.DB())
+
+	cache, err := NewCacheManager(db.DB(), filepath.Join(tmpDir, "cache"))
+	if err != nil {
+		t.Fatalf("failed to create cache manager: %v", err)
+	}
+	hc := NewHydrationController(im, cache, nil, jm, nil, db.DB())
+
+	if hc.TempDir() != filepath.Join(tmpDir, "temp") {
+		t.Errorf("expected temp dir next to cache, got %s", hc.TempDir())
+	}
+
+	content := []byte("hydrated content")
+	mkVersion := func(name string) *model.Version {
+		entry := &model.Entry{Name: name, Type: model.EntryTypeFile, LogicalSize: int64(len(content))}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{
+			EntryID: entry.ID, VersionNum: 1, Size: int64(len(content)),
+			ContentHash: "pending", State: model.VersionStateActive,
+		}
+		im.CreateVersion(ctx, v)
+		return v
+	}
+
+	// Complete download: hash must match, so record the real hash
+	complete := mkVersion("complete.txt")
+	completeTemp, _ := hc.NewTempPath(complete.EntryID, complete.ID)
+	os.WriteFile(completeTemp, content, 0600)
+	hash, _ := calculateFileHash(completeTemp)
+	db.DB().ExecContext(ctx, `UPDATE versions SET content_hash = ? WHERE id = ?`, hash, complete.ID)
+	completeOp, _ := jm.BeginOperation(ctx, "hydrate",
+		fmt.Sprintf(`{"entry_id":%d,"version_id":%d,"temp_path":%q}`, complete.EntryID, complete.ID, completeTemp))
+
+	// Truncated download
+	partial := mkVersion("partial.txt")
+	partialTemp, _ := hc.NewTempPath(partial.EntryID, partial.ID)
+	os.WriteFile(partialTemp, content[:4], 0600)
+	partialOp, _ := jm.BeginOperation(ctx, "hydrate",
+		fmt.Sprintf(`{"entry_id":%d,"version_id":%d,"temp_path":%q}`, partial.EntryID, partial.ID, partialTemp))
+
+	// Orphan with no journal entry, plus a non-hydration file that must survive
+	orphan := filepath.Join(hc.TempDir(), "hydrate_99_99_1")
+	os.WriteFile(orphan, content, 0600)
+	ingest := filepath.Join(hc.TempDir(), "ingest_1_1")
+	os.WriteFile(ingest, content, 0600)
+
+	// A download still being written, possibly by another process
+	fresh := mkVersion("fresh.txt")
+	freshTemp, _ := hc.NewTempPath(fresh.EntryID, fresh.ID)
+	os.WriteFile(freshTemp, content[:4], 0600)
+	freshOp, _ := jm.BeginOperation(ctx, "hydrate",
+		fmt.Sprintf(`{"entry_id":%d,"version_id":%d,"temp_path":%q}`, fresh.EntryID, fresh.ID, freshTemp))
+	freshOrphan := filepath.Join(hc.TempDir(), "hydrate_98_98_1")
+	os.WriteFile(freshOrphan, content, 0600)
+
+	// Everything else was left behind by a crash long ago
+	stale := time.Now().Add(-2 * hydrateTempGrace)
+	for _, p := range []string{completeTemp, partialTemp, orphan, ingest} {
+		os.Chtimes(p, stale, stale)
+	}
+
+	result, err := hc.RecoverTempFiles(ctx)
+	if err != nil {
+		t.Fatalf("failed to recover temp files: %v", err)
+	}
+	if result.Resumed != 1 || result.RolledBack != 1 || result.Removed != 2 {
+		t.Errorf("unexpected recovery result: %+v", result)
+	}
+
+	if path, _ := cache.Get(ctx, complete.EntryID, complete.ID); path == "" {
+		t.Error("complete download should be promoted into cache")
+	}
+	for _, p := range []string{completeTemp, partialTemp, orphan} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("temp file should be gone: %s", p)
+		}
+	}
+	if _, err := os.Stat(ingest); err != nil {
+		t.Error("non-hydration temp files must not be touched")
+	}
+	for _, p := range []string{freshTemp, freshOrphan} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("in-flight temp file must survive: %s", p)
+		}
+	}
+
+	var state string
+	db.DB().QueryRowContext(ctx, `SELECT state FROM journal WHERE operation_id = ?`, completeOp).Scan(&state)
+	if state != "synced" {
+		t.Errorf("expected resumed operation to be synced, got %s", state)
+	}
+	db.DB().QueryRowContext(ctx, `SELECT state FROM journal WHERE operation_id = ?`, partialOp).Scan(&state)
+	if state != "rolled_back" {
+		t.Errorf("expected incomplete operation to be rolled back, got %s", state)
+	}
+	db.DB().QueryRowContext(ctx, `SELECT state FROM journal WHERE operation_id = ?`, freshOp).Scan(&state)
+	if state != "pending" {
+		t.Errorf("expected in-flight operation to stay pending, got %s", state)
+	}
+}
+
+// TestHydrationProviderFaults hydrates through a flaky backend. Every fault
+// must roll the journal back and leave the entry a placeholder with nothing
+// cached; once the backend recovers the same entry hydrates normally.
+func TestHydrationProviderFaults(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-failure-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	ctx := context.Background()
+
+	im, err := NewIndexManager(dbPath, "")
+	if err != nil {
+		t.Fatalf("failed to create index manager: %v", err)
+	}
+	defer im.Close()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	jm := NewJournalManager(db.DB())
+
+	cache, err := NewCacheManager(db.DB(), filepath.Join(tmpDir, "cache"))
+	if err != nil {
+		t.Fatalf("failed to create cache manager: %v", err)
+	}
+	pm, err := NewPlaceholderManager(filepath.Join(tmpDir, "root"))
+	if err != nil {
+		t.Fatalf("failed to create placeholder manager: %v", err)
+	}
+
+	content := []byte("quarterly numbers, do not lose")
+	src := filepath.Join(tmpDir, "report.pdf")
+	os.WriteFile(src, content, 0600)
+	hash, _ := calculateFileHash(src)
+
+	backend := newMemProvider("gdrive")
+	backend.data["gdrive:report.pdf"] = content
+	backend.objects["gdrive:report.pdf"] = int64(len(content))
+
+	entry := &model.Entry{Name: "report.pdf", Type: model.EntryTypeFile, LogicalSize: int64(len(content))}
+	im.CreateEntry(ctx, entry)
+	version := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: hash, Size: int64(len(content)), State: model.VersionStateActive}
+	im.CreateVersion(ctx, version)
+	db.DB().ExecContext(ctx, `
+		INSERT INTO placements (version_id, provider_id, remote_path, state)
+		VALUES (?, 'flaky', 'gdrive:report.pdf', 'uploaded')
+	`, version.ID)
+	pm.CreatePlaceholder(ctx, entry, version, "", "flaky", "gdrive:report.pdf")
+
+	hydrate := func(cfg faulty.Config, timeout time.Duration) error {
+		flaky, err := faulty.NewProvider("flaky", backend, cfg)
+		if err != nil {
+			t.Fatalf("failed to create faulty provider: %v", err)
+		}
+		registry := provider.NewRegistry()
+		registry.Register(flaky)
+		hc := NewHydrationController(im, cache, pm, jm, registry, db.DB())
+
+		hctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		_, err = hc.Hydrate(hctx, entry.ID, nil)
+		return err
+	}
+
+	faults := []struct {
+		name string
+		cfg  faulty.Config
+	}{
+		{"error", faulty.Config{ErrorRate: 1}},
+		{"hang", faulty.Config{HangRate: 1}},
+		{"truncate", faulty.Config{TruncateRate: 1, Seed: 7}},
+		{"corrupt", faulty.Config{CorruptRate: 1, Seed: 7}},
+	}
+	for i, f := range faults {
+		if err := hydrate(f.cfg, 100*time.Millisecond); err == nil {
+			t.Fatalf("%s: expected hydration to fail", f.name)
+		}
+
+		var rolledBack int
+		db.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM journal WHERE operation_type = 'hydrate' AND state = 'rolled_back'`).Scan(&rolledBack)
+		if rolledBack != i+1 {
+			t.Errorf("%s: expected %d rolled back hydrations, got %d", f.name, i+1, rolledBack)
+		}
+		var state string
+		db.DB().QueryRowContext(ctx, `SELECT current_state FROM hydration_state WHERE entry_id = ?`, entry.ID).Scan(&state)
+		if state != string(model.HydrationStatePlaceholder) {
+			t.Errorf("%s: expected placeholder state, got %q", f.name, state)
+		}
+		if cached, _ := cache.Get(ctx, entry.ID, version.ID); cached != "" {
+			t.Errorf("%s: damaged download reached the cache: %s", f.name, cached)
+		}
+	}
+
+	temps, _ := os.ReadDir(filepath.Join(tmpDir, "cache", "..", "temp"))
+	if len(temps) != 0 {
+		t.Errorf("expected failed downloads removed from temp, found %d files", len(temps))
+	}
+
+	// A hydration the cache quota rejects fails before any download starts
+	var journaled int
+	db.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM journal WHERE operation_type = 'hydrate'`).Scan(&journaled)
+	cache.SetQuota(ctx, &CacheQuota{QuotaBytes: int64(len(content)) - 1})
+	if err := hydrate(faulty.Config{}, 5*time.Second); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("expected ErrCacheFull, got %v", err)
+	}
+	var after int
+	db.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM journal WHERE operation_type = 'hydrate'`).Scan(&after)
+	if after != journaled {
+		t.Errorf("expected no download for a rejected hydration, got %d new operations", after-journaled)
+	}
+	cache.SetQuota(ctx, &CacheQuota{})
+
+	// The backend recovers
+	if err := hydrate(faulty.Config{}, 5*time.Second); err != nil {
+		t.Fatalf("expected hydration to succeed without faults: %v", err)
+	}
+	if got, _ := os.ReadFile(pm.GetRealPath(entry, "")); string(got) != string(content) {
+		t.Errorf("unexpected hydrated content %q", got)
+	}
+}
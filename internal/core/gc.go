@@ -0,0 +1,248 @@
+// Package core provides orphaned remote object garbage collection for CloudFS.
+// Based on design.txt Section 9: Deletion Authority.
+//
+// INVARIANTS:
+// - Scanning is READ-ONLY; only listed objects absent from placements are orphans
+// - Only remotes marked dedicated are scanned; shared remotes hold files CloudFS never wrote
+// - Objects newer than the grace period are never reported (uploads in flight)
+// - Deletion goes through DeleteCoordinator and requires explicit confirmation
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// DefaultGCGracePeriod protects objects that a push may still be recording.
+const DefaultGCGracePeriod = 24 * time.Hour
+
+// DedicatedConfigKey marks a provider whose remote holds only CloudFS data.
+const DedicatedConfigKey = "dedicated"
+
+// ErrNotDedicated is returned when scanning a remote that may hold files
+// CloudFS did not write; every one of them would look like an orphan.
+var ErrNotDedicated = errors.New("remote is not dedicated to CloudFS")
+
+// Orphan is a remote object with no placement referencing it.
+type Orphan struct {
+	ProviderID string
+	RemotePath string
+	Size       int64
+	ModTime    time.Time
+	Age        time.Duration
+	Pending    bool // Already queued in pending_deletes
+}
+
+// OrphanReport is the result of diffing a provider listing against placements.
+type OrphanReport struct {
+	ProviderID string
+	Scanned    int
+	Referenced int
+	Recent     int // Unreferenced objects younger than the grace period
+	Orphans    []Orphan
+	TotalSize  int64
+}
+
+// GarbageCollector finds remote objects that the index no longer references.
+type GarbageCollector struct {
+	db       *sql.DB
+	deleter  *DeleteCoordinator
+	registry provider.Registry
+}
+
+// NewGarbageCollector creates a new garbage collector.
+func NewGarbageCollector(db *sql.DB, deleter *DeleteCoordinator, registry provider.Registry) *GarbageCollector {
+	return &GarbageCollector{
+		db:       db,
+		deleter:  deleter,
+		registry: registry,
+	}
+}
+
+// IsDedicated reports whether a provider's remote is marked as holding
+// only CloudFS data.
+func IsDedicated(ctx context.Context, db *sql.DB, name string) (bool, error) {
+	var value string
+	err := db.QueryRowContext(ctx, `
+		SELECT pc.value FROM provider_config pc
+		JOIN providers p ON pc.provider_id = p.id
+		WHERE p.name = ? AND pc.key = ?
+	`, name, DedicatedConfigKey).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read provider config: %w", err)
+	}
+	dedicated, _ := strconv.ParseBool(value)
+	return dedicated, nil
+}
+
+// SetDedicated marks or unmarks a provider's remote as dedicated to CloudFS.
+func SetDedicated(ctx context.Context, db *sql.DB, name string, dedicated bool) error {
+	var providerID int64
+	if err := db.QueryRowContext(ctx, `SELECT id FROM providers WHERE name = ?`, name).Scan(&providerID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("provider not found: %s", name)
+		}
+		return fmt.Errorf("failed to look up provider: %w", err)
+	}
+
+	_, err := db.ExecContext(ctx, `DELETE FROM provider_config WHERE provider_id = ? AND key = ?`, providerID, DedicatedConfigKey)
+	if err == nil && dedicated {
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO provider_config (provider_id, key, value) VALUES (?, ?, 'true')
+		`, providerID, DedicatedConfigKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store dedicated flag: %w", err)
+	}
+	return nil
+}
+
+// FindOrphans lists every object on the provider's remote and reports those
+// without a placement. Objects modified within grace are skipped.
+// Returns ErrNotDedicated unless the remote is marked dedicated.
+func (gc *GarbageCollector) FindOrphans(ctx context.Context, providerID string, grace time.Duration) (*OrphanReport, error) {
+	dedicated, err := IsDedicated(ctx, gc.db, providerID)
+	if err != nil {
+		return nil, err
+	}
+	if !dedicated {
+		return nil, fmt.Errorf("%s: %w", providerID, ErrNotDedicated)
+	}
+
+	var prov provider.Provider
+	ok := false
+	if gc.registry != nil {
+		prov, ok = gc.registry.Get(providerID)
+	}
+	if !ok {
+		return nil, fmt.Errorf("provider %s not available", providerID)
+	}
+	lister, ok := provider.AsLister(prov)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support listing", providerID)
+	}
+
+	objects, err := lister.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", providerID, err)
+	}
+
+	referenced, err := gc.remotePaths(ctx, `SELECT remote_path FROM placements WHERE provider_id = ?`, providerID)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := gc.remotePaths(ctx, `SELECT remote_path FROM pending_deletes WHERE provider_id = ?`, providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &OrphanReport{ProviderID: providerID, Scanned: len(objects)}
+	for _, obj := range objects {
+		key := canonicalRemotePath(obj.Path)
+		if referenced[key] {
+			report.Referenced++
+			continue
+		}
+		age := now.Sub(obj.ModTime)
+		if !obj.ModTime.IsZero() && age < grace {
+			report.Recent++
+			continue
+		}
+		report.Orphans = append(report.Orphans, Orphan{
+			ProviderID: providerID,
+			RemotePath: obj.Path,
+			Size:       obj.Size,
+			ModTime:    obj.ModTime,
+			Age:        age,
+			Pending:    pending[key],
+		})
+		report.TotalSize += obj.Size
+	}
+
+	sort.Slice(report.Orphans, func(i, j int) bool {
+		return report.Orphans[i].RemotePath < report.Orphans[j].RemotePath
+	})
+	return report, nil
+}
+
+// Collect deletes the orphans in a report through the DeleteCoordinator.
+// Requires explicit user confirmation.
+func (gc *GarbageCollector) Collect(ctx context.Context, report *OrphanReport, confirmed bool) (*DeleteResult, error) {
+	if gc.deleter == nil {
+		return nil, fmt.Errorf("no delete coordinator configured")
+	}
+
+	req := &DeleteRequest{Source: DeleteSourceGC}
+	for _, o := range report.Orphans {
+		req.Placements = append(req.Placements, PlacementRef{
+			ProviderID: o.ProviderID,
+			RemotePath: o.RemotePath,
+			Size:       o.Size,
+		})
+	}
+	return gc.deleter.Execute(ctx, req, confirmed)
+}
+
+// remotePaths loads a set of canonical remote paths from a query.
+func (gc *GarbageCollector) remotePaths(ctx context.Context, query string, args ...interface{}) (map[string]bool, error) {
+	rows, err := gc.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load remote paths: %w", err)
+	}
+	defer rows.Close()
+
+	paths := make(map[string]bool)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("failed to scan remote path: %w", err)
+		}
+		paths[canonicalRemotePath(p)] = true
+	}
+	return paths, nil
+}
+
+// canonicalRemotePath normalizes "remote:/a//b" and "remote:a/b" to one form.
+func canonicalRemotePath(p string) string {
+	remote, rest := "", p
+	if i := strings.Index(p, ":"); i >= 0 {
+		remote, rest = p[:i+1], p[i+1:]
+	}
+	parts := strings.Split(rest, "/")
+	kept := parts[:0]
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return remote + strings.Join(kept, "/")
+}
+
+// ParseAge parses a duration that may use a "d" (days) suffix, e.g. "30d" or "12h".
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid age: %s", s)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age: %s", s)
+	}
+	return d, nil
+}
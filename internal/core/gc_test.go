@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestGarbageCollector_FindOrphans(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	journal := NewJournalManager(db.DB())
+
+	gdrive := newMemProvider("gdrive")
+	registry := provider.NewRegistry()
+	registry.Register(gdrive)
+
+	old := time.Now().Add(-72 * time.Hour)
+	for path, size := range map[string]int64{
+		"gdrive:cloudfs/kept.txt":   10,
+		"gdrive:cloudfs/orphan.bin": 200,
+		"gdrive:cloudfs/fresh.bin":  30,
+	} {
+		gdrive.objects[path] = size
+		gdrive.modTimes[path] = old
+	}
+	gdrive.modTimes["gdrive:cloudfs/fresh.bin"] = time.Now()
+
+	// Push stores "remote:/name"; the listing reports "remote:name"
+	db.DB().ExecContext(ctx, `
+		INSERT INTO placements (version_id, provider_id, remote_path, state)
+		VALUES (1, 'gdrive', 'gdrive:cloudfs//kept.txt', 'uploaded')
+	`)
+
+	gc := NewGarbageCollector(db.DB(), NewDeleteCoordinator(db.DB(), journal, registry), registry)
+	db.DB().ExecContext(ctx, `INSERT INTO providers (name, type) VALUES ('gdrive', 'rclone')`)
+	if _, err := gc.FindOrphans(ctx, "gdrive", DefaultGCGracePeriod); !errors.Is(err, ErrNotDedicated) {
+		t.Fatalf("expected shared remote to be refused, got %v", err)
+	}
+	if err := SetDedicated(ctx, db.DB(), "gdrive", true); err != nil {
+		t.Fatalf("failed to mark provider dedicated: %v", err)
+	}
+
+	report, err := gc.FindOrphans(ctx, "gdrive", DefaultGCGracePeriod)
+	if err != nil {
+		t.Fatalf("failed to find orphans: %v", err)
+	}
+	if report.Scanned != 3 || report.Referenced != 1 || report.Recent != 1 {
+		t.Errorf("unexpected counts: %+v", report)
+	}
+	if len(report.Orphans) != 1 || report.Orphans[0].RemotePath != "gdrive:cloudfs/orphan.bin" || report.TotalSize != 200 {
+		t.Fatalf("expected orphan.bin only, got %+v", report.Orphans)
+	}
+
+	if _, err := gc.Collect(ctx, report, false); err == nil {
+		t.Error("expected collect without confirmation to fail")
+	}
+	result, err := gc.Collect(ctx, report, true)
+	if err != nil || result.Deleted != 1 {
+		t.Fatalf("expected 1 deletion, got %+v (%v)", result, err)
+	}
+	if _, ok := gdrive.objects["gdrive:cloudfs/orphan.bin"]; ok {
+		t.Error("orphan still on provider")
+	}
+	if _, ok := gdrive.objects["gdrive:cloudfs/kept.txt"]; !ok {
+		t.Error("referenced object was deleted")
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range tests {
+		got, err := ParseAge(in)
+		if err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseAge("soon"); err == nil {
+		t.Error("expected error for invalid age")
+	}
+}
@@ -0,0 +1,341 @@
+// Package core provides last-resort replication for CloudFS.
+// Based on design.txt Section 7: Backend failure, and Section 18: Health scoring.
+//
+// INVARIANTS:
+// - Healing ONLY adds replicas; existing placements are never moved or deleted
+// - Planning is READ-ONLY; copies require explicit user confirmation
+// - Targets never include a provider that already holds the version
+// - Copied data is hash-verified against the index before upload
+// - Every copy is journaled; failures roll back and leave no placement
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// DefaultHealThreshold is the health score below which entries are healed.
+const DefaultHealThreshold = 0.5
+
+// HealSource is where the data for a new replica is copied from.
+type HealSource struct {
+	Kind       string // "cache" or "remote"
+	ProviderID string
+	RemotePath string
+	LocalPath  string
+}
+
+// HealAction is a planned extra replica for one entry.
+type HealAction struct {
+	EntryID     int64
+	Path        string
+	Score       float64
+	Issues      []string
+	VersionID   int64
+	Size        int64
+	ContentHash string
+	Holders     []string // Providers already holding the version
+	Source      *HealSource
+	Target      string // Provider receiving the new replica
+	TargetPath  string
+	Reason      string // Why the target was chosen, or why the entry cannot be healed
+}
+
+// HealPlan lists the replicas a heal run would create.
+type HealPlan struct {
+	Threshold  float64
+	Actions    []*HealAction
+	Unhealable []*HealAction
+	TotalBytes int64
+}
+
+// HealResult reports what a heal run did.
+type HealResult struct {
+	Healed int
+	Failed int
+	Bytes  int64
+	Errors []error
+}
+
+// Healer duplicates data for entries whose health has fallen below a threshold.
+type Healer struct {
+	db       *sql.DB
+	health   *HealthManager
+	planner  *PlacementPlanner
+	cache    *CacheManager
+	journal  *JournalManager
+	registry provider.Registry
+	tempDir  string
+}
+
+// NewHealer creates a new healer. cache may be nil, in which case
+// replicas are always copied from another provider.
+func NewHealer(db *sql.DB, health *HealthManager, planner *PlacementPlanner, cache *CacheManager,
+	journal *JournalManager, registry provider.Registry, tempDir string) *Healer {
+	return &Healer{
+		db:       db,
+		health:   health,
+		planner:  planner,
+		cache:    cache,
+		journal:  journal,
+		registry: registry,
+		tempDir:  tempDir,
+	}
+}
+
+// Plan selects every entry scoring below threshold and plans one extra
+// replica for each, copied from the healthiest available source.
+func (h *Healer) Plan(ctx context.Context, threshold float64) (*HealPlan, error) {
+	critical, err := h.health.GetCriticalEntries(ctx, threshold, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := entryPaths(ctx, h.db)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &HealPlan{Threshold: threshold}
+	for _, c := range critical {
+		action := &HealAction{
+			EntryID: c.EntryID,
+			Path:    paths[c.EntryID],
+			Score:   c.HealthScore,
+			Issues:  c.Issues,
+		}
+		if action.Path == "" {
+			action.Path = c.EntryName
+		}
+
+		if err := h.planAction(ctx, action); err != nil {
+			return nil, err
+		}
+		if action.Source == nil || action.Target == "" {
+			plan.Unhealable = append(plan.Unhealable, action)
+			continue
+		}
+		plan.Actions = append(plan.Actions, action)
+		plan.TotalBytes += action.Size
+	}
+	return plan, nil
+}
+
+// planAction fills in the source and target for a single entry.
+func (h *Healer) planAction(ctx context.Context, action *HealAction) error {
+	if err := h.planSource(ctx, action); err != nil {
+		return err
+	}
+	if action.Source == nil {
+		return nil
+	}
+
+	placement, err := h.planner.PlanReplica(ctx, filepath.Base(action.Path), action.Size, false, action.Holders)
+	if err != nil {
+		return fmt.Errorf("failed to plan replica: %w", err)
+	}
+	for _, p := range placement.Placements {
+		if _, ok := h.provider(p.ProviderName); !ok {
+			continue
+		}
+		action.Target = p.ProviderName
+		action.Reason = p.Reason
+		action.TargetPath, err = h.replicaPath(ctx, p.ProviderName, filepath.Base(action.Path))
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	action.Reason = placement.Reason
+	if action.Reason == "" {
+		action.Reason = "no registered provider can take another replica"
+	}
+	return nil
+}
+
+// planSource loads the active version of an entry, the providers already
+// holding it, and the healthiest copy to read from. Source is left nil
+// (with a Reason) when no usable copy exists.
+func (h *Healer) planSource(ctx context.Context, action *HealAction) error {
+	err := h.db.QueryRowContext(ctx, `
+		SELECT id, size, content_hash FROM versions
+		WHERE entry_id = ? AND state = 'active'
+		ORDER BY version_num DESC LIMIT 1
+	`, action.EntryID).Scan(&action.VersionID, &action.Size, &action.ContentHash)
+	if err == sql.ErrNoRows {
+		action.Reason = "no active version"
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get active version: %w", err)
+	}
+
+	// Healthiest remote copies first: verified, most recently checked
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT provider_id, remote_path, state FROM placements
+		WHERE version_id = ?
+		ORDER BY state = 'verified' DESC, verified_at IS NULL, verified_at DESC, id
+	`, action.VersionID)
+	if err != nil {
+		return fmt.Errorf("failed to get placements: %w", err)
+	}
+	var remotes []*HealSource
+	for rows.Next() {
+		var providerID, remotePath, state string
+		if err := rows.Scan(&providerID, &remotePath, &state); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan placement: %w", err)
+		}
+		action.Holders = append(action.Holders, providerID)
+		if state == "degraded" || state == "failed" {
+			continue
+		}
+		if _, ok := h.provider(providerID); ok {
+			remotes = append(remotes, &HealSource{Kind: "remote", ProviderID: providerID, RemotePath: remotePath})
+		}
+	}
+	rows.Close()
+
+	// A local cached copy avoids egress entirely
+	if h.cache != nil {
+		if cachePath, err := h.cache.Get(ctx, action.EntryID, action.VersionID); err == nil && cachePath != "" {
+			if info, err := os.Stat(cachePath); err == nil && info.Size() == action.Size {
+				action.Source = &HealSource{Kind: "cache", LocalPath: cachePath}
+			}
+		}
+	}
+	if action.Source == nil && len(remotes) > 0 {
+		action.Source = remotes[0]
+	}
+	if action.Source == nil {
+		action.Reason = "no healthy copy in cache or on any provider"
+	}
+	return nil
+}
+
+// Execute copies each planned replica and records its placement.
+// Requires explicit user confirmation.
+func (h *Healer) Execute(ctx context.Context, plan *HealPlan, confirmed bool) (*HealResult, error) {
+	if !confirmed {
+		return nil, fmt.Errorf("heal requires explicit user confirmation")
+	}
+
+	result := &HealResult{Errors: make([]error, 0)}
+	for _, action := range plan.Actions {
+		if err := h.copyReplica(ctx, "heal", action); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Errorf("%s: %w", action.Path, err))
+			continue
+		}
+		result.Healed++
+		result.Bytes += action.Size
+	}
+	return result, nil
+}
+
+// copyReplica copies one replica under its own journal entry of type opType.
+func (h *Healer) copyReplica(ctx context.Context, opType string, action *HealAction) error {
+	sourcePath := action.Source.RemotePath
+	if action.Source.Kind == "cache" {
+		sourcePath = action.Source.LocalPath
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"entry_id":    action.EntryID,
+		"version_id":  action.VersionID,
+		"source":      action.Source.Kind,
+		"source_path": sourcePath,
+		"provider":    action.Target,
+		"remote_path": action.TargetPath,
+	})
+	opID, err := h.journal.BeginOperation(ctx, opType, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to begin journal: %w", err)
+	}
+
+	fail := func(err error) error {
+		h.journal.RollbackOperation(ctx, opID, err.Error())
+		return err
+	}
+
+	target, ok := h.provider(action.Target)
+	if !ok {
+		return fail(fmt.Errorf("provider %s not available", action.Target))
+	}
+
+	localPath := action.Source.LocalPath
+	if action.Source.Kind == "remote" {
+		src, ok := h.provider(action.Source.ProviderID)
+		if !ok {
+			return fail(fmt.Errorf("provider %s not available", action.Source.ProviderID))
+		}
+		if err := os.MkdirAll(h.tempDir, 0700); err != nil {
+			return fail(fmt.Errorf("failed to create temp dir: %w", err))
+		}
+		localPath = filepath.Join(h.tempDir, fmt.Sprintf("%s_%d_%d", opType, action.VersionID, time.Now().UnixNano()))
+		defer os.Remove(localPath)
+
+		if _, err := src.Download(ctx, action.Source.RemotePath, localPath, nil); err != nil {
+			return fail(fmt.Errorf("failed to download from %s: %w", action.Source.ProviderID, err))
+		}
+	}
+
+	if action.ContentHash != "" {
+		hash, err := calculateFileHash(localPath)
+		if err != nil {
+			return fail(fmt.Errorf("failed to hash source: %w", err))
+		}
+		if !strings.EqualFold(hash, action.ContentHash) {
+			return fail(fmt.Errorf("source hash mismatch: expected %s, got %s", action.ContentHash, hash))
+		}
+	}
+
+	if _, err := target.Upload(ctx, localPath, action.TargetPath, nil); err != nil {
+		return fail(fmt.Errorf("failed to upload to %s: %w", action.Target, err))
+	}
+
+	_, err = h.db.ExecContext(ctx, `
+		INSERT INTO placements (version_id, provider_id, remote_path, state)
+		VALUES (?, ?, ?, 'uploaded')
+	`, action.VersionID, action.Target, action.TargetPath)
+	if err != nil {
+		return fail(fmt.Errorf("failed to record placement: %w", err))
+	}
+
+	h.journal.CommitOperation(ctx, opID)
+	h.journal.SyncOperation(ctx, opID)
+	return nil
+}
+
+// provider resolves a registered provider by name.
+func (h *Healer) provider(name string) (provider.Provider, bool) {
+	if h.registry == nil {
+		return nil, false
+	}
+	return h.registry.Get(name)
+}
+
+// replicaPath builds the remote path for a new replica, matching push.
+func (h *Healer) replicaPath(ctx context.Context, providerName, fileName string) (string, error) {
+	var remote sql.NullString
+	err := h.db.QueryRowContext(ctx, `
+		SELECT c.value FROM providers p
+		JOIN provider_config c ON c.provider_id = p.id AND c.key = 'remote'
+		WHERE p.name = ?
+	`, providerName).Scan(&remote)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get remote for %s: %w", providerName, err)
+	}
+	if !remote.Valid || remote.String == "" {
+		remote.String = providerName + ":"
+	}
+	return remote.String + "/" + fileName, nil
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestHealer_PlanAndExecute(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	journal := NewJournalManager(db.DB())
+
+	gdrive := newMemProvider("gdrive")
+	b2 := newMemProvider("b2")
+	registry := provider.NewRegistry()
+	registry.Register(gdrive)
+	registry.Register(b2)
+	for i, name := range []string{"gdrive", "b2"} {
+		db.DB().ExecContext(ctx, `INSERT INTO providers (name, type, priority) VALUES (?, 'memory', ?)`, name, i+1)
+	}
+
+	content := []byte("quarterly numbers")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	place := func(name, state string) {
+		entry := &model.Entry{Name: name, Type: model.EntryTypeFile, LogicalSize: int64(len(content))}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: hash, Size: int64(len(content)), State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+		db.DB().ExecContext(ctx, `
+			INSERT INTO placements (version_id, provider_id, remote_path, state)
+			VALUES (?, 'gdrive', ?, ?)
+		`, v.ID, "gdrive:"+name, state)
+		gdrive.objects["gdrive:"+name] = int64(len(content))
+		gdrive.data["gdrive:"+name] = content
+	}
+	place("report.xlsx", "uploaded") // single replica, never verified: 0.4
+	place("lost.bin", "degraded")    // no usable copy anywhere
+
+	healer := NewHealer(db.DB(), NewHealthManager(db.DB()), NewPlacementPlanner(db.DB()), nil, journal, registry, filepath.Join(tmpDir, "temp"))
+	plan, err := healer.Plan(ctx, DefaultHealThreshold)
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+
+	if len(plan.Actions) != 1 || len(plan.Unhealable) != 1 {
+		t.Fatalf("expected 1 action and 1 unhealable, got %d/%d", len(plan.Actions), len(plan.Unhealable))
+	}
+	a := plan.Actions[0]
+	if a.Path != "report.xlsx" || a.Source.Kind != "remote" || a.Source.ProviderID != "gdrive" || a.Target != "b2" || a.TargetPath != "b2:/report.xlsx" {
+		t.Errorf("unexpected heal action: %+v source=%+v", a, a.Source)
+	}
+	if plan.Unhealable[0].Path != "lost.bin" {
+		t.Errorf("expected lost.bin unhealable, got %+v", plan.Unhealable[0])
+	}
+
+	if _, err := healer.Execute(ctx, plan, false); err == nil {
+		t.Error("expected heal without confirmation to fail")
+	}
+	result, err := healer.Execute(ctx, plan, true)
+	if err != nil || result.Healed != 1 || result.Failed != 0 {
+		t.Fatalf("expected 1 replica, got %+v (%v)", result, err)
+	}
+	if string(b2.data["b2:/report.xlsx"]) != string(content) {
+		t.Error("replica not uploaded to b2")
+	}
+
+	var replicas int
+	db.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM placements WHERE version_id = ?`, a.VersionID).Scan(&replicas)
+	if replicas != 2 {
+		t.Errorf("expected 2 placements after heal, got %d", replicas)
+	}
+
+	// Corrupt source data is never copied
+	gdrive.data["gdrive:report.xlsx"] = []byte("tampered")
+	a.Target, a.TargetPath = "b2", "b2:/again.xlsx"
+	result, _ = healer.Execute(ctx, &HealPlan{Actions: []*HealAction{a}}, true)
+	if result.Failed != 1 {
+		t.Errorf("expected hash mismatch failure, got %+v", result)
+	}
+	if _, ok := b2.data["b2:/again.xlsx"]; ok {
+		t.Error("corrupt data was uploaded")
+	}
+}
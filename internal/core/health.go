@@ -3,6 +3,7 @@
 //
 // INVARIANTS:
 // - Health scoring is OBSERVATIONAL only
+// - Scores come from the HealthModel (see health_model.go)
 // - NO automatic remediation
 // - NO auto-replication
 // - NO data movement
@@ -13,14 +14,17 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 // HealthManager provides health scoring visibility.
 type HealthManager struct {
-	db *sql.DB
-	mu sync.RWMutex
+	db    *sql.DB
+	model HealthModel // nil uses the weighted model with configured weights
+	mu    sync.RWMutex
 }
 
 // NewHealthManager creates a new health manager.
@@ -36,6 +40,7 @@ type EntryHealth struct {
 	ReplicationCount  int
 	LastVerified      *time.Time
 	VerificationAge   int // days since last verification
+	Deductions        []HealthDeduction
 	Issues            []string
 	Recommendations   []string
 }
@@ -95,35 +100,22 @@ func (hm *HealthManager) GetOverallHealth(ctx context.Context) (*OverallHealth,
 	}
 
 	// Calculate health categories
-	// Healthy = has placement, verified recently
-	// Warning = missing verification or low replication
-	// Critical = no placement at all
-	
-	// Get placement counts per entry
-	rows, err := hm.db.QueryContext(ctx, `
-		SELECT 
-			v.entry_id,
-			COUNT(p.id) as placement_count,
-			MAX(p.verified_at) as last_verified
-		FROM versions v
-		LEFT JOIN placements p ON v.id = p.version_id
-		WHERE v.state = 'active'
-		GROUP BY v.entry_id
-	`)
+	// Healthy = score >= 0.8
+	// Warning = score >= 0.5
+	// Critical = anything lower
+	entryIDs, err := activeFileEntries(ctx, hm.db)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get health data: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
+	model := hm.scoringModel(ctx)
 	var scoreSum float64
-	for rows.Next() {
-		var entryID int64
-		var placementCount int
-		var lastVerified sql.NullString
-
-		rows.Scan(&entryID, &placementCount, &lastVerified)
-
-		score := calculateHealthScore(placementCount, lastVerified)
+	for _, entryID := range entryIDs {
+		in, err := gatherHealthInputs(ctx, hm.db, entryID)
+		if err != nil {
+			return nil, err
+		}
+		score, _ := model.Score(in)
 		scoreSum += score
 
 		if score >= 0.8 {
@@ -144,40 +136,57 @@ func (hm *HealthManager) GetOverallHealth(ctx context.Context) (*OverallHealth,
 	return health, nil
 }
 
-// calculateHealthScore computes a health score for an entry.
-func calculateHealthScore(replicationCount int, lastVerified sql.NullString) float64 {
-	score := 1.0
+// activeFileEntries returns the IDs of file entries with an active version.
+func activeFileEntries(ctx context.Context, db *sql.DB) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT e.id FROM entries e
+		JOIN versions v ON e.id = v.entry_id AND v.state = 'active'
+		WHERE e.entry_type = 'file'
+		ORDER BY e.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entries: %w", err)
+	}
+	defer rows.Close()
 
-	// No placements = critical
-	if replicationCount == 0 {
-		return 0.2
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan entry: %w", err)
+		}
+		ids = append(ids, id)
 	}
+	return ids, rows.Err()
+}
 
-	// Low replication
-	if replicationCount < 2 {
-		score -= 0.2
+// scoreEntry scores one entry and explains every deduction.
+func (hm *HealthManager) scoreEntry(ctx context.Context, model HealthModel, entryID int64, entryName string) (*EntryHealth, error) {
+	in, err := gatherHealthInputs(ctx, hm.db, entryID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Verification age
-	if lastVerified.Valid {
-		verifiedTime, err := time.Parse(time.RFC3339, lastVerified.String)
-		if err == nil {
-			daysSinceVerified := int(time.Since(verifiedTime).Hours() / 24)
-			if daysSinceVerified > 30 {
-				score -= 0.3
-			} else if daysSinceVerified > 7 {
-				score -= 0.1
-			}
-		}
-	} else {
-		// Never verified
-		score -= 0.4
+	health := &EntryHealth{
+		EntryID:          entryID,
+		EntryName:        entryName,
+		ReplicationCount: in.Replicas,
+		LastVerified:     in.LastVerified,
+	}
+	if in.LastVerified != nil {
+		health.VerificationAge = int(time.Since(*in.LastVerified).Hours() / 24)
 	}
 
-	if score < 0 {
-		score = 0
+	health.HealthScore, health.Deductions = model.Score(in)
+	seen := make(map[string]bool)
+	for _, d := range health.Deductions {
+		health.Issues = append(health.Issues, d.Reason)
+		if rec := healthRecommendation(d); rec != "" && !seen[rec] {
+			seen[rec] = true
+			health.Recommendations = append(health.Recommendations, rec)
+		}
 	}
-	return score
+	return health, nil
 }
 
 // GetEntryHealth returns the health status of a specific entry.
@@ -197,66 +206,25 @@ func (hm *HealthManager) GetEntryHealth(ctx context.Context, entryID int64) (*En
 		return nil, fmt.Errorf("failed to get entry: %w", err)
 	}
 
-	health := &EntryHealth{
-		EntryID:   entryID,
-		EntryName: entryName,
-	}
-
-	// Count placements
-	err = hm.db.QueryRowContext(ctx, `
-		SELECT COUNT(p.id)
-		FROM versions v
-		JOIN placements p ON v.id = p.version_id
-		WHERE v.entry_id = ? AND v.state = 'active'
-	`, entryID).Scan(&health.ReplicationCount)
-	if err != nil {
-		health.ReplicationCount = 0
-	}
-
-	// Get last verified time
-	var lastVerified sql.NullString
-	err = hm.db.QueryRowContext(ctx, `
-		SELECT MAX(p.verified_at)
-		FROM versions v
-		JOIN placements p ON v.id = p.version_id
-		WHERE v.entry_id = ? AND v.state = 'active'
-	`, entryID).Scan(&lastVerified)
-	if err == nil && lastVerified.Valid {
-		t, _ := time.Parse(time.RFC3339, lastVerified.String)
-		health.LastVerified = &t
-		health.VerificationAge = int(time.Since(t).Hours() / 24)
-	}
-
-	// Calculate score
-	health.HealthScore = calculateHealthScore(health.ReplicationCount, lastVerified)
-
-	// Generate issues and recommendations
-	if health.ReplicationCount == 0 {
-		health.Issues = append(health.Issues, "No provider placements found")
-		health.Recommendations = append(health.Recommendations, "Run 'cloudfs push' to upload to provider")
-	} else if health.ReplicationCount < 2 {
-		health.Issues = append(health.Issues, "Low replication count (1)")
-		health.Recommendations = append(health.Recommendations, "Consider adding a second provider for redundancy")
-	}
-
-	if health.LastVerified == nil {
-		health.Issues = append(health.Issues, "Never verified with provider")
-		health.Recommendations = append(health.Recommendations, "Run 'cloudfs verify' to check provider data")
-	} else if health.VerificationAge > 30 {
-		health.Issues = append(health.Issues, fmt.Sprintf("Not verified in %d days", health.VerificationAge))
-		health.Recommendations = append(health.Recommendations, "Run 'cloudfs verify' to refresh verification")
-	}
-
-	return health, nil
+	return hm.scoreEntry(ctx, hm.scoringModel(ctx), entryID, entryName)
 }
 
 // GetHealthByPath returns health for an entry by its path.
 func (hm *HealthManager) GetHealthByPath(ctx context.Context, path string) (*EntryHealth, error) {
-	hm.mu.RLock()
-	defer hm.mu.RUnlock()
+	indexed, err := listIndexedPaths(ctx, hm.db)
+	if err != nil {
+		return nil, err
+	}
+	path = strings.Join(splitPath(path), "/")
+	for _, ip := range indexed {
+		if ip.path == path {
+			return hm.GetEntryHealth(ctx, ip.id)
+		}
+	}
 
+	// Fall back to a bare name lookup
 	var entryID int64
-	err := hm.db.QueryRowContext(ctx, `
+	err = hm.db.QueryRowContext(ctx, `
 		SELECT id FROM entries WHERE name = ?
 	`, path).Scan(&entryID)
 	if err == sql.ErrNoRows {
@@ -265,50 +233,43 @@ func (hm *HealthManager) GetHealthByPath(ctx context.Context, path string) (*Ent
 	if err != nil {
 		return nil, fmt.Errorf("failed to find entry: %w", err)
 	}
-
-	hm.mu.RUnlock()
-	defer hm.mu.RLock()
 	return hm.GetEntryHealth(ctx, entryID)
 }
 
-// GetCriticalEntries returns entries with critical health issues.
-func (hm *HealthManager) GetCriticalEntries(ctx context.Context, limit int) ([]*EntryHealth, error) {
+// GetCriticalEntries returns entries whose health score is below threshold,
+// lowest score first. Degraded or failed placements do not count as replicas.
+// A limit <= 0 returns every matching entry.
+func (hm *HealthManager) GetCriticalEntries(ctx context.Context, threshold float64, limit int) ([]*EntryHealth, error) {
 	hm.mu.RLock()
 	defer hm.mu.RUnlock()
 
-	if limit <= 0 {
-		limit = 10
-	}
-
-	// Find entries with no placements
-	rows, err := hm.db.QueryContext(ctx, `
-		SELECT e.id, e.name
-		FROM entries e
-		JOIN versions v ON e.id = v.entry_id AND v.state = 'active'
-		LEFT JOIN placements p ON v.id = p.version_id
-		WHERE e.entry_type = 'file'
-		GROUP BY e.id
-		HAVING COUNT(p.id) = 0
-		LIMIT ?
-	`, limit)
+	entryIDs, err := activeFileEntries(ctx, hm.db)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find critical entries: %w", err)
 	}
-	defer rows.Close()
 
+	model := hm.scoringModel(ctx)
 	var results []*EntryHealth
-	for rows.Next() {
-		var id int64
+	for _, entryID := range entryIDs {
 		var name string
-		rows.Scan(&id, &name)
-		results = append(results, &EntryHealth{
-			EntryID:     id,
-			EntryName:   name,
-			HealthScore: 0.2,
-			Issues:      []string{"No provider placements"},
-		})
+		if err := hm.db.QueryRowContext(ctx, `SELECT name FROM entries WHERE id = ?`, entryID).Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to get entry: %w", err)
+		}
+		h, err := hm.scoreEntry(ctx, model, entryID, name)
+		if err != nil {
+			return nil, err
+		}
+		if h.HealthScore < threshold {
+			results = append(results, h)
+		}
 	}
 
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].HealthScore < results[j].HealthScore
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
 	return results, nil
 }
 
@@ -0,0 +1,403 @@
+// Package core provides the health scoring model for CloudFS.
+// Based on design.txt Section 18: Health scoring.
+//
+// INVARIANTS:
+// - Scores are COMPUTED from index state; no provider calls while scoring
+// - Every point deducted is explained by a HealthDeduction
+// - Weights scale deductions only; a score is always within [0, 1]
+// - Scoring is OBSERVATIONAL; remediation stays with heal, repair and replicate
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// Health score factors (design Section 18 inputs).
+const (
+	HealthFactorReplication  = "replication"
+	HealthFactorVerification = "verification"
+	HealthFactorParity       = "parity"
+	HealthFactorProvider     = "provider"
+	HealthFactorPlacement    = "placement"
+	HealthFactorIndex        = "index"
+)
+
+// metaHealthWeights stores the configured HealthWeights as JSON in index_meta.
+const metaHealthWeights = "health_weights"
+
+// HealthInputs are the observations a HealthModel scores.
+type HealthInputs struct {
+	Replicas         int        // Usable placements (not degraded or failed)
+	DegradedReplicas int        // Degraded or failed placements
+	LastVerified     *time.Time // Most recent verification of a usable placement
+	Archived         bool
+	ParityAvailable  bool              // PAR2 recovery files present for the archive
+	ProviderStates   map[string]string // health_state of each provider holding a usable replica
+	IndexIssues      []string          // Index inconsistencies found for the entry
+}
+
+// HealthDeduction explains one reduction of a health score.
+type HealthDeduction struct {
+	Factor string  `json:"factor"`
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+// HealthModel computes a health score from observations.
+// Implementations must return a score in [0, 1].
+type HealthModel interface {
+	Score(in *HealthInputs) (float64, []HealthDeduction)
+}
+
+// HealthWeights scales the deduction of each factor. 1.0 is the default
+// impact, 0 ignores the factor entirely.
+type HealthWeights struct {
+	Replication  float64 `json:"replication"`
+	Verification float64 `json:"verification"`
+	Parity       float64 `json:"parity"`
+	Provider     float64 `json:"provider"`
+	Placement    float64 `json:"placement"`
+	Index        float64 `json:"index"`
+}
+
+// DefaultHealthWeights applies every factor at its base impact.
+var DefaultHealthWeights = HealthWeights{
+	Replication:  1.0,
+	Verification: 1.0,
+	Parity:       1.0,
+	Provider:     1.0,
+	Placement:    1.0,
+	Index:        1.0,
+}
+
+// Set updates the weight of a single factor.
+func (w *HealthWeights) Set(factor string, value float64) error {
+	if value < 0 {
+		return fmt.Errorf("weight for %s must not be negative", factor)
+	}
+	switch factor {
+	case HealthFactorReplication:
+		w.Replication = value
+	case HealthFactorVerification:
+		w.Verification = value
+	case HealthFactorParity:
+		w.Parity = value
+	case HealthFactorProvider:
+		w.Provider = value
+	case HealthFactorPlacement:
+		w.Placement = value
+	case HealthFactorIndex:
+		w.Index = value
+	default:
+		return fmt.Errorf("unknown health factor: %s", factor)
+	}
+	return nil
+}
+
+// WeightedHealthModel is the default model: a fixed deduction per finding,
+// scaled by a per-factor weight.
+type WeightedHealthModel struct {
+	Weights HealthWeights
+}
+
+// NewWeightedHealthModel creates a weighted health model.
+func NewWeightedHealthModel(weights HealthWeights) *WeightedHealthModel {
+	return &WeightedHealthModel{Weights: weights}
+}
+
+// Score implements HealthModel.
+func (m *WeightedHealthModel) Score(in *HealthInputs) (float64, []HealthDeduction) {
+	var deductions []HealthDeduction
+	deduct := func(factor string, weight, amount float64, reason string) {
+		if weight*amount > 0 {
+			deductions = append(deductions, HealthDeduction{Factor: factor, Amount: weight * amount, Reason: reason})
+		}
+	}
+
+	// Replication count
+	switch {
+	case in.Replicas == 0:
+		deduct(HealthFactorReplication, m.Weights.Replication, 0.8, "No usable provider placements")
+	case in.Replicas == 1 && in.ParityAvailable:
+		deduct(HealthFactorReplication, m.Weights.Replication, 0.1, "Low replication count (1), partly covered by PAR2 parity")
+	case in.Replicas == 1:
+		deduct(HealthFactorReplication, m.Weights.Replication, 0.2, "Low replication count (1)")
+	}
+
+	// Verification freshness; meaningless without a copy to verify
+	if in.Replicas > 0 {
+		if in.LastVerified == nil {
+			deduct(HealthFactorVerification, m.Weights.Verification, 0.4, "Never verified with provider")
+		} else if days := int(time.Since(*in.LastVerified).Hours() / 24); days > 30 {
+			deduct(HealthFactorVerification, m.Weights.Verification, 0.3, fmt.Sprintf("Not verified in %d days", days))
+		} else if days > 7 {
+			deduct(HealthFactorVerification, m.Weights.Verification, 0.1, fmt.Sprintf("Not verified in %d days", days))
+		}
+	}
+
+	// Parity availability
+	if in.Archived && !in.ParityAvailable {
+		deduct(HealthFactorParity, m.Weights.Parity, 0.2, "Archive PAR2 recovery files missing")
+	}
+
+	// Backend health of providers holding usable copies
+	names := make([]string, 0, len(in.ProviderStates))
+	for name := range in.ProviderStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch in.ProviderStates[name] {
+		case "degraded":
+			deduct(HealthFactorProvider, m.Weights.Provider, 0.1, fmt.Sprintf("Provider %s is degraded", name))
+		case "unavailable":
+			deduct(HealthFactorProvider, m.Weights.Provider, 0.25, fmt.Sprintf("Provider %s is unavailable", name))
+		}
+	}
+
+	// Placement states
+	if in.DegradedReplicas > 0 {
+		amount := 0.15 * float64(in.DegradedReplicas)
+		if amount > 0.3 {
+			amount = 0.3
+		}
+		deduct(HealthFactorPlacement, m.Weights.Placement, amount, fmt.Sprintf("%d degraded placements", in.DegradedReplicas))
+	}
+
+	// Index consistency
+	for _, issue := range in.IndexIssues {
+		deduct(HealthFactorIndex, m.Weights.Index, 0.1, issue)
+	}
+
+	score := 1.0
+	for _, d := range deductions {
+		score -= d.Amount
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score, deductions
+}
+
+// providerStateScore converts a provider health_state to a 0-1 score.
+func providerStateScore(state string) float64 {
+	switch state {
+	case "degraded":
+		return 0.5
+	case "unavailable":
+		return 0
+	default:
+		return 1
+	}
+}
+
+// ProviderHealthScore averages the provider states of usable replicas.
+// Returns nil when no replica is held by a known provider.
+func (in *HealthInputs) ProviderHealthScore() *float64 {
+	if len(in.ProviderStates) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, state := range in.ProviderStates {
+		sum += providerStateScore(state)
+	}
+	score := sum / float64(len(in.ProviderStates))
+	return &score
+}
+
+// gatherHealthInputs collects every scoring input for one entry from the index.
+func gatherHealthInputs(ctx context.Context, db *sql.DB, entryID int64) (*HealthInputs, error) {
+	in := &HealthInputs{ProviderStates: make(map[string]string)}
+
+	var lastVerified sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(CASE WHEN p.state NOT IN ('degraded', 'failed') THEN p.id END),
+		       COUNT(CASE WHEN p.state IN ('degraded', 'failed') THEN p.id END),
+		       MAX(CASE WHEN p.state NOT IN ('degraded', 'failed') THEN p.verified_at END)
+		FROM versions v
+		JOIN placements p ON v.id = p.version_id
+		WHERE v.entry_id = ? AND v.state = 'active'
+	`, entryID).Scan(&in.Replicas, &in.DegradedReplicas, &lastVerified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count placements: %w", err)
+	}
+	if lastVerified.Valid {
+		t := parseDBTime(lastVerified.String)
+		in.LastVerified = &t
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT pr.name, COALESCE(pr.health_state, 'healthy')
+		FROM versions v
+		JOIN placements p ON v.id = p.version_id
+		JOIN providers pr ON pr.name = p.provider_id
+		WHERE v.entry_id = ? AND v.state = 'active' AND p.state NOT IN ('degraded', 'failed')
+	`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider health: %w", err)
+	}
+	for rows.Next() {
+		var name, state string
+		if err := rows.Scan(&name, &state); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan provider health: %w", err)
+		}
+		in.ProviderStates[name] = state
+	}
+	rows.Close()
+
+	var par2Path string
+	err = db.QueryRowContext(ctx, `
+		SELECT par2_path FROM archives WHERE entry_id = ?
+		ORDER BY created_at DESC LIMIT 1
+	`, entryID).Scan(&par2Path)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get archive: %w", err)
+	}
+	if err == nil {
+		in.Archived = true
+		if _, statErr := os.Stat(par2Path); statErr == nil {
+			in.ParityAvailable = true
+		}
+	}
+
+	in.IndexIssues, err = entryIndexIssues(ctx, db, entryID)
+	if err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// entryIndexIssues runs the scanner's index consistency checks for one entry.
+func entryIndexIssues(ctx context.Context, db *sql.DB, entryID int64) ([]string, error) {
+	var issues []string
+
+	var active, missingHash int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(CASE WHEN content_hash IS NULL OR content_hash = '' THEN 1 END)
+		FROM versions WHERE entry_id = ? AND state = 'active'
+	`, entryID).Scan(&active, &missingHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check versions: %w", err)
+	}
+	if active == 0 {
+		issues = append(issues, "No active version in index")
+	}
+	if missingHash > 0 {
+		issues = append(issues, "Active version has no content hash")
+	}
+
+	// Journal payloads are JSON objects with sorted keys
+	var pending int
+	err = db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM journal
+		WHERE state IN ('pending', 'committed')
+		AND (payload LIKE ? OR payload LIKE ?)
+	`, fmt.Sprintf("%%\"entry_id\":%d,%%", entryID), fmt.Sprintf("%%\"entry_id\":%d}%%", entryID)).Scan(&pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check journal: %w", err)
+	}
+	if pending > 0 {
+		issues = append(issues, fmt.Sprintf("%d unfinished journal operations", pending))
+	}
+
+	return issues, nil
+}
+
+// GetWeights returns the configured health weights, or the defaults.
+func (hm *HealthManager) GetWeights(ctx context.Context) (HealthWeights, error) {
+	weights := DefaultHealthWeights
+	var value string
+	err := hm.db.QueryRowContext(ctx, `SELECT value FROM index_meta WHERE key = ?`, metaHealthWeights).Scan(&value)
+	if err == sql.ErrNoRows {
+		return weights, nil
+	}
+	if err != nil {
+		return weights, fmt.Errorf("failed to read health weights: %w", err)
+	}
+	if err := json.Unmarshal([]byte(value), &weights); err != nil {
+		return DefaultHealthWeights, fmt.Errorf("invalid health weights: %w", err)
+	}
+	return weights, nil
+}
+
+// SetWeights stores the health weights.
+func (hm *HealthManager) SetWeights(ctx context.Context, weights HealthWeights) error {
+	value, _ := json.Marshal(weights)
+	_, err := hm.db.ExecContext(ctx, `
+		INSERT INTO index_meta (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, metaHealthWeights, string(value))
+	if err != nil {
+		return fmt.Errorf("failed to store health weights: %w", err)
+	}
+	return nil
+}
+
+// ResetWeights restores the default health weights.
+func (hm *HealthManager) ResetWeights(ctx context.Context) error {
+	if _, err := hm.db.ExecContext(ctx, `DELETE FROM index_meta WHERE key = ?`, metaHealthWeights); err != nil {
+		return fmt.Errorf("failed to reset health weights: %w", err)
+	}
+	return nil
+}
+
+// RecordProviderHealth stores the observed health_state of a provider.
+func (hm *HealthManager) RecordProviderHealth(ctx context.Context, name string, state provider.HealthState) error {
+	_, err := hm.db.ExecContext(ctx, `
+		UPDATE providers SET health_state = ?, last_health_check = ?
+		WHERE name = ?
+	`, string(state), time.Now().UTC().Format(time.RFC3339), name)
+	if err != nil {
+		return fmt.Errorf("failed to record provider health: %w", err)
+	}
+	return nil
+}
+
+// SetModel replaces the scoring model. A nil model restores the weighted
+// model using the configured weights.
+func (hm *HealthManager) SetModel(m HealthModel) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.model = m
+}
+
+// scoringModel returns the installed model or the configured weighted model.
+func (hm *HealthManager) scoringModel(ctx context.Context) HealthModel {
+	if hm.model != nil {
+		return hm.model
+	}
+	weights, err := hm.GetWeights(ctx)
+	if err != nil {
+		weights = DefaultHealthWeights
+	}
+	return NewWeightedHealthModel(weights)
+}
+
+// healthRecommendation suggests a command for a deduction.
+func healthRecommendation(d HealthDeduction) string {
+	switch d.Factor {
+	case HealthFactorReplication:
+		return "Run 'cloudfs heal' or 'cloudfs replicate' to add a replica"
+	case HealthFactorVerification:
+		return "Run 'cloudfs scrub' to check provider data"
+	case HealthFactorParity:
+		return "Recreate the archive with 'cloudfs archive create'"
+	case HealthFactorProvider:
+		return "Check the provider with 'cloudfs provider status'"
+	case HealthFactorPlacement:
+		return "Run 'cloudfs heal' or 'cloudfs replicate' to replace degraded copies"
+	case HealthFactorIndex:
+		return "Run 'cloudfs scan index' and 'cloudfs journal resume'"
+	default:
+		return ""
+	}
+}
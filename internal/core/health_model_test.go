@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestWeightedHealthModel_Score(t *testing.T) {
+	verified := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		weights HealthWeights
+		in      HealthInputs
+		want    float64
+		factors []string
+	}{
+		{"no placements", DefaultHealthWeights, HealthInputs{}, 0.2, []string{HealthFactorReplication}},
+		{"single unverified", DefaultHealthWeights, HealthInputs{Replicas: 1}, 0.4, []string{HealthFactorReplication, HealthFactorVerification}},
+		{"healthy", DefaultHealthWeights, HealthInputs{Replicas: 2, LastVerified: &verified}, 1.0, nil},
+		{"parity offsets replication", DefaultHealthWeights, HealthInputs{Replicas: 1, LastVerified: &verified, Archived: true, ParityAvailable: true}, 0.9, []string{HealthFactorReplication}},
+		{"missing parity", DefaultHealthWeights, HealthInputs{Replicas: 2, LastVerified: &verified, Archived: true}, 0.8, []string{HealthFactorParity}},
+		{"provider unavailable", DefaultHealthWeights, HealthInputs{Replicas: 2, LastVerified: &verified, ProviderStates: map[string]string{"b2": "unavailable", "gdrive": "healthy"}}, 0.75, []string{HealthFactorProvider}},
+		{"degraded placement and index issue", DefaultHealthWeights, HealthInputs{Replicas: 2, LastVerified: &verified, DegradedReplicas: 1, IndexIssues: []string{"1 unfinished journal operations"}}, 0.75, []string{HealthFactorPlacement, HealthFactorIndex}},
+		{"weighted provider", HealthWeights{Replication: 1, Verification: 0, Provider: 2}, HealthInputs{Replicas: 1, ProviderStates: map[string]string{"b2": "degraded"}}, 0.6, []string{HealthFactorReplication, HealthFactorProvider}},
+	}
+
+	for _, tt := range tests {
+		score, deductions := NewWeightedHealthModel(tt.weights).Score(&tt.in)
+		if math.Abs(score-tt.want) > 1e-9 {
+			t.Errorf("%s: expected score %.2f, got %.2f (%+v)", tt.name, tt.want, score, deductions)
+		}
+		if len(deductions) != len(tt.factors) {
+			t.Errorf("%s: expected %d deductions, got %+v", tt.name, len(tt.factors), deductions)
+			continue
+		}
+		for i, d := range deductions {
+			if d.Factor != tt.factors[i] || d.Reason == "" {
+				t.Errorf("%s: unexpected deduction %d: %+v", tt.name, i, d)
+			}
+		}
+	}
+}
+
+func TestHealthManager_EntryDeductions(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	for _, name := range []string{"gdrive", "b2"} {
+		db.DB().ExecContext(ctx, `INSERT INTO providers (name, type) VALUES (?, 'rclone')`, name)
+	}
+
+	entry := &model.Entry{Name: "thesis.pdf", Type: model.EntryTypeFile, LogicalSize: 10}
+	im.CreateEntry(ctx, entry)
+	v := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: "abc", Size: 10, State: model.VersionStateActive}
+	im.CreateVersion(ctx, v)
+	verifiedAt := time.Now().UTC().Format(time.RFC3339)
+	for _, p := range []struct{ provider, state string }{{"gdrive", "verified"}, {"b2", "verified"}, {"gdrive", "degraded"}} {
+		db.DB().ExecContext(ctx, `
+			INSERT INTO placements (version_id, provider_id, remote_path, state, verified_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, v.ID, p.provider, p.provider+":/thesis.pdf."+p.state, p.state, verifiedAt)
+	}
+	db.DB().ExecContext(ctx, `
+		INSERT INTO archives (entry_id, archive_path, par2_path, original_size, archive_size, content_hash, recovery_level)
+		VALUES (?, ?, ?, 10, 8, 'abc', 10)
+	`, entry.ID, filepath.Join(tmpDir, "thesis.tar.zst"), filepath.Join(tmpDir, "thesis.par2"))
+
+	hm := NewHealthManager(db.DB())
+	if err := hm.RecordProviderHealth(ctx, "b2", provider.HealthStateDegraded); err != nil {
+		t.Fatalf("failed to record provider health: %v", err)
+	}
+
+	health, err := hm.GetHealthByPath(ctx, "thesis.pdf")
+	if err != nil {
+		t.Fatalf("failed to get health: %v", err)
+	}
+	// parity 0.2 + provider 0.1 + placement 0.15
+	if math.Abs(health.HealthScore-0.55) > 1e-9 || health.ReplicationCount != 2 {
+		t.Errorf("expected score 0.55 with 2 replicas, got %.2f/%d: %+v", health.HealthScore, health.ReplicationCount, health.Deductions)
+	}
+	factors := map[string]bool{}
+	for _, d := range health.Deductions {
+		factors[d.Factor] = true
+	}
+	for _, f := range []string{HealthFactorParity, HealthFactorProvider, HealthFactorPlacement} {
+		if !factors[f] {
+			t.Errorf("expected a %s deduction, got %+v", f, health.Deductions)
+		}
+	}
+	if len(health.Recommendations) != 3 {
+		t.Errorf("expected one recommendation per factor, got %v", health.Recommendations)
+	}
+
+	// Parity restored and provider weight disabled
+	os.WriteFile(filepath.Join(tmpDir, "thesis.par2"), []byte("par2"), 0600)
+	weights := DefaultHealthWeights
+	weights.Set(HealthFactorProvider, 0)
+	if err := weights.Set("popularity", 1); err == nil {
+		t.Error("expected unknown factor to be rejected")
+	}
+	if err := hm.SetWeights(ctx, weights); err != nil {
+		t.Fatalf("failed to set weights: %v", err)
+	}
+	if got, _ := hm.GetWeights(ctx); got != weights {
+		t.Errorf("expected stored weights %+v, got %+v", weights, got)
+	}
+	health, _ = hm.GetEntryHealth(ctx, entry.ID)
+	if math.Abs(health.HealthScore-0.85) > 1e-9 {
+		t.Errorf("expected score 0.85, got %.2f: %+v", health.HealthScore, health.Deductions)
+	}
+
+	hm.ResetWeights(ctx)
+	if got, _ := hm.GetWeights(ctx); got != DefaultHealthWeights {
+		t.Errorf("expected default weights after reset, got %+v", got)
+	}
+}
@@ -0,0 +1,386 @@
+// Package core provides health and usage history for CloudFS.
+// Based on design.txt Section 18: Health scoring, and Section 8: Provider Selection.
+//
+// INVARIANTS:
+// - Recording is command-triggered; there is no background sampler
+// - History is OBSERVATIONAL; placement always uses live GetUsage()
+// - A provider whose usage cannot be read is skipped, never recorded as zero
+// - Forecasts are linear extrapolations of recorded samples only
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// Usage history scopes.
+const (
+	UsageScopeProvider = "provider"
+	UsageScopeCache    = "cache"
+)
+
+// HealthSnapshot is a recorded repository-wide health measurement.
+type HealthSnapshot struct {
+	MeasuredAt   time.Time
+	TotalEntries int
+	Healthy      int
+	Warning      int
+	Critical     int
+	AverageScore float64
+}
+
+// UsageSample is a recorded usage measurement of a provider or the cache.
+type UsageSample struct {
+	MeasuredAt time.Time
+	Scope      string
+	Name       string
+	UsedBytes  int64
+	TotalBytes int64 // 0 when unknown
+}
+
+// HistoryRecord reports what a single Record call stored.
+type HistoryRecord struct {
+	Health  *HealthSnapshot
+	Usage   []UsageSample
+	Skipped map[string]error // Providers whose usage could not be read
+}
+
+// UsageForecast extrapolates usage growth towards a provider's limits.
+type UsageForecast struct {
+	Scope        string
+	Name         string
+	Samples      int
+	Since        time.Time // First sample used
+	Current      int64     // Most recent sample
+	GrowthPerDay float64   // Bytes per day, least squares over the samples
+	SoftLimit    int64     // 0 = none
+	HardLimit    int64     // 0 = none
+	TotalBytes   int64     // Provider capacity, 0 = unknown
+	SoftLimitAt  *time.Time
+	HardLimitAt  *time.Time
+	FullAt       *time.Time
+}
+
+// HistoryManager records and reads health and usage history.
+type HistoryManager struct {
+	db       *sql.DB
+	registry provider.Registry
+	cache    *CacheManager
+}
+
+// NewHistoryManager creates a new history manager. registry and cache may be
+// nil, in which case provider or cache usage is not recorded.
+func NewHistoryManager(db *sql.DB, registry provider.Registry, cache *CacheManager) *HistoryManager {
+	return &HistoryManager{
+		db:       db,
+		registry: registry,
+		cache:    cache,
+	}
+}
+
+// Record stores the current overall health, the live usage of every
+// configured provider and the cache size.
+func (hm *HistoryManager) Record(ctx context.Context) (*HistoryRecord, error) {
+	now := time.Now().UTC()
+	record := &HistoryRecord{Skipped: make(map[string]error)}
+
+	overall, err := NewHealthManager(hm.db).GetOverallHealth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record.Health = &HealthSnapshot{
+		MeasuredAt:   now,
+		TotalEntries: overall.TotalEntries,
+		Healthy:      overall.HealthyEntries,
+		Warning:      overall.WarningEntries,
+		Critical:     overall.CriticalEntries,
+		AverageScore: overall.AverageScore,
+	}
+	_, err = hm.db.ExecContext(ctx, `
+		INSERT INTO health_history (measured_at, total_entries, healthy, warning, critical, average_score)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, now.Format(time.RFC3339), overall.TotalEntries, overall.HealthyEntries,
+		overall.WarningEntries, overall.CriticalEntries, overall.AverageScore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record health history: %w", err)
+	}
+
+	names, err := hm.providerNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		var prov provider.Provider
+		ok := false
+		if hm.registry != nil {
+			prov, ok = hm.registry.Get(name)
+		}
+		if !ok {
+			record.Skipped[name] = fmt.Errorf("provider not registered")
+			continue
+		}
+		usage, err := prov.GetUsage(ctx)
+		if err != nil {
+			record.Skipped[name] = err
+			continue
+		}
+
+		sample := UsageSample{MeasuredAt: now, Scope: UsageScopeProvider, Name: name, UsedBytes: usage.UsedBytes, TotalBytes: usage.TotalBytes}
+		if err := hm.recordUsage(ctx, sample); err != nil {
+			return nil, err
+		}
+		// Keep the cached scalar in step with the latest sample
+		hm.db.ExecContext(ctx, `UPDATE providers SET current_usage = ? WHERE name = ?`, usage.UsedBytes, name)
+		record.Usage = append(record.Usage, sample)
+	}
+
+	if hm.cache != nil {
+		usage, err := hm.cache.Usage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sample := UsageSample{MeasuredAt: now, Scope: UsageScopeCache, Name: UsageScopeCache, UsedBytes: usage.Used, TotalBytes: usage.Quota.QuotaBytes}
+		if err := hm.recordUsage(ctx, sample); err != nil {
+			return nil, err
+		}
+		record.Usage = append(record.Usage, sample)
+	}
+
+	return record, nil
+}
+
+// recordUsage stores a single usage sample.
+func (hm *HistoryManager) recordUsage(ctx context.Context, s UsageSample) error {
+	var total sql.NullInt64
+	if s.TotalBytes > 0 {
+		total = sql.NullInt64{Int64: s.TotalBytes, Valid: true}
+	}
+	_, err := hm.db.ExecContext(ctx, `
+		INSERT INTO usage_history (measured_at, scope, name, used_bytes, total_bytes)
+		VALUES (?, ?, ?, ?, ?)
+	`, s.MeasuredAt.UTC().Format(time.RFC3339), s.Scope, s.Name, s.UsedBytes, total)
+	if err != nil {
+		return fmt.Errorf("failed to record usage for %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+// HealthHistory returns repository health snapshots since a point in time, oldest first.
+func (hm *HistoryManager) HealthHistory(ctx context.Context, since time.Time) ([]HealthSnapshot, error) {
+	rows, err := hm.db.QueryContext(ctx, `
+		SELECT measured_at, total_entries, healthy, warning, critical, average_score
+		FROM health_history WHERE measured_at >= ?
+		ORDER BY measured_at, id
+	`, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read health history: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []HealthSnapshot
+	for rows.Next() {
+		var s HealthSnapshot
+		var measuredAt string
+		if err := rows.Scan(&measuredAt, &s.TotalEntries, &s.Healthy, &s.Warning, &s.Critical, &s.AverageScore); err != nil {
+			return nil, fmt.Errorf("failed to scan health history: %w", err)
+		}
+		s.MeasuredAt = parseDBTime(measuredAt)
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// EntryHealthHistory returns the health_metrics recorded for one entry
+// (by scrub) since a point in time, oldest first.
+func (hm *HistoryManager) EntryHealthHistory(ctx context.Context, entryID int64, since time.Time) ([]model.HealthMetric, error) {
+	// measured_at is written by SQLite datetime(), so compare in that format
+	rows, err := hm.db.QueryContext(ctx, `
+		SELECT id, measured_at, replication_count, verification_age_days,
+		       parity_available, provider_health_score, overall_score
+		FROM health_metrics WHERE entry_id = ? AND measured_at >= ?
+		ORDER BY measured_at, id
+	`, entryID, since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entry health history: %w", err)
+	}
+	defer rows.Close()
+
+	var metrics []model.HealthMetric
+	for rows.Next() {
+		m := model.HealthMetric{EntryID: &entryID}
+		var measuredAt string
+		var ageDays sql.NullInt64
+		var providerScore sql.NullFloat64
+		if err := rows.Scan(&m.ID, &measuredAt, &m.ReplicationCount, &ageDays,
+			&m.ParityAvailable, &providerScore, &m.OverallScore); err != nil {
+			return nil, fmt.Errorf("failed to scan entry health history: %w", err)
+		}
+		m.MeasuredAt = parseDBTime(measuredAt)
+		if ageDays.Valid {
+			days := int(ageDays.Int64)
+			m.VerificationAgeDays = &days
+		}
+		if providerScore.Valid {
+			m.ProviderHealthScore = &providerScore.Float64
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// UsageHistory returns usage samples for one provider or the cache since a
+// point in time, oldest first.
+func (hm *HistoryManager) UsageHistory(ctx context.Context, scope, name string, since time.Time) ([]UsageSample, error) {
+	rows, err := hm.db.QueryContext(ctx, `
+		SELECT measured_at, used_bytes, COALESCE(total_bytes, 0)
+		FROM usage_history WHERE scope = ? AND name = ? AND measured_at >= ?
+		ORDER BY measured_at, id
+	`, scope, name, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage history: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []UsageSample
+	for rows.Next() {
+		s := UsageSample{Scope: scope, Name: name}
+		var measuredAt string
+		if err := rows.Scan(&measuredAt, &s.UsedBytes, &s.TotalBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan usage history: %w", err)
+		}
+		s.MeasuredAt = parseDBTime(measuredAt)
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// UsageNames returns every provider or cache name with recorded usage.
+func (hm *HistoryManager) UsageNames(ctx context.Context, scope string) ([]string, error) {
+	rows, err := hm.db.QueryContext(ctx, `
+		SELECT DISTINCT name FROM usage_history WHERE scope = ? ORDER BY name
+	`, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage history: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan usage history: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Forecast estimates when a provider reaches its soft limit, hard limit and
+// capacity at the growth rate of its samples since a point in time.
+// Fewer than two samples yield a forecast with no growth.
+func (hm *HistoryManager) Forecast(ctx context.Context, scope, name string, since time.Time) (*UsageForecast, error) {
+	samples, err := hm.UsageHistory(ctx, scope, name, since)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &UsageForecast{Scope: scope, Name: name, Samples: len(samples)}
+	if len(samples) == 0 {
+		return f, nil
+	}
+	last := samples[len(samples)-1]
+	f.Since = samples[0].MeasuredAt
+	f.Current = last.UsedBytes
+	f.TotalBytes = last.TotalBytes
+	f.GrowthPerDay = growthPerDay(samples)
+
+	if scope == UsageScopeProvider {
+		var soft, hard sql.NullInt64
+		err := hm.db.QueryRowContext(ctx, `
+			SELECT soft_limit, hard_limit FROM providers WHERE name = ?
+		`, name).Scan(&soft, &hard)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to get limits for %s: %w", name, err)
+		}
+		f.SoftLimit = soft.Int64
+		f.HardLimit = hard.Int64
+	}
+
+	f.SoftLimitAt = reachAt(last, f.GrowthPerDay, f.SoftLimit)
+	f.HardLimitAt = reachAt(last, f.GrowthPerDay, f.HardLimit)
+	f.FullAt = reachAt(last, f.GrowthPerDay, f.TotalBytes)
+	return f, nil
+}
+
+// growthPerDay fits used bytes against time by least squares.
+func growthPerDay(samples []UsageSample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	origin := samples[0].MeasuredAt
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.MeasuredAt.Sub(origin).Hours() / 24
+		y := float64(s.UsedBytes)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+// forecastHorizon bounds forecasts; beyond it a date means nothing, and
+// time.Duration overflows after about 292 years.
+const forecastHorizon = 100 * 365 * 24 * time.Hour
+
+// reachAt returns when usage reaches limit at the given growth, measured from
+// the latest sample. Returns nil for no limit, no growth, or a date beyond
+// forecastHorizon; a limit already reached returns the sample time.
+func reachAt(last UsageSample, perDay float64, limit int64) *time.Time {
+	if limit <= 0 {
+		return nil
+	}
+	if last.UsedBytes >= limit {
+		t := last.MeasuredAt
+		return &t
+	}
+	if perDay <= 0 {
+		return nil
+	}
+	days := float64(limit-last.UsedBytes) / perDay
+	if days*float64(24*time.Hour) > float64(forecastHorizon) {
+		return nil
+	}
+	t := last.MeasuredAt.Add(time.Duration(days * float64(24*time.Hour)))
+	return &t
+}
+
+// providerNames returns every configured provider name.
+func (hm *HistoryManager) providerNames(ctx context.Context) ([]string, error) {
+	rows, err := hm.db.QueryContext(ctx, `SELECT name FROM providers ORDER BY priority, name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list providers: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan provider: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
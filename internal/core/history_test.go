@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestHistoryManager_Record(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	gdrive := newMemProvider("gdrive")
+	gdrive.objects["gdrive:/a.bin"] = 1000
+	registry := provider.NewRegistry()
+	registry.Register(gdrive)
+	for _, name := range []string{"gdrive", "b2"} {
+		db.DB().ExecContext(ctx, `INSERT INTO providers (name, type) VALUES (?, 'rclone')`, name)
+	}
+
+	entry := &model.Entry{Name: "a.bin", Type: model.EntryTypeFile, LogicalSize: 1000}
+	im.CreateEntry(ctx, entry)
+
+	hist := NewHistoryManager(db.DB(), registry, nil)
+	record, err := hist.Record(ctx)
+	if err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	if record.Health.TotalEntries != 1 {
+		t.Errorf("expected 1 entry in health snapshot, got %+v", record.Health)
+	}
+	// b2 is configured but not registered, so it must be skipped rather than recorded as zero
+	if len(record.Usage) != 1 || record.Usage[0].Name != "gdrive" || record.Usage[0].UsedBytes != 1000 {
+		t.Errorf("unexpected usage samples: %+v", record.Usage)
+	}
+	if _, ok := record.Skipped["b2"]; !ok {
+		t.Errorf("expected b2 to be skipped, got %v", record.Skipped)
+	}
+
+	var current int64
+	db.DB().QueryRowContext(ctx, `SELECT current_usage FROM providers WHERE name = 'gdrive'`).Scan(&current)
+	if current != 1000 {
+		t.Errorf("expected current_usage 1000, got %d", current)
+	}
+
+	since := time.Now().Add(-time.Hour)
+	snapshots, _ := hist.HealthHistory(ctx, since)
+	if len(snapshots) != 1 || snapshots[0].TotalEntries != 1 {
+		t.Errorf("expected 1 health snapshot, got %+v", snapshots)
+	}
+	if samples, _ := hist.UsageHistory(ctx, UsageScopeProvider, "b2", since); len(samples) != 0 {
+		t.Errorf("expected no b2 samples, got %+v", samples)
+	}
+
+	db.DB().ExecContext(ctx, `
+		INSERT INTO health_metrics (entry_id, replication_count, parity_available, overall_score)
+		VALUES (?, 1, 0, 0.4)
+	`, entry.ID)
+	metrics, err := hist.EntryHealthHistory(ctx, entry.ID, since)
+	if err != nil || len(metrics) != 1 || metrics[0].OverallScore != 0.4 || metrics[0].VerificationAgeDays != nil {
+		t.Errorf("unexpected entry health history: %+v (%v)", metrics, err)
+	}
+}
+
+func TestHistoryManager_Forecast(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	db.DB().ExecContext(ctx, `
+		INSERT INTO providers (name, type, soft_limit, hard_limit) VALUES ('gdrive', 'rclone', 1500, 2000)
+	`)
+
+	hist := NewHistoryManager(db.DB(), nil, nil)
+	start := time.Now().UTC().Add(-10 * 24 * time.Hour).Truncate(time.Second)
+	// 100 bytes/day for ten days, ending at 1000 bytes of 5000
+	for day := 0; day <= 10; day++ {
+		hist.recordUsage(ctx, UsageSample{
+			MeasuredAt: start.Add(time.Duration(day) * 24 * time.Hour),
+			Scope:      UsageScopeProvider,
+			Name:       "gdrive",
+			UsedBytes:  int64(day * 100),
+			TotalBytes: 5000,
+		})
+	}
+
+	f, err := hist.Forecast(ctx, UsageScopeProvider, "gdrive", start.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("failed to forecast: %v", err)
+	}
+	if f.Samples != 11 || f.Current != 1000 || math.Abs(f.GrowthPerDay-100) > 1e-6 {
+		t.Fatalf("unexpected forecast: %+v", f)
+	}
+
+	last := start.Add(10 * 24 * time.Hour)
+	for label, tc := range map[string]struct {
+		at   *time.Time
+		days int
+	}{
+		"soft": {f.SoftLimitAt, 5},
+		"hard": {f.HardLimitAt, 10},
+		"full": {f.FullAt, 40},
+	} {
+		if tc.at == nil {
+			t.Errorf("expected %s limit forecast", label)
+			continue
+		}
+		if got := tc.at.Sub(last); got != time.Duration(tc.days)*24*time.Hour {
+			t.Errorf("expected %s limit in %d days, got %v", label, tc.days, got)
+		}
+	}
+
+	// A shrinking provider never reaches its limits
+	hist.recordUsage(ctx, UsageSample{MeasuredAt: last.Add(24 * time.Hour), Scope: UsageScopeProvider, Name: "gdrive", UsedBytes: 0})
+	f, _ = hist.Forecast(ctx, UsageScopeProvider, "gdrive", last.Add(-time.Hour))
+	if f.GrowthPerDay >= 0 || f.SoftLimitAt != nil || f.FullAt != nil {
+		t.Errorf("expected no forecast for shrinking usage, got %+v", f)
+	}
+
+	// Near-zero growth puts the limit centuries out, past what a Duration holds
+	if at := reachAt(UsageSample{MeasuredAt: last, UsedBytes: 1000}, 1e-6, 1<<40); at != nil {
+		t.Errorf("expected no forecast beyond the horizon, got %v", at)
+	}
+}
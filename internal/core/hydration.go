@@ -15,6 +15,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -31,6 +32,7 @@ type HydrationController struct {
 	journal     *JournalManager
 	registry    provider.Registry
 	db          *sql.DB
+	tempDir     string // Staging area on the same filesystem as the cache
 	mu          sync.Mutex
 }
 
@@ -60,7 +62,7 @@ func NewHydrationController(
 	registry provider.Registry,
 	db *sql.DB,
 ) *HydrationController {
-	return &HydrationController{
+	hc := &HydrationController{
 		index:       index,
 		cache:       cache,
 		placeholder: placeholder,
@@ -68,6 +70,27 @@ func NewHydrationController(
 		registry:    registry,
 		db:          db,
 	}
+	// Stage downloads next to the cache (<repo>/.cloudfs/temp) so the
+	// final move into cache is a same-filesystem rename.
+	if cache != nil {
+		hc.tempDir = filepath.Join(filepath.Dir(cache.CacheDir()), "temp")
+	}
+	return hc
+}
+
+// TempDir returns the directory used to stage hydration downloads.
+func (hc *HydrationController) TempDir() string {
+	return hc.tempDir
+}
+
+// NewTempPath returns a fresh staging path for a hydration download.
+// Names carry the hydrateTempPrefix so the startup sweep can find them.
+func (hc *HydrationController) NewTempPath(entryID, versionID int64) (string, error) {
+	if err := os.MkdirAll(hc.tempDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	name := fmt.Sprintf("%s%d_%d_%d", hydrateTempPrefix, entryID, versionID, time.Now().UnixNano())
+	return filepath.Join(hc.tempDir, name), nil
 }
 
 // Hydrate downloads and materializes a file.
@@ -134,12 +157,22 @@ func (hc *HydrationController) Hydrate(ctx context.Context, entryID int64, opts
 		return nil, fmt.Errorf("provider not found: %s", placement.ProviderID)
 	}
 
-	// Step 6: Begin journal operation
+	// Make room first: a download the cache would reject still costs egress
+	if err := hc.cache.EnsureSpace(ctx, entryID, "", version.Size); err != nil {
+		return nil, err
+	}
+
+	// Step 6: Begin journal operation (temp path recorded for crash recovery)
+	tempPath, err := hc.NewTempPath(entryID, version.ID)
+	if err != nil {
+		return nil, err
+	}
 	payload, _ := json.Marshal(map[string]interface{}{
 		"entry_id":    entryID,
 		"version_id":  version.ID,
 		"provider_id": placement.ProviderID,
 		"remote_path": placement.RemotePath,
+		"temp_path":   tempPath,
 	})
 	opID, err := hc.journal.BeginOperation(ctx, "hydrate", string(payload))
 	if err != nil {
@@ -152,18 +185,12 @@ func (hc *HydrationController) Hydrate(ctx context.Context, entryID int64, opts
 		return nil, fmt.Errorf("failed to update hydration state: %w", err)
 	}
 
-	// Step 8: Download to cache (temp file)
-	tempPath := fmt.Sprintf("%s/.cloudfs/temp/%d_%d_%d", os.Getenv("HOME"), entryID, version.ID, time.Now().UnixNano())
-	if err := os.MkdirAll(os.Getenv("HOME")+"/.cloudfs/temp", 0700); err != nil {
-		hc.journal.RollbackOperation(ctx, opID, err.Error())
-		return nil, fmt.Errorf("failed to create temp dir: %w", err)
-	}
-
+	// Step 8: Download to temp file under the repository's .cloudfs/temp
 	// Progress wrapper
 	var progressFunc provider.ProgressFunc
 	if opts != nil && opts.ProgressFunc != nil {
-		progressFunc = func(p float64) {
-			percent := int(p * 100)
+		progressFunc = func(p provider.Progress) {
+			percent := int(p.Fraction() * 100)
 			opts.ProgressFunc(entryID, percent)
 			hc.setHydrationState(ctx, entryID, model.HydrationStateHydrating, nil, percent)
 		}
@@ -171,8 +198,10 @@ func (hc *HydrationController) Hydrate(ctx context.Context, entryID int64, opts
 
 	downloadResult, err := prov.Download(ctx, placement.RemotePath, tempPath, progressFunc)
 	if err != nil {
-		hc.setHydrationState(ctx, entryID, model.HydrationStatePlaceholder, nil, 0)
-		hc.journal.RollbackOperation(ctx, opID, err.Error())
+		// A cancelled or timed-out download must still be rolled back
+		cleanupCtx := context.WithoutCancel(ctx)
+		hc.setHydrationState(cleanupCtx, entryID, model.HydrationStatePlaceholder, nil, 0)
+		hc.journal.RollbackOperation(cleanupCtx, opID, err.Error())
 		os.Remove(tempPath)
 		return nil, fmt.Errorf("download failed: %w", err)
 	}
@@ -0,0 +1,483 @@
+// Package core provides partial (range) hydration for CloudFS.
+// Based on design.txt Section 14: Hydration is explicit intent only.
+//
+// INVARIANTS:
+// - Only the requested chunks are downloaded
+// - Cache space is ensured for each chunk before it is fetched
+// - Partial data lives in cache ONLY, never in the filesystem view
+// - Every chunk present is recorded in hydrated_chunks
+// - A fully populated partial file is hash-verified before promotion
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// DefaultChunkSize is the chunk granularity for partial hydration.
+const DefaultChunkSize int64 = 8 * 1024 * 1024
+
+// ErrRangeUnsupported is returned when a provider cannot fetch byte ranges;
+// callers may fall back to a full hydration.
+var ErrRangeUnsupported = errors.New("provider does not support ranged downloads")
+
+// partialFileName names the sparse cache file of a partially hydrated version.
+const partialFileName = "partial"
+
+// ByteRange is a half-open byte range [Offset, Offset+Length).
+type ByteRange struct {
+	Offset int64
+	Length int64
+}
+
+// ParseByteRange parses a range expression against a file of the given size.
+// Accepted forms (sizes may use K/M/G suffixes):
+//
+//	"START-END"  inclusive byte range
+//	"START-"     from START to end of file
+//	"-N"         last N bytes (tail)
+func ParseByteRange(expr string, size int64) (ByteRange, error) {
+	start, end, ok := strings.Cut(strings.TrimSpace(expr), "-")
+	if !ok {
+		return ByteRange{}, fmt.Errorf("invalid range %q (expected START-END, START- or -N)", expr)
+	}
+
+	var r ByteRange
+	switch {
+	case start == "" && end == "":
+		return ByteRange{}, fmt.Errorf("invalid range %q", expr)
+	case start == "":
+		n, err := ParseSize(end)
+		if err != nil {
+			return ByteRange{}, err
+		}
+		if n > size {
+			n = size
+		}
+		r = ByteRange{Offset: size - n, Length: n}
+	default:
+		from, err := ParseSize(start)
+		if err != nil {
+			return ByteRange{}, err
+		}
+		to := size - 1
+		if end != "" {
+			if to, err = ParseSize(end); err != nil {
+				return ByteRange{}, err
+			}
+		}
+		if to >= size {
+			to = size - 1
+		}
+		if from > to {
+			return ByteRange{}, fmt.Errorf("range %q is outside file of %d bytes", expr, size)
+		}
+		r = ByteRange{Offset: from, Length: to - from + 1}
+	}
+
+	if r.Length <= 0 {
+		return ByteRange{}, fmt.Errorf("range %q is empty", expr)
+	}
+	return r, nil
+}
+
+// ParseSize parses a byte count with an optional K/M/G/T suffix.
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "B")
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return v * mult, nil
+}
+
+// ParseChunkList parses a chunk list such as "0,3,5-7".
+func ParseChunkList(expr string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(lo)
+		if err != nil || from < 0 {
+			return nil, fmt.Errorf("invalid chunk index: %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(hi); err != nil || to < from {
+				return nil, fmt.Errorf("invalid chunk range: %q", part)
+			}
+		}
+		for i := from; i <= to; i++ {
+			seen[i] = true
+		}
+	}
+
+	chunks := make([]int, 0, len(seen))
+	for i := range seen {
+		chunks = append(chunks, i)
+	}
+	sort.Ints(chunks)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no chunks specified")
+	}
+	return chunks, nil
+}
+
+// ChunkCount returns the number of chunks for a file of the given size.
+func ChunkCount(size, chunkSize int64) int {
+	if size <= 0 {
+		return 0
+	}
+	return int((size + chunkSize - 1) / chunkSize)
+}
+
+// ChunksForRange returns the chunk indexes covering a byte range.
+func ChunksForRange(r ByteRange, chunkSize int64) []int {
+	if r.Length <= 0 {
+		return nil
+	}
+	first := int(r.Offset / chunkSize)
+	last := int((r.Offset + r.Length - 1) / chunkSize)
+	chunks := make([]int, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		chunks = append(chunks, i)
+	}
+	return chunks
+}
+
+// PartialHydrationResult contains the outcome of a range hydration.
+type PartialHydrationResult struct {
+	EntryID       int64
+	VersionID     int64
+	ChunksFetched int
+	ChunksPresent int
+	ChunksTotal   int
+	BytesLoaded   int64
+	BytesPresent  int64
+	PartialPath   string // Sparse cache file holding the present chunks
+	Complete      bool   // All chunks present; promoted to full hydration
+	Duration      time.Duration
+}
+
+// PartialState describes which chunks of the active version are in cache.
+type PartialState struct {
+	VersionID     int64
+	ChunkSize     int64
+	ChunksPresent []int
+	ChunksTotal   int
+	BytesPresent  int64
+	PartialPath   string
+}
+
+// HydrateRange downloads only the given chunks of an entry's active version.
+// Returns ErrRangeUnsupported unless the provider can fetch byte ranges.
+//
+// Flow:
+// 1. Journal entry (pending)
+// 2. Fetch each missing chunk into a sparse cache file
+// 3. Record chunk presence in hydrated_chunks
+// 4. Update hydration_state to 'partial' (or promote when complete)
+// 5. Journal entry (synced)
+func (hc *HydrationController) HydrateRange(ctx context.Context, entryID int64, chunks []int, opts *HydrationOptions) (*PartialHydrationResult, error) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	start := time.Now()
+
+	entry, err := hc.index.GetEntry(ctx, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entry: %w", err)
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("entry not found: %d", entryID)
+	}
+	if entry.Type == model.EntryTypeDirectory {
+		return nil, fmt.Errorf("cannot hydrate directory")
+	}
+
+	version, err := hc.index.GetActiveVersion(ctx, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version: %w", err)
+	}
+	if version == nil {
+		return nil, fmt.Errorf("no active version for entry: %d", entryID)
+	}
+
+	total := ChunkCount(version.Size, DefaultChunkSize)
+	for _, c := range chunks {
+		if c >= total {
+			return nil, fmt.Errorf("chunk %d out of range (file has %d chunks)", c, total)
+		}
+	}
+
+	placement, err := hc.getPlacement(ctx, version.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get placement: %w", err)
+	}
+	if placement == nil {
+		return nil, fmt.Errorf("no placement found for version: %d", version.ID)
+	}
+
+	prov, ok := hc.registry.Get(placement.ProviderID)
+	if !ok {
+		return nil, fmt.Errorf("provider not found: %s", placement.ProviderID)
+	}
+	ranged, ok := provider.AsRangeDownloader(prov)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", placement.ProviderID, ErrRangeUnsupported)
+	}
+
+	present, err := hc.presentChunks(ctx, version.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"entry_id":    entryID,
+		"version_id":  version.ID,
+		"provider_id": placement.ProviderID,
+		"remote_path": placement.RemotePath,
+		"chunks":      chunks,
+	})
+	opID, err := hc.journal.BeginOperation(ctx, "hydrate_range", string(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin journal: %w", err)
+	}
+
+	partialPath := hc.partialPath(entryID, version.ID)
+	if err := os.MkdirAll(filepath.Dir(partialPath), 0700); err != nil {
+		hc.journal.RollbackOperation(ctx, opID, err.Error())
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	f, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		hc.journal.RollbackOperation(ctx, opID, err.Error())
+		return nil, fmt.Errorf("failed to open partial file: %w", err)
+	}
+	defer f.Close()
+
+	result := &PartialHydrationResult{
+		EntryID:     entryID,
+		VersionID:   version.ID,
+		ChunksTotal: total,
+		PartialPath: partialPath,
+	}
+
+	for i, c := range chunks {
+		if present[c] {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			hc.journal.RollbackOperation(ctx, opID, "cancelled")
+			return nil, ctx.Err()
+		default:
+		}
+
+		offset := int64(c) * DefaultChunkSize
+		length := DefaultChunkSize
+		if offset+length > version.Size {
+			length = version.Size - offset
+		}
+
+		if err := hc.cache.EnsureSpace(ctx, entryID, "", length); err != nil {
+			hc.journal.RollbackOperation(ctx, opID, err.Error())
+			return nil, err
+		}
+
+		// Chunks land at their real offsets; the file stays sparse until complete
+		hasher := sha256.New()
+		w := io.MultiWriter(io.NewOffsetWriter(f, offset), hasher)
+		n, err := ranged.DownloadRange(ctx, placement.RemotePath, offset, length, w)
+		if err != nil {
+			hc.journal.RollbackOperation(ctx, opID, err.Error())
+			return nil, fmt.Errorf("failed to download chunk %d: %w", c, err)
+		}
+		if n != length {
+			hc.journal.RollbackOperation(ctx, opID, "short read")
+			return nil, fmt.Errorf("chunk %d: expected %d bytes, got %d", c, length, n)
+		}
+
+		_, err = hc.db.ExecContext(ctx, `
+			INSERT OR REPLACE INTO hydrated_chunks
+				(version_id, entry_id, chunk_index, chunk_offset, size, chunk_hash, hydrated_at)
+			VALUES (?, ?, ?, ?, ?, ?, datetime('now'))
+		`, version.ID, entryID, c, offset, length, hex.EncodeToString(hasher.Sum(nil)))
+		if err != nil {
+			hc.journal.RollbackOperation(ctx, opID, err.Error())
+			return nil, fmt.Errorf("failed to record chunk: %w", err)
+		}
+
+		present[c] = true
+		result.ChunksFetched++
+		result.BytesLoaded += n
+
+		if opts != nil && opts.ProgressFunc != nil {
+			opts.ProgressFunc(entryID, (i+1)*100/len(chunks))
+		}
+	}
+
+	for c := range present {
+		result.ChunksPresent++
+		if c == total-1 {
+			result.BytesPresent += version.Size - int64(c)*DefaultChunkSize
+		} else {
+			result.BytesPresent += DefaultChunkSize
+		}
+	}
+
+	progress := 0
+	if version.Size > 0 {
+		progress = int(result.BytesPresent * 100 / version.Size)
+	}
+
+	if result.ChunksPresent == total {
+		f.Close()
+		if err := hc.promotePartial(ctx, entry, version, partialPath); err != nil {
+			hc.journal.RollbackOperation(ctx, opID, err.Error())
+			return nil, err
+		}
+		result.Complete = true
+	} else if err := hc.setHydrationState(ctx, entryID, model.HydrationStatePartial, &version.ID, progress); err != nil {
+		hc.journal.RollbackOperation(ctx, opID, err.Error())
+		return nil, fmt.Errorf("failed to update hydration state: %w", err)
+	}
+
+	if err := hc.journal.CommitOperation(ctx, opID); err != nil {
+		fmt.Printf("warning: failed to commit journal: %v\n", err)
+	}
+	if err := hc.journal.SyncOperation(ctx, opID); err != nil {
+		fmt.Printf("warning: failed to sync journal: %v\n", err)
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// promotePartial verifies a fully populated partial file and turns it into
+// a regular hydrated cache entry.
+func (hc *HydrationController) promotePartial(ctx context.Context, entry *model.Entry, version *model.Version, partialPath string) error {
+	hash, err := calculateFileHash(partialPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash partial file: %w", err)
+	}
+	if version.ContentHash != "" && hash != version.ContentHash {
+		return fmt.Errorf("hash verification failed: expected %s, got %s", version.ContentHash, hash)
+	}
+
+	cacheEntry, err := hc.cache.Put(ctx, entry.ID, version.ID, partialPath)
+	if err != nil {
+		return fmt.Errorf("failed to cache file: %w", err)
+	}
+
+	if hc.placeholder != nil {
+		if err := hc.placeholder.AtomicSwap(ctx, entry, cacheEntry.CachePath, version.ContentHash, ""); err != nil {
+			return fmt.Errorf("failed to swap placeholder: %w", err)
+		}
+	}
+
+	hc.db.ExecContext(ctx, `DELETE FROM hydrated_chunks WHERE version_id = ?`, version.ID)
+	return hc.setHydrationState(ctx, entry.ID, model.HydrationStateHydrated, &version.ID, 100)
+}
+
+// GetPartialState returns chunk presence for an entry's active version.
+// Returns nil if no chunks of the active version are present.
+func (hc *HydrationController) GetPartialState(ctx context.Context, entryID int64) (*PartialState, error) {
+	var versionID, size int64
+	err := hc.db.QueryRowContext(ctx, `
+		SELECT id, size FROM versions WHERE entry_id = ? AND state = 'active'
+	`, entryID).Scan(&versionID, &size)
+	if err != nil {
+		return nil, nil
+	}
+
+	rows, err := hc.db.QueryContext(ctx, `
+		SELECT chunk_index, size FROM hydrated_chunks
+		WHERE version_id = ? ORDER BY chunk_index
+	`, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks: %w", err)
+	}
+	defer rows.Close()
+
+	state := &PartialState{
+		VersionID:   versionID,
+		ChunkSize:   DefaultChunkSize,
+		ChunksTotal: ChunkCount(size, DefaultChunkSize),
+		PartialPath: hc.partialPath(entryID, versionID),
+	}
+	for rows.Next() {
+		var idx int
+		var n int64
+		if err := rows.Scan(&idx, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		state.ChunksPresent = append(state.ChunksPresent, idx)
+		state.BytesPresent += n
+	}
+	if len(state.ChunksPresent) == 0 {
+		return nil, nil
+	}
+	return state, nil
+}
+
+// presentChunks returns the set of chunk indexes already in cache.
+func (hc *HydrationController) presentChunks(ctx context.Context, versionID int64) (map[int]bool, error) {
+	rows, err := hc.db.QueryContext(ctx, `
+		SELECT chunk_index FROM hydrated_chunks WHERE version_id = ?
+	`, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks: %w", err)
+	}
+	defer rows.Close()
+
+	present := make(map[int]bool)
+	for rows.Next() {
+		var idx int
+		if err := rows.Scan(&idx); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		present[idx] = true
+	}
+	return present, nil
+}
+
+// partialPath returns the sparse cache file for a partially hydrated version.
+func (hc *HydrationController) partialPath(entryID, versionID int64) string {
+	return filepath.Join(hc.cache.CacheDir(), "entries",
+		fmt.Sprintf("%d", entryID), fmt.Sprintf("%d", versionID), partialFileName)
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestParseByteRange(t *testing.T) {
+	const size = 100 << 20
+
+	cases := []struct {
+		expr string
+		want ByteRange
+	}{
+		{"0-1023", ByteRange{0, 1024}},
+		{"1M-", ByteRange{1 << 20, size - 1<<20}},
+		{"-10M", ByteRange{size - 10<<20, 10 << 20}},
+		{"99M-200M", ByteRange{99 << 20, 1 << 20}},
+	}
+	for _, c := range cases {
+		got, err := ParseByteRange(c.expr, size)
+		if err != nil {
+			t.Fatalf("ParseByteRange(%q) failed: %v", c.expr, err)
+		}
+		if got != c.want {
+			t.Errorf("ParseByteRange(%q) = %+v, want %+v", c.expr, got, c.want)
+		}
+	}
+
+	if _, err := ParseByteRange("200M-", size); err == nil {
+		t.Error("expected error for range past end of file")
+	}
+
+	chunks := ChunksForRange(ByteRange{Offset: DefaultChunkSize - 1, Length: 2}, DefaultChunkSize)
+	if len(chunks) != 2 || chunks[0] != 0 || chunks[1] != 1 {
+		t.Errorf("expected chunks [0 1], got %v", chunks)
+	}
+}
+
+func TestHydrationController_HydrateRange(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, err := NewIndexManager(dbPath, "")
+	if err != nil {
+		t.Fatalf("failed to create index manager: %v", err)
+	}
+	defer im.Close()
+
+	ctx := context.Background()
+	if err := im.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	db, err := OpenEncryptedDB(dbPath, "")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	cache, err := NewCacheManager(db.DB(), filepath.Join(tmpDir, "cache"))
+	if err != nil {
+		t.Fatalf("failed to create cache manager: %v", err)
+	}
+	journal := NewJournalManager(db.DB())
+
+	// 2.5 chunks of data
+	data := bytes.Repeat([]byte("cloudfs!"), int(DefaultChunkSize*5/2/8))
+	sum := sha256.Sum256(data)
+
+	entry := &model.Entry{Name: "disk.img", Type: model.EntryTypeFile, LogicalSize: int64(len(data))}
+	if err := im.CreateEntry(ctx, entry); err != nil {
+		t.Fatalf("failed to create entry: %v", err)
+	}
+	version := &model.Version{
+		EntryID: entry.ID, VersionNum: 1, Size: int64(len(data)),
+		ContentHash: hex.EncodeToString(sum[:]), State: model.VersionStateActive,
+	}
+	if err := im.CreateVersion(ctx, version); err != nil {
+		t.Fatalf("failed to create version: %v", err)
+	}
+	db.DB().ExecContext(ctx, `
+		INSERT INTO placements (version_id, provider_id, remote_path, state)
+		VALUES (?, 'mem', 'mem:disk.img', 'uploaded')
+	`, version.ID)
+
+	mem := newMemProvider("mem")
+	mem.data["mem:disk.img"] = data
+	registry := provider.NewRegistry()
+	registry.Register(mem)
+	hc := NewHydrationController(im, cache, nil, journal, registry, db.DB())
+
+	// Tail only
+	result, err := hc.HydrateRange(ctx, entry.ID, []int{2}, nil)
+	if err != nil {
+		t.Fatalf("failed to hydrate range: %v", err)
+	}
+	if result.Complete || result.ChunksFetched != 1 || result.ChunksTotal != 3 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	state, err := hc.GetHydrationState(ctx, entry.ID)
+	if err != nil {
+		t.Fatalf("failed to get hydration state: %v", err)
+	}
+	if state.CurrentState != model.HydrationStatePartial {
+		t.Errorf("expected partial state, got %s", state.CurrentState)
+	}
+
+	partial, err := hc.GetPartialState(ctx, entry.ID)
+	if err != nil || partial == nil {
+		t.Fatalf("failed to get partial state: %v", err)
+	}
+	if len(partial.ChunksPresent) != 1 || partial.ChunksPresent[0] != 2 {
+		t.Errorf("expected chunk 2 present, got %v", partial.ChunksPresent)
+	}
+
+	// The sparse file counts only its hydrated bytes
+	usage, err := cache.Usage(ctx)
+	if err != nil {
+		t.Fatalf("failed to get usage: %v", err)
+	}
+	if usage.Used != DefaultChunkSize/2 {
+		t.Errorf("expected %d bytes used, got %d", DefaultChunkSize/2, usage.Used)
+	}
+
+	// Each chunk must fit before it is fetched
+	cache.SetQuota(ctx, &CacheQuota{QuotaBytes: DefaultChunkSize})
+	if _, err := hc.HydrateRange(ctx, entry.ID, []int{0}, nil); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("expected ErrCacheFull, got %v", err)
+	}
+	cache.SetQuota(ctx, &CacheQuota{QuotaBytes: 3 * DefaultChunkSize})
+
+	// Remaining chunks complete the file and promote it
+	result, err = hc.HydrateRange(ctx, entry.ID, []int{0, 1, 2}, nil)
+	if err != nil {
+		t.Fatalf("failed to hydrate remaining chunks: %v", err)
+	}
+	if !result.Complete || result.ChunksFetched != 2 {
+		t.Errorf("expected completion after 2 fetches, got %+v", result)
+	}
+
+	cachePath, err := cache.Get(ctx, entry.ID, version.ID)
+	if err != nil || cachePath == "" {
+		t.Fatalf("expected promoted cache entry: %v", err)
+	}
+	got, _ := os.ReadFile(cachePath)
+	if !bytes.Equal(got, data) {
+		t.Error("promoted cache file does not match source data")
+	}
+}
@@ -0,0 +1,310 @@
+// Package core provides batch hydration planning for CloudFS.
+// Based on design.txt Section 14 (explicit intent) and Section 10 (cost guardrails).
+//
+// INVARIANTS:
+// - Planning is READ-ONLY (index + local disk stats only)
+// - NO provider access during planning
+// - Targets are resolved from the index, never from the filesystem
+// - The plan is shown to the user BEFORE any download starts
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EgressCostConfigKey is the provider_config key holding egress cost in USD per GB.
+const EgressCostConfigKey = "egress_cost_per_gb"
+
+// HydrationTarget is a single file selected for hydration.
+type HydrationTarget struct {
+	EntryID     int64
+	VersionID   int64
+	Path        string // Logical path in the index (slash-separated)
+	Name        string
+	Size        int64
+	ContentHash string
+	ProviderID  string // Provider name (placements.provider_id)
+	RemotePath  string
+	Cached      bool // Valid cache copy already present
+}
+
+// ProviderHydrationSummary aggregates targets served by one provider.
+type ProviderHydrationSummary struct {
+	Provider   string
+	Files      int
+	Bytes      int64
+	CostPerGB  float64
+	EgressCost float64
+}
+
+// HydrationPlan is the pre-flight summary for a batch hydration.
+type HydrationPlan struct {
+	Pattern       string
+	Targets       []*HydrationTarget
+	Unavailable   []string // Matched files with no usable placement
+	TotalFiles    int
+	TotalBytes    int64
+	CachedFiles   int
+	Providers     []*ProviderHydrationSummary
+	EgressCost    float64
+	CacheRequired int64 // Bytes that must be downloaded into cache
+	CacheFree     int64 // Free bytes on the cache volume
+}
+
+// Fits reports whether the cache volume has room for the plan.
+func (p *HydrationPlan) Fits() bool {
+	return p.CacheRequired <= p.CacheFree
+}
+
+// IsGlobPattern reports whether a hydration target contains glob metacharacters.
+func IsGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// MatchGlob matches a slash-separated path against a glob pattern.
+// Supports path.Match syntax per segment plus "**" for zero or more segments.
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(splitPath(pattern), splitPath(name))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// indexedPath is an entry with its full logical path.
+type indexedPath struct {
+	id        int64
+	path      string
+	entryType string
+}
+
+// listIndexedPaths returns every entry with its full path built from the parent chain.
+func listIndexedPaths(ctx context.Context, db *sql.DB) ([]indexedPath, error) {
+	return queryIndexedPaths(ctx, db, "")
+}
+
+// listLivePaths is listIndexedPaths without entries in trash.
+func listLivePaths(ctx context.Context, db *sql.DB) ([]indexedPath, error) {
+	return queryIndexedPaths(ctx, db, `
+		WHERE e.id NOT IN (SELECT original_entry_id FROM trash)
+		AND e.id NOT IN (SELECT entry_id FROM trash_items)
+	`)
+}
+
+// queryIndexedPaths lists entry paths, filtered by a WHERE clause on entries e.
+func queryIndexedPaths(ctx context.Context, db *sql.DB, where string) ([]indexedPath, error) {
+	rows, err := db.QueryContext(ctx, `
+		WITH RECURSIVE tree(id, path) AS (
+			SELECT id, name FROM entries WHERE parent_id IS NULL
+			UNION ALL
+			SELECT e.id, tree.path || '/' || e.name
+			FROM entries e JOIN tree ON e.parent_id = tree.id
+		)
+		SELECT tree.id, tree.path, e.entry_type
+		FROM tree JOIN entries e ON e.id = tree.id
+		`+where+`
+		ORDER BY tree.path
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list index paths: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []indexedPath
+	for rows.Next() {
+		var p indexedPath
+		if err := rows.Scan(&p.id, &p.path, &p.entryType); err != nil {
+			return nil, fmt.Errorf("failed to scan index path: %w", err)
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// resolveTargets resolves a path, directory or glob to the matching file entries.
+// Directories expand to every file beneath them. Trashed entries are skipped.
+func resolveTargets(ctx context.Context, db *sql.DB, pattern string) ([]indexedPath, error) {
+	all, err := listLivePaths(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []indexedPath
+	if IsGlobPattern(pattern) {
+		for _, p := range all {
+			if p.entryType == "file" && MatchGlob(pattern, p.path) {
+				files = append(files, p)
+			}
+		}
+		return files, nil
+	}
+
+	target := strings.Join(splitPath(pattern), "/")
+	var match *indexedPath
+	for i := range all {
+		if all[i].path == target {
+			match = &all[i]
+			break
+		}
+	}
+	if match == nil && !strings.Contains(target, "/") {
+		// Fall back to a name lookup, matching the rest of the CLI,
+		// but only when the name is unique.
+		var named []string
+		for i := range all {
+			if path.Base(all[i].path) == target {
+				match = &all[i]
+				named = append(named, all[i].path)
+			}
+		}
+		if len(named) > 1 {
+			return nil, fmt.Errorf("ambiguous name %s: matches %s", pattern, strings.Join(named, ", "))
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("entry not found: %s", pattern)
+	}
+
+	if match.entryType == "file" {
+		return []indexedPath{*match}, nil
+	}
+	prefix := match.path + "/"
+	for _, p := range all {
+		if p.entryType == "file" && strings.HasPrefix(p.path, prefix) {
+			files = append(files, p)
+		}
+	}
+	return files, nil
+}
+
+// PlanHydration builds a pre-flight plan for hydrating a path, directory or glob.
+func (hc *HydrationController) PlanHydration(ctx context.Context, pattern string) (*HydrationPlan, error) {
+	files, err := resolveTargets(ctx, hc.db, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &HydrationPlan{Pattern: pattern}
+	byProvider := make(map[string]*ProviderHydrationSummary)
+
+	for _, f := range files {
+		t := &HydrationTarget{
+			EntryID: f.id,
+			Path:    f.path,
+			Name:    path.Base(f.path),
+		}
+
+		err := hc.db.QueryRowContext(ctx, `
+			SELECT id, size, content_hash FROM versions
+			WHERE entry_id = ? AND state = 'active'
+		`, f.id).Scan(&t.VersionID, &t.Size, &t.ContentHash)
+		if err != nil {
+			plan.Unavailable = append(plan.Unavailable, f.path)
+			continue
+		}
+
+		err = hc.db.QueryRowContext(ctx, `
+			SELECT provider_id, remote_path FROM placements
+			WHERE version_id = ? AND state IN ('uploaded', 'verified')
+			ORDER BY CASE state WHEN 'verified' THEN 0 ELSE 1 END
+			LIMIT 1
+		`, t.VersionID).Scan(&t.ProviderID, &t.RemotePath)
+		if err != nil {
+			plan.Unavailable = append(plan.Unavailable, f.path)
+			continue
+		}
+
+		var cached int
+		hc.db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM cache_entries
+			WHERE entry_id = ? AND version_id = ? AND state = 'valid'
+		`, t.EntryID, t.VersionID).Scan(&cached)
+		t.Cached = cached > 0
+
+		plan.Targets = append(plan.Targets, t)
+		plan.TotalFiles++
+		plan.TotalBytes += t.Size
+		if t.Cached {
+			plan.CachedFiles++
+			continue
+		}
+		plan.CacheRequired += t.Size
+
+		s, ok := byProvider[t.ProviderID]
+		if !ok {
+			s = &ProviderHydrationSummary{
+				Provider:  t.ProviderID,
+				CostPerGB: hc.egressCostPerGB(ctx, t.ProviderID),
+			}
+			byProvider[t.ProviderID] = s
+		}
+		s.Files++
+		s.Bytes += t.Size
+	}
+
+	for _, s := range byProvider {
+		s.EgressCost = float64(s.Bytes) / (1 << 30) * s.CostPerGB
+		plan.EgressCost += s.EgressCost
+		plan.Providers = append(plan.Providers, s)
+	}
+	sort.Slice(plan.Providers, func(i, j int) bool {
+		return plan.Providers[i].Provider < plan.Providers[j].Provider
+	})
+
+	if hc.cache != nil {
+		plan.CacheFree, _ = DiskFree(hc.cache.CacheDir())
+	}
+
+	return plan, nil
+}
+
+// egressCostPerGB returns the configured egress cost for a provider, or 0.
+func (hc *HydrationController) egressCostPerGB(ctx context.Context, providerName string) float64 {
+	var value string
+	err := hc.db.QueryRowContext(ctx, `
+		SELECT pc.value FROM provider_config pc
+		JOIN providers p ON pc.provider_id = p.id
+		WHERE p.name = ? AND pc.key = ?
+	`, providerName, EgressCostConfigKey).Scan(&value)
+	if err != nil {
+		return 0
+	}
+	cost, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return cost
+}
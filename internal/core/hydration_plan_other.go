@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || dragonfly || aix)
+
+package core
+
+import "math"
+
+// DiskFree reports unlimited space: free space is not measured on this
+// platform, so only the cache quota is enforced.
+func DiskFree(dir string) (int64, error) {
+	return math.MaxInt64, nil
+}
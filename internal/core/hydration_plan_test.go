@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"photos/2024/**/*.jpg", "photos/2024/a.jpg", true},
+		{"photos/2024/**/*.jpg", "photos/2024/trip/day1/a.jpg", true},
+		{"photos/2024/**/*.jpg", "photos/2023/a.jpg", false},
+		{"photos/2024/**/*.jpg", "photos/2024/a.png", false},
+		{"*.txt", "notes.txt", true},
+		{"*.txt", "docs/notes.txt", false},
+		{"**", "docs/notes.txt", true},
+		{"docs/?.md", "docs/a.md", true},
+	}
+
+	for _, c := range cases {
+		if got := MatchGlob(c.pattern, c.name); got != c.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestHydrationController_PlanHydration(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, err := NewIndexManager(dbPath, "")
+	if err != nil {
+		t.Fatalf("failed to create index manager: %v", err)
+	}
+	defer im.Close()
+
+	ctx := context.Background()
+	if err := im.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	db, err := OpenEncryptedDB(dbPath, "")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	cache, err := NewCacheManager(db.DB(), filepath.Join(tmpDir, "cache"))
+	if err != nil {
+		t.Fatalf("failed to create cache manager: %v", err)
+	}
+
+	// photos/2024/{a.jpg, trip/b.jpg, c.png}
+	mkdir := func(name string, parent *int64) *model.Entry {
+		e := &model.Entry{Name: name, Type: model.EntryTypeDirectory, ParentID: parent}
+		if err := im.CreateEntry(ctx, e); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		return e
+	}
+	mkfile := func(name string, parent *int64, size int64, provider string) {
+		e := &model.Entry{Name: name, Type: model.EntryTypeFile, ParentID: parent, LogicalSize: size}
+		if err := im.CreateEntry(ctx, e); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+		v := &model.Version{EntryID: e.ID, VersionNum: 1, ContentHash: "h-" + name, Size: size, State: model.VersionStateActive}
+		if err := im.CreateVersion(ctx, v); err != nil {
+			t.Fatalf("failed to create version: %v", err)
+		}
+		if provider != "" {
+			db.DB().ExecContext(ctx, `
+				INSERT INTO placements (version_id, provider_id, remote_path, state)
+				VALUES (?, ?, ?, 'uploaded')
+			`, v.ID, provider, provider+":"+name)
+		}
+	}
+
+	photos := mkdir("photos", nil)
+	y2024 := mkdir("2024", &photos.ID)
+	trip := mkdir("trip", &y2024.ID)
+	mkfile("a.jpg", &y2024.ID, 1<<30, "gdrive")
+	mkfile("b.jpg", &trip.ID, 1<<30, "s3")
+	mkfile("c.png", &y2024.ID, 100, "")
+
+	res, _ := db.DB().ExecContext(ctx, `INSERT INTO providers (name, type) VALUES ('s3', 'rclone')`)
+	s3ID, _ := res.LastInsertId()
+	db.DB().ExecContext(ctx, `INSERT INTO provider_config (provider_id, key, value) VALUES (?, ?, '0.09')`, s3ID, EgressCostConfigKey)
+
+	hc := NewHydrationController(im, cache, nil, nil, nil, db.DB())
+
+	plan, err := hc.PlanHydration(ctx, "photos/2024/**/*.jpg")
+	if err != nil {
+		t.Fatalf("failed to plan hydration: %v", err)
+	}
+	if plan.TotalFiles != 2 {
+		t.Errorf("expected 2 files, got %d", plan.TotalFiles)
+	}
+	if plan.TotalBytes != 2<<30 {
+		t.Errorf("expected %d bytes, got %d", int64(2<<30), plan.TotalBytes)
+	}
+	if len(plan.Providers) != 2 {
+		t.Fatalf("expected 2 providers, got %d", len(plan.Providers))
+	}
+	if plan.EgressCost < 0.0899 || plan.EgressCost > 0.0901 {
+		t.Errorf("expected egress cost 0.09, got %f", plan.EgressCost)
+	}
+
+	// Directory target expands to everything beneath it
+	plan, err = hc.PlanHydration(ctx, "photos/2024")
+	if err != nil {
+		t.Fatalf("failed to plan directory hydration: %v", err)
+	}
+	if plan.TotalFiles != 2 || len(plan.Unavailable) != 1 {
+		t.Errorf("expected 2 targets and 1 unavailable, got %d and %d", plan.TotalFiles, len(plan.Unavailable))
+	}
+
+	// A bare unique name falls back to a name lookup
+	plan, err = hc.PlanHydration(ctx, "b.jpg")
+	if err != nil || plan.TotalFiles != 1 || plan.Targets[0].Path != "photos/2024/trip/b.jpg" {
+		t.Errorf("expected b.jpg to resolve to photos/2024/trip/b.jpg, got %+v (%v)", plan, err)
+	}
+
+	// A missing path never resolves to a same-named file elsewhere
+	if _, err := hc.PlanHydration(ctx, "photos/2023/a.jpg"); err == nil {
+		t.Error("expected photos/2023/a.jpg not to resolve")
+	}
+
+	// A name shared by several files is ambiguous
+	other := mkdir("other", nil)
+	mkfile("a.jpg", &other.ID, 10, "gdrive")
+	if _, err := hc.PlanHydration(ctx, "a.jpg"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("expected ambiguous name error, got %v", err)
+	}
+
+	// Trashed entries are not hydration targets
+	tm := NewTrashManager(db.DB(), NewJournalManager(db.DB()))
+	if err := tm.MoveToTrash(ctx, other.ID, "other", 30); err != nil {
+		t.Fatalf("failed to trash: %v", err)
+	}
+	plan, err = hc.PlanHydration(ctx, "a.jpg")
+	if err != nil || plan.TotalFiles != 1 || plan.Targets[0].Path != "photos/2024/a.jpg" {
+		t.Errorf("expected a.jpg to resolve to photos/2024/a.jpg once other/ is trashed, got %+v (%v)", plan, err)
+	}
+	if _, err := hc.PlanHydration(ctx, "other"); err == nil {
+		t.Error("expected a trashed directory not to resolve")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd || dragonfly || aix
+
+package core
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// DiskFree returns the bytes available to the current user on the volume holding dir.
+func DiskFree(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("failed to stat filesystem: %w", err)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,176 @@
+// Package core provides hydration temp-file recovery for CloudFS.
+// Based on design.txt Section 7: Resume or rollback on restart.
+//
+// INVARIANTS:
+// - Only files named with hydrateTempPrefix are considered
+// - Operations and files active within hydrateTempGrace are left alone (another process may own them)
+// - A temp file is resumed ONLY if its size and hash match the version
+// - Everything else is removed and its journal entry rolled back
+// - Partial downloads NEVER appear in the filesystem view
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+)
+
+// hydrateTempPrefix marks staging files written by hydration.
+const hydrateTempPrefix = "hydrate_"
+
+// hydrateTempGrace is how long a hydration may go without writing to its
+// temp file before the sweep treats it as interrupted. Every command runs
+// the sweep, so a download in another process must not be swept.
+const hydrateTempGrace = 10 * time.Minute
+
+// TempRecoveryResult summarizes a startup temp sweep.
+type TempRecoveryResult struct {
+	Resumed    int // Complete downloads promoted into cache
+	RolledBack int // Pending operations rolled back (incomplete or corrupt)
+	Removed    int // Temp files deleted (including orphans)
+}
+
+// Changed reports whether the sweep did anything.
+func (r *TempRecoveryResult) Changed() bool {
+	return r.Resumed+r.RolledBack+r.Removed > 0
+}
+
+// hydratePayload is the journal payload shared by hydrate operations.
+type hydratePayload struct {
+	EntryID   int64  `json:"entry_id"`
+	VersionID int64  `json:"version_id"`
+	TempPath  string `json:"temp_path"`
+}
+
+// RecoverTempFiles reconciles leftover hydration temp files against
+// pending 'hydrate' journal entries.
+//
+// For each pending hydrate operation that recorded a temp path:
+//   - temp file complete and hash-verified → moved into cache, journal synced
+//   - otherwise → temp file removed, journal rolled back
+//
+// Temp files not referenced by any pending operation are removed.
+// Operations whose temp file (or, before it exists, whose journal entry)
+// is younger than hydrateTempGrace are still in flight and skipped.
+func (hc *HydrationController) RecoverTempFiles(ctx context.Context) (*TempRecoveryResult, error) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	result := &TempRecoveryResult{}
+	if hc.tempDir == "" || hc.journal == nil {
+		return result, nil
+	}
+
+	pending, err := hc.journal.GetPendingOperations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make(map[string]bool)
+	for _, op := range pending {
+		if op.OperationType != "hydrate" || op.State != model.JournalStatePending {
+			continue
+		}
+		var p hydratePayload
+		if err := json.Unmarshal([]byte(op.Payload), &p); err != nil || p.TempPath == "" {
+			continue
+		}
+		if filepath.Dir(p.TempPath) != hc.tempDir {
+			continue
+		}
+		claimed[filepath.Base(p.TempPath)] = true
+
+		active := op.CreatedAt
+		if info, err := os.Stat(p.TempPath); err == nil {
+			active = info.ModTime()
+		}
+		if time.Since(active) < hydrateTempGrace {
+			continue
+		}
+
+		if hc.resumeTempFile(ctx, p) {
+			hc.journal.CommitOperation(ctx, op.OperationID)
+			hc.journal.SyncOperation(ctx, op.OperationID)
+			result.Resumed++
+			continue
+		}
+
+		if err := os.Remove(p.TempPath); err == nil {
+			result.Removed++
+		}
+		hc.setHydrationState(ctx, p.EntryID, model.HydrationStatePlaceholder, nil, 0)
+		hc.journal.RollbackOperation(ctx, op.OperationID, "interrupted hydration: temp file incomplete")
+		result.RolledBack++
+	}
+
+	entries, err := os.ReadDir(hc.tempDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, fmt.Errorf("failed to read temp dir: %w", err)
+	}
+	for _, de := range entries {
+		name := de.Name()
+		if de.IsDir() || !strings.HasPrefix(name, hydrateTempPrefix) || claimed[name] {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil || time.Since(info.ModTime()) < hydrateTempGrace {
+			continue
+		}
+		if err := os.Remove(filepath.Join(hc.tempDir, name)); err == nil {
+			result.Removed++
+		}
+	}
+
+	return result, nil
+}
+
+// resumeTempFile promotes a complete, verified temp download into cache.
+func (hc *HydrationController) resumeTempFile(ctx context.Context, p hydratePayload) bool {
+	info, err := os.Stat(p.TempPath)
+	if err != nil {
+		return false
+	}
+
+	var version *model.Version
+	if p.VersionID != 0 {
+		version, err = hc.index.GetVersion(ctx, p.VersionID)
+	} else {
+		version, err = hc.index.GetActiveVersion(ctx, p.EntryID)
+	}
+	if err != nil || version == nil || info.Size() != version.Size {
+		return false
+	}
+
+	hash, err := calculateFileHash(p.TempPath)
+	if err != nil || (version.ContentHash != "" && hash != version.ContentHash) {
+		return false
+	}
+
+	cacheEntry, err := hc.cache.Put(ctx, version.EntryID, version.ID, p.TempPath)
+	if err != nil {
+		return false
+	}
+
+	if hc.placeholder != nil {
+		entry, err := hc.index.GetEntry(ctx, version.EntryID)
+		if err == nil && entry != nil {
+			if err := hc.placeholder.AtomicSwap(ctx, entry, cacheEntry.CachePath, version.ContentHash, ""); err != nil {
+				// Data is safely cached; leave the placeholder for an explicit hydrate.
+				hc.setHydrationState(ctx, version.EntryID, model.HydrationStatePlaceholder, nil, 0)
+				return true
+			}
+		}
+	}
+
+	hc.setHydrationState(ctx, version.EntryID, model.HydrationStateHydrated, &version.ID, 100)
+	return true
+}
@@ -178,6 +178,19 @@ CREATE TABLE IF NOT EXISTS hydration_state (
     last_hydrated   TEXT
 );
 
+-- Chunks present in cache for partially hydrated versions
+CREATE TABLE IF NOT EXISTS hydrated_chunks (
+    version_id      INTEGER NOT NULL REFERENCES versions(id) ON DELETE CASCADE,
+    entry_id        INTEGER NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
+    chunk_index     INTEGER NOT NULL,
+    chunk_offset    INTEGER NOT NULL,
+    size            INTEGER NOT NULL,
+    chunk_hash      TEXT NOT NULL,
+    hydrated_at     TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (version_id, chunk_index)
+);
+CREATE INDEX IF NOT EXISTS idx_hydrated_chunks_entry ON hydrated_chunks(entry_id);
+
 -- Snapshots (metadata-only, Phase 2)
 CREATE TABLE IF NOT EXISTS snapshots (
     id              INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -202,6 +215,36 @@ CREATE TABLE IF NOT EXISTS trash (
     auto_purge_after TEXT
 );
 
+-- Members of a trash group: the trashed entry and, for directories, its subtree
+CREATE TABLE IF NOT EXISTS trash_items (
+    trash_id        INTEGER NOT NULL REFERENCES trash(id) ON DELETE CASCADE,
+    entry_id        INTEGER NOT NULL,
+    version_id      INTEGER,
+    PRIMARY KEY (trash_id, entry_id)
+);
+
+-- Remote deletions that failed and must be retried
+CREATE TABLE IF NOT EXISTS pending_deletes (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    provider_id     TEXT NOT NULL,
+    remote_path     TEXT NOT NULL,
+    size            INTEGER NOT NULL DEFAULT 0,
+    source          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 1,
+    last_error      TEXT,
+    created_at      TEXT NOT NULL DEFAULT (datetime('now')),
+    last_attempt    TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(provider_id, remote_path)
+);
+
+-- Last scrub attempt per placement (lets an interrupted scrub resume)
+CREATE TABLE IF NOT EXISTS scrub_log (
+    placement_id    INTEGER PRIMARY KEY,
+    checked_at      TEXT NOT NULL,
+    result          TEXT NOT NULL,
+    detail          TEXT
+);
+
 -- Policies
 CREATE TABLE IF NOT EXISTS policies (
     id              INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -242,6 +285,43 @@ CREATE TABLE IF NOT EXISTS health_metrics (
     overall_score   REAL NOT NULL
 );
 
+-- Repository health snapshots recorded by 'cloudfs health record'
+CREATE TABLE IF NOT EXISTS health_history (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    measured_at     TEXT NOT NULL,
+    total_entries   INTEGER NOT NULL,
+    healthy         INTEGER NOT NULL,
+    warning         INTEGER NOT NULL,
+    critical        INTEGER NOT NULL,
+    average_score   REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_health_history_time ON health_history(measured_at);
+
+-- Provider and cache usage samples (observational only)
+CREATE TABLE IF NOT EXISTS usage_history (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    measured_at     TEXT NOT NULL,
+    scope           TEXT NOT NULL CHECK(scope IN ('provider', 'cache')),
+    name            TEXT NOT NULL,
+    used_bytes      INTEGER NOT NULL,
+    total_bytes     INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_usage_history_name ON usage_history(scope, name, measured_at);
+
+-- Provider transfers in flight (observational only)
+CREATE TABLE IF NOT EXISTS transfers (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    direction       TEXT NOT NULL CHECK(direction IN ('upload', 'download')),
+    name            TEXT NOT NULL,
+    provider        TEXT NOT NULL,
+    bytes           INTEGER NOT NULL DEFAULT 0,
+    total_bytes     INTEGER NOT NULL DEFAULT 0,
+    speed           REAL NOT NULL DEFAULT 0,
+    eta_seconds     INTEGER,
+    started_at      TEXT NOT NULL,
+    updated_at      TEXT NOT NULL
+);
+
 -- Cold data archives (Phase 3)
 CREATE TABLE IF NOT EXISTS archives (
     id              INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -496,6 +576,35 @@ func (im *IndexManager) GetActiveVersion(ctx context.Context, entryID int64) (*m
 	return &version, nil
 }
 
+// GetVersion retrieves a version by ID.
+func (im *IndexManager) GetVersion(ctx context.Context, id int64) (*model.Version, error) {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+
+	query := `
+		SELECT id, entry_id, version_num, content_hash, size, created_at, state, encryption_key_id
+		FROM versions WHERE id = ?
+	`
+	row := im.db.QueryRowContext(ctx, query, id)
+
+	var version model.Version
+	var createdAt string
+	err := row.Scan(
+		&version.ID, &version.EntryID, &version.VersionNum,
+		&version.ContentHash, &version.Size, &createdAt,
+		&version.State, &version.EncryptionKeyID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version: %w", err)
+	}
+
+	version.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+
+	return &version, nil
+}
+
 // --- Validation ---
 
 // Validate performs index integrity validation.
@@ -628,7 +737,7 @@ func (jm *JournalManager) GetPendingOperations(ctx context.Context) ([]*model.Jo
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan journal entry: %w", err)
 		}
-		entry.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		entry.CreatedAt = parseDBTime(createdAt)
 		entries = append(entries, &entry)
 	}
 
@@ -149,6 +149,40 @@ func (pp *PlacementPlanner) Plan(ctx context.Context, fileName string, fileSize
 	return plan, nil
 }
 
+// PlanReplica plans an additional copy of existing data. Providers named in
+// exclude already hold a replica and are rejected as "already_holds_replica".
+func (pp *PlacementPlanner) PlanReplica(ctx context.Context, fileName string, fileSize int64, encrypted bool, exclude []string) (*PlacementPlan, error) {
+	plan, err := pp.Plan(ctx, fileName, fileSize, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	holders := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		holders[name] = true
+	}
+
+	kept := plan.Placements[:0]
+	for _, p := range plan.Placements {
+		if holders[p.ProviderName] {
+			plan.RejectedProviders = append(plan.RejectedProviders, RejectedProvider{
+				ProviderID:   p.ProviderID,
+				ProviderName: p.ProviderName,
+				Reason:       "already_holds_replica",
+			})
+			continue
+		}
+		kept = append(kept, p)
+	}
+	plan.Placements = kept
+
+	if len(plan.Placements) == 0 && !plan.Rejected {
+		plan.Rejected = true
+		plan.Reason = "every suitable provider already holds a replica"
+	}
+	return plan, nil
+}
+
 // Revalidate checks if a plan is still valid (called immediately before upload).
 func (pp *PlacementPlanner) Revalidate(ctx context.Context, plan *PlacementPlan) error {
 	pp.mu.RLock()
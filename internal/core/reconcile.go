@@ -0,0 +1,373 @@
+// Package core provides remote-state reconciliation for CloudFS.
+// Based on design.txt Section 5: Conflict Resolution.
+//
+// INVARIANTS:
+// - Index wins ALWAYS; remote state never rewrites index metadata
+// - Backend mismatch → placement marked DEGRADED (never deleted)
+// - Listing or provider failures NEVER degrade placements
+// - Untracked remote objects are reported only; deletion is left to gc
+// - Untracked objects are only looked for on remotes marked dedicated
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// Reconcile finding kinds.
+const (
+	FindingMissing      = "missing"       // Placement has no remote object
+	FindingSizeMismatch = "size_mismatch" // Remote size differs from the index
+	FindingHashMismatch = "hash_mismatch" // Remote hash differs from the index
+	FindingUntracked    = "untracked"     // Remote object absent from the index
+	FindingUnavailable  = "unavailable"   // Provider could not be scanned
+	FindingNotListable  = "not_listable"  // Provider has no List support; existence checked per object
+)
+
+// Suggested repair actions.
+const (
+	RepairReplicate = "replicate" // Add a replica with heal or replicate
+	RepairCollect   = "collect"   // Review and delete with gc
+	RepairRetry     = "retry"     // Re-run the scan once the provider is reachable
+)
+
+// ReconcileOptions controls a reconciliation scan.
+type ReconcileOptions struct {
+	Providers []string // Empty scans every registered provider
+	Hash      bool     // Also compare content hashes (one request per object)
+	DryRun    bool     // Report only; do not mark placements degraded
+}
+
+// ReconcileFinding is a single mismatch between the index and a provider.
+type ReconcileFinding struct {
+	Kind         string `json:"kind"`
+	Severity     string `json:"severity"` // "warning", "error"
+	ProviderID   string `json:"provider_id"`
+	RemotePath   string `json:"remote_path,omitempty"`
+	PlacementID  int64  `json:"placement_id,omitempty"`
+	VersionID    int64  `json:"version_id,omitempty"`
+	EntryPath    string `json:"entry_path,omitempty"`
+	ExpectedSize int64  `json:"expected_size,omitempty"`
+	ActualSize   int64  `json:"actual_size,omitempty"`
+	ExpectedHash string `json:"expected_hash,omitempty"`
+	ActualHash   string `json:"actual_hash,omitempty"`
+	Detail       string `json:"detail,omitempty"`
+	Degraded     bool   `json:"degraded"` // Placement was marked degraded by this scan
+	Action       string `json:"action"`
+	Command      string `json:"command,omitempty"`
+}
+
+// ProviderReconcileSummary counts results for one provider.
+type ProviderReconcileSummary struct {
+	ProviderID string `json:"provider_id"`
+	Placements int    `json:"placements"`
+	Listed     int    `json:"listed"`
+	OK         int    `json:"ok"`
+	Mismatched int    `json:"mismatched"`
+	Untracked  int    `json:"untracked"`
+	Dedicated  bool   `json:"dedicated"` // Untracked objects are only counted on dedicated remotes
+	Degraded   int    `json:"degraded"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ReconcileReport is the machine-readable result of a reconciliation scan.
+type ReconcileReport struct {
+	ScanTime  time.Time                   `json:"scan_time"`
+	DryRun    bool                        `json:"dry_run"`
+	Hash      bool                        `json:"hash_checked"`
+	Providers []*ProviderReconcileSummary `json:"providers"`
+	Findings  []ReconcileFinding          `json:"findings"`
+}
+
+// Reconciler compares placements against live provider state.
+type Reconciler struct {
+	db       *sql.DB
+	registry provider.Registry
+}
+
+// NewReconciler creates a new reconciler.
+func NewReconciler(db *sql.DB, registry provider.Registry) *Reconciler {
+	return &Reconciler{
+		db:       db,
+		registry: registry,
+	}
+}
+
+// reconcilePlacement is a placement row with the size and hash the index expects.
+type reconcilePlacement struct {
+	id         int64
+	versionID  int64
+	remotePath string
+	state      string
+	size       int64
+	hash       string
+	entryPath  string
+}
+
+// Reconcile scans providers and reports every placement whose remote object
+// is missing or differs from the index, plus remote objects the index lacks
+// on dedicated remotes.
+func (r *Reconciler) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error) {
+	report := &ReconcileReport{
+		ScanTime: time.Now(),
+		DryRun:   opts.DryRun,
+		Hash:     opts.Hash,
+	}
+
+	providerIDs := opts.Providers
+	if len(providerIDs) == 0 {
+		var err error
+		if providerIDs, err = r.knownProviders(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	paths, err := entryPaths(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range providerIDs {
+		summary, findings, err := r.reconcileProvider(ctx, id, paths, opts)
+		if err != nil {
+			return nil, err
+		}
+		report.Providers = append(report.Providers, summary)
+		report.Findings = append(report.Findings, findings...)
+	}
+	return report, nil
+}
+
+// reconcileProvider scans a single provider.
+func (r *Reconciler) reconcileProvider(ctx context.Context, providerID string, paths map[int64]string, opts ReconcileOptions) (*ProviderReconcileSummary, []ReconcileFinding, error) {
+	summary := &ProviderReconcileSummary{ProviderID: providerID}
+
+	placements, err := r.placements(ctx, providerID, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	summary.Placements = len(placements)
+
+	var prov provider.Provider
+	ok := false
+	if r.registry != nil {
+		prov, ok = r.registry.Get(providerID)
+	}
+	if !ok {
+		summary.Error = "provider not registered"
+		return summary, []ReconcileFinding{{
+			Kind:       FindingUnavailable,
+			Severity:   "error",
+			ProviderID: providerID,
+			Detail:     fmt.Sprintf("%d placements could not be checked: provider not registered", len(placements)),
+			Action:     RepairRetry,
+			Command:    "cloudfs provider list",
+		}}, nil
+	}
+
+	var findings []ReconcileFinding
+	var listed map[string]provider.RemoteObject
+	if lister, ok := provider.AsLister(prov); ok {
+		objects, err := lister.List(ctx, "")
+		if err != nil {
+			summary.Error = err.Error()
+			return summary, []ReconcileFinding{{
+				Kind:       FindingUnavailable,
+				Severity:   "error",
+				ProviderID: providerID,
+				Detail:     fmt.Sprintf("listing failed: %v", err),
+				Action:     RepairRetry,
+				Command:    fmt.Sprintf("cloudfs scan providers --provider %s", providerID),
+			}}, nil
+		}
+		summary.Listed = len(objects)
+		listed = make(map[string]provider.RemoteObject, len(objects))
+		for _, obj := range objects {
+			listed[canonicalRemotePath(obj.Path)] = obj
+		}
+	} else {
+		findings = append(findings, ReconcileFinding{
+			Kind:       FindingNotListable,
+			Severity:   "warning",
+			ProviderID: providerID,
+			Detail:     "provider cannot list objects; sizes and untracked objects were not checked",
+			Action:     RepairRetry,
+		})
+	}
+
+	referenced := make(map[string]bool, len(placements))
+	for _, p := range placements {
+		key := canonicalRemotePath(p.remotePath)
+		referenced[key] = true
+
+		f := ReconcileFinding{
+			ProviderID:   providerID,
+			RemotePath:   p.remotePath,
+			PlacementID:  p.id,
+			VersionID:    p.versionID,
+			EntryPath:    p.entryPath,
+			ExpectedSize: p.size,
+			Severity:     "error",
+			Action:       RepairReplicate,
+			Command:      "cloudfs heal",
+		}
+
+		if listed != nil {
+			obj, exists := listed[key]
+			switch {
+			case !exists:
+				f.Kind = FindingMissing
+			case p.size > 0 && obj.Size != p.size:
+				f.Kind = FindingSizeMismatch
+				f.ActualSize = obj.Size
+			}
+		} else {
+			vr, err := prov.Verify(ctx, p.remotePath)
+			if err != nil {
+				f.Kind = FindingUnavailable
+				f.Detail = err.Error()
+				f.Action = RepairRetry
+				f.Command = ""
+				findings = append(findings, f)
+				continue
+			}
+			if !vr.IsValid {
+				f.Kind = FindingMissing
+				f.Detail = vr.ErrorMessage
+			}
+		}
+
+		if f.Kind == "" && opts.Hash && p.hash != "" {
+			if vr, err := prov.Verify(ctx, p.remotePath); err == nil && vr.ContentHash != "" && vr.ContentHash != p.hash {
+				f.Kind = FindingHashMismatch
+				f.ExpectedHash = p.hash
+				f.ActualHash = vr.ContentHash
+			}
+		}
+
+		if f.Kind == "" {
+			summary.OK++
+			continue
+		}
+
+		summary.Mismatched++
+		if p.state != "degraded" && !opts.DryRun {
+			if _, err := r.db.ExecContext(ctx, `UPDATE placements SET state = 'degraded' WHERE id = ?`, p.id); err != nil {
+				return nil, nil, fmt.Errorf("failed to mark placement degraded: %w", err)
+			}
+			f.Degraded = true
+			summary.Degraded++
+		}
+		findings = append(findings, f)
+	}
+
+	// Remote objects the index does not know about. A shared remote also
+	// holds files CloudFS never wrote, so only dedicated ones are checked.
+	summary.Dedicated, err = IsDedicated(ctx, r.db, providerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var untracked []ReconcileFinding
+	for key, obj := range listed {
+		if referenced[key] || !summary.Dedicated {
+			continue
+		}
+		untracked = append(untracked, ReconcileFinding{
+			Kind:       FindingUntracked,
+			Severity:   "warning",
+			ProviderID: providerID,
+			RemotePath: obj.Path,
+			ActualSize: obj.Size,
+			Action:     RepairCollect,
+			Command:    fmt.Sprintf("cloudfs gc --provider %s", providerID),
+		})
+	}
+	sort.Slice(untracked, func(i, j int) bool {
+		return untracked[i].RemotePath < untracked[j].RemotePath
+	})
+	summary.Untracked = len(untracked)
+	findings = append(findings, untracked...)
+
+	return summary, findings, nil
+}
+
+// placements loads every placement for a provider with its expected size and hash.
+// Chunk placements are checked against the chunk, whole-version placements against the version.
+func (r *Reconciler) placements(ctx context.Context, providerID string, paths map[int64]string) ([]reconcilePlacement, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.id, COALESCE(p.version_id, c.version_id, 0), p.remote_path, p.state,
+		       COALESCE(c.size, v.size, 0),
+		       CASE WHEN p.chunk_id IS NOT NULL THEN COALESCE(c.chunk_hash, '') ELSE COALESCE(v.content_hash, '') END,
+		       COALESCE(v.entry_id, cv.entry_id, 0)
+		FROM placements p
+		LEFT JOIN chunks c ON c.id = p.chunk_id
+		LEFT JOIN versions v ON v.id = p.version_id
+		LEFT JOIN versions cv ON cv.id = c.version_id
+		WHERE p.provider_id = ?
+		ORDER BY p.id
+	`, providerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load placements: %w", err)
+	}
+	defer rows.Close()
+
+	var placements []reconcilePlacement
+	for rows.Next() {
+		var p reconcilePlacement
+		var entryID int64
+		if err := rows.Scan(&p.id, &p.versionID, &p.remotePath, &p.state, &p.size, &p.hash, &entryID); err != nil {
+			return nil, fmt.Errorf("failed to scan placement: %w", err)
+		}
+		p.entryPath = paths[entryID]
+		placements = append(placements, p)
+	}
+	return placements, nil
+}
+
+// knownProviders returns registered providers plus any named by placements.
+func (r *Reconciler) knownProviders(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	if r.registry != nil {
+		for _, p := range r.registry.All() {
+			seen[p.ID()] = true
+		}
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT provider_id FROM placements`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list placement providers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan provider: %w", err)
+		}
+		seen[id] = true
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// entryPaths maps entry IDs to their indexed paths.
+func entryPaths(ctx context.Context, db *sql.DB) (map[int64]string, error) {
+	indexed, err := listIndexedPaths(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[int64]string, len(indexed))
+	for _, ip := range indexed {
+		paths[ip.id] = ip.path
+	}
+	return paths, nil
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestReconciler_Reconcile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	gdrive := newMemProvider("gdrive")
+	registry := provider.NewRegistry()
+	registry.Register(gdrive)
+
+	place := func(name string, size int64, providerID, remotePath string) int64 {
+		entry := &model.Entry{Name: name, Type: model.EntryTypeFile, LogicalSize: size}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: name, Size: size, State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+		res, _ := db.DB().ExecContext(ctx, `
+			INSERT INTO placements (version_id, provider_id, remote_path, state)
+			VALUES (?, ?, ?, 'uploaded')
+		`, v.ID, providerID, remotePath)
+		id, _ := res.LastInsertId()
+		return id
+	}
+
+	okID := place("ok.txt", 10, "gdrive", "gdrive:/ok.txt")
+	missingID := place("missing.txt", 20, "gdrive", "gdrive:missing.txt")
+	shortID := place("short.txt", 30, "gdrive", "gdrive:short.txt")
+	goneID := place("gone.txt", 40, "b2", "b2:gone.txt")
+	gdrive.objects["gdrive:ok.txt"] = 10
+	gdrive.objects["gdrive:short.txt"] = 5
+	gdrive.objects["gdrive:stray.bin"] = 99
+
+	stateOf := func(id int64) string {
+		var state string
+		db.DB().QueryRowContext(ctx, `SELECT state FROM placements WHERE id = ?`, id).Scan(&state)
+		return state
+	}
+
+	rec := NewReconciler(db.DB(), registry)
+
+	// Dry run reports without touching placement state
+	report, err := rec.Reconcile(ctx, ReconcileOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if stateOf(missingID) != "uploaded" {
+		t.Error("dry run must not degrade placements")
+	}
+	// A shared remote holds files CloudFS never wrote
+	for _, f := range report.Findings {
+		if f.Kind == FindingUntracked {
+			t.Errorf("untracked object reported on a shared remote: %+v", f)
+		}
+	}
+
+	db.DB().ExecContext(ctx, `INSERT INTO providers (name, type) VALUES ('gdrive', 'rclone')`)
+	if err := SetDedicated(ctx, db.DB(), "gdrive", true); err != nil {
+		t.Fatalf("failed to mark provider dedicated: %v", err)
+	}
+
+	report, err = rec.Reconcile(ctx, ReconcileOptions{})
+	if err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	kinds := map[string]ReconcileFinding{}
+	for _, f := range report.Findings {
+		kinds[f.Kind+":"+f.ProviderID] = f
+	}
+	if f := kinds[FindingMissing+":gdrive"]; f.PlacementID != missingID || !f.Degraded || f.EntryPath != "missing.txt" {
+		t.Errorf("expected missing.txt finding, got %+v", f)
+	}
+	if f := kinds[FindingSizeMismatch+":gdrive"]; f.PlacementID != shortID || f.ActualSize != 5 || f.Action != RepairReplicate {
+		t.Errorf("expected short.txt size mismatch, got %+v", f)
+	}
+	if f := kinds[FindingUntracked+":gdrive"]; f.RemotePath != "gdrive:stray.bin" || f.Action != RepairCollect {
+		t.Errorf("expected stray.bin untracked, got %+v", f)
+	}
+	if _, ok := kinds[FindingUnavailable+":b2"]; !ok {
+		t.Error("expected unregistered provider to be reported")
+	}
+
+	if stateOf(okID) != "uploaded" || stateOf(goneID) != "uploaded" {
+		t.Error("matching or unscannable placements must keep their state")
+	}
+	if stateOf(missingID) != "degraded" || stateOf(shortID) != "degraded" {
+		t.Error("expected mismatched placements to be degraded")
+	}
+
+	var summary *ProviderReconcileSummary
+	for _, p := range report.Providers {
+		if p.ProviderID == "gdrive" {
+			summary = p
+		}
+	}
+	if summary == nil || summary.OK != 1 || summary.Mismatched != 2 || summary.Untracked != 1 || summary.Degraded != 2 {
+		t.Errorf("unexpected gdrive summary: %+v", summary)
+	}
+}
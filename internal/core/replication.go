@@ -0,0 +1,599 @@
+// Package core provides replication policy enforcement for CloudFS.
+// Based on design.txt Section 7: Backend failure, and Section 8: Provider Selection.
+//
+// INVARIANTS:
+// - Only VERIFIED placements count as copies
+// - Entries without a matching policy are never touched
+// - Planning is READ-ONLY; changes require explicit user confirmation
+// - Missing replicas are created before any excess replica is trimmed
+// - Trimming never leaves fewer verified copies than the policy requires
+// - All remote deletion goes through the DeleteCoordinator
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// ReplicationPolicy is a per-path replication policy stored in the policies table.
+type ReplicationPolicy struct {
+	Pattern       string   `json:"pattern"`
+	MinCopies     int      `json:"min_copies"`
+	DistinctTypes bool     `json:"distinct_types,omitempty"` // Copies must be on different provider types
+	Preferred     []string `json:"preferred,omitempty"`      // Providers filled first and trimmed last
+}
+
+// String formats the policy for display.
+func (p ReplicationPolicy) String() string {
+	s := fmt.Sprintf("%d copies", p.MinCopies)
+	if p.DistinctTypes {
+		s += " on distinct provider types"
+	}
+	if len(p.Preferred) > 0 {
+		s += ", prefer " + strings.Join(p.Preferred, ",")
+	}
+	return s
+}
+
+// ReplicaCopy is one placement of an entry's active version.
+type ReplicaCopy struct {
+	PlacementID  int64
+	ProviderID   string
+	ProviderType string
+	RemotePath   string
+	State        string
+	VerifiedAt   *time.Time
+}
+
+// ReplicationStatus compares an entry's copies with its policy.
+type ReplicationStatus struct {
+	EntryID     int64
+	Path        string
+	Policy      *ReplicationPolicy
+	VersionID   int64
+	Size        int64
+	ContentHash string
+	Required    int
+	Current     int // Verified objects; distinct provider types when the policy requires it
+	Copies      []ReplicaCopy
+	Creates     []*HealAction
+	Trims       []PlacementRef
+	Reason      string // Why the entry cannot be brought into compliance
+}
+
+// Unverified returns the providers holding copies that are not yet verified.
+func (s *ReplicationStatus) Unverified() []string {
+	var names []string
+	for _, c := range s.Copies {
+		if c.State == "pending" || c.State == "uploaded" {
+			names = append(names, c.ProviderID)
+		}
+	}
+	return names
+}
+
+// ReplicationPlan lists the replicas a replicate run would create and trim.
+type ReplicationPlan struct {
+	Entries     []*ReplicationStatus // Entries that do not match their policy
+	Compliant   int
+	Creates     int
+	Trims       int
+	CreateBytes int64
+	TrimBytes   int64
+}
+
+// ReplicationResult reports what a replicate run did.
+type ReplicationResult struct {
+	Created int
+	Trimmed int
+	Failed  int
+	Bytes   int64
+	Errors  []error
+}
+
+// Replicator enforces replication policies by creating and trimming replicas.
+type Replicator struct {
+	db      *sql.DB
+	healer  *Healer
+	planner *PlacementPlanner
+	deleter *DeleteCoordinator
+}
+
+// NewReplicator creates a new replicator. cache may be nil, in which case
+// replicas are always copied from another provider.
+func NewReplicator(db *sql.DB, planner *PlacementPlanner, cache *CacheManager, journal *JournalManager,
+	deleter *DeleteCoordinator, registry provider.Registry, tempDir string) *Replicator {
+	return &Replicator{
+		db:      db,
+		healer:  NewHealer(db, NewHealthManager(db), planner, cache, journal, registry, tempDir),
+		planner: planner,
+		deleter: deleter,
+	}
+}
+
+// SetPolicy creates or replaces the replication policy for a path pattern.
+func (r *Replicator) SetPolicy(ctx context.Context, policy ReplicationPolicy) error {
+	if policy.MinCopies < 1 {
+		return fmt.Errorf("min copies must be at least 1, got %d", policy.MinCopies)
+	}
+
+	policy.Pattern = strings.Join(splitPath(policy.Pattern), "/")
+	config, _ := json.Marshal(policy)
+
+	// More specific patterns take precedence
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO policies (name, policy_type, config, priority)
+		VALUES (?, 'replication', ?, ?)
+		ON CONFLICT(name) DO UPDATE SET config = excluded.config, priority = excluded.priority
+	`, "replication:"+policy.Pattern, string(config), patternPriority(policy.Pattern))
+	if err != nil {
+		return fmt.Errorf("failed to set replication policy: %w", err)
+	}
+	return nil
+}
+
+// RemovePolicy deletes the replication policy for a path pattern.
+func (r *Replicator) RemovePolicy(ctx context.Context, pattern string) error {
+	pattern = strings.Join(splitPath(pattern), "/")
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM policies WHERE name = ? AND policy_type = 'replication'
+	`, "replication:"+pattern)
+	if err != nil {
+		return fmt.Errorf("failed to remove replication policy: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no replication policy for: %s", pattern)
+	}
+	return nil
+}
+
+// ListPolicies returns all replication policies, most specific first.
+func (r *Replicator) ListPolicies(ctx context.Context) ([]ReplicationPolicy, error) {
+	return listReplicationPolicies(ctx, r.db)
+}
+
+func listReplicationPolicies(ctx context.Context, db *sql.DB) ([]ReplicationPolicy, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT config FROM policies WHERE policy_type = 'replication'
+		ORDER BY priority DESC, name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replication policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []ReplicationPolicy
+	for rows.Next() {
+		var config string
+		if err := rows.Scan(&config); err != nil {
+			return nil, fmt.Errorf("failed to scan replication policy: %w", err)
+		}
+		var p ReplicationPolicy
+		if json.Unmarshal([]byte(config), &p) == nil {
+			policies = append(policies, p)
+		}
+	}
+	// Rows written before patternPriority may carry another ranking
+	sort.SliceStable(policies, func(i, j int) bool {
+		return patternPriority(policies[i].Pattern) > patternPriority(policies[j].Pattern)
+	})
+	return policies, nil
+}
+
+// matchReplicationPolicy returns the first (most specific) policy matching
+// path, or nil. A plain pattern also matches everything beneath it.
+func matchReplicationPolicy(policies []ReplicationPolicy, path string) *ReplicationPolicy {
+	for i, p := range policies {
+		if MatchGlob(p.Pattern, path) || (!IsGlobPattern(p.Pattern) && strings.HasPrefix(path, p.Pattern+"/")) {
+			return &policies[i]
+		}
+	}
+	return nil
+}
+
+// Plan compares the verified copies of every entry covered by a policy with
+// the copies it requires, and plans the replicas to create or trim.
+func (r *Replicator) Plan(ctx context.Context) (*ReplicationPlan, error) {
+	plan := &ReplicationPlan{}
+
+	policies, err := listReplicationPolicies(ctx, r.db)
+	if err != nil || len(policies) == 0 {
+		return plan, err
+	}
+
+	paths, err := entryPaths(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	types, err := providerTypes(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect first: status queries must not run while rows are open
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT e.id FROM entries e
+		WHERE e.entry_type = 'file'
+		AND e.id NOT IN (SELECT original_entry_id FROM trash)
+		AND e.id NOT IN (SELECT entry_id FROM trash_items)
+		ORDER BY e.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entries: %w", err)
+	}
+	var entryIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan entry: %w", err)
+		}
+		entryIDs = append(entryIDs, id)
+	}
+	rows.Close()
+
+	for _, id := range entryIDs {
+		policy := matchReplicationPolicy(policies, paths[id])
+		if policy == nil {
+			continue
+		}
+		status, err := replicationStatus(ctx, r.db, id, paths[id], policy, types)
+		if err != nil {
+			return nil, err
+		}
+		if status == nil {
+			continue // No active version
+		}
+
+		if status.Current < status.Required {
+			if err := r.planCreates(ctx, status, types); err != nil {
+				return nil, err
+			}
+		} else {
+			r.planTrims(status)
+			if len(status.Trims) == 0 {
+				plan.Compliant++
+				continue
+			}
+		}
+
+		plan.Entries = append(plan.Entries, status)
+		plan.Creates += len(status.Creates)
+		plan.Trims += len(status.Trims)
+		plan.CreateBytes += int64(len(status.Creates)) * status.Size
+		plan.TrimBytes += int64(len(status.Trims)) * status.Size
+	}
+
+	sort.SliceStable(plan.Entries, func(i, j int) bool {
+		return plan.Entries[i].Path < plan.Entries[j].Path
+	})
+	return plan, nil
+}
+
+// planCreates plans the replicas an under-replicated entry is missing.
+// Copies still awaiting verification are not duplicated.
+func (r *Replicator) planCreates(ctx context.Context, status *ReplicationStatus, types map[string]string) error {
+	used := make(map[string]bool)
+	for _, c := range status.Copies {
+		if c.State == "verified" {
+			used[c.ProviderType] = true
+		}
+	}
+
+	need := status.Required - status.Current
+	for _, c := range status.Copies {
+		if c.State != "pending" && c.State != "uploaded" {
+			continue
+		}
+		if status.Policy.DistinctTypes {
+			if used[c.ProviderType] {
+				continue
+			}
+			used[c.ProviderType] = true
+		}
+		need--
+	}
+	if need <= 0 {
+		status.Reason = fmt.Sprintf("%d copies awaiting verification; run 'cloudfs scrub'", len(status.Unverified()))
+		return nil
+	}
+
+	base := &HealAction{EntryID: status.EntryID, Path: status.Path}
+	if err := r.healer.planSource(ctx, base); err != nil {
+		return err
+	}
+	if base.Source == nil {
+		status.Reason = base.Reason
+		return nil
+	}
+
+	fileName := filepath.Base(status.Path)
+	placement, err := r.planner.PlanReplica(ctx, fileName, base.Size, false, base.Holders)
+	if err != nil {
+		return fmt.Errorf("failed to plan replica: %w", err)
+	}
+
+	candidates := placement.Placements
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return preferenceRank(status.Policy, candidates[i].ProviderName) < preferenceRank(status.Policy, candidates[j].ProviderName)
+	})
+
+	for _, p := range candidates {
+		if len(status.Creates) == need {
+			break
+		}
+		if _, ok := r.healer.provider(p.ProviderName); !ok {
+			continue
+		}
+		if status.Policy.DistinctTypes {
+			if used[types[p.ProviderName]] {
+				continue
+			}
+			used[types[p.ProviderName]] = true
+		}
+
+		action := *base
+		action.Target = p.ProviderName
+		action.Reason = p.Reason
+		if preferenceRank(status.Policy, p.ProviderName) < len(status.Policy.Preferred) {
+			action.Reason = "preferred_provider"
+		}
+		action.TargetPath, err = r.healer.replicaPath(ctx, p.ProviderName, fileName)
+		if err != nil {
+			return err
+		}
+		status.Creates = append(status.Creates, &action)
+	}
+
+	if len(status.Creates) < need {
+		status.Reason = placement.Reason
+		if status.Reason == "" && status.Policy.DistinctTypes {
+			status.Reason = "not enough providers of distinct types"
+		}
+		if status.Reason == "" {
+			status.Reason = "not enough registered providers"
+		}
+		status.Reason = fmt.Sprintf("%d of %d missing copies can be placed: %s", len(status.Creates), need, status.Reason)
+	}
+	return nil
+}
+
+// planTrims selects verified copies beyond the policy. Preferred providers
+// and the most recently verified copies are kept.
+func (r *Replicator) planTrims(status *ReplicationStatus) {
+	var verified []ReplicaCopy
+	for _, c := range status.Copies {
+		if c.State == "verified" {
+			verified = append(verified, c)
+		}
+	}
+	sort.SliceStable(verified, func(i, j int) bool {
+		ri, rj := preferenceRank(status.Policy, verified[i].ProviderID), preferenceRank(status.Policy, verified[j].ProviderID)
+		if ri != rj {
+			return ri < rj
+		}
+		if verified[i].VerifiedAt == nil || verified[j].VerifiedAt == nil {
+			return verified[j].VerifiedAt == nil && verified[i].VerifiedAt != nil
+		}
+		return verified[i].VerifiedAt.After(*verified[j].VerifiedAt)
+	})
+
+	kept := 0
+	keptTypes := make(map[string]bool)
+	keptObjects := make(map[string]bool)
+	for _, c := range verified {
+		if keptObjects[replicaObject(c)] {
+			// Another placement of a kept object; deleting it would delete the copy
+			continue
+		}
+		if kept < status.Required && !(status.Policy.DistinctTypes && keptTypes[c.ProviderType]) {
+			kept++
+			keptTypes[c.ProviderType] = true
+			keptObjects[replicaObject(c)] = true
+			continue
+		}
+		status.Trims = append(status.Trims, PlacementRef{
+			PlacementID: c.PlacementID,
+			VersionID:   status.VersionID,
+			ProviderID:  c.ProviderID,
+			RemotePath:  c.RemotePath,
+			EntryName:   status.Path,
+			Size:        status.Size,
+		})
+	}
+}
+
+// Execute creates every planned replica, then trims excess copies.
+// Requires explicit user confirmation.
+func (r *Replicator) Execute(ctx context.Context, plan *ReplicationPlan, confirmed bool) (*ReplicationResult, error) {
+	if !confirmed {
+		return nil, fmt.Errorf("replicate requires explicit user confirmation")
+	}
+
+	result := &ReplicationResult{Errors: make([]error, 0)}
+	for _, status := range plan.Entries {
+		for _, action := range status.Creates {
+			if err := r.healer.copyReplica(ctx, "replicate", action); err != nil {
+				result.Failed++
+				result.Errors = append(result.Errors, fmt.Errorf("%s → %s: %w", status.Path, action.Target, err))
+				continue
+			}
+			result.Created++
+			result.Bytes += action.Size
+		}
+	}
+
+	if r.deleter == nil {
+		return result, nil
+	}
+	types, err := providerTypes(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range plan.Entries {
+		if len(status.Trims) == 0 {
+			continue
+		}
+
+		// Copies may have degraded since planning; never trim below the policy
+		fresh, err := replicationStatus(ctx, r.db, status.EntryID, status.Path, status.Policy, types)
+		if err != nil {
+			return nil, err
+		}
+		if fresh == nil || !trimSafe(fresh, status.Trims) {
+			result.Failed += len(status.Trims)
+			result.Errors = append(result.Errors, fmt.Errorf("%s: copies changed since planning, not trimmed", status.Path))
+			continue
+		}
+
+		deleted, err := r.deleter.Execute(ctx, &DeleteRequest{Placements: status.Trims, Source: DeleteSourceReplicationTrim}, true)
+		if err != nil {
+			return nil, err
+		}
+		result.Trimmed += deleted.Deleted
+		result.Failed += deleted.Failed
+		result.Errors = append(result.Errors, deleted.Errors...)
+	}
+	return result, nil
+}
+
+// trimSafe reports whether the verified copies left after trimming still
+// satisfy the policy and none of them shares an object being trimmed.
+func trimSafe(status *ReplicationStatus, trims []PlacementRef) bool {
+	trimmed := make(map[int64]bool, len(trims))
+	trimmedObjects := make(map[string]bool, len(trims))
+	for _, t := range trims {
+		trimmed[t.PlacementID] = true
+		trimmedObjects[replicaObject(ReplicaCopy{ProviderID: t.ProviderID, RemotePath: t.RemotePath})] = true
+	}
+	var remaining []ReplicaCopy
+	for _, c := range status.Copies {
+		if trimmed[c.PlacementID] {
+			continue
+		}
+		if trimmedObjects[replicaObject(c)] {
+			return false
+		}
+		remaining = append(remaining, c)
+	}
+	return countCopies(remaining, status.Policy.DistinctTypes) >= status.Required
+}
+
+// replicationStatus loads the copies of an entry's active version and
+// compares them with policy. Returns nil when there is no active version.
+func replicationStatus(ctx context.Context, db *sql.DB, entryID int64, path string, policy *ReplicationPolicy, types map[string]string) (*ReplicationStatus, error) {
+	status := &ReplicationStatus{
+		EntryID:  entryID,
+		Path:     path,
+		Policy:   policy,
+		Required: policy.MinCopies,
+	}
+
+	err := db.QueryRowContext(ctx, `
+		SELECT id, size, content_hash FROM versions
+		WHERE entry_id = ? AND state = 'active'
+		ORDER BY version_num DESC LIMIT 1
+	`, entryID).Scan(&status.VersionID, &status.Size, &status.ContentHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active version: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, provider_id, remote_path, state, verified_at FROM placements
+		WHERE version_id = ?
+		ORDER BY id
+	`, status.VersionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get placements: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c ReplicaCopy
+		var verifiedAt sql.NullString
+		if err := rows.Scan(&c.PlacementID, &c.ProviderID, &c.RemotePath, &c.State, &verifiedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan placement: %w", err)
+		}
+		c.ProviderType = types[c.ProviderID]
+		if c.ProviderType == "" {
+			c.ProviderType = c.ProviderID
+		}
+		if verifiedAt.Valid {
+			t := parseDBTime(verifiedAt.String)
+			c.VerifiedAt = &t
+		}
+		status.Copies = append(status.Copies, c)
+	}
+
+	status.Current = countCopies(status.Copies, policy.DistinctTypes)
+	return status, nil
+}
+
+// countCopies counts distinct verified objects, or the distinct provider
+// types holding them when distinct is set. Placements sharing one remote
+// object are a single copy.
+func countCopies(copies []ReplicaCopy, distinct bool) int {
+	n := 0
+	seen := make(map[string]bool)
+	objects := make(map[string]bool)
+	for _, c := range copies {
+		if c.State != "verified" || objects[replicaObject(c)] {
+			continue
+		}
+		objects[replicaObject(c)] = true
+		if distinct {
+			if seen[c.ProviderType] {
+				continue
+			}
+			seen[c.ProviderType] = true
+		}
+		n++
+	}
+	return n
+}
+
+// replicaObject identifies the remote object behind a copy.
+func replicaObject(c ReplicaCopy) string {
+	return c.ProviderID + "\x00" + canonicalRemotePath(c.RemotePath)
+}
+
+// preferenceRank returns the position of a provider in the policy's
+// preferred list, or len(Preferred) when it is not preferred.
+func preferenceRank(policy *ReplicationPolicy, name string) int {
+	for i, p := range policy.Preferred {
+		if p == name {
+			return i
+		}
+	}
+	return len(policy.Preferred)
+}
+
+// providerTypes maps provider names to their configured type.
+func providerTypes(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, type FROM providers`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list providers: %w", err)
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var name, providerType string
+		if err := rows.Scan(&name, &providerType); err != nil {
+			return nil, fmt.Errorf("failed to scan provider: %w", err)
+		}
+		types[name] = providerType
+	}
+	return types, nil
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestReplicator_PlanAndExecute(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	journal := NewJournalManager(db.DB())
+
+	providers := map[string]*memProvider{
+		"gdrive":  newMemProvider("gdrive"),
+		"gdrive2": newMemProvider("gdrive2"),
+		"b2":      newMemProvider("b2"),
+	}
+	registry := provider.NewRegistry()
+	for i, name := range []string{"gdrive", "gdrive2", "b2"} {
+		registry.Register(providers[name])
+		providerType := "drive"
+		if name == "b2" {
+			providerType = "b2"
+		}
+		db.DB().ExecContext(ctx, `INSERT INTO providers (name, type, priority) VALUES (?, ?, ?)`, name, providerType, i+1)
+	}
+
+	content := []byte("replicated bytes")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	dir := func(name string) *int64 {
+		entry := &model.Entry{Name: name, Type: model.EntryTypeDirectory}
+		im.CreateEntry(ctx, entry)
+		return &entry.ID
+	}
+	file := func(parent *int64, name string, holders map[string]string) {
+		entry := &model.Entry{Name: name, ParentID: parent, Type: model.EntryTypeFile, LogicalSize: int64(len(content))}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: hash, Size: int64(len(content)), State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+		for name, state := range holders {
+			remotePath := name + ":/" + entry.Name
+			db.DB().ExecContext(ctx, `
+				INSERT INTO placements (version_id, provider_id, remote_path, state, verified_at)
+				VALUES (?, ?, ?, ?, ?)
+			`, v.ID, name, remotePath, state, time.Now().UTC().Format(time.RFC3339))
+			providers[name].objects[remotePath] = int64(len(content))
+			providers[name].data[remotePath] = content
+		}
+	}
+
+	docs, media := dir("docs"), dir("media")
+	file(docs, "report.pdf", map[string]string{"gdrive": "verified"})
+	file(media, "clip.mp4", map[string]string{"gdrive": "verified", "b2": "verified"})
+	file(nil, "notes.txt", map[string]string{"gdrive": "uploaded"})
+
+	dc := NewDeleteCoordinator(db.DB(), journal, registry)
+	r := NewReplicator(db.DB(), NewPlacementPlanner(db.DB()), nil, journal, dc, registry, filepath.Join(tmpDir, "temp"))
+
+	if err := r.SetPolicy(ctx, ReplicationPolicy{Pattern: "docs", MinCopies: 0}); err == nil {
+		t.Error("expected policy with zero copies to be rejected")
+	}
+	r.SetPolicy(ctx, ReplicationPolicy{Pattern: "docs", MinCopies: 2, DistinctTypes: true})
+	r.SetPolicy(ctx, ReplicationPolicy{Pattern: "media/*.mp4", MinCopies: 1, Preferred: []string{"b2"}})
+
+	plan, err := r.Plan(ctx)
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+	if len(plan.Entries) != 2 || plan.Creates != 1 || plan.Trims != 1 {
+		t.Fatalf("expected 2 entries with 1 create and 1 trim, got %d/%d/%d", len(plan.Entries), plan.Creates, plan.Trims)
+	}
+
+	report, clip := plan.Entries[0], plan.Entries[1]
+	if report.Path != "docs/report.pdf" || report.Current != 1 || report.Required != 2 {
+		t.Errorf("unexpected report status: %+v", report)
+	}
+	// gdrive2 is the same provider type as gdrive, so only b2 qualifies
+	if a := report.Creates[0]; a.Target != "b2" || a.TargetPath != "b2:/report.pdf" || a.Source.ProviderID != "gdrive" {
+		t.Errorf("unexpected create: %+v", a)
+	}
+	// b2 is preferred, so the gdrive copy is trimmed
+	if clip.Path != "media/clip.mp4" || len(clip.Trims) != 1 || clip.Trims[0].ProviderID != "gdrive" {
+		t.Errorf("unexpected clip status: %+v", clip)
+	}
+
+	if _, err := r.Execute(ctx, plan, false); err == nil {
+		t.Error("expected replicate without confirmation to fail")
+	}
+	result, err := r.Execute(ctx, plan, true)
+	if err != nil {
+		t.Fatalf("failed to execute: %v", err)
+	}
+	if result.Created != 1 || result.Trimmed != 1 || result.Failed != 0 {
+		t.Fatalf("expected 1 created and 1 trimmed, got %+v", result)
+	}
+	if string(providers["b2"].data["b2:/report.pdf"]) != string(content) {
+		t.Error("expected report copied to b2")
+	}
+	if _, ok := providers["gdrive"].objects["gdrive:/clip.mp4"]; ok {
+		t.Error("expected clip trimmed from gdrive")
+	}
+	if _, ok := providers["gdrive"].objects["gdrive:/notes.txt"]; !ok {
+		t.Error("entry without a policy must not be touched")
+	}
+
+	exp := NewExplainer(db.DB(), tmpDir, tmpDir)
+	state := exp.getReplicationState(ctx, clip.EntryID)
+	if state == nil || state.Policy == nil || state.Current != 1 || state.Required != 1 || len(state.Verified) != 1 || state.Verified[0] != "b2" {
+		t.Errorf("unexpected explain replication state: %+v", state)
+	}
+}
+
+func TestReplicator_PolicySpecificity(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	r := NewReplicator(db.DB(), NewPlacementPlanner(db.DB()), nil, nil, nil, provider.NewRegistry(), filepath.Join(tmpDir, "temp"))
+
+	// A longer glob must not outrank a shorter literal path
+	r.SetPolicy(ctx, ReplicationPolicy{Pattern: "**/*.pdf", MinCopies: 1})
+	r.SetPolicy(ctx, ReplicationPolicy{Pattern: "docs", MinCopies: 3})
+	policies, err := r.ListPolicies(ctx)
+	if err != nil {
+		t.Fatalf("failed to list policies: %v", err)
+	}
+	if p := matchReplicationPolicy(policies, "docs/report.pdf"); p == nil || p.Pattern != "docs" {
+		t.Errorf("expected the docs policy to win, got %+v", p)
+	}
+}
+
+// TestReplicator_SharedObjects checks that placements naming one remote
+// object count as a single copy and that the object is never trimmed.
+func TestReplicator_SharedObjects(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	journal := NewJournalManager(db.DB())
+
+	registry := provider.NewRegistry()
+	for i, name := range []string{"gdrive", "b2"} {
+		registry.Register(newMemProvider(name))
+		db.DB().ExecContext(ctx, `INSERT INTO providers (name, type, priority) VALUES (?, ?, ?)`, name, name, i+1)
+	}
+
+	dir := &model.Entry{Name: "docs", Type: model.EntryTypeDirectory}
+	im.CreateEntry(ctx, dir)
+	file := func(name string, holders ...string) {
+		entry := &model.Entry{Name: name, ParentID: &dir.ID, Type: model.EntryTypeFile, LogicalSize: 4}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{EntryID: entry.ID, VersionNum: 1, Size: 4, State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+		for _, holder := range holders {
+			db.DB().ExecContext(ctx, `
+				INSERT INTO placements (version_id, provider_id, remote_path, state, verified_at)
+				VALUES (?, ?, ?, 'verified', ?)
+			`, v.ID, holder, holder+":/"+name, time.Now().UTC().Format(time.RFC3339))
+		}
+	}
+	// Two rows for one gdrive object are one copy
+	file("a.txt", "gdrive", "gdrive")
+	// The second gdrive row must not be trimmed while the first is kept
+	file("b.txt", "gdrive", "gdrive", "b2")
+
+	dc := NewDeleteCoordinator(db.DB(), journal, registry)
+	r := NewReplicator(db.DB(), NewPlacementPlanner(db.DB()), nil, journal, dc, registry, filepath.Join(tmpDir, "temp"))
+	r.SetPolicy(ctx, ReplicationPolicy{Pattern: "docs/a.txt", MinCopies: 2})
+	r.SetPolicy(ctx, ReplicationPolicy{Pattern: "docs/b.txt", MinCopies: 1, Preferred: []string{"gdrive"}})
+
+	plan, err := r.Plan(ctx)
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+	if len(plan.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", plan.Entries)
+	}
+	a, b := plan.Entries[0], plan.Entries[1]
+	if a.Current != 1 || len(a.Creates) != 1 || a.Creates[0].Target != "b2" {
+		t.Errorf("expected one copy of a.txt and a create on b2, got %+v", a)
+	}
+	if b.Current != 2 || len(b.Trims) != 1 || b.Trims[0].ProviderID != "b2" {
+		t.Errorf("expected only the b2 copy of b.txt trimmed, got %+v", b)
+	}
+}
@@ -313,18 +313,33 @@ func (s *Scanner) ScanProviders(ctx context.Context) (*ScanResult, error) {
 	// Check unverified placements
 	var unverifiedCount int
 	s.db.QueryRowContext(ctx, `
-		SELECT COUNT(*) FROM placements WHERE verified = 0
+		SELECT COUNT(*) FROM placements WHERE state IN ('pending', 'uploaded')
 	`).Scan(&unverifiedCount)
 	if unverifiedCount > 0 {
 		result.Findings = append(result.Findings, ScanFinding{
 			Severity:    "warning",
 			Category:    "placements",
 			Description: fmt.Sprintf("%d unverified placements", unverifiedCount),
-			Suggestion:  "Run 'cloudfs verify' to verify",
+			Suggestion:  "Run 'cloudfs scan providers --hash' to check them against providers",
 		})
 		result.WarningCount++
 	}
 
+	// Check degraded placements
+	var degradedCount int
+	s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM placements WHERE state IN ('degraded', 'failed')
+	`).Scan(&degradedCount)
+	if degradedCount > 0 {
+		result.Findings = append(result.Findings, ScanFinding{
+			Severity:    "error",
+			Category:    "placements",
+			Description: fmt.Sprintf("%d degraded placements", degradedCount),
+			Suggestion:  "Run 'cloudfs heal' or 'cloudfs replicate' to add a healthy replica",
+		})
+		result.ErrorCount++
+	}
+
 	return result, nil
 }
 
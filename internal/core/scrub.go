@@ -0,0 +1,391 @@
+// Package core provides the placement verification scrubber for CloudFS.
+// Based on design.txt Section 18: Health scoring, and Section 5:
+// Backend mismatch → mark DEGRADED.
+//
+// INVARIANTS:
+// - Stalest placements (never verified first) are checked first
+// - Remote data is NEVER modified; only placement state and verified_at change
+// - Provider errors are inconclusive and NEVER degrade a placement
+// - Every conclusive check is logged so an interrupted run resumes where it stopped
+// - The byte budget caps downloads made to rehash objects; items that do not fit are skipped
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// metaScrubStarted records the start of an unfinished scrub run.
+const metaScrubStarted = "scrub_started_at"
+
+// scrubTimeFormat is fixed-width so run and check times compare as strings.
+const scrubTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+// Scrub results.
+const (
+	ScrubVerified = "verified" // Object present and content matches
+	ScrubMissing  = "missing"  // Object not found on provider
+	ScrubCorrupt  = "corrupt"  // Object hash differs from the index
+	ScrubError    = "error"    // Inconclusive; retried by the next run
+)
+
+// ScrubOptions controls a scrub run.
+type ScrubOptions struct {
+	OlderThan time.Duration // Only placements not verified within this window (0 = all)
+	Budget    int64         // Maximum bytes downloaded for rehashing (0 = unlimited)
+}
+
+// ScrubItem is a placement selected for verification.
+type ScrubItem struct {
+	PlacementID  int64
+	EntryID      int64
+	VersionID    int64
+	ProviderID   string
+	RemotePath   string
+	Path         string
+	Size         int64
+	ExpectedHash string
+	LastVerified *time.Time
+	Result       string
+	Method       string // "provider_hash", "download" or "exists"
+	Detail       string
+}
+
+// ScrubResult summarizes a scrub run.
+type ScrubResult struct {
+	Resumed    bool // Continued an interrupted run
+	Checked    int
+	Verified   int
+	Degraded   int
+	Errors     int
+	Downloaded int64
+	Remaining  int  // Candidates left when the run stopped or skipped for the budget
+	Complete   bool // No candidates remain; the run state is cleared
+	Items      []*ScrubItem
+}
+
+// ScrubProgressFunc is called after each placement is checked.
+type ScrubProgressFunc func(item *ScrubItem)
+
+// Scrubber verifies placements against providers and records verified_at.
+type Scrubber struct {
+	db       *sql.DB
+	registry provider.Registry
+	tempDir  string
+}
+
+// NewScrubber creates a new scrubber.
+// tempDir stages downloads for backends that cannot report SHA-256.
+func NewScrubber(db *sql.DB, registry provider.Registry, tempDir string) *Scrubber {
+	return &Scrubber{
+		db:       db,
+		registry: registry,
+		tempDir:  tempDir,
+	}
+}
+
+// Plan returns the placements the next scrub run would check, stalest first.
+// resumed reports whether an interrupted run is being continued.
+func (s *Scrubber) Plan(ctx context.Context, opts ScrubOptions) (items []*ScrubItem, resumed bool, err error) {
+	started, err := s.runStarted(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	resumed = started != ""
+	if !resumed {
+		started = time.Now().UTC().Format(scrubTimeFormat)
+	}
+
+	cutoff := "9999"
+	if opts.OlderThan > 0 {
+		cutoff = time.Now().UTC().Add(-opts.OlderThan).Format(time.RFC3339)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.id, COALESCE(v.entry_id, cv.entry_id, 0), COALESCE(p.version_id, c.version_id, 0),
+		       p.provider_id, p.remote_path, COALESCE(c.size, v.size, 0),
+		       CASE WHEN p.chunk_id IS NOT NULL THEN COALESCE(c.chunk_hash, '') ELSE COALESCE(v.content_hash, '') END,
+		       p.verified_at
+		FROM placements p
+		LEFT JOIN chunks c ON c.id = p.chunk_id
+		LEFT JOIN versions v ON v.id = p.version_id
+		LEFT JOIN versions cv ON cv.id = c.version_id
+		LEFT JOIN scrub_log s ON s.placement_id = p.id
+		WHERE (p.verified_at IS NULL OR p.verified_at < ?)
+		  AND (s.checked_at IS NULL OR s.checked_at < ?)
+		ORDER BY p.verified_at IS NOT NULL, p.verified_at, p.id
+	`, cutoff, started)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to select placements: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &ScrubItem{}
+		var verifiedAt sql.NullString
+		if err := rows.Scan(&item.PlacementID, &item.EntryID, &item.VersionID, &item.ProviderID,
+			&item.RemotePath, &item.Size, &item.ExpectedHash, &verifiedAt); err != nil {
+			return nil, false, fmt.Errorf("failed to scan placement: %w", err)
+		}
+		if verifiedAt.Valid {
+			t := parseDBTime(verifiedAt.String)
+			item.LastVerified = &t
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to select placements: %w", err)
+	}
+
+	paths, err := entryPaths(ctx, s.db)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, item := range items {
+		item.Path = paths[item.EntryID]
+	}
+	return items, resumed, nil
+}
+
+// Scrub verifies the stalest placements until every candidate is checked,
+// the download budget runs out, or ctx is cancelled. A run that stops early
+// is resumed by the next call without re-checking completed placements.
+func (s *Scrubber) Scrub(ctx context.Context, opts ScrubOptions, progress ScrubProgressFunc) (*ScrubResult, error) {
+	items, resumed, err := s.Plan(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ScrubResult{Resumed: resumed}
+	if !resumed {
+		if err := s.setRunStarted(ctx, time.Now().UTC().Format(scrubTimeFormat)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Results are still recorded after ctx is cancelled so the run can resume
+	store := context.WithoutCancel(ctx)
+
+	touched := make(map[int64]bool)
+	for i, item := range items {
+		if ctx.Err() != nil {
+			result.Remaining += len(items) - i
+			break
+		}
+
+		var prov provider.Provider
+		ok := false
+		if s.registry != nil {
+			prov, ok = s.registry.Get(item.ProviderID)
+		}
+		if !ok {
+			item.Result = ScrubError
+			item.Detail = fmt.Sprintf("provider %s not available", item.ProviderID)
+		} else if skip := s.check(ctx, prov, item, opts.Budget-result.Downloaded, opts.Budget > 0); skip {
+			// Left for the next run; smaller items may still fit
+			result.Remaining++
+			continue
+		}
+
+		if item.Method == "download" {
+			result.Downloaded += item.Size
+		}
+		if err := s.record(store, item); err != nil {
+			return result, err
+		}
+
+		result.Checked++
+		switch item.Result {
+		case ScrubVerified:
+			result.Verified++
+		case ScrubMissing, ScrubCorrupt:
+			result.Degraded++
+		default:
+			result.Errors++
+		}
+		if item.Result != ScrubError && item.EntryID != 0 {
+			touched[item.EntryID] = true
+		}
+		result.Items = append(result.Items, item)
+		if progress != nil {
+			progress(item)
+		}
+	}
+
+	for entryID := range touched {
+		if err := s.recordHealth(store, entryID); err != nil {
+			return result, err
+		}
+	}
+
+	if result.Remaining == 0 {
+		result.Complete = true
+		if _, err := s.db.ExecContext(store, `DELETE FROM index_meta WHERE key = ?`, metaScrubStarted); err != nil {
+			return result, fmt.Errorf("failed to clear scrub state: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// check verifies one placement. It returns true, leaving the item
+// unchecked, when it would exceed the remaining download budget.
+func (s *Scrubber) check(ctx context.Context, prov provider.Provider, item *ScrubItem, remaining int64, limited bool) bool {
+	vr, err := prov.Verify(ctx, item.RemotePath)
+	if err != nil {
+		item.Result = ScrubError
+		item.Detail = err.Error()
+		return false
+	}
+	if !vr.IsValid {
+		item.Result = ScrubMissing
+		item.Method = "exists"
+		item.Detail = vr.ErrorMessage
+		return false
+	}
+
+	switch {
+	case item.ExpectedHash == "":
+		// Nothing to compare against; existence is all we can confirm
+		item.Method = "exists"
+		item.Result = ScrubVerified
+	case vr.ContentHash != "":
+		item.Method = "provider_hash"
+		item.Result = compareHash(item.ExpectedHash, vr.ContentHash)
+	default:
+		if limited && item.Size > remaining {
+			return true
+		}
+		item.Method = "download"
+		hash, err := s.downloadHash(ctx, prov, item)
+		if err != nil {
+			item.Result = ScrubError
+			item.Detail = err.Error()
+			return false
+		}
+		item.Result = compareHash(item.ExpectedHash, hash)
+	}
+
+	if item.Result == ScrubCorrupt {
+		item.Detail = "content hash does not match the index"
+	}
+	return false
+}
+
+// downloadHash downloads an object to the temp dir and returns its SHA-256.
+func (s *Scrubber) downloadHash(ctx context.Context, prov provider.Provider, item *ScrubItem) (string, error) {
+	if err := os.MkdirAll(s.tempDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	tempPath := filepath.Join(s.tempDir, fmt.Sprintf("scrub_%d_%d", item.PlacementID, time.Now().UnixNano()))
+	defer os.Remove(tempPath)
+
+	if _, err := prov.Download(ctx, item.RemotePath, tempPath, nil); err != nil {
+		return "", fmt.Errorf("failed to download for rehash: %w", err)
+	}
+
+	hash, err := calculateFileHash(tempPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash download: %w", err)
+	}
+	return hash, nil
+}
+
+// record stores the outcome of a check. Conclusive results update the
+// placement and the scrub log; errors are left for the next run.
+func (s *Scrubber) record(ctx context.Context, item *ScrubItem) error {
+	if item.Result == ScrubError {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	var err error
+	if item.Result == ScrubVerified {
+		_, err = s.db.ExecContext(ctx, `
+			UPDATE placements SET verified_at = ?, state = 'verified' WHERE id = ?
+		`, now.Format(time.RFC3339), item.PlacementID)
+	} else {
+		_, err = s.db.ExecContext(ctx, `
+			UPDATE placements SET state = 'degraded' WHERE id = ?
+		`, item.PlacementID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update placement: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO scrub_log (placement_id, checked_at, result, detail) VALUES (?, ?, ?, ?)
+		ON CONFLICT(placement_id) DO UPDATE SET
+			checked_at = excluded.checked_at,
+			result = excluded.result,
+			detail = excluded.detail
+	`, item.PlacementID, now.Format(scrubTimeFormat), item.Result, item.Detail)
+	if err != nil {
+		return fmt.Errorf("failed to log scrub result: %w", err)
+	}
+	return nil
+}
+
+// recordHealth writes a health_metrics row for an entry after scrubbing.
+func (s *Scrubber) recordHealth(ctx context.Context, entryID int64) error {
+	in, err := gatherHealthInputs(ctx, s.db, entryID)
+	if err != nil {
+		return fmt.Errorf("failed to measure health: %w", err)
+	}
+
+	var ageDays sql.NullInt64
+	if in.LastVerified != nil {
+		ageDays = sql.NullInt64{Int64: int64(time.Since(*in.LastVerified).Hours() / 24), Valid: true}
+	}
+	var providerScore sql.NullFloat64
+	if ps := in.ProviderHealthScore(); ps != nil {
+		providerScore = sql.NullFloat64{Float64: *ps, Valid: true}
+	}
+	score, _ := NewHealthManager(s.db).scoringModel(ctx).Score(in)
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO health_metrics (entry_id, replication_count, verification_age_days,
+			parity_available, provider_health_score, overall_score)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, entryID, in.Replicas, ageDays, in.ParityAvailable, providerScore, score)
+	if err != nil {
+		return fmt.Errorf("failed to record health metrics: %w", err)
+	}
+	return nil
+}
+
+// runStarted returns the start time of an unfinished run, or "".
+func (s *Scrubber) runStarted(ctx context.Context) (string, error) {
+	var started string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM index_meta WHERE key = ?`, metaScrubStarted).Scan(&started)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to read scrub state: %w", err)
+	}
+	return started, nil
+}
+
+// setRunStarted records the start of a new run.
+func (s *Scrubber) setRunStarted(ctx context.Context, started string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO index_meta (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, metaScrubStarted, started)
+	if err != nil {
+		return fmt.Errorf("failed to store scrub state: %w", err)
+	}
+	return nil
+}
+
+// compareHash returns ScrubVerified when two hex hashes match.
+func compareHash(expected, actual string) string {
+	if strings.EqualFold(expected, actual) {
+		return ScrubVerified
+	}
+	return ScrubCorrupt
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestScrubber_ScrubAndResume(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	gdrive := newMemProvider("gdrive")
+	registry := provider.NewRegistry()
+	registry.Register(gdrive)
+
+	place := func(name, hash, verifiedAt string) int64 {
+		entry := &model.Entry{Name: name, Type: model.EntryTypeFile, LogicalSize: 100}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: name, Size: 100, State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+		res, _ := db.DB().ExecContext(ctx, `
+			INSERT INTO placements (version_id, provider_id, remote_path, state, verified_at)
+			VALUES (?, 'gdrive', ?, 'uploaded', NULLIF(?, ''))
+		`, v.ID, "gdrive:"+name, verifiedAt)
+		id, _ := res.LastInsertId()
+		gdrive.objects["gdrive:"+name] = 100
+		gdrive.hashes["gdrive:"+name] = hash
+		return id
+	}
+
+	good := place("good.txt", "good.txt", "2020-01-01T00:00:00Z")
+	corrupt := place("corrupt.txt", "bad", "")
+	missing := place("missing.txt", "missing.txt", "")
+	unhashed := place("unhashed.txt", "", "2021-01-01T00:00:00Z")
+	delete(gdrive.objects, "gdrive:missing.txt")
+
+	scrubber := NewScrubber(db.DB(), registry, filepath.Join(tmpDir, "temp"))
+
+	items, _, err := scrubber.Plan(ctx, ScrubOptions{})
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+	if len(items) != 4 || items[0].LastVerified != nil || items[2].PlacementID != good || items[3].PlacementID != unhashed {
+		t.Fatalf("expected never-verified placements first, then oldest: %+v", items)
+	}
+
+	// A tiny budget skips downloading the unhashed object
+	result, err := scrubber.Scrub(ctx, ScrubOptions{Budget: 10}, nil)
+	if err != nil {
+		t.Fatalf("failed to scrub: %v", err)
+	}
+	if result.Checked != 3 || result.Verified != 1 || result.Degraded != 2 || result.Complete || result.Remaining != 1 {
+		t.Errorf("unexpected first run: %+v", result)
+	}
+
+	state := func(id int64) (string, bool) {
+		var s string
+		var verifiedAt *string
+		db.DB().QueryRowContext(ctx, `SELECT state, verified_at FROM placements WHERE id = ?`, id).Scan(&s, &verifiedAt)
+		return s, verifiedAt != nil && *verifiedAt > "2020-01-01T00:00:00Z"
+	}
+	if s, fresh := state(good); s != "verified" || !fresh {
+		t.Errorf("expected good.txt verified with fresh verified_at, got %s", s)
+	}
+	if s, _ := state(corrupt); s != "degraded" {
+		t.Errorf("expected corrupt.txt degraded, got %s", s)
+	}
+	if s, _ := state(missing); s != "degraded" {
+		t.Errorf("expected missing.txt degraded, got %s", s)
+	}
+
+	var metrics int
+	db.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM health_metrics`).Scan(&metrics)
+	if metrics != 3 {
+		t.Errorf("expected 3 health_metrics rows, got %d", metrics)
+	}
+
+	// The next run resumes with only the remaining placement
+	items, resumed, _ := scrubber.Plan(ctx, ScrubOptions{})
+	if !resumed || len(items) != 1 || items[0].PlacementID != unhashed {
+		t.Fatalf("expected resume with unhashed.txt only, got resumed=%v %+v", resumed, items)
+	}
+
+	// Hash now available from the provider; the run completes
+	gdrive.hashes["gdrive:unhashed.txt"] = "unhashed.txt"
+	result, err = scrubber.Scrub(ctx, ScrubOptions{}, nil)
+	if err != nil {
+		t.Fatalf("failed to resume scrub: %v", err)
+	}
+	if !result.Resumed || result.Checked != 1 || !result.Complete {
+		t.Errorf("unexpected resumed run: %+v", result)
+	}
+
+	// A fresh run with --older-than skips recently verified placements
+	items, resumed, _ = scrubber.Plan(ctx, ScrubOptions{OlderThan: 24 * time.Hour})
+	if resumed || len(items) != 2 {
+		t.Errorf("expected only the 2 degraded placements, got resumed=%v %d items", resumed, len(items))
+	}
+}
+
+func TestScrubber_ErrorsAndBudget(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	gdrive := newMemProvider("gdrive")
+	b2 := newMemProvider("b2")
+	b2.failVerify = true
+	registry := provider.NewRegistry()
+	registry.Register(gdrive)
+	registry.Register(b2)
+
+	place := func(prov *memProvider, name, hash string, size int64) int64 {
+		entry := &model.Entry{Name: name, Type: model.EntryTypeFile, LogicalSize: size}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: name, Size: size, State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+		res, _ := db.DB().ExecContext(ctx, `
+			INSERT INTO placements (version_id, provider_id, remote_path, state)
+			VALUES (?, ?, ?, 'uploaded')
+		`, v.ID, prov.id, prov.id+":"+name)
+		id, _ := res.LastInsertId()
+		prov.objects[prov.id+":"+name] = size
+		prov.hashes[prov.id+":"+name] = hash
+		return id
+	}
+
+	big := place(gdrive, "big.bin", "", 100)
+	small := place(gdrive, "small.txt", "small.txt", 5)
+	outage := place(b2, "outage.txt", "outage.txt", 5)
+
+	// big.bin needs a download that exceeds the budget; the run moves on
+	scrubber := NewScrubber(db.DB(), registry, filepath.Join(tmpDir, "temp"))
+	result, err := scrubber.Scrub(ctx, ScrubOptions{Budget: 10}, nil)
+	if err != nil {
+		t.Fatalf("failed to scrub: %v", err)
+	}
+	if result.Checked != 2 || result.Verified != 1 || result.Errors != 1 || result.Degraded != 0 ||
+		result.Remaining != 1 || result.Complete {
+		t.Errorf("unexpected run: %+v", result)
+	}
+
+	state := func(id int64) (string, bool) {
+		var s string
+		var verifiedAt *string
+		db.DB().QueryRowContext(ctx, `SELECT state, verified_at FROM placements WHERE id = ?`, id).Scan(&s, &verifiedAt)
+		return s, verifiedAt != nil
+	}
+	if s, _ := state(small); s != "verified" {
+		t.Errorf("expected small.txt verified past the oversize item, got %s", s)
+	}
+	if s, verified := state(big); s != "uploaded" || verified {
+		t.Errorf("expected big.bin left unchecked, got %s", s)
+	}
+	// A provider error is inconclusive and must not touch the placement
+	if s, verified := state(outage); s != "uploaded" || verified {
+		t.Errorf("expected outage.txt untouched by a provider error, got %s", s)
+	}
+
+	items, resumed, _ := scrubber.Plan(ctx, ScrubOptions{})
+	if !resumed || len(items) != 2 || items[0].PlacementID != big || items[1].PlacementID != outage {
+		t.Errorf("expected resume with big.bin and outage.txt, got resumed=%v %+v", resumed, items)
+	}
+}
@@ -0,0 +1,150 @@
+// Package core provides tracking of provider transfers in flight.
+// Commands record their transfers so the TUI can show live progress.
+//
+// INVARIANTS:
+// - Tracking is OBSERVATIONAL; a transfer never fails because it cannot be recorded
+// - A row exists only while its transfer runs; Finish removes it
+// - Progress is written at most once per TransferUpdateInterval
+// - Rows of crashed commands are reported as stalled, then pruned
+package core
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// Transfer directions.
+const (
+	TransferUpload   = "upload"
+	TransferDownload = "download"
+)
+
+const (
+	// TransferUpdateInterval bounds how often progress is written.
+	TransferUpdateInterval = time.Second
+	// TransferStallAfter is how long without progress before a transfer
+	// is reported as stalled.
+	TransferStallAfter = 30 * time.Second
+	// transferPruneAfter is how long a stalled row is kept.
+	transferPruneAfter = time.Hour
+)
+
+// TransferStatus is a transfer in flight as recorded in the index.
+type TransferStatus struct {
+	ID        int64
+	Direction string
+	Name      string
+	Provider  string
+	Progress  provider.Progress
+	StartedAt time.Time
+	UpdatedAt time.Time
+	Stalled   bool // No progress for TransferStallAfter
+}
+
+// TransferTracker records transfers in flight.
+type TransferTracker struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewTransferTracker creates a new transfer tracker.
+func NewTransferTracker(db *sql.DB) *TransferTracker {
+	return &TransferTracker{db: db, now: time.Now}
+}
+
+// Transfer is a tracked transfer. Its methods are safe for concurrent use.
+type Transfer struct {
+	tt      *TransferTracker
+	id      int64 // 0 when the transfer could not be recorded
+	mu      sync.Mutex
+	written time.Time
+}
+
+// Start records a new transfer of total bytes (0 when unknown).
+func (tt *TransferTracker) Start(ctx context.Context, direction, name, providerName string, total int64) *Transfer {
+	now := tt.now().UTC()
+	tt.db.ExecContext(ctx, `DELETE FROM transfers WHERE updated_at < ?`,
+		now.Add(-transferPruneAfter).Format(time.RFC3339))
+
+	t := &Transfer{tt: tt, written: now}
+	res, err := tt.db.ExecContext(ctx, `
+		INSERT INTO transfers (direction, name, provider, total_bytes, started_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, direction, name, providerName, total, now.Format(time.RFC3339), now.Format(time.RFC3339))
+	if err == nil {
+		t.id, _ = res.LastInsertId()
+	}
+	return t
+}
+
+// Update records progress. Updates within TransferUpdateInterval of the
+// last write are dropped, except the one completing the transfer.
+func (t *Transfer) Update(p provider.Progress) {
+	if t == nil || t.id == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.tt.now().UTC()
+	complete := p.Total > 0 && p.Bytes >= p.Total
+	if now.Sub(t.written) < TransferUpdateInterval && !complete {
+		return
+	}
+	t.written = now
+
+	var eta sql.NullInt64
+	if p.ETA > 0 {
+		eta = sql.NullInt64{Int64: int64(p.ETA.Seconds()), Valid: true}
+	}
+	// The transfer must not be cancelled half way through a write
+	ctx, cancel := context.WithTimeout(context.Background(), TransferUpdateInterval)
+	defer cancel()
+	t.tt.db.ExecContext(ctx, `
+		UPDATE transfers SET bytes = ?, total_bytes = MAX(total_bytes, ?), speed = ?, eta_seconds = ?, updated_at = ?
+		WHERE id = ?
+	`, p.Bytes, p.Total, p.Speed, eta, now.Format(time.RFC3339), t.id)
+}
+
+// Finish removes the transfer, whether it succeeded or not.
+func (t *Transfer) Finish() {
+	if t == nil || t.id == 0 {
+		return
+	}
+	t.tt.db.ExecContext(context.Background(), `DELETE FROM transfers WHERE id = ?`, t.id)
+}
+
+// Active returns the transfers in flight, oldest first.
+func (tt *TransferTracker) Active(ctx context.Context) ([]TransferStatus, error) {
+	rows, err := tt.db.QueryContext(ctx, `
+		SELECT id, direction, name, provider, bytes, total_bytes, speed, eta_seconds, started_at, updated_at
+		FROM transfers ORDER BY started_at, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := tt.now()
+	var transfers []TransferStatus
+	for rows.Next() {
+		var s TransferStatus
+		var eta sql.NullInt64
+		var startedAt, updatedAt string
+		if err := rows.Scan(&s.ID, &s.Direction, &s.Name, &s.Provider, &s.Progress.Bytes, &s.Progress.Total,
+			&s.Progress.Speed, &eta, &startedAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		if eta.Valid {
+			s.Progress.ETA = time.Duration(eta.Int64) * time.Second
+		}
+		s.StartedAt = parseDBTime(startedAt)
+		s.UpdatedAt = parseDBTime(updatedAt)
+		s.Stalled = now.Sub(s.UpdatedAt) > TransferStallAfter
+		transfers = append(transfers, s)
+	}
+	return transfers, rows.Err()
+}
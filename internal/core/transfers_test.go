@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestTransferTracker_Lifecycle(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+
+	clock := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tt := NewTransferTracker(db.DB())
+	tt.now = func() time.Time { return clock }
+
+	up := tt.Start(ctx, TransferUpload, "video.mkv", "gdrive", 1000)
+	down := tt.Start(ctx, TransferDownload, "thesis.pdf", "b2", 0)
+
+	// Updates within the interval are dropped, completion is not
+	clock = clock.Add(2 * time.Second)
+	up.Update(provider.Progress{Bytes: 400, Total: 1000, Speed: 200, ETA: 3 * time.Second})
+	up.Update(provider.Progress{Bytes: 500, Total: 1000, Speed: 200})
+	down.Update(provider.Progress{Bytes: 50, Total: 100})
+	down.Update(provider.Progress{Bytes: 100, Total: 100})
+
+	active, err := tt.Active(ctx)
+	if err != nil {
+		t.Fatalf("Active failed: %v", err)
+	}
+	if len(active) != 2 {
+		t.Fatalf("expected 2 transfers, got %+v", active)
+	}
+	want := provider.Progress{Bytes: 400, Total: 1000, Speed: 200, ETA: 3 * time.Second}
+	if active[0].Name != "video.mkv" || active[0].Direction != TransferUpload || active[0].Progress != want {
+		t.Errorf("unexpected upload status: %+v", active[0])
+	}
+	if active[1].Provider != "b2" || active[1].Progress.Bytes != 100 || active[1].Progress.Total != 100 {
+		t.Errorf("expected completed download recorded, got %+v", active[1])
+	}
+	if active[0].Stalled || !active[0].StartedAt.Equal(clock.Add(-2*time.Second)) {
+		t.Errorf("unexpected timestamps: %+v", active[0])
+	}
+
+	// A transfer without progress is reported as stalled, then pruned
+	clock = clock.Add(TransferStallAfter + time.Second)
+	active, _ = tt.Active(ctx)
+	if len(active) != 2 || !active[0].Stalled {
+		t.Errorf("expected stalled transfers, got %+v", active)
+	}
+	up.Finish()
+	clock = clock.Add(transferPruneAfter)
+	tt.Start(ctx, TransferUpload, "next.bin", "gdrive", 1).Finish()
+	if active, _ = tt.Active(ctx); len(active) != 0 {
+		t.Errorf("expected finished and stale transfers removed, got %+v", active)
+	}
+
+	// Tracking never fails the transfer, even without the table
+	db.DB().ExecContext(ctx, `DROP TABLE transfers`)
+	orphan := tt.Start(ctx, TransferUpload, "a", "b", 1)
+	orphan.Update(provider.Progress{Bytes: 1, Total: 1})
+	orphan.Finish()
+}
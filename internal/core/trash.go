@@ -14,6 +14,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,6 +27,7 @@ import (
 type TrashManager struct {
 	db      *sql.DB
 	journal *JournalManager
+	deleter *DeleteCoordinator // Remote deletion on purge
 	mu      sync.RWMutex
 }
 
@@ -35,15 +39,31 @@ func NewTrashManager(db *sql.DB, journal *JournalManager) *TrashManager {
 	}
 }
 
+// SetDeleteCoordinator sets the coordinator used to delete provider data on purge.
+func (tm *TrashManager) SetDeleteCoordinator(dc *DeleteCoordinator) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.deleter = dc
+}
+
 // TrashInfo contains detailed trash entry information.
 type TrashInfo struct {
-	Entry       *model.TrashEntry
+	Entry        *model.TrashEntry
 	OriginalName string
-	Size        int64
-	DaysInTrash int
+	Size         int64
+	DaysInTrash  int
+	Members      int // Entries in the group (1 for a file, subtree size for a directory)
 }
 
+// trashGroupSizeSQL sums the versions held by every member of trash row t.
+// Rows trashed before groups existed fall back to the trash row's version.
+const trashGroupSizeSQL = `COALESCE(
+	(SELECT SUM(v.size) FROM trash_items ti JOIN versions v ON v.id = ti.version_id WHERE ti.trash_id = t.id),
+	(SELECT v.size FROM versions v WHERE v.id = t.version_id),
+	0)`
+
 // MoveToTrash moves an entry to trash.
+// Directories are trashed with their whole subtree as one group.
 // This does NOT delete provider data - only marks as deleted.
 func (tm *TrashManager) MoveToTrash(ctx context.Context, entryID int64, originalPath string, autoPurgeDays int) error {
 	tm.mu.Lock()
@@ -59,14 +79,33 @@ func (tm *TrashManager) MoveToTrash(ctx context.Context, entryID int64, original
 		return fmt.Errorf("failed to begin journal: %w", err)
 	}
 
-	// Get active version ID
-	var versionID sql.NullInt64
-	err = tm.db.QueryRowContext(ctx, `
-		SELECT id FROM versions WHERE entry_id = ? AND state = 'active'
-	`, entryID).Scan(&versionID)
-	if err != nil && err != sql.ErrNoRows {
+	members, err := tm.subtree(ctx, entryID)
+	if err != nil {
 		tm.journal.RollbackOperation(ctx, opID, err.Error())
-		return fmt.Errorf("failed to get version: %w", err)
+		return err
+	}
+
+	// Remember each member's active version so restore can reactivate it.
+	// Members already trashed on their own keep the version recorded then.
+	activeVersions := make(map[int64]sql.NullInt64, len(members))
+	for _, id := range members {
+		var versionID sql.NullInt64
+		err := tm.db.QueryRowContext(ctx, `
+			SELECT id FROM versions WHERE entry_id = ? AND state = 'active'
+		`, id).Scan(&versionID)
+		if err != nil && err != sql.ErrNoRows {
+			tm.journal.RollbackOperation(ctx, opID, err.Error())
+			return fmt.Errorf("failed to get version: %w", err)
+		}
+		activeVersions[id] = versionID
+	}
+	nested, err := tm.nestedTrash(ctx, members)
+	if err != nil {
+		tm.journal.RollbackOperation(ctx, opID, err.Error())
+		return err
+	}
+	for _, item := range nested {
+		activeVersions[item.entryID] = item.versionID
 	}
 
 	// Calculate auto-purge date if specified
@@ -77,22 +116,40 @@ func (tm *TrashManager) MoveToTrash(ctx context.Context, entryID int64, original
 	}
 
 	// Insert into trash
-	_, err = tm.db.ExecContext(ctx, `
+	result, err := tm.db.ExecContext(ctx, `
 		INSERT INTO trash (original_entry_id, original_path, version_id, auto_purge_after)
 		VALUES (?, ?, ?, ?)
-	`, entryID, originalPath, versionID, autoPurgeAfter)
+	`, entryID, originalPath, activeVersions[entryID], autoPurgeAfter)
 	if err != nil {
 		tm.journal.RollbackOperation(ctx, opID, err.Error())
 		return fmt.Errorf("failed to add to trash: %w", err)
 	}
+	trashID, _ := result.LastInsertId()
+
+	// Record group members; fold earlier trash rows inside the subtree into this group
+	for _, id := range members {
+		_, err := tm.db.ExecContext(ctx, `
+			INSERT INTO trash_items (trash_id, entry_id, version_id) VALUES (?, ?, ?)
+		`, trashID, id, activeVersions[id])
+		if err != nil {
+			tm.journal.RollbackOperation(ctx, opID, err.Error())
+			return fmt.Errorf("failed to record trash group: %w", err)
+		}
+	}
+	for _, item := range nested {
+		tm.db.ExecContext(ctx, `DELETE FROM trash_items WHERE trash_id = ?`, item.trashID)
+		tm.db.ExecContext(ctx, `DELETE FROM trash WHERE id = ?`, item.trashID)
+	}
 
 	// Mark versions as deleted (but don't remove from DB)
-	_, err = tm.db.ExecContext(ctx, `
-		UPDATE versions SET state = 'deleted' WHERE entry_id = ?
-	`, entryID)
-	if err != nil {
-		tm.journal.RollbackOperation(ctx, opID, err.Error())
-		return fmt.Errorf("failed to mark versions deleted: %w", err)
+	for _, id := range members {
+		_, err = tm.db.ExecContext(ctx, `
+			UPDATE versions SET state = 'deleted' WHERE entry_id = ?
+		`, id)
+		if err != nil {
+			tm.journal.RollbackOperation(ctx, opID, err.Error())
+			return fmt.Errorf("failed to mark versions deleted: %w", err)
+		}
 	}
 
 	// Complete journal
@@ -102,6 +159,85 @@ func (tm *TrashManager) MoveToTrash(ctx context.Context, entryID int64, original
 	return nil
 }
 
+// subtree returns entryID and every entry beneath it.
+func (tm *TrashManager) subtree(ctx context.Context, entryID int64) ([]int64, error) {
+	rows, err := tm.db.QueryContext(ctx, `
+		WITH RECURSIVE sub(id) AS (
+			SELECT ?
+			UNION
+			SELECT e.id FROM entries e JOIN sub ON e.parent_id = sub.id
+		)
+		SELECT id FROM sub
+	`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk subtree: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan subtree: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// trashItem is one member of a trash group.
+type trashItem struct {
+	trashID   int64
+	entryID   int64
+	versionID sql.NullInt64
+}
+
+// groupItems returns the members of a trash group.
+// Rows trashed before groups existed fall back to the trash row itself.
+func (tm *TrashManager) groupItems(ctx context.Context, trashID int64) ([]trashItem, error) {
+	rows, err := tm.db.QueryContext(ctx, `
+		SELECT trash_id, entry_id, version_id FROM trash_items WHERE trash_id = ?
+		UNION ALL
+		SELECT id, original_entry_id, version_id FROM trash
+		WHERE id = ? AND NOT EXISTS (SELECT 1 FROM trash_items WHERE trash_id = ?)
+	`, trashID, trashID, trashID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trash group: %w", err)
+	}
+	defer rows.Close()
+
+	var items []trashItem
+	for rows.Next() {
+		var item trashItem
+		if err := rows.Scan(&item.trashID, &item.entryID, &item.versionID); err != nil {
+			return nil, fmt.Errorf("failed to scan trash group: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// nestedTrash returns the members of existing trash groups rooted inside members.
+func (tm *TrashManager) nestedTrash(ctx context.Context, members []int64) ([]trashItem, error) {
+	var nested []trashItem
+	for _, id := range members {
+		var trashID int64
+		err := tm.db.QueryRowContext(ctx, `SELECT id FROM trash WHERE original_entry_id = ?`, id).Scan(&trashID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check trash: %w", err)
+		}
+		items, err := tm.groupItems(ctx, trashID)
+		if err != nil {
+			return nil, err
+		}
+		nested = append(nested, items...)
+	}
+	return nested, nil
+}
+
 // List returns all entries in trash.
 func (tm *TrashManager) List(ctx context.Context) ([]*TrashInfo, error) {
 	tm.mu.RLock()
@@ -109,9 +245,9 @@ func (tm *TrashManager) List(ctx context.Context) ([]*TrashInfo, error) {
 
 	rows, err := tm.db.QueryContext(ctx, `
 		SELECT t.id, t.original_entry_id, t.original_path, t.deleted_at, t.version_id, t.auto_purge_after,
-		       COALESCE(v.size, 0) as size
+		       `+trashGroupSizeSQL+` as size,
+		       (SELECT COUNT(*) FROM trash_items ti WHERE ti.trash_id = t.id) as items
 		FROM trash t
-		LEFT JOIN versions v ON t.version_id = v.id
 		ORDER BY t.deleted_at DESC
 	`)
 	if err != nil {
@@ -125,16 +261,17 @@ func (tm *TrashManager) List(ctx context.Context) ([]*TrashInfo, error) {
 		var deletedAt string
 		var versionID, size sql.NullInt64
 		var autoPurge sql.NullString
+		var members int
 
 		err := rows.Scan(
 			&entry.ID, &entry.OriginalEntryID, &entry.OriginalPath, &deletedAt,
-			&versionID, &autoPurge, &size,
+			&versionID, &autoPurge, &size, &members,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan trash entry: %w", err)
 		}
 
-		entry.DeletedAt, _ = time.Parse(time.RFC3339, deletedAt)
+		entry.DeletedAt = parseDBTime(deletedAt)
 		if versionID.Valid {
 			entry.VersionID = &versionID.Int64
 		}
@@ -150,84 +287,263 @@ func (tm *TrashManager) List(ctx context.Context) ([]*TrashInfo, error) {
 			OriginalName: entry.OriginalPath,
 			Size:         size.Int64,
 			DaysInTrash:  daysInTrash,
+			Members:      members,
 		})
 	}
 
 	return items, nil
 }
 
-// Restore restores an entry from trash.
+// RestoreOptions controls where a trash entry is restored.
+type RestoreOptions struct {
+	To               string // Alternate path; empty restores to the original path
+	RenameOnConflict bool   // Pick a free name instead of failing if the target exists
+}
+
+// RestoredEntry is one entry brought back by a restore.
+type RestoredEntry struct {
+	EntryID   int64
+	Path      string
+	Type      model.EntryType
+	VersionID *int64
+}
+
+// RestoreResult reports what a restore did.
+type RestoreResult struct {
+	Path        string   // Final path of the restored entry
+	Renamed     bool     // Restored under a different name due to a conflict
+	CreatedDirs []string // Missing parent directories recreated in the index
+	Entries     []RestoredEntry
+}
+
+// Restore restores an entry from trash to its original path.
 func (tm *TrashManager) Restore(ctx context.Context, trashID int64) error {
+	_, err := tm.RestoreTo(ctx, trashID, RestoreOptions{})
+	return err
+}
+
+// RestoreTo restores a trash group, optionally to an alternate path.
+// Missing parent directories are recreated in the index.
+func (tm *TrashManager) RestoreTo(ctx context.Context, trashID int64, opts RestoreOptions) (*RestoreResult, error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
 	// Get trash entry
 	var entryID int64
-	var versionID sql.NullInt64
+	var originalPath string
 	err := tm.db.QueryRowContext(ctx, `
-		SELECT original_entry_id, version_id FROM trash WHERE id = ?
-	`, trashID).Scan(&entryID, &versionID)
+		SELECT original_entry_id, original_path FROM trash WHERE id = ?
+	`, trashID).Scan(&entryID, &originalPath)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("trash entry not found: %d", trashID)
+		return nil, fmt.Errorf("trash entry not found: %d", trashID)
 	}
 	if err != nil {
-		return fmt.Errorf("failed to get trash entry: %w", err)
+		return nil, fmt.Errorf("failed to get trash entry: %w", err)
+	}
+
+	target := opts.To
+	if target == "" {
+		// Restore in place; fall back to the recorded path if the parent is gone
+		target = originalPath
+		if current, err := tm.indexedPath(ctx, entryID); err == nil && current != "" {
+			target = current
+		}
+	}
+	target = strings.Trim(path.Clean("/"+filepath.ToSlash(target)), "/")
+	if target == "" {
+		return nil, fmt.Errorf("invalid restore path: %q", opts.To)
+	}
+
+	items, err := tm.groupItems(ctx, trashID)
+	if err != nil {
+		return nil, err
+	}
+	inGroup := make(map[int64]bool, len(items))
+	for _, item := range items {
+		inGroup[item.entryID] = true
 	}
 
 	// Begin journal
 	payload, _ := json.Marshal(map[string]interface{}{
 		"trash_id": trashID,
 		"entry_id": entryID,
+		"target":   target,
 	})
 	opID, err := tm.journal.BeginOperation(ctx, "trash_restore", string(payload))
 	if err != nil {
-		return fmt.Errorf("failed to begin journal: %w", err)
+		return nil, fmt.Errorf("failed to begin journal: %w", err)
+	}
+
+	result := &RestoreResult{}
+	fail := func(err error) (*RestoreResult, error) {
+		tm.journal.RollbackOperation(ctx, opID, err.Error())
+		return nil, err
+	}
+
+	// Recreate missing parent directories
+	var parentID sql.NullInt64
+	dir := path.Dir(target)
+	if dir != "." {
+		walked := ""
+		for _, name := range strings.Split(dir, "/") {
+			walked = path.Join(walked, name)
+			id, entryType, err := tm.childByName(ctx, parentID, name)
+			if err != nil {
+				return fail(err)
+			}
+			switch {
+			case id == 0:
+				res, err := tm.db.ExecContext(ctx, `
+					INSERT INTO entries (parent_id, name, entry_type) VALUES (?, ?, 'directory')
+				`, parentID, name)
+				if err != nil {
+					return fail(fmt.Errorf("failed to create directory %s: %w", walked, err))
+				}
+				id, _ = res.LastInsertId()
+				result.CreatedDirs = append(result.CreatedDirs, walked)
+			case entryType != string(model.EntryTypeDirectory):
+				return fail(fmt.Errorf("cannot restore into %s: not a directory", walked))
+			case inGroup[id]:
+				return fail(fmt.Errorf("cannot restore %s into itself", originalPath))
+			}
+			parentID = sql.NullInt64{Int64: id, Valid: true}
+		}
+	}
+
+	// Resolve name conflicts
+	name := path.Base(target)
+	existing, _, err := tm.childByName(ctx, parentID, name)
+	if err != nil {
+		return fail(err)
+	}
+	if existing != 0 && existing != entryID {
+		if !opts.RenameOnConflict {
+			return fail(fmt.Errorf("restore target already exists: %s (use --to or --rename-on-conflict)", target))
+		}
+		for n := 1; existing != 0 && existing != entryID; n++ {
+			name = restoredName(path.Base(target), n)
+			if existing, _, err = tm.childByName(ctx, parentID, name); err != nil {
+				return fail(err)
+			}
+		}
+		result.Renamed = true
+	}
+
+	_, err = tm.db.ExecContext(ctx, `
+		UPDATE entries SET parent_id = ?, name = ?, modified_at = datetime('now') WHERE id = ?
+	`, parentID, name, entryID)
+	if err != nil {
+		return fail(fmt.Errorf("failed to move entry: %w", err))
+	}
+	result.Path = path.Join(dir, name)
+	if dir == "." {
+		result.Path = name
 	}
 
 	// Restore version state to active
-	if versionID.Valid {
+	for _, item := range items {
+		if !item.versionID.Valid {
+			continue
+		}
 		_, err = tm.db.ExecContext(ctx, `
 			UPDATE versions SET state = 'active' WHERE id = ?
-		`, versionID.Int64)
+		`, item.versionID.Int64)
 		if err != nil {
-			tm.journal.RollbackOperation(ctx, opID, err.Error())
-			return fmt.Errorf("failed to restore version: %w", err)
+			return fail(fmt.Errorf("failed to restore version: %w", err))
 		}
 	}
 
 	// Remove from trash
+	tm.db.ExecContext(ctx, `DELETE FROM trash_items WHERE trash_id = ?`, trashID)
 	_, err = tm.db.ExecContext(ctx, `DELETE FROM trash WHERE id = ?`, trashID)
 	if err != nil {
-		tm.journal.RollbackOperation(ctx, opID, err.Error())
-		return fmt.Errorf("failed to remove from trash: %w", err)
+		return fail(fmt.Errorf("failed to remove from trash: %w", err))
+	}
+
+	// Report restored entries at their new paths
+	paths, err := listIndexedPaths(ctx, tm.db)
+	if err != nil {
+		return fail(err)
+	}
+	for _, p := range paths {
+		if !inGroup[p.id] {
+			continue
+		}
+		re := RestoredEntry{EntryID: p.id, Path: p.path, Type: model.EntryType(p.entryType)}
+		for _, item := range items {
+			if item.entryID == p.id && item.versionID.Valid {
+				v := item.versionID.Int64
+				re.VersionID = &v
+			}
+		}
+		result.Entries = append(result.Entries, re)
 	}
 
 	// Complete journal
 	tm.journal.CommitOperation(ctx, opID)
 	tm.journal.SyncOperation(ctx, opID)
 
-	return nil
+	return result, nil
 }
 
-// RestoreByPath restores an entry by its original path.
-func (tm *TrashManager) RestoreByPath(ctx context.Context, path string) error {
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
+// indexedPath returns the full index path of an entry, or "" if its
+// parent chain no longer reaches the root.
+func (tm *TrashManager) indexedPath(ctx context.Context, entryID int64) (string, error) {
+	paths, err := listIndexedPaths(ctx, tm.db)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range paths {
+		if p.id == entryID {
+			return p.path, nil
+		}
+	}
+	return "", nil
+}
 
-	var trashID int64
+// childByName looks up a live (untrashed) entry by parent and name (NULL parent is the root).
+func (tm *TrashManager) childByName(ctx context.Context, parentID sql.NullInt64, name string) (int64, string, error) {
+	var id int64
+	var entryType string
 	err := tm.db.QueryRowContext(ctx, `
-		SELECT id FROM trash WHERE original_path = ?
-	`, path).Scan(&trashID)
+		SELECT id, entry_type FROM entries
+		WHERE name = ? AND (parent_id = ? OR (parent_id IS NULL AND ? IS NULL))
+		  AND id NOT IN (SELECT original_entry_id FROM trash)
+		  AND id NOT IN (SELECT entry_id FROM trash_items)
+		ORDER BY id LIMIT 1
+	`, name, parentID, parentID).Scan(&id, &entryType)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("not found in trash: %s", path)
+		return 0, "", nil
 	}
 	if err != nil {
-		return fmt.Errorf("failed to find in trash: %w", err)
+		return 0, "", fmt.Errorf("failed to look up %s: %w", name, err)
+	}
+	return id, entryType, nil
+}
+
+// restoredName returns "name (restored).ext", "name (restored 2).ext", ...
+func restoredName(name string, n int) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		base, ext = name, ""
 	}
+	if n == 1 {
+		return fmt.Sprintf("%s (restored)%s", base, ext)
+	}
+	return fmt.Sprintf("%s (restored %d)%s", base, n, ext)
+}
 
-	tm.mu.Unlock()
-	defer tm.mu.Lock()
-	return tm.Restore(ctx, trashID)
+// RestoreByPath restores an entry by its original path.
+func (tm *TrashManager) RestoreByPath(ctx context.Context, path string) error {
+	entry, err := tm.GetByPath(ctx, path)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("not found in trash: %s", path)
+	}
+	return tm.Restore(ctx, entry.ID)
 }
 
 // PurgePreview returns what would be purged.
@@ -245,16 +561,14 @@ func (tm *TrashManager) GetPurgePreview(ctx context.Context, autoPurgeOnly bool)
 	var query string
 	if autoPurgeOnly {
 		query = `
-			SELECT t.original_path, COALESCE(v.size, 0)
+			SELECT t.original_path, ` + trashGroupSizeSQL + `
 			FROM trash t
-			LEFT JOIN versions v ON t.version_id = v.id
 			WHERE t.auto_purge_after IS NOT NULL AND t.auto_purge_after <= datetime('now')
 		`
 	} else {
 		query = `
-			SELECT t.original_path, COALESCE(v.size, 0)
+			SELECT t.original_path, ` + trashGroupSizeSQL + `
 			FROM trash t
-			LEFT JOIN versions v ON t.version_id = v.id
 		`
 	}
 
@@ -277,17 +591,82 @@ func (tm *TrashManager) GetPurgePreview(ctx context.Context, autoPurgeOnly bool)
 	return preview, nil
 }
 
+// PurgeResult reports what a purge removed.
+type PurgeResult struct {
+	Purged  int     // Trash entries removed from the index
+	Deleted int     // Remote objects deleted
+	Failed  int     // Remote deletions queued for retry
+	Errors  []error // Per-object deletion errors
+}
+
+// PurgePlacements returns every placement of every version of the
+// trashed entries that a purge would delete.
+func (tm *TrashManager) PurgePlacements(ctx context.Context, autoPurgeOnly bool) ([]PlacementRef, error) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	if tm.deleter == nil {
+		return nil, fmt.Errorf("no delete coordinator configured")
+	}
+
+	ids, err := tm.trashIDs(ctx, autoPurgeOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	var placements []PlacementRef
+	for _, id := range ids {
+		items, err := tm.groupItems(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := tm.groupPlacements(ctx, items)
+		if err != nil {
+			return nil, err
+		}
+		placements = append(placements, refs...)
+	}
+	return placements, nil
+}
+
+// groupPlacements returns the placements of every member of a trash group.
+func (tm *TrashManager) groupPlacements(ctx context.Context, items []trashItem) ([]PlacementRef, error) {
+	var placements []PlacementRef
+	for _, item := range items {
+		refs, err := tm.deleter.GetPlacementsForEntry(ctx, item.entryID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get placements: %w", err)
+		}
+		placements = append(placements, refs...)
+	}
+	return placements, nil
+}
+
 // Purge permanently deletes entries from trash.
 // This is the ONLY place where provider Delete() should be called.
 // Requires explicit user confirmation.
-func (tm *TrashManager) Purge(ctx context.Context, trashID int64, confirmed bool) error {
+func (tm *TrashManager) Purge(ctx context.Context, trashID int64, confirmed bool) (*PurgeResult, error) {
 	if !confirmed {
-		return fmt.Errorf("purge requires explicit user confirmation")
+		return nil, fmt.Errorf("purge requires explicit user confirmation")
 	}
 
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
+	result := &PurgeResult{}
+	if err := tm.purgeLocked(ctx, trashID, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// purgeLocked deletes provider data for one trash entry, then removes it
+// from the index. Caller must hold tm.mu.
+func (tm *TrashManager) purgeLocked(ctx context.Context, trashID int64, result *PurgeResult) error {
+	if tm.deleter == nil {
+		return fmt.Errorf("no delete coordinator configured")
+	}
+
 	// Get trash entry info for journal
 	var entryID int64
 	var originalPath string
@@ -301,129 +680,129 @@ func (tm *TrashManager) Purge(ctx context.Context, trashID int64, confirmed bool
 		return fmt.Errorf("failed to get trash entry: %w", err)
 	}
 
+	// Collect placements of every member BEFORE the entry rows go away
+	items, err := tm.groupItems(ctx, trashID)
+	if err != nil {
+		return err
+	}
+	placements, err := tm.groupPlacements(ctx, items)
+	if err != nil {
+		return err
+	}
+
 	// Begin journal
 	payload, _ := json.Marshal(map[string]interface{}{
 		"trash_id":      trashID,
 		"entry_id":      entryID,
 		"original_path": originalPath,
+		"placements":    len(placements),
 	})
 	opID, err := tm.journal.BeginOperation(ctx, "trash_purge", string(payload))
 	if err != nil {
 		return fmt.Errorf("failed to begin journal: %w", err)
 	}
 
+	// Delete provider data; failures are queued in pending_deletes
+	if len(placements) > 0 {
+		deleted, err := tm.deleter.Execute(ctx, &DeleteRequest{
+			Placements: placements,
+			Source:     DeleteSourceTrashPurge,
+		}, true)
+		if err != nil {
+			tm.journal.RollbackOperation(ctx, opID, err.Error())
+			return fmt.Errorf("failed to delete provider data: %w", err)
+		}
+		result.Deleted += deleted.Deleted
+		result.Failed += deleted.Failed
+		result.Errors = append(result.Errors, deleted.Errors...)
+	}
+
 	// Delete from trash
+	tm.db.ExecContext(ctx, `DELETE FROM trash_items WHERE trash_id = ?`, trashID)
 	_, err = tm.db.ExecContext(ctx, `DELETE FROM trash WHERE id = ?`, trashID)
 	if err != nil {
 		tm.journal.RollbackOperation(ctx, opID, err.Error())
 		return fmt.Errorf("failed to purge from trash: %w", err)
 	}
 
-	// Delete from entries (cleanup zombie)
-	_, err = tm.db.ExecContext(ctx, `DELETE FROM entries WHERE id = ?`, entryID)
-	if err != nil {
-		// Log warning but don't fail, as trash is already gone
-		fmt.Printf("Warning: failed to delete entry %d: %v\n", entryID, err)
+	// Delete from entries (cleanup zombies, whole subtree for directories)
+	for _, item := range items {
+		_, err = tm.db.ExecContext(ctx, `DELETE FROM entries WHERE id = ?`, item.entryID)
+		if err != nil {
+			tm.journal.RollbackOperation(ctx, opID, err.Error())
+			return fmt.Errorf("failed to delete entry %d: %w", item.entryID, err)
+		}
 	}
 
-	// Note: Actual provider deletion would happen here via provider.Delete()
-	// This is the ONLY valid place for that call per design.txt
-
 	// Complete journal
 	tm.journal.CommitOperation(ctx, opID)
 	tm.journal.SyncOperation(ctx, opID)
 
+	result.Purged++
 	return nil
 }
 
-// PurgeAll permanently deletes all entries from trash.
-// Requires explicit user confirmation.
-func (tm *TrashManager) PurgeAll(ctx context.Context, confirmed bool) (int, error) {
-	if !confirmed {
-		return 0, fmt.Errorf("purge requires explicit user confirmation")
+// trashIDs returns trash entry IDs, optionally only those past auto-purge.
+func (tm *TrashManager) trashIDs(ctx context.Context, autoPurgeOnly bool) ([]int64, error) {
+	query := `SELECT id FROM trash ORDER BY id`
+	if autoPurgeOnly {
+		query = `
+			SELECT id FROM trash
+			WHERE auto_purge_after IS NOT NULL AND auto_purge_after <= datetime('now')
+			ORDER BY id
+		`
 	}
 
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
-
-	// Get all trash IDs
-	rows, err := tm.db.QueryContext(ctx, `SELECT id FROM trash`)
+	rows, err := tm.db.QueryContext(ctx, query)
 	if err != nil {
-		return 0, fmt.Errorf("failed to list trash: %w", err)
+		return nil, fmt.Errorf("failed to list trash: %w", err)
 	}
 	defer rows.Close()
 
-	var trashIDs []int64
+	var ids []int64
 	for rows.Next() {
 		var id int64
-		rows.Scan(&id)
-		trashIDs = append(trashIDs, id)
-	}
-
-	// Begin journal for batch purge
-	payload, _ := json.Marshal(map[string]interface{}{
-		"count": len(trashIDs),
-	})
-	opID, err := tm.journal.BeginOperation(ctx, "trash_purge_all", string(payload))
-	if err != nil {
-		return 0, fmt.Errorf("failed to begin journal: %w", err)
-	}
-
-	// Delete all from entries (referenced by trash)
-	_, err = tm.db.ExecContext(ctx, `DELETE FROM entries WHERE id IN (SELECT original_entry_id FROM trash)`)
-	if err != nil {
-		tm.journal.RollbackOperation(ctx, opID, err.Error())
-		return 0, fmt.Errorf("failed to purge entries: %w", err)
-	}
-
-	// Delete all from trash
-	result, err := tm.db.ExecContext(ctx, `DELETE FROM trash`)
-	if err != nil {
-		tm.journal.RollbackOperation(ctx, opID, err.Error())
-		return 0, fmt.Errorf("failed to purge all: %w", err)
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan trash entry: %w", err)
+		}
+		ids = append(ids, id)
 	}
+	return ids, nil
+}
 
-	count, _ := result.RowsAffected()
-
-	// Complete journal
-	tm.journal.CommitOperation(ctx, opID)
-	tm.journal.SyncOperation(ctx, opID)
-
-	return int(count), nil
+// PurgeAll permanently deletes all entries from trash.
+// Requires explicit user confirmation.
+func (tm *TrashManager) PurgeAll(ctx context.Context, confirmed bool) (*PurgeResult, error) {
+	return tm.purgeMany(ctx, false, confirmed)
 }
 
 // PurgeExpired purges entries past their auto-purge date.
 // Requires explicit user confirmation.
-func (tm *TrashManager) PurgeExpired(ctx context.Context, confirmed bool) (int, error) {
+func (tm *TrashManager) PurgeExpired(ctx context.Context, confirmed bool) (*PurgeResult, error) {
+	return tm.purgeMany(ctx, true, confirmed)
+}
+
+// purgeMany purges every (or every expired) trash entry.
+func (tm *TrashManager) purgeMany(ctx context.Context, autoPurgeOnly, confirmed bool) (*PurgeResult, error) {
 	if !confirmed {
-		return 0, fmt.Errorf("purge requires explicit user confirmation")
+		return nil, fmt.Errorf("purge requires explicit user confirmation")
 	}
 
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
-	// Begin journal
-	opID, err := tm.journal.BeginOperation(ctx, "trash_purge_expired", "{}")
+	ids, err := tm.trashIDs(ctx, autoPurgeOnly)
 	if err != nil {
-		return 0, fmt.Errorf("failed to begin journal: %w", err)
+		return nil, err
 	}
 
-	// Delete expired entries
-	result, err := tm.db.ExecContext(ctx, `
-		DELETE FROM trash WHERE auto_purge_after IS NOT NULL AND auto_purge_after <= datetime('now')
-	`)
-	if err != nil {
-		tm.journal.RollbackOperation(ctx, opID, err.Error())
-		return 0, fmt.Errorf("failed to purge expired: %w", err)
+	result := &PurgeResult{}
+	for _, id := range ids {
+		if err := tm.purgeLocked(ctx, id, result); err != nil {
+			return result, err
+		}
 	}
-
-	count, _ := result.RowsAffected()
-
-	// Complete journal
-	tm.journal.CommitOperation(ctx, opID)
-	tm.journal.SyncOperation(ctx, opID)
-
-	return int(count), nil
+	return result, nil
 }
 
 // GetByPath finds a trash entry by its original path.
@@ -0,0 +1,196 @@
+// Package core provides space-driven trash reclaim for CloudFS.
+// Based on design.txt Section 15: Trash is cleared only when space is
+// required, using the same ranking model as cache eviction.
+//
+// INVARIANTS:
+// - Ranking is READ-ONLY; purging still requires explicit confirmation
+// - Reclaim purges whole trash entries (all versions, all providers)
+// - Only "just enough" entries are selected to cover the requested bytes
+// - Provider usage for soft-limit checks comes from LIVE GetUsage()
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// ReclaimWeights weights each factor of the trash reclaim score.
+type ReclaimWeights struct {
+	Age     float64 // Time spent in trash
+	Size    float64 // Bytes freed on the target provider
+	Replica float64 // Copies held across providers
+}
+
+// DefaultReclaimWeights favours old, large, widely replicated trash.
+var DefaultReclaimWeights = ReclaimWeights{
+	Age:     0.50,
+	Size:    0.30,
+	Replica: 0.20,
+}
+
+// ReclaimCandidate is a scored trash entry.
+type ReclaimCandidate struct {
+	TrashID     int64
+	EntryID     int64
+	Path        string
+	Size        int64 // Bytes held on the target provider
+	TotalSize   int64 // Bytes held across all providers
+	DaysInTrash int
+	Replicas    int // Providers holding a copy
+	Score       float64
+	Factors     map[string]float64
+}
+
+// ReclaimPlan is the ranked set of trash entries needed to free space on a provider.
+type ReclaimPlan struct {
+	ProviderID string // Empty means all providers
+	Target     int64
+	Selected   []*ReclaimCandidate
+	Freed      int64
+	Shortfall  int64
+}
+
+// ProviderPressure describes how far an upload would push a provider past its soft limit.
+type ProviderPressure struct {
+	ProviderID string
+	SoftLimit  int64
+	Used       int64
+	Incoming   int64
+	Over       int64 // Bytes above the soft limit after the upload
+}
+
+// RankForReclaim scores every trash entry that holds data on providerID
+// (or on any provider when providerID is empty), highest score first.
+func (tm *TrashManager) RankForReclaim(ctx context.Context, providerID string, weights ReclaimWeights) ([]*ReclaimCandidate, error) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	rows, err := tm.db.QueryContext(ctx, `
+		SELECT t.id, t.original_entry_id, t.original_path, t.deleted_at,
+		       COALESCE(SUM(CASE WHEN p.provider_id = ? OR (? = '' AND p.id IS NOT NULL) THEN v.size ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN p.id IS NOT NULL THEN v.size ELSE 0 END), 0),
+		       COUNT(DISTINCT p.provider_id)
+		FROM trash t
+		LEFT JOIN versions v ON v.entry_id IN (
+			SELECT entry_id FROM trash_items WHERE trash_id = t.id
+			UNION SELECT t.original_entry_id
+		)
+		LEFT JOIN placements p ON p.version_id = v.id
+		GROUP BY t.id
+	`, providerID, providerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank trash: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var candidates []*ReclaimCandidate
+	for rows.Next() {
+		c := &ReclaimCandidate{}
+		var deletedAt string
+		if err := rows.Scan(&c.TrashID, &c.EntryID, &c.Path, &deletedAt,
+			&c.Size, &c.TotalSize, &c.Replicas); err != nil {
+			return nil, fmt.Errorf("failed to scan trash entry: %w", err)
+		}
+		if c.Size == 0 {
+			continue // Nothing to free on this provider
+		}
+
+		// Same age and size terms as cache eviction
+		ageDays := daysSince(now, parseDBTime(deletedAt))
+		c.DaysInTrash = int(ageDays)
+		c.Factors = map[string]float64{
+			"age":     ageFactor(ageDays),
+			"size":    sizeFactor(c.Size),
+			"replica": 1 - 1/float64(c.Replicas+1),
+		}
+
+		total := weights.Age + weights.Size + weights.Replica
+		if total > 0 {
+			c.Score = (weights.Age*c.Factors["age"] +
+				weights.Size*c.Factors["size"] +
+				weights.Replica*c.Factors["replica"]) / total
+		}
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates, nil
+}
+
+// PlanReclaim selects the highest-scoring trash entries until bytesToFree
+// is reached on providerID. Nothing is purged.
+func (tm *TrashManager) PlanReclaim(ctx context.Context, providerID string, bytesToFree int64) (*ReclaimPlan, error) {
+	candidates, err := tm.RankForReclaim(ctx, providerID, DefaultReclaimWeights)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ReclaimPlan{ProviderID: providerID, Target: bytesToFree}
+	for _, c := range candidates {
+		if plan.Freed >= bytesToFree {
+			break
+		}
+		plan.Selected = append(plan.Selected, c)
+		plan.Freed += c.Size
+	}
+	if plan.Freed < bytesToFree {
+		plan.Shortfall = bytesToFree - plan.Freed
+	}
+	return plan, nil
+}
+
+// Reclaim purges the entries selected by a reclaim plan.
+// Requires explicit user confirmation.
+func (tm *TrashManager) Reclaim(ctx context.Context, plan *ReclaimPlan, confirmed bool) (*PurgeResult, error) {
+	if !confirmed {
+		return nil, fmt.Errorf("purge requires explicit user confirmation")
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	result := &PurgeResult{}
+	for _, c := range plan.Selected {
+		if err := tm.purgeLocked(ctx, c.TrashID, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// SoftLimitPressure reports whether uploading incoming bytes would push
+// a provider past its soft limit. Returns nil if no soft limit is set.
+func SoftLimitPressure(ctx context.Context, db *sql.DB, prov provider.Provider, incoming int64) (*ProviderPressure, error) {
+	var softLimit sql.NullInt64
+	err := db.QueryRowContext(ctx, `SELECT soft_limit FROM providers WHERE name = ?`, prov.ID()).Scan(&softLimit)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get soft limit: %w", err)
+	}
+	if !softLimit.Valid || softLimit.Int64 <= 0 {
+		return nil, nil
+	}
+
+	usage, err := prov.GetUsage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage for %s: %w", prov.ID(), err)
+	}
+
+	p := &ProviderPressure{
+		ProviderID: prov.ID(),
+		SoftLimit:  softLimit.Int64,
+		Used:       usage.UsedBytes,
+		Incoming:   incoming,
+	}
+	if over := usage.UsedBytes + incoming - softLimit.Int64; over > 0 {
+		p.Over = over
+	}
+	return p, nil
+}
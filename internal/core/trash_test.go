@@ -0,0 +1,460 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/model"
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// memProvider stores objects in memory and can be told to fail deletes.
+type memProvider struct {
+	id         string
+	objects    map[string]int64
+	modTimes   map[string]time.Time
+	hashes     map[string]string
+	data       map[string][]byte
+	failDelete bool
+	failVerify bool
+}
+
+func newMemProvider(id string) *memProvider {
+	return &memProvider{id: id, objects: make(map[string]int64), modTimes: make(map[string]time.Time), hashes: make(map[string]string), data: make(map[string][]byte)}
+}
+
+func (p *memProvider) ID() string          { return p.id }
+func (p *memProvider) Type() string        { return "memory" }
+func (p *memProvider) DisplayName() string { return p.id }
+func (p *memProvider) Init(ctx context.Context, config map[string]interface{}) error {
+	return nil
+}
+func (p *memProvider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{}, nil
+}
+func (p *memProvider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	var used int64
+	for _, size := range p.objects {
+		used += size
+	}
+	return &provider.Usage{UsedBytes: used}, nil
+}
+func (p *memProvider) Upload(ctx context.Context, localPath, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	b, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, err
+	}
+	p.data[remotePath] = b
+	p.objects[remotePath] = int64(len(b))
+	return &provider.UploadResult{RemotePath: remotePath, Size: int64(len(b))}, nil
+}
+func (p *memProvider) Download(ctx context.Context, remotePath, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	b, ok := p.data[remotePath]
+	if !ok {
+		return nil, fmt.Errorf("not found: %s", remotePath)
+	}
+	if err := os.WriteFile(localPath, b, 0600); err != nil {
+		return nil, err
+	}
+	return &provider.DownloadResult{LocalPath: localPath, Size: int64(len(b))}, nil
+}
+func (p *memProvider) DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	b, ok := p.data[remotePath]
+	if !ok {
+		return 0, fmt.Errorf("not found: %s", remotePath)
+	}
+	end := int64(len(b))
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+	n, err := w.Write(b[offset:end])
+	return int64(n), err
+}
+func (p *memProvider) Delete(ctx context.Context, remotePath string) error {
+	if p.failDelete {
+		return fmt.Errorf("simulated outage")
+	}
+	delete(p.objects, remotePath)
+	return nil
+}
+func (p *memProvider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	if p.failVerify {
+		return nil, fmt.Errorf("simulated outage")
+	}
+	_, ok := p.objects[remotePath]
+	return &provider.VerifyResult{IsValid: ok, ContentHash: p.hashes[remotePath]}, nil
+}
+func (p *memProvider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	var objects []provider.RemoteObject
+	for path, size := range p.objects {
+		objects = append(objects, provider.RemoteObject{Path: path, Size: size, ModTime: p.modTimes[path]})
+	}
+	return objects, nil
+}
+func (p *memProvider) CheckHealth(ctx context.Context) provider.HealthState {
+	return provider.HealthStateHealthy
+}
+
+func TestTrashManager_PurgeDeletesRemote(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	journal := NewJournalManager(db.DB())
+
+	gdrive := newMemProvider("gdrive")
+	b2 := newMemProvider("b2")
+	b2.failDelete = true
+	registry := provider.NewRegistry()
+	registry.Register(gdrive)
+	registry.Register(b2)
+
+	// Two versions, each placed on both providers
+	entry := &model.Entry{Name: "report.pdf", Type: model.EntryTypeFile, LogicalSize: 100}
+	im.CreateEntry(ctx, entry)
+	for n := 1; n <= 2; n++ {
+		v := &model.Version{EntryID: entry.ID, VersionNum: n, ContentHash: fmt.Sprintf("h%d", n), Size: 100, State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+		for _, prov := range []*memProvider{gdrive, b2} {
+			path := fmt.Sprintf("%s:report.pdf.v%d", prov.id, n)
+			prov.objects[path] = 100
+			db.DB().ExecContext(ctx, `
+				INSERT INTO placements (version_id, provider_id, remote_path, state)
+				VALUES (?, ?, ?, 'uploaded')
+			`, v.ID, prov.id, path)
+		}
+	}
+
+	tm := NewTrashManager(db.DB(), journal)
+	dc := NewDeleteCoordinator(db.DB(), journal, registry)
+	tm.SetDeleteCoordinator(dc)
+
+	if err := tm.MoveToTrash(ctx, entry.ID, "report.pdf", 30); err != nil {
+		t.Fatalf("failed to move to trash: %v", err)
+	}
+
+	placements, err := tm.PurgePlacements(ctx, false)
+	if err != nil {
+		t.Fatalf("failed to collect placements: %v", err)
+	}
+	previews, _ := dc.PreviewByProvider(ctx, &DeleteRequest{Placements: placements})
+	if len(previews) != 2 || previews["gdrive"].TotalSize != 200 || len(previews["b2"].Files) != 2 {
+		t.Errorf("unexpected per-provider preview: %+v", previews)
+	}
+
+	result, err := tm.PurgeAll(ctx, true)
+	if err != nil {
+		t.Fatalf("failed to purge: %v", err)
+	}
+	if result.Purged != 1 || result.Deleted != 2 || result.Failed != 2 {
+		t.Errorf("unexpected purge result: %+v", result)
+	}
+	if len(gdrive.objects) != 0 {
+		t.Errorf("expected gdrive objects deleted, %d remain", len(gdrive.objects))
+	}
+
+	// Failed deletions survive the entry for retry
+	pending, err := dc.ListPending(ctx)
+	if err != nil {
+		t.Fatalf("failed to list pending deletes: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ProviderID != "b2" {
+		t.Fatalf("expected 2 pending b2 deletes, got %+v", pending)
+	}
+
+	b2.failDelete = false
+	retry, err := dc.RetryPending(ctx, true)
+	if err != nil {
+		t.Fatalf("failed to retry: %v", err)
+	}
+	if retry.Deleted != 2 || len(b2.objects) != 0 {
+		t.Errorf("expected retry to delete b2 objects, got %+v (%d remain)", retry, len(b2.objects))
+	}
+	if pending, _ := dc.ListPending(ctx); len(pending) != 0 {
+		t.Errorf("expected empty retry queue, got %d", len(pending))
+	}
+}
+
+func TestTrashManager_PurgeKeepsSharedObjects(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	journal := NewJournalManager(db.DB())
+
+	gdrive := newMemProvider("gdrive")
+	registry := provider.NewRegistry()
+	registry.Register(gdrive)
+
+	// Push names objects "<remote>/<name>": a/x.txt and b/x.txt share one,
+	// and so do both versions of a/y.txt
+	place := func(dir, name string, versions int) int64 {
+		parent := &model.Entry{Name: dir, Type: model.EntryTypeDirectory}
+		db.DB().QueryRowContext(ctx, `SELECT id FROM entries WHERE name = ? AND parent_id IS NULL`, dir).Scan(&parent.ID)
+		if parent.ID == 0 {
+			im.CreateEntry(ctx, parent)
+		}
+		entry := &model.Entry{Name: name, Type: model.EntryTypeFile, ParentID: &parent.ID, LogicalSize: 10}
+		im.CreateEntry(ctx, entry)
+		for n := 1; n <= versions; n++ {
+			v := &model.Version{EntryID: entry.ID, VersionNum: n, ContentHash: fmt.Sprintf("%s%d", name, n), Size: 10, State: model.VersionStateActive}
+			im.CreateVersion(ctx, v)
+			db.DB().ExecContext(ctx, `
+				INSERT INTO placements (version_id, provider_id, remote_path, state)
+				VALUES (?, 'gdrive', ?, 'uploaded')
+			`, v.ID, "gdrive:/"+name)
+		}
+		gdrive.objects["gdrive:/"+name] = 10
+		return entry.ID
+	}
+	ax := place("a", "x.txt", 1)
+	bx := place("b", "x.txt", 1)
+	ay := place("a", "y.txt", 2)
+
+	tm := NewTrashManager(db.DB(), journal)
+	tm.SetDeleteCoordinator(NewDeleteCoordinator(db.DB(), journal, registry))
+	tm.MoveToTrash(ctx, ax, "a/x.txt", 30)
+	tm.MoveToTrash(ctx, ay, "a/y.txt", 30)
+
+	result, err := tm.PurgeAll(ctx, true)
+	if err != nil {
+		t.Fatalf("failed to purge: %v", err)
+	}
+	if result.Purged != 2 || result.Failed != 0 {
+		t.Errorf("unexpected purge result: %+v", result)
+	}
+	if _, ok := gdrive.objects["gdrive:/x.txt"]; !ok {
+		t.Error("purging a/x.txt deleted the object behind live b/x.txt")
+	}
+	if _, ok := gdrive.objects["gdrive:/y.txt"]; ok {
+		t.Error("expected y.txt deleted once its last placement went")
+	}
+
+	var live, total int
+	db.DB().QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM placements p JOIN versions v ON v.id = p.version_id WHERE v.entry_id = ?
+	`, bx).Scan(&live)
+	db.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM placements`).Scan(&total)
+	if live != 1 || total != 1 {
+		t.Errorf("expected only b/x.txt's placement to remain, got %d of %d", live, total)
+	}
+}
+
+func TestTrashManager_PlanReclaim(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	journal := NewJournalManager(db.DB())
+
+	gdrive := newMemProvider("gdrive")
+	registry := provider.NewRegistry()
+	registry.Register(gdrive)
+
+	tm := NewTrashManager(db.DB(), journal)
+	tm.SetDeleteCoordinator(NewDeleteCoordinator(db.DB(), journal, registry))
+
+	// name -> (days in trash, size, also on b2)
+	trash := func(name string, days int, size int64, b2 bool) {
+		entry := &model.Entry{Name: name, Type: model.EntryTypeFile, LogicalSize: size}
+		im.CreateEntry(ctx, entry)
+		v := &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: name, Size: size, State: model.VersionStateActive}
+		im.CreateVersion(ctx, v)
+		gdrive.objects["gdrive:"+name] = size
+		db.DB().ExecContext(ctx, `
+			INSERT INTO placements (version_id, provider_id, remote_path, state)
+			VALUES (?, 'gdrive', ?, 'uploaded')
+		`, v.ID, "gdrive:"+name)
+		if b2 {
+			db.DB().ExecContext(ctx, `
+				INSERT INTO placements (version_id, provider_id, remote_path, state)
+				VALUES (?, 'b2', ?, 'uploaded')
+			`, v.ID, "b2:"+name)
+		}
+		tm.MoveToTrash(ctx, entry.ID, name, 30)
+		db.DB().ExecContext(ctx, `UPDATE trash SET deleted_at = datetime('now', ?) WHERE original_entry_id = ?`,
+			fmt.Sprintf("-%d days", days), entry.ID)
+	}
+
+	trash("old.bin", 60, 100, false)
+	trash("new.bin", 1, 100, false)
+	trash("b2-only.bin", 90, 0, true)
+	trash("mid.bin", 20, 100, true)
+
+	// Soft limit of 250 bytes: 300 used + 50 incoming is 100 over
+	db.DB().ExecContext(ctx, `INSERT INTO providers (name, type, soft_limit) VALUES ('gdrive', 'memory', 250)`)
+	pressure, err := SoftLimitPressure(ctx, db.DB(), gdrive, 50)
+	if err != nil || pressure == nil {
+		t.Fatalf("failed to get soft limit pressure: %v", err)
+	}
+	if pressure.Over != 100 {
+		t.Errorf("expected 100 bytes over soft limit, got %d", pressure.Over)
+	}
+
+	plan, err := tm.PlanReclaim(ctx, "gdrive", pressure.Over)
+	if err != nil {
+		t.Fatalf("failed to plan reclaim: %v", err)
+	}
+	if len(plan.Selected) != 1 || plan.Selected[0].Path != "old.bin" {
+		t.Fatalf("expected only old.bin selected, got %+v", plan.Selected)
+	}
+
+	// Larger target adds candidates in score order; zero-size entries are skipped
+	plan, _ = tm.PlanReclaim(ctx, "gdrive", 1000)
+	if len(plan.Selected) != 3 || plan.Selected[2].Path != "new.bin" || plan.Shortfall != 700 {
+		t.Errorf("unexpected plan: %d selected, shortfall %d", len(plan.Selected), plan.Shortfall)
+	}
+
+	plan, _ = tm.PlanReclaim(ctx, "gdrive", 100)
+	result, err := tm.Reclaim(ctx, plan, true)
+	if err != nil {
+		t.Fatalf("failed to reclaim: %v", err)
+	}
+	if _, ok := gdrive.objects["gdrive:old.bin"]; result.Purged != 1 || ok {
+		t.Errorf("expected old.bin purged from gdrive, got %+v", result)
+	}
+
+	// A live file still uses mid.bin's object, so reclaiming mid.bin keeps it
+	live := &model.Entry{Name: "live.bin", Type: model.EntryTypeFile, LogicalSize: 100}
+	im.CreateEntry(ctx, live)
+	lv := &model.Version{EntryID: live.ID, VersionNum: 1, ContentHash: "mid.bin", Size: 100, State: model.VersionStateActive}
+	im.CreateVersion(ctx, lv)
+	db.DB().ExecContext(ctx, `
+		INSERT INTO placements (version_id, provider_id, remote_path, state)
+		VALUES (?, 'gdrive', 'gdrive:mid.bin', 'uploaded')
+	`, lv.ID)
+
+	plan, _ = tm.PlanReclaim(ctx, "gdrive", 1000)
+	if _, err := tm.Reclaim(ctx, plan, true); err != nil {
+		t.Fatalf("failed to reclaim: %v", err)
+	}
+	if _, ok := gdrive.objects["gdrive:new.bin"]; ok {
+		t.Error("expected new.bin purged from gdrive")
+	}
+	if _, ok := gdrive.objects["gdrive:mid.bin"]; !ok {
+		t.Error("an object still referenced by a live file must survive reclaim")
+	}
+}
+
+func TestTrashManager_RestoreDirectory(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cloudfs-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "index.db")
+	im, _ := NewIndexManager(dbPath, "")
+	defer im.Close()
+	ctx := context.Background()
+	im.Initialize(ctx)
+
+	db, _ := OpenEncryptedDB(dbPath, "")
+	defer db.Close()
+	tm := NewTrashManager(db.DB(), NewJournalManager(db.DB()))
+
+	add := func(name string, parent *int64, entryType model.EntryType) *model.Entry {
+		entry := &model.Entry{Name: name, ParentID: parent, Type: entryType, LogicalSize: 10}
+		if err := im.CreateEntry(ctx, entry); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		if entryType == model.EntryTypeFile {
+			im.CreateVersion(ctx, &model.Version{EntryID: entry.ID, VersionNum: 1, ContentHash: name, Size: 10, State: model.VersionStateActive})
+		}
+		return entry
+	}
+
+	photos := add("photos", nil, model.EntryTypeDirectory)
+	add("a.jpg", &photos.ID, model.EntryTypeFile)
+	sub := add("sub", &photos.ID, model.EntryTypeDirectory)
+	b := add("b.jpg", &sub.ID, model.EntryTypeFile)
+
+	// A file trashed on its own is folded into the directory group
+	if err := tm.MoveToTrash(ctx, b.ID, "photos/sub/b.jpg", 30); err != nil {
+		t.Fatalf("failed to trash file: %v", err)
+	}
+	if err := tm.MoveToTrash(ctx, photos.ID, "photos", 30); err != nil {
+		t.Fatalf("failed to trash directory: %v", err)
+	}
+
+	items, _ := tm.List(ctx)
+	if len(items) != 1 || items[0].Members != 4 || items[0].Size != 20 {
+		t.Fatalf("expected one 4-member group of 20 bytes, got %+v", items)
+	}
+
+	result, err := tm.RestoreTo(ctx, items[0].Entry.ID, RestoreOptions{To: "archive/photos-2023"})
+	if err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if result.Path != "archive/photos-2023" || len(result.CreatedDirs) != 1 || result.CreatedDirs[0] != "archive" {
+		t.Errorf("unexpected restore result: %+v", result)
+	}
+
+	restored := map[string]bool{}
+	for _, re := range result.Entries {
+		restored[re.Path] = re.Type == model.EntryTypeDirectory || re.VersionID != nil
+	}
+	for _, p := range []string{"archive/photos-2023", "archive/photos-2023/a.jpg", "archive/photos-2023/sub/b.jpg"} {
+		if !restored[p] {
+			t.Errorf("expected %s restored with its version, got %v", p, restored)
+		}
+	}
+	if v, err := im.GetActiveVersion(ctx, b.ID); err != nil || v == nil {
+		t.Errorf("expected b.jpg version reactivated: %v", err)
+	}
+	if items, _ := tm.List(ctx); len(items) != 0 {
+		t.Errorf("expected empty trash, got %d", len(items))
+	}
+
+	// Name conflicts fail unless renaming is allowed
+	notes := add("notes.txt", nil, model.EntryTypeFile)
+	tm.MoveToTrash(ctx, notes.ID, "notes.txt", 30)
+	add("notes.txt", nil, model.EntryTypeFile)
+	entry, _ := tm.GetByPath(ctx, "notes.txt")
+
+	if _, err := tm.RestoreTo(ctx, entry.ID, RestoreOptions{}); err == nil {
+		t.Fatal("expected conflict error")
+	}
+	result, err = tm.RestoreTo(ctx, entry.ID, RestoreOptions{RenameOnConflict: true})
+	if err != nil {
+		t.Fatalf("failed to restore with rename: %v", err)
+	}
+	if !result.Renamed || result.Path != "notes (restored).txt" {
+		t.Errorf("expected rename to 'notes (restored).txt', got %+v", result)
+	}
+}
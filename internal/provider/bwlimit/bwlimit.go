@@ -0,0 +1,244 @@
+// Package bwlimit throttles provider transfers with token buckets driven by
+// time-of-day schedules. Limiters travel in the context of a transfer call:
+// native providers wrap their streams with Reader, and the rclone provider
+// passes Flag to rclone.
+//
+// INVARIANTS:
+// - Limits apply to payload bytes, not to protocol overhead
+// - Every limiter in the context applies; the tightest one wins
+// - A limiter shared by several transfers caps their combined rate
+// - Schedule changes take effect on the next read, not the next transfer
+// - Without limiters in the context streams pass through untouched
+package bwlimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction is the way bytes move relative to the provider.
+type Direction int
+
+const (
+	Upload Direction = iota
+	Download
+)
+
+// maxChunk bounds a single throttled read so waits stay short and
+// cancellation stays responsive.
+const maxChunk = 64 << 10
+
+// bucket is a token bucket holding at most one second of tokens. Tokens
+// may go negative: a reader takes what it read and sleeps off the debt,
+// which keeps concurrent readers fair without reservations.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   int64
+}
+
+// take removes n tokens at rate and returns how long to wait.
+func (b *bucket) take(n int, rate int64, now time.Time) time.Duration {
+	if rate <= 0 {
+		b.rate, b.tokens, b.last = 0, 0, now
+		return 0
+	}
+	if b.rate != rate || b.last.IsZero() {
+		// Start full on a new rate so the first read is not penalised
+		b.rate, b.tokens, b.last = rate, float64(rate), now
+	}
+	b.tokens += now.Sub(b.last).Seconds() * float64(rate)
+	if b.tokens > float64(rate) {
+		b.tokens = float64(rate)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(rate) * float64(time.Second))
+}
+
+// Limiter throttles the transfers it is attached to according to a
+// Schedule. A nil *Limiter is unlimited.
+type Limiter struct {
+	mu       sync.Mutex
+	schedule *Schedule
+	up, down bucket
+	now      func() time.Time
+}
+
+// NewLimiter returns a limiter following schedule (nil = unlimited).
+func NewLimiter(schedule *Schedule) *Limiter {
+	return &Limiter{schedule: schedule, now: time.Now}
+}
+
+// SetSchedule replaces the schedule, e.g. for a --bwlimit override.
+func (l *Limiter) SetSchedule(schedule *Schedule) {
+	l.mu.Lock()
+	l.schedule = schedule
+	l.mu.Unlock()
+}
+
+// Schedule returns the current schedule.
+func (l *Limiter) Schedule() *Schedule {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.schedule
+}
+
+// Rate returns the rate in force at t.
+func (l *Limiter) Rate(t time.Time) Rate {
+	return l.Schedule().At(t)
+}
+
+// wait accounts for n bytes and blocks until they fit the rate.
+func (l *Limiter) wait(ctx context.Context, dir Direction, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := l.now()
+	rate := l.schedule.At(now)
+	var d time.Duration
+	if dir == Upload {
+		d = l.up.take(n, rate.Up, now)
+	} else {
+		d = l.down.take(n, rate.Down, now)
+	}
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chunk returns the largest read that keeps waits short at the current rate.
+func (l *Limiter) chunk(dir Direction) int {
+	rate := l.Rate(l.now())
+	limit := rate.Up
+	if dir == Download {
+		limit = rate.Down
+	}
+	if limit <= 0 || limit/4 >= maxChunk {
+		return maxChunk
+	}
+	if limit/4 < 512 {
+		return 512
+	}
+	return int(limit / 4)
+}
+
+type contextKey struct{}
+
+// WithLimiter returns a context whose transfers are also throttled by l.
+// Nil limiters are ignored.
+func WithLimiter(ctx context.Context, limiters ...*Limiter) context.Context {
+	existing := Limiters(ctx)
+	all := append([]*Limiter(nil), existing...)
+	for _, l := range limiters {
+		if l != nil {
+			all = append(all, l)
+		}
+	}
+	if len(all) == len(existing) {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, all)
+}
+
+// Limiters returns the limiters attached to ctx.
+func Limiters(ctx context.Context) []*Limiter {
+	limiters, _ := ctx.Value(contextKey{}).([]*Limiter)
+	return limiters
+}
+
+// RateAt returns the combined rate of the limiters in ctx at t.
+func RateAt(ctx context.Context, t time.Time) Rate {
+	var rate Rate
+	for _, l := range Limiters(ctx) {
+		rate = rate.min(l.Rate(t))
+	}
+	return rate
+}
+
+// Flag returns the rclone --bwlimit value for the limiters in ctx, or ""
+// when unlimited. A single limiter passes its timetable so rclone follows
+// it during long transfers; several are collapsed into the current rate.
+// NOTE: each rclone process enforces the limit on its own.
+func Flag(ctx context.Context) string {
+	var limited []*Schedule
+	for _, l := range Limiters(ctx) {
+		if s := l.Schedule(); !s.Unlimited() {
+			limited = append(limited, s)
+		}
+	}
+	switch len(limited) {
+	case 0:
+		return ""
+	case 1:
+		return limited[0].String()
+	}
+	rate := RateAt(ctx, time.Now())
+	if rate.Unlimited() {
+		return ""
+	}
+	return rate.String()
+}
+
+// Reader throttles reads from r by the limiters in ctx. Without limiters r
+// is returned as is.
+func Reader(ctx context.Context, dir Direction, r io.Reader) io.Reader {
+	limiters := Limiters(ctx)
+	if len(limiters) == 0 {
+		return r
+	}
+	return &reader{ctx: ctx, dir: dir, r: r, limiters: limiters}
+}
+
+// ReadCloser is Reader for a body that must still be closed.
+func ReadCloser(ctx context.Context, dir Direction, rc io.ReadCloser) io.ReadCloser {
+	r := Reader(ctx, dir, rc)
+	if r == io.Reader(rc) {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, rc}
+}
+
+type reader struct {
+	ctx      context.Context
+	dir      Direction
+	r        io.Reader
+	limiters []*Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for _, l := range r.limiters {
+		if c := l.chunk(r.dir); len(p) > c {
+			p = p[:c]
+		}
+	}
+	n, err := r.r.Read(p)
+	for _, l := range r.limiters {
+		if werr := l.wait(r.ctx, r.dir, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
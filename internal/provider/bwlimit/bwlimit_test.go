@@ -0,0 +1,238 @@
+package bwlimit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		in, want  string
+		timetable bool
+	}{
+		{"", "off", false},
+		{"off", "off", false},
+		{"1M", "1M", false},
+		{"512", "512k", false},
+		{"1.5M", "1536k", false},
+		{"100b", "100b", false},
+		{"10M:off", "10M:off", false},
+		{"08:00,512k 18:00,off", "08:00,512k 18:00,off", true},
+		{"mon-08:00,1M:4M Saturday-00:00,off", "Mon-08:00,1M:4M Sat-00:00,off", true},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if s.String() != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.in, tt.want, s.String())
+		}
+		if s.Timetable() != tt.timetable {
+			t.Errorf("%q: expected timetable=%v", tt.in, tt.timetable)
+		}
+		again, err := ParseSchedule(s.String())
+		if err != nil || again.String() != s.String() {
+			t.Errorf("%q: canonical form did not round-trip: %v %v", tt.in, again, err)
+		}
+	}
+
+	for _, bad := range []string{"fast", "-1M", "1M:x", "08:00,", "25:00,1M", "Funday-08:00,1M", "08:00,1M 9am,off"} {
+		if _, err := ParseSchedule(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestSchedule_At(t *testing.T) {
+	daily, _ := ParseSchedule("08:00,512k 18:00,10M 23:00,off")
+	weekly, _ := ParseSchedule("Mon-08:00,1M Sat-00:00,off")
+	// 2026-10-19 is a Monday
+	at := func(day int, clock string) time.Time {
+		c, _ := time.Parse("15:04", clock)
+		return time.Date(2026, 10, 19+day, c.Hour(), c.Minute(), 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name string
+		s    *Schedule
+		t    time.Time
+		want int64
+	}{
+		{"daily morning", daily, at(0, "08:00"), 512 << 10},
+		{"daily evening", daily, at(2, "20:30"), 10 << 20},
+		{"daily night", daily, at(2, "23:59"), 0},
+		{"daily wraps before first entry", daily, at(3, "03:00"), 0},
+		{"weekly midweek", weekly, at(2, "12:00"), 1 << 20},
+		{"weekly weekend", weekly, at(5, "09:00"), 0},
+		{"weekly wraps to sunday", weekly, at(6, "23:00"), 0},
+		{"weekly monday early", weekly, at(0, "07:59"), 0},
+	}
+	for _, tt := range tests {
+		if got := tt.s.At(tt.t); got.Up != tt.want || got.Down != tt.want {
+			t.Errorf("%s: expected %d, got %+v", tt.name, tt.want, got)
+		}
+	}
+
+	var none *Schedule
+	if !none.At(time.Now()).Unlimited() || !none.Unlimited() {
+		t.Error("nil schedule must be unlimited")
+	}
+}
+
+func TestReader_Throttles(t *testing.T) {
+	sched, _ := ParseSchedule("256k")
+	l := NewLimiter(sched)
+	ctx := WithLimiter(context.Background(), l)
+
+	// The bucket starts with one second of tokens, so read past them
+	data := make([]byte, 384<<10)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, Reader(ctx, Upload, bytes.NewReader(data)))
+	elapsed := time.Since(start)
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("copy failed: %d %v", n, err)
+	}
+	if elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("expected about 500ms at 256k/s, took %v", elapsed)
+	}
+
+	// Downloads are not limited by an upload-only schedule
+	upOnly, _ := ParseSchedule("1k:off")
+	l.SetSchedule(upOnly)
+	start = time.Now()
+	io.Copy(io.Discard, Reader(ctx, Download, bytes.NewReader(data)))
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("download should be unlimited, took %v", elapsed)
+	}
+}
+
+func TestReader_SharedLimiter(t *testing.T) {
+	sched, _ := ParseSchedule("256k")
+	l := NewLimiter(sched)
+	ctx := WithLimiter(context.Background(), l)
+
+	// Two readers share 256k/s: 2×256k past the initial second takes ~1s
+	data := make([]byte, 384<<10)
+	start := time.Now()
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			io.Copy(io.Discard, Reader(ctx, Download, bytes.NewReader(data)))
+			done <- struct{}{}
+		}()
+	}
+	<-done
+	<-done
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Errorf("expected shared limit to take about 2s, took %v", elapsed)
+	}
+}
+
+func TestReader_Cancel(t *testing.T) {
+	sched, _ := ParseSchedule("1k")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ctx = WithLimiter(ctx, NewLimiter(sched))
+
+	_, err := io.Copy(io.Discard, Reader(ctx, Upload, bytes.NewReader(make([]byte, 64<<10))))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestReader_PassThrough(t *testing.T) {
+	r := bytes.NewReader(nil)
+	if Reader(context.Background(), Upload, r) != io.Reader(r) {
+		t.Error("expected reader untouched without limiters")
+	}
+	rc := io.NopCloser(r)
+	if ReadCloser(context.Background(), Download, rc) != rc {
+		t.Error("expected read closer untouched without limiters")
+	}
+	if ctx := context.Background(); WithLimiter(ctx, nil) != ctx {
+		t.Error("nil limiters must not change the context")
+	}
+}
+
+func TestFlag(t *testing.T) {
+	ctx := context.Background()
+	if f := Flag(ctx); f != "" {
+		t.Errorf("expected no flag, got %q", f)
+	}
+
+	timetable, _ := ParseSchedule("08:00,512k 18:00,off")
+	off, _ := ParseSchedule("off")
+	if f := Flag(WithLimiter(ctx, NewLimiter(timetable), NewLimiter(off))); f != "08:00,512k 18:00,off" {
+		t.Errorf("expected the single timetable, got %q", f)
+	}
+
+	one, _ := ParseSchedule("1M")
+	two, _ := ParseSchedule("2M:512k")
+	if f := Flag(WithLimiter(ctx, NewLimiter(one), NewLimiter(two))); f != "1M:512k" {
+		t.Errorf("expected the tightest current rate, got %q", f)
+	}
+}
+
+// stubProvider records the limiters attached to each call.
+type stubProvider struct {
+	seen []int
+}
+
+func (s *stubProvider) ID() string          { return "stub" }
+func (s *stubProvider) Type() string        { return "stub" }
+func (s *stubProvider) DisplayName() string { return "Stub" }
+func (s *stubProvider) Init(ctx context.Context, config map[string]interface{}) error {
+	return nil
+}
+func (s *stubProvider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{}, nil
+}
+func (s *stubProvider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	return &provider.Usage{}, nil
+}
+func (s *stubProvider) CheckHealth(ctx context.Context) provider.HealthState {
+	return provider.HealthStateHealthy
+}
+func (s *stubProvider) Upload(ctx context.Context, localPath, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	s.seen = append(s.seen, len(Limiters(ctx)))
+	return &provider.UploadResult{}, nil
+}
+func (s *stubProvider) Download(ctx context.Context, remotePath, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	s.seen = append(s.seen, len(Limiters(ctx)))
+	return &provider.DownloadResult{}, nil
+}
+func (s *stubProvider) Delete(ctx context.Context, remotePath string) error {
+	s.seen = append(s.seen, len(Limiters(ctx)))
+	return nil
+}
+func (s *stubProvider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	return &provider.VerifyResult{}, nil
+}
+
+func TestProvider_AttachesLimiters(t *testing.T) {
+	stub := &stubProvider{}
+	global, own := NewLimiter(nil), NewLimiter(nil)
+	p := NewProvider(stub, global, nil, own)
+	ctx := context.Background()
+
+	p.Upload(ctx, "a", "a", nil)
+	p.Download(ctx, "a", "a", nil)
+	p.Delete(ctx, "a")
+	if len(stub.seen) != 3 || stub.seen[0] != 2 || stub.seen[1] != 2 || stub.seen[2] != 0 {
+		t.Errorf("expected limiters on transfers only, got %v", stub.seen)
+	}
+	if _, err := p.DownloadRange(ctx, "a", 0, 1, io.Discard); err == nil {
+		t.Error("expected error for provider without ranged downloads")
+	}
+	if p.Inner() != stub {
+		t.Error("Inner must return the wrapped provider")
+	}
+}
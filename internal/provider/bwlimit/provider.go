@@ -0,0 +1,108 @@
+package bwlimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// Provider attaches limiters to every transfer made through another
+// provider. The wrapped provider does the throttling: native providers
+// through Reader, rclone through Flag.
+type Provider struct {
+	inner    provider.Provider
+	limiters []*Limiter
+}
+
+// NewProvider wraps inner. Typically limiters are the global limiter,
+// shared by all providers, and the provider's own. Nil limiters are ignored.
+func NewProvider(inner provider.Provider, limiters ...*Limiter) *Provider {
+	p := &Provider{inner: inner}
+	for _, l := range limiters {
+		if l != nil {
+			p.limiters = append(p.limiters, l)
+		}
+	}
+	return p
+}
+
+// Inner returns the wrapped provider.
+func (p *Provider) Inner() provider.Provider {
+	return p.inner
+}
+
+// ID returns the wrapped provider's ID.
+func (p *Provider) ID() string {
+	return p.inner.ID()
+}
+
+// Type returns the wrapped provider's type.
+func (p *Provider) Type() string {
+	return p.inner.Type()
+}
+
+// DisplayName returns the wrapped provider's name.
+func (p *Provider) DisplayName() string {
+	return p.inner.DisplayName()
+}
+
+// Init initializes the wrapped provider.
+func (p *Provider) Init(ctx context.Context, config map[string]interface{}) error {
+	return p.inner.Init(ctx, config)
+}
+
+// Capabilities returns the wrapped provider's capabilities.
+func (p *Provider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return p.inner.Capabilities(ctx)
+}
+
+// GetUsage returns the wrapped provider's usage.
+func (p *Provider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	return p.inner.GetUsage(ctx)
+}
+
+// Upload uploads with the limiters attached.
+func (p *Provider) Upload(ctx context.Context, localPath string, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	return p.inner.Upload(WithLimiter(ctx, p.limiters...), localPath, remotePath, progress)
+}
+
+// Download downloads with the limiters attached.
+func (p *Provider) Download(ctx context.Context, remotePath string, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	return p.inner.Download(WithLimiter(ctx, p.limiters...), remotePath, localPath, progress)
+}
+
+// DownloadRange downloads a range with the limiters attached.
+func (p *Provider) DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	ranged, ok := provider.AsRangeDownloader(p.inner)
+	if !ok {
+		return 0, fmt.Errorf("provider %s does not support ranged downloads", p.inner.ID())
+	}
+	return ranged.DownloadRange(WithLimiter(ctx, p.limiters...), remotePath, offset, length, w)
+}
+
+// Delete deletes through the wrapped provider.
+func (p *Provider) Delete(ctx context.Context, remotePath string) error {
+	return p.inner.Delete(ctx, remotePath)
+}
+
+// Verify verifies through the wrapped provider. Providers that hash by
+// downloading are not throttled here.
+func (p *Provider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	return p.inner.Verify(ctx, remotePath)
+}
+
+// List lists through the wrapped provider.
+func (p *Provider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	lister, ok := provider.AsLister(p.inner)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support listing", p.inner.ID())
+	}
+	return lister.List(ctx, prefix)
+}
+
+// CheckHealth returns the wrapped provider's health.
+func (p *Provider) CheckHealth(ctx context.Context) provider.HealthState {
+	return p.inner.CheckHealth(ctx)
+}
@@ -0,0 +1,254 @@
+package bwlimit
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate is a pair of limits in bytes per second. Zero means unlimited.
+type Rate struct {
+	Up   int64
+	Down int64
+}
+
+// Unlimited reports whether neither direction is limited.
+func (r Rate) Unlimited() bool {
+	return r.Up <= 0 && r.Down <= 0
+}
+
+// String formats the rate in the timetable syntax, e.g. "512k" or "1M:off".
+func (r Rate) String() string {
+	if r.Up == r.Down {
+		return formatBytes(r.Up)
+	}
+	return formatBytes(r.Up) + ":" + formatBytes(r.Down)
+}
+
+// min returns the tighter of two rates, direction by direction.
+func (r Rate) min(o Rate) Rate {
+	return Rate{Up: minLimit(r.Up, o.Up), Down: minLimit(r.Down, o.Down)}
+}
+
+func minLimit(a, b int64) int64 {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 || a < b {
+		return a
+	}
+	return b
+}
+
+const minutesPerDay = 24 * 60
+
+var weekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// slot is a timetable entry. day is -1 for entries applying every day.
+type slot struct {
+	day    int
+	minute int
+	rate   Rate
+}
+
+// Schedule is a bandwidth timetable in rclone's --bwlimit syntax, so the
+// same string can be handed to rclone:
+//
+//	1M                                 1 MiB/s both ways, all the time
+//	10M:off                            limit uploads only (upload:download)
+//	08:00,512k 18:00,10M 23:00,off     daily timetable
+//	Mon-08:00,512k Sat-00:00,off       weekly timetable
+//
+// Sizes take b, k, M, G or T suffixes (powers of 1024); a bare number is
+// KiB/s. "off" or 0 means unlimited. Each timetable entry holds until the
+// next one and the last entry wraps around.
+type Schedule struct {
+	slots []slot
+}
+
+// ParseSchedule parses a timetable. An empty string means unlimited.
+func ParseSchedule(s string) (*Schedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return &Schedule{}, nil
+	}
+	if len(fields) == 1 && !strings.Contains(fields[0], ",") {
+		rate, err := parseRate(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		return &Schedule{slots: []slot{{day: -1, rate: rate}}}, nil
+	}
+
+	sched := &Schedule{}
+	for _, field := range fields {
+		when, rateText, ok := strings.Cut(field, ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid bwlimit entry %q (want [Day-]HH:MM,rate)", field)
+		}
+		sl := slot{day: -1}
+		if dayText, clock, ok := strings.Cut(when, "-"); ok {
+			sl.day = -2
+			for i, d := range weekdays {
+				if strings.EqualFold(dayText, d) || strings.EqualFold(dayText, time.Weekday(i).String()) {
+					sl.day = i
+				}
+			}
+			if sl.day == -2 {
+				return nil, fmt.Errorf("invalid bwlimit day %q", dayText)
+			}
+			when = clock
+		}
+		clock, err := time.Parse("15:04", when)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bwlimit time %q (want HH:MM)", when)
+		}
+		sl.minute = clock.Hour()*60 + clock.Minute()
+		if sl.rate, err = parseRate(rateText); err != nil {
+			return nil, err
+		}
+		sched.slots = append(sched.slots, sl)
+	}
+	return sched, nil
+}
+
+// parseRate parses "rate" or "up:down".
+func parseRate(s string) (Rate, error) {
+	upText, downText, split := strings.Cut(s, ":")
+	up, err := parseBytes(upText)
+	if err != nil {
+		return Rate{}, err
+	}
+	if !split {
+		return Rate{Up: up, Down: up}, nil
+	}
+	down, err := parseBytes(downText)
+	if err != nil {
+		return Rate{}, err
+	}
+	return Rate{Up: up, Down: down}, nil
+}
+
+// parseBytes parses a size in bytes per second, KiB when unsuffixed.
+func parseBytes(s string) (int64, error) {
+	if strings.EqualFold(s, "off") {
+		return 0, nil
+	}
+	mult := float64(1 << 10)
+	num := s
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'b', 'B':
+			mult, num = 1, s[:n-1]
+		case 'k', 'K':
+			mult, num = 1<<10, s[:n-1]
+		case 'm', 'M':
+			mult, num = 1<<20, s[:n-1]
+		case 'g', 'G':
+			mult, num = 1<<30, s[:n-1]
+		case 't', 'T':
+			mult, num = 1<<40, s[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q (e.g. 512k, 10M or off)", s)
+	}
+	return int64(v * mult), nil
+}
+
+// formatBytes formats a limit so parseBytes reads it back exactly.
+func formatBytes(n int64) string {
+	switch {
+	case n <= 0:
+		return "off"
+	case n%(1<<30) == 0:
+		return strconv.FormatInt(n>>30, 10) + "G"
+	case n%(1<<20) == 0:
+		return strconv.FormatInt(n>>20, 10) + "M"
+	case n%(1<<10) == 0:
+		return strconv.FormatInt(n>>10, 10) + "k"
+	default:
+		return strconv.FormatInt(n, 10) + "b"
+	}
+}
+
+// Unlimited reports whether the schedule never limits anything.
+func (s *Schedule) Unlimited() bool {
+	if s == nil {
+		return true
+	}
+	for _, sl := range s.slots {
+		if !sl.rate.Unlimited() {
+			return false
+		}
+	}
+	return true
+}
+
+// Timetable reports whether the rate changes with the time of day.
+func (s *Schedule) Timetable() bool {
+	if s == nil || len(s.slots) == 0 {
+		return false
+	}
+	// A constant rate is stored as one daily entry at midnight
+	return len(s.slots) > 1 || s.slots[0].day >= 0 || s.slots[0].minute != 0
+}
+
+// At returns the rate in force at t (local time).
+func (s *Schedule) At(t time.Time) Rate {
+	if s == nil || len(s.slots) == 0 {
+		return Rate{}
+	}
+	if !s.Timetable() {
+		return s.slots[0].rate
+	}
+
+	// Daily entries repeat on every day of the week
+	type point struct {
+		at   int
+		rate Rate
+	}
+	var points []point
+	for _, sl := range s.slots {
+		if sl.day >= 0 {
+			points = append(points, point{sl.day*minutesPerDay + sl.minute, sl.rate})
+			continue
+		}
+		for d := 0; d < 7; d++ {
+			points = append(points, point{d*minutesPerDay + sl.minute, sl.rate})
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].at < points[j].at })
+
+	now := int(t.Weekday())*minutesPerDay + t.Hour()*60 + t.Minute()
+	rate := points[len(points)-1].rate
+	for _, p := range points {
+		if p.at > now {
+			break
+		}
+		rate = p.rate
+	}
+	return rate
+}
+
+// String returns the schedule in canonical timetable syntax, or "off".
+func (s *Schedule) String() string {
+	if s == nil || len(s.slots) == 0 {
+		return "off"
+	}
+	if !s.Timetable() {
+		return s.slots[0].rate.String()
+	}
+	parts := make([]string, len(s.slots))
+	for i, sl := range s.slots {
+		clock := fmt.Sprintf("%02d:%02d", sl.minute/60, sl.minute%60)
+		if sl.day >= 0 {
+			clock = weekdays[sl.day] + "-" + clock
+		}
+		parts[i] = clock + "," + sl.rate.String()
+	}
+	return strings.Join(parts, " ")
+}
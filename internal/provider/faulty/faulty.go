@@ -0,0 +1,443 @@
+// Package faulty wraps a provider and injects failures, so push, hydrate,
+// journal recovery and health degradation can be exercised against a
+// backend that flakes.
+//
+// INVARIANTS:
+// - Faults are drawn from a seeded source; a sequential run is reproducible
+// - Every call draws the same number of values whatever the rates
+// - Damaged downloads report the hash of what was written, like a real provider
+// - Without faults every call passes through unchanged
+package faulty
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// Injected errors. Callers tell them apart with errors.Is.
+var (
+	ErrInjected      = errors.New("injected provider failure")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// Operation names used in Config.Ops and Stats.
+const (
+	OpCapabilities = "capabilities"
+	OpUsage        = "usage"
+	OpUpload       = "upload"
+	OpDownload     = "download"
+	OpRange        = "range"
+	OpDelete       = "delete"
+	OpVerify       = "verify"
+	OpList         = "list"
+	OpHealth       = "health"
+)
+
+// Fault kinds counted in Stats.
+const (
+	FaultError    = "error"
+	FaultHang     = "hang"
+	FaultQuota    = "quota"
+	FaultTruncate = "truncate"
+	FaultCorrupt  = "corrupt"
+)
+
+// Config controls which faults are injected and how often.
+// Rates are probabilities per call, from 0 to 1.
+type Config struct {
+	Seed         int64           // Source seed (0 = 1)
+	Latency      time.Duration   // Added to every affected call
+	ErrorRate    float64         // Fail with ErrInjected
+	HangRate     float64         // Block until the context is done
+	QuotaRate    float64         // Uploads fail with ErrQuotaExceeded
+	QuotaBytes   int64           // Uploads fail once this many bytes went through (0 = no limit)
+	TruncateRate float64         // Downloads keep only a prefix of the data
+	CorruptRate  float64         // Downloads have one byte flipped
+	Ops          map[string]bool // Affected operations (nil = all)
+}
+
+// ParseConfig builds a Config from provider_config key/value pairs:
+// seed, latency (e.g. "200ms"), error_rate, hang_rate, quota_rate,
+// quota_bytes, truncate_rate, corrupt_rate and ops (comma-separated).
+func ParseConfig(cfg map[string]string) (Config, error) {
+	var c Config
+	if v := cfg["seed"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid faulty seed: %s", v)
+		}
+		c.Seed = n
+	}
+	if v := cfg["latency"]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return c, fmt.Errorf("invalid faulty latency: %s", v)
+		}
+		c.Latency = d
+	}
+	if v := cfg["quota_bytes"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return c, fmt.Errorf("invalid faulty quota_bytes: %s", v)
+		}
+		c.QuotaBytes = n
+	}
+	for key, dst := range map[string]*float64{
+		"error_rate":    &c.ErrorRate,
+		"hang_rate":     &c.HangRate,
+		"quota_rate":    &c.QuotaRate,
+		"truncate_rate": &c.TruncateRate,
+		"corrupt_rate":  &c.CorruptRate,
+	} {
+		if v := cfg[key]; v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return c, fmt.Errorf("invalid faulty %s: %s", key, v)
+			}
+			*dst = f
+		}
+	}
+	if v := cfg["ops"]; v != "" {
+		c.Ops = make(map[string]bool)
+		for _, op := range strings.Split(v, ",") {
+			c.Ops[strings.TrimSpace(op)] = true
+		}
+	}
+	return c, c.validate()
+}
+
+// validate checks rates and operation names.
+func (c *Config) validate() error {
+	for name, rate := range map[string]float64{
+		"error_rate": c.ErrorRate, "hang_rate": c.HangRate, "quota_rate": c.QuotaRate,
+		"truncate_rate": c.TruncateRate, "corrupt_rate": c.CorruptRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("faulty %s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if c.HangRate+c.ErrorRate+c.QuotaRate > 1 {
+		return fmt.Errorf("faulty hang_rate + error_rate + quota_rate must not exceed 1")
+	}
+	if c.TruncateRate+c.CorruptRate > 1 {
+		return fmt.Errorf("faulty truncate_rate + corrupt_rate must not exceed 1")
+	}
+	for op := range c.Ops {
+		switch op {
+		case OpCapabilities, OpUsage, OpUpload, OpDownload, OpRange, OpDelete, OpVerify, OpList, OpHealth:
+		default:
+			return fmt.Errorf("unknown faulty operation: %s", op)
+		}
+	}
+	return nil
+}
+
+// Provider decorates another provider with injected faults.
+type Provider struct {
+	id          string
+	displayName string
+	inner       provider.Provider
+	cfg         Config
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	uploaded int64          // Bytes accepted, for QuotaBytes
+	stats    map[string]int // Injected faults by kind
+}
+
+// NewProvider wraps inner. id is the name the wrapper is registered under.
+func NewProvider(id string, inner provider.Provider, cfg Config) (*Provider, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = 1
+	}
+	return &Provider{
+		id:          id,
+		displayName: id + " (faulty " + inner.DisplayName() + ")",
+		inner:       inner,
+		cfg:         cfg,
+		rng:         rand.New(rand.NewSource(seed)),
+		stats:       make(map[string]int),
+	}, nil
+}
+
+// Inner returns the wrapped provider.
+func (p *Provider) Inner() provider.Provider {
+	return p.inner
+}
+
+// Stats returns the number of faults injected so far, by kind.
+func (p *Provider) Stats() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]int, len(p.stats))
+	for k, v := range p.stats {
+		stats[k] = v
+	}
+	return stats
+}
+
+// ID returns the unique identifier for this provider instance.
+func (p *Provider) ID() string {
+	return p.id
+}
+
+// Type returns the provider type.
+func (p *Provider) Type() string {
+	return "faulty"
+}
+
+// DisplayName returns the human-readable name.
+func (p *Provider) DisplayName() string {
+	return p.displayName
+}
+
+// Init initializes the wrapped provider. Init itself is never faulted.
+func (p *Provider) Init(ctx context.Context, config map[string]interface{}) error {
+	return p.inner.Init(ctx, config)
+}
+
+// Capabilities returns the wrapped provider's capabilities.
+func (p *Provider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	if err := p.inject(ctx, OpCapabilities, false); err != nil {
+		return nil, err
+	}
+	return p.inner.Capabilities(ctx)
+}
+
+// GetUsage returns the wrapped provider's usage.
+func (p *Provider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	if err := p.inject(ctx, OpUsage, false); err != nil {
+		return nil, err
+	}
+	return p.inner.GetUsage(ctx)
+}
+
+// Upload may fail before reaching the wrapped provider, including with
+// ErrQuotaExceeded once QuotaBytes have been uploaded.
+func (p *Provider) Upload(ctx context.Context, localPath string, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	if err := p.inject(ctx, OpUpload, true); err != nil {
+		return nil, fmt.Errorf("upload %s: %w", remotePath, err)
+	}
+	if p.affects(OpUpload) && p.cfg.QuotaBytes > 0 {
+		info, err := os.Stat(localPath)
+		if err != nil {
+			return nil, fmt.Errorf("local file not found: %w", err)
+		}
+		p.mu.Lock()
+		over := p.uploaded+info.Size() > p.cfg.QuotaBytes
+		if over {
+			p.stats[FaultQuota]++
+		} else {
+			p.uploaded += info.Size()
+		}
+		p.mu.Unlock()
+		if over {
+			return nil, fmt.Errorf("upload %s: %w", remotePath, ErrQuotaExceeded)
+		}
+	}
+	return p.inner.Upload(ctx, localPath, remotePath, progress)
+}
+
+// Download may fail, or succeed with truncated or corrupted data.
+func (p *Provider) Download(ctx context.Context, remotePath string, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	if err := p.inject(ctx, OpDownload, false); err != nil {
+		return nil, fmt.Errorf("download %s: %w", remotePath, err)
+	}
+	damage, pos := p.drawDamage(OpDownload)
+
+	result, err := p.inner.Download(ctx, remotePath, localPath, progress)
+	if err != nil || damage == "" {
+		return result, err
+	}
+
+	size, hash, err := damageFile(localPath, damage, pos)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", remotePath, err)
+	}
+	damaged := *result
+	damaged.Size = size
+	damaged.ContentHash = hash
+	return &damaged, nil
+}
+
+// DownloadRange may fail, or deliver a short or corrupted range.
+func (p *Provider) DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	ranged, ok := provider.AsRangeDownloader(p.inner)
+	if !ok {
+		return 0, fmt.Errorf("provider %s does not support ranged downloads", p.inner.ID())
+	}
+	if err := p.inject(ctx, OpRange, false); err != nil {
+		return 0, fmt.Errorf("ranged download %s: %w", remotePath, err)
+	}
+	damage, pos := p.drawDamage(OpRange)
+	if damage == "" {
+		return ranged.DownloadRange(ctx, remotePath, offset, length, w)
+	}
+
+	var buf bytes.Buffer
+	if _, err := ranged.DownloadRange(ctx, remotePath, offset, length, &buf); err != nil {
+		return 0, err
+	}
+	data := damageBytes(buf.Bytes(), damage, pos)
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// Delete may fail before reaching the wrapped provider.
+func (p *Provider) Delete(ctx context.Context, remotePath string) error {
+	if err := p.inject(ctx, OpDelete, false); err != nil {
+		return fmt.Errorf("delete %s: %w", remotePath, err)
+	}
+	return p.inner.Delete(ctx, remotePath)
+}
+
+// Verify may fail before reaching the wrapped provider.
+func (p *Provider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	if err := p.inject(ctx, OpVerify, false); err != nil {
+		return nil, fmt.Errorf("verify %s: %w", remotePath, err)
+	}
+	return p.inner.Verify(ctx, remotePath)
+}
+
+// List may fail before reaching the wrapped provider.
+func (p *Provider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	lister, ok := provider.AsLister(p.inner)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support listing", p.inner.ID())
+	}
+	if err := p.inject(ctx, OpList, false); err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+	return lister.List(ctx, prefix)
+}
+
+// CheckHealth reports degraded on an injected error and unavailable
+// on an injected hang (once ctx is done).
+// NOTE: Health is observational, not decision authority.
+func (p *Provider) CheckHealth(ctx context.Context) provider.HealthState {
+	if err := p.inject(ctx, OpHealth, false); err != nil {
+		if errors.Is(err, ErrInjected) {
+			return provider.HealthStateDegraded
+		}
+		return provider.HealthStateUnavailable
+	}
+	return p.inner.CheckHealth(ctx)
+}
+
+// affects reports whether faults apply to op.
+func (p *Provider) affects(op string) bool {
+	return p.cfg.Ops == nil || p.cfg.Ops[op]
+}
+
+// inject applies latency and draws a hang, error or (for uploads) quota fault.
+func (p *Provider) inject(ctx context.Context, op string, upload bool) error {
+	if !p.affects(op) {
+		return nil
+	}
+
+	p.mu.Lock()
+	r := p.rng.Float64()
+	fault := ""
+	switch {
+	case r < p.cfg.HangRate:
+		fault = FaultHang
+	case r < p.cfg.HangRate+p.cfg.ErrorRate:
+		fault = FaultError
+	case upload && r < p.cfg.HangRate+p.cfg.ErrorRate+p.cfg.QuotaRate:
+		fault = FaultQuota
+	}
+	if fault != "" {
+		p.stats[fault]++
+	}
+	p.mu.Unlock()
+
+	if p.cfg.Latency > 0 {
+		select {
+		case <-time.After(p.cfg.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	switch fault {
+	case FaultHang:
+		<-ctx.Done()
+		return ctx.Err()
+	case FaultError:
+		return ErrInjected
+	case FaultQuota:
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// drawDamage decides whether a download is truncated or corrupted. pos
+// in [0,1) picks the truncation point or the flipped byte.
+func (p *Provider) drawDamage(op string) (string, float64) {
+	if !p.affects(op) {
+		return "", 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, pos := p.rng.Float64(), p.rng.Float64()
+	var damage string
+	switch {
+	case r < p.cfg.TruncateRate:
+		damage = FaultTruncate
+	case r < p.cfg.TruncateRate+p.cfg.CorruptRate:
+		damage = FaultCorrupt
+	default:
+		return "", 0
+	}
+	p.stats[damage]++
+	return damage, pos
+}
+
+// damageFile truncates or corrupts path in place and returns the new
+// size and SHA-256.
+func damageFile(path, damage string, pos float64) (int64, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, "", err
+	}
+	data = damageBytes(data, damage, pos)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return 0, "", err
+	}
+	sum := sha256.Sum256(data)
+	return int64(len(data)), hex.EncodeToString(sum[:]), nil
+}
+
+// damageBytes returns data truncated at pos or with the byte at pos flipped.
+func damageBytes(data []byte, damage string, pos float64) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	i := int(pos * float64(len(data)))
+	switch damage {
+	case FaultTruncate:
+		return data[:i]
+	case FaultCorrupt:
+		damaged := append([]byte(nil), data...)
+		damaged[i] ^= 0xff
+		return damaged
+	}
+	return data
+}
@@ -0,0 +1,245 @@
+package faulty
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/providertest"
+)
+
+// memProvider stores objects in memory.
+type memProvider struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemProvider() *memProvider {
+	return &memProvider{data: make(map[string][]byte)}
+}
+
+func (m *memProvider) ID() string          { return "mem" }
+func (m *memProvider) Type() string        { return "memory" }
+func (m *memProvider) DisplayName() string { return "Memory" }
+func (m *memProvider) Init(ctx context.Context, config map[string]interface{}) error {
+	return nil
+}
+func (m *memProvider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{ConcurrentUploads: 2}, nil
+}
+func (m *memProvider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	return &provider.Usage{}, nil
+}
+func (m *memProvider) CheckHealth(ctx context.Context) provider.HealthState {
+	return provider.HealthStateHealthy
+}
+func (m *memProvider) Upload(ctx context.Context, localPath, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.data[remotePath] = b
+	m.mu.Unlock()
+	return &provider.UploadResult{RemotePath: remotePath, ContentHash: hashOf(b), Size: int64(len(b))}, nil
+}
+func (m *memProvider) Download(ctx context.Context, remotePath, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	m.mu.Lock()
+	b, ok := m.data[remotePath]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("not found: %s", remotePath)
+	}
+	os.MkdirAll(filepath.Dir(localPath), 0700)
+	if err := os.WriteFile(localPath, b, 0600); err != nil {
+		return nil, err
+	}
+	return &provider.DownloadResult{LocalPath: localPath, ContentHash: hashOf(b), Size: int64(len(b))}, nil
+}
+func (m *memProvider) Delete(ctx context.Context, remotePath string) error {
+	m.mu.Lock()
+	delete(m.data, remotePath)
+	m.mu.Unlock()
+	return nil
+}
+func (m *memProvider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	m.mu.Lock()
+	b, ok := m.data[remotePath]
+	m.mu.Unlock()
+	if !ok {
+		return &provider.VerifyResult{IsValid: false}, nil
+	}
+	return &provider.VerifyResult{IsValid: true, ContentHash: hashOf(b)}, nil
+}
+
+func (m *memProvider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var objects []provider.RemoteObject
+	for path, b := range m.data {
+		if strings.HasPrefix(path, prefix) {
+			objects = append(objects, provider.RemoteObject{Path: path, Size: int64(len(b))})
+		}
+	}
+	return objects, nil
+}
+
+func hashOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestProvider_PassThrough(t *testing.T) {
+	p, err := NewProvider("flaky", newMemProvider(), Config{})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	// Without faults the wrapper is indistinguishable from its provider
+	providertest.Run(t, p, providertest.Options{Prefix: "mem:", LargeSize: 1 << 20})
+	if len(p.Stats()) != 0 {
+		t.Errorf("expected no injected faults, got %v", p.Stats())
+	}
+}
+
+func TestProvider_Deterministic(t *testing.T) {
+	ctx := context.Background()
+	pattern := func(seed int64) string {
+		p, _ := NewProvider("flaky", newMemProvider(), Config{Seed: seed, ErrorRate: 0.3, HangRate: 0})
+		var b strings.Builder
+		for i := 0; i < 64; i++ {
+			if err := p.Delete(ctx, "mem:/x"); errors.Is(err, ErrInjected) {
+				b.WriteByte('x')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		return b.String()
+	}
+
+	first := pattern(42)
+	if first != pattern(42) {
+		t.Error("expected the same seed to inject the same faults")
+	}
+	if first == pattern(43) {
+		t.Error("expected another seed to inject different faults")
+	}
+	if n := strings.Count(first, "x"); n < 8 || n > 32 {
+		t.Errorf("expected roughly 30%% failures, got %d/64: %s", n, first)
+	}
+}
+
+func TestProvider_DamagedDownloads(t *testing.T) {
+	ctx := context.Background()
+	inner := newMemProvider()
+	content := bytes.Repeat([]byte("payload "), 512)
+	inner.data["mem:/a"] = content
+	dir := t.TempDir()
+
+	for _, cfg := range []Config{{TruncateRate: 1}, {CorruptRate: 1}} {
+		p, _ := NewProvider("flaky", inner, cfg)
+		local := filepath.Join(dir, "a")
+		result, err := p.Download(ctx, "mem:/a", local, nil)
+		if err != nil {
+			t.Fatalf("damaged download should still succeed: %v", err)
+		}
+		got, _ := os.ReadFile(local)
+		if bytes.Equal(got, content) || result.ContentHash != hashOf(got) || result.Size != int64(len(got)) {
+			t.Errorf("%+v: expected damaged content reported faithfully, got %d bytes %+v", cfg, len(got), result)
+		}
+		if cfg.TruncateRate == 1 && len(got) >= len(content) {
+			t.Errorf("expected truncated download, got %d bytes", len(got))
+		}
+		if cfg.CorruptRate == 1 && len(got) != len(content) {
+			t.Errorf("expected corrupted download of full length, got %d bytes", len(got))
+		}
+	}
+}
+
+func TestProvider_QuotaAndOps(t *testing.T) {
+	ctx := context.Background()
+	local := filepath.Join(t.TempDir(), "f")
+	os.WriteFile(local, make([]byte, 600), 0600)
+
+	p, _ := NewProvider("flaky", newMemProvider(), Config{QuotaBytes: 1000})
+	if _, err := p.Upload(ctx, local, "mem:/1", nil); err != nil {
+		t.Fatalf("first upload within quota failed: %v", err)
+	}
+	if _, err := p.Upload(ctx, local, "mem:/2", nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+
+	// Faults limited to uploads leave other operations alone
+	p, _ = NewProvider("flaky", newMemProvider(), Config{ErrorRate: 1, Ops: map[string]bool{OpUpload: true}})
+	if _, err := p.Upload(ctx, local, "mem:/1", nil); !errors.Is(err, ErrInjected) {
+		t.Errorf("expected injected upload failure, got %v", err)
+	}
+	if err := p.Delete(ctx, "mem:/1"); err != nil {
+		t.Errorf("expected delete unaffected, got %v", err)
+	}
+	if p.CheckHealth(ctx) != provider.HealthStateHealthy {
+		t.Error("expected health unaffected")
+	}
+	if p.Stats()[FaultError] != 1 {
+		t.Errorf("unexpected stats %v", p.Stats())
+	}
+}
+
+func TestProvider_HangAndHealth(t *testing.T) {
+	p, _ := NewProvider("flaky", newMemProvider(), Config{HangRate: 1, Latency: 10 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.Verify(ctx, "mem:/a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected hang until deadline, got %v", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("expected the call to block until the deadline")
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if p.CheckHealth(ctx) != provider.HealthStateUnavailable {
+		t.Error("expected a hanging provider to be unavailable")
+	}
+	p, _ = NewProvider("flaky", newMemProvider(), Config{ErrorRate: 1})
+	if p.CheckHealth(context.Background()) != provider.HealthStateDegraded {
+		t.Error("expected a failing provider to be degraded")
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig(map[string]string{
+		"seed": "9", "latency": "250ms", "error_rate": "0.1", "corrupt_rate": "0.05",
+		"quota_bytes": "1048576", "ops": "upload, download",
+	})
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if cfg.Seed != 9 || cfg.Latency != 250*time.Millisecond || cfg.ErrorRate != 0.1 || cfg.QuotaBytes != 1<<20 || !cfg.Ops[OpDownload] {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	for _, bad := range []map[string]string{
+		{"error_rate": "1.5"},
+		{"error_rate": "0.6", "hang_rate": "0.6"},
+		{"latency": "soon"},
+		{"ops": "upload,teleport"},
+	} {
+		if _, err := ParseConfig(bad); err == nil {
+			t.Errorf("expected %v to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+)
+
+// ErrNotFound is returned by Find when no plugin exists for a type.
+var ErrNotFound = errors.New("provider plugin not found")
+
+// Find returns the path of the plugin executable for provType.
+func Find(provType string) (string, error) {
+	path, err := exec.LookPath(ExecutablePrefix + provType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s%s not on PATH", ErrNotFound, ExecutablePrefix, provType)
+	}
+	return path, nil
+}
+
+// Provider adapts a plugin executable to provider.Provider. The process
+// is started on first use and restarted if it exits.
+type Provider struct {
+	id          string
+	displayName string
+	provType    string
+	executable  string
+	config      map[string]string
+
+	mu   sync.Mutex
+	conn *conn
+}
+
+// NewProvider creates a provider backed by executable. config is sent to
+// the plugin in the init request each time the process starts.
+func NewProvider(id, displayName, provType, executable string, config map[string]string) *Provider {
+	cfg := make(map[string]string, len(config))
+	for k, v := range config {
+		cfg[k] = v
+	}
+	return &Provider{
+		id:          id,
+		displayName: displayName,
+		provType:    provType,
+		executable:  executable,
+		config:      cfg,
+	}
+}
+
+// ID returns the unique identifier for this provider instance.
+func (p *Provider) ID() string {
+	return p.id
+}
+
+// Type returns the provider type.
+func (p *Provider) Type() string {
+	return p.provType
+}
+
+// DisplayName returns the human-readable name.
+func (p *Provider) DisplayName() string {
+	return p.displayName
+}
+
+// Init merges config into the stored configuration and (re)initializes the plugin.
+func (p *Provider) Init(ctx context.Context, config map[string]interface{}) error {
+	p.mu.Lock()
+	for k, v := range config {
+		p.config[k] = fmt.Sprint(v)
+	}
+	old := p.conn
+	p.conn = nil
+	p.mu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+	_, err := p.start(ctx)
+	return err
+}
+
+// Close stops the plugin process.
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	c := p.conn
+	p.conn = nil
+	p.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	return c.close()
+}
+
+// Capabilities returns what the plugin supports.
+func (p *Provider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	var caps provider.Capabilities
+	if err := p.call(ctx, MethodCapabilities, nil, &caps, nil); err != nil {
+		return nil, err
+	}
+	return &caps, nil
+}
+
+// GetUsage returns current usage statistics.
+func (p *Provider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	var usage provider.Usage
+	if err := p.call(ctx, MethodGetUsage, nil, &usage, nil); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// Upload uploads a file through the plugin, streaming progress.
+func (p *Provider) Upload(ctx context.Context, localPath string, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	params := TransferParams{LocalPath: localPath, RemotePath: remotePath, Progress: progress != nil, BWLimit: bwlimit.Flag(ctx)}
+	var result provider.UploadResult
+	if err := p.call(ctx, MethodUpload, params, &result, progress); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Download downloads a file through the plugin, streaming progress.
+func (p *Provider) Download(ctx context.Context, remotePath string, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	params := TransferParams{LocalPath: localPath, RemotePath: remotePath, Progress: progress != nil, BWLimit: bwlimit.Flag(ctx)}
+	var result provider.DownloadResult
+	if err := p.call(ctx, MethodDownload, params, &result, progress); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Delete removes a file from the provider.
+// NOTE: Only invoked during explicit purge or trash eviction after user confirmation.
+func (p *Provider) Delete(ctx context.Context, remotePath string) error {
+	return p.call(ctx, MethodDelete, PathParams{RemotePath: remotePath}, nil, nil)
+}
+
+// Verify checks data integrity on provider.
+func (p *Provider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	var result provider.VerifyResult
+	if err := p.call(ctx, MethodVerify, PathParams{RemotePath: remotePath}, &result, nil); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CheckHealth returns current health state. A plugin that cannot be
+// started or does not answer is unavailable.
+// NOTE: Health is observational, not decision authority.
+func (p *Provider) CheckHealth(ctx context.Context) provider.HealthState {
+	var result HealthResult
+	if err := p.call(ctx, MethodCheckHealth, nil, &result, nil); err != nil {
+		return provider.HealthStateUnavailable
+	}
+	switch result.State {
+	case provider.HealthStateHealthy, provider.HealthStateDegraded:
+		return result.State
+	default:
+		return provider.HealthStateUnavailable
+	}
+}
+
+// List returns every object below prefix. Fails with CodeMethodNotFound
+// when the plugin did not advertise list.
+func (p *Provider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	c, err := p.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !c.methods[MethodList] {
+		return nil, &Error{Code: CodeMethodNotFound, Message: "plugin does not implement list"}
+	}
+	var objects []provider.RemoteObject
+	if err := p.call(ctx, MethodList, ListParams{Prefix: prefix}, &objects, nil); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// call sends one request and waits for its response.
+func (p *Provider) call(ctx context.Context, method string, params, result interface{}, progress provider.ProgressFunc) error {
+	c, err := p.start(ctx)
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, method, params, result, progress)
+}
+
+// start returns the live connection, starting the plugin if needed.
+func (p *Provider) start(ctx context.Context) (*conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil && !p.conn.exited() {
+		return p.conn, nil
+	}
+
+	c, err := dial(p.executable)
+	if err != nil {
+		return nil, err
+	}
+
+	var hello HandshakeResult
+	if err := c.call(ctx, MethodHandshake, HandshakeParams{ProtocolVersion: ProtocolVersion}, &hello, nil); err != nil {
+		c.close()
+		return nil, fmt.Errorf("plugin handshake failed: %w", err)
+	}
+	if hello.ProtocolVersion != ProtocolVersion {
+		c.close()
+		return nil, fmt.Errorf("plugin %s speaks protocol %d, expected %d", p.executable, hello.ProtocolVersion, ProtocolVersion)
+	}
+
+	var init InitResult
+	if err := c.call(ctx, MethodInit, InitParams{Config: p.config}, &init, nil); err != nil {
+		c.close()
+		return nil, fmt.Errorf("plugin init failed: %w", err)
+	}
+	for _, m := range init.Methods {
+		c.methods[m] = true
+	}
+
+	p.conn = c
+	return c, nil
+}
+
+// pendingCall is a request awaiting its response.
+type pendingCall struct {
+	resp     chan *message
+	progress provider.ProgressFunc
+}
+
+// conn is one running plugin process.
+type conn struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	methods map[string]bool
+
+	wmu sync.Mutex // serializes writes to stdin
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*pendingCall
+	done    chan struct{} // closed when stdout reaches EOF
+	err     error         // why the connection ended
+}
+
+// dial starts the plugin process.
+func dial(executable string) (*conn, error) {
+	cmd := exec.Command(executable)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", executable, err)
+	}
+
+	c := &conn{
+		cmd:     cmd,
+		stdin:   stdin,
+		methods: make(map[string]bool),
+		pending: make(map[int64]*pendingCall),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(stdout)
+	return c, nil
+}
+
+// readLoop dispatches responses and progress notifications until EOF.
+func (c *conn) readLoop(stdout io.Reader) {
+	dec := json.NewDecoder(stdout)
+	var readErr error
+	for {
+		var msg message
+		if err := dec.Decode(&msg); err != nil {
+			readErr = err
+			break
+		}
+
+		switch {
+		case msg.ID != nil && msg.Method == "":
+			c.mu.Lock()
+			pc := c.pending[*msg.ID]
+			delete(c.pending, *msg.ID)
+			c.mu.Unlock()
+			// Responses to cancelled calls have no waiter
+			if pc != nil {
+				pc.resp <- &msg
+			}
+		case msg.Method == MethodProgress:
+			var params ProgressParams
+			if json.Unmarshal(msg.Params, &params) != nil {
+				continue
+			}
+			// Called under the lock so no callback runs after forget
+			c.mu.Lock()
+			if pc := c.pending[params.ID]; pc != nil && pc.progress != nil {
+				pc.progress(provider.Progress{
+					Bytes: params.Bytes,
+					Total: params.Total,
+					Speed: params.Speed,
+					ETA:   time.Duration(params.ETA * float64(time.Second)),
+				})
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	waitErr := c.cmd.Wait()
+	c.mu.Lock()
+	if errors.Is(readErr, io.EOF) {
+		c.err = fmt.Errorf("plugin exited")
+		if waitErr != nil {
+			c.err = fmt.Errorf("plugin exited: %w", waitErr)
+		}
+	} else {
+		c.err = fmt.Errorf("invalid plugin output: %w", readErr)
+	}
+	c.pending = make(map[int64]*pendingCall)
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// exited reports whether the process has gone away.
+func (c *conn) exited() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// call sends a request and waits for the response, the process exiting,
+// or ctx being cancelled.
+func (c *conn) call(ctx context.Context, method string, params, result interface{}, progress provider.ProgressFunc) error {
+	var raw json.RawMessage
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		raw = data
+	}
+
+	pc := &pendingCall{resp: make(chan *message, 1), progress: progress}
+	c.mu.Lock()
+	if c.exited() {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = pc
+	c.mu.Unlock()
+
+	if err := c.send(&message{JSONRPC: "2.0", ID: &id, Method: method, Params: raw}); err != nil {
+		c.forget(id)
+		return fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	select {
+	case msg := <-pc.resp:
+		return decodeResult(method, msg, result)
+	case <-c.done:
+		// The response may have arrived just before the plugin exited
+		select {
+		case msg := <-pc.resp:
+			return decodeResult(method, msg, result)
+		default:
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err
+	case <-ctx.Done():
+		c.forget(id)
+		cancel, _ := json.Marshal(CancelParams{ID: id})
+		c.send(&message{JSONRPC: "2.0", Method: MethodCancel, Params: cancel})
+		return ctx.Err()
+	}
+}
+
+// decodeResult unpacks a response into result.
+func decodeResult(method string, msg *message, result interface{}) error {
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result != nil && len(msg.Result) > 0 {
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// forget drops a pending call so a late response is discarded.
+func (c *conn) forget(id int64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// send writes one message as a line of JSON.
+func (c *conn) send(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// close ends the session by closing stdin, killing the plugin if it
+// does not exit within a few seconds.
+func (c *conn) close() error {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+		return nil
+	case <-time.After(5 * time.Second):
+		c.cmd.Process.Kill()
+		<-c.done
+		return fmt.Errorf("plugin %s did not exit; killed", c.cmd.Path)
+	}
+}
@@ -0,0 +1,269 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/providertest"
+)
+
+// referencePlugin is the cloudfs-provider-local binary built by TestMain.
+var referencePlugin string
+
+// TestMain builds the reference plugin. Run with CLOUDFS_FAKE_PLUGIN set,
+// the test binary itself acts as the fake plugin below.
+func TestMain(m *testing.M) {
+	if os.Getenv("CLOUDFS_FAKE_PLUGIN") != "" {
+		Main("fake", func(ctx context.Context, config map[string]string) (provider.Provider, error) {
+			if config["fail"] != "" {
+				return nil, errors.New(config["fail"])
+			}
+			return &fakeProvider{}, nil
+		})
+	}
+
+	dir, err := os.MkdirTemp("", "cloudfs-plugin")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	referencePlugin = filepath.Join(dir, ExecutablePrefix+"local")
+	build := exec.Command("go", "build", "-o", referencePlugin, "../../../cmd/cloudfs-provider-local")
+	if output, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to build reference plugin: %v\n%s", err, output)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// fakeProvider misbehaves on request: uploads block until cancelled and
+// deleting "crash" kills the process.
+type fakeProvider struct {
+	cancelled atomic.Int64
+}
+
+func (f *fakeProvider) ID() string          { return "fake" }
+func (f *fakeProvider) Type() string        { return "fake" }
+func (f *fakeProvider) DisplayName() string { return "fake" }
+func (f *fakeProvider) Init(ctx context.Context, config map[string]interface{}) error {
+	return nil
+}
+func (f *fakeProvider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{ConcurrentUploads: 1}, nil
+}
+func (f *fakeProvider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	return &provider.Usage{TotalBytes: 100, UsedBytes: 40, AvailableBytes: 60}, nil
+}
+func (f *fakeProvider) Upload(ctx context.Context, localPath, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	progress(provider.Progress{Bytes: 50, Total: 100, Speed: 10, ETA: 5 * time.Second})
+	<-ctx.Done()
+	f.cancelled.Add(1)
+	return nil, ctx.Err()
+}
+func (f *fakeProvider) Download(ctx context.Context, remotePath, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	return nil, fmt.Errorf("object %s is archived", remotePath)
+}
+func (f *fakeProvider) Delete(ctx context.Context, remotePath string) error {
+	if remotePath == "crash" {
+		os.Exit(3)
+	}
+	return nil
+}
+func (f *fakeProvider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	// Reports how many uploads saw their cancellation
+	return &provider.VerifyResult{IsValid: true, ContentHash: fmt.Sprint(f.cancelled.Load())}, nil
+}
+func (f *fakeProvider) CheckHealth(ctx context.Context) provider.HealthState {
+	return provider.HealthStateDegraded
+}
+
+func newFakeProvider(t *testing.T, config map[string]string) *Provider {
+	t.Helper()
+	t.Setenv("CLOUDFS_FAKE_PLUGIN", "1")
+	p := NewProvider("fake", "Fake", "fake", os.Args[0], config)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestReferencePlugin_Conformance(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	p := NewProvider("usb", "USB disk", "local", referencePlugin, map[string]string{
+		"remote":   "usb:",
+		"location": root,
+		"quota":    "1000000",
+	})
+	defer p.Close()
+
+	if err := p.Init(ctx, nil); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	caps, err := p.Capabilities(ctx)
+	if err != nil || caps.ConcurrentUploads != 4 {
+		t.Errorf("unexpected capabilities: %+v (%v)", caps, err)
+	}
+
+	content := bytes.Repeat([]byte("cloudfs "), 100000) // 800000 bytes, several progress steps
+	local := filepath.Join(t.TempDir(), "a.bin")
+	os.WriteFile(local, content, 0600)
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	var progress []float64
+	result, err := p.Upload(ctx, local, "usb:/docs/a.bin", func(pr provider.Progress) { progress = append(progress, pr.Fraction()) })
+	if err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+	if result.ContentHash != hash || result.Size != int64(len(content)) || result.RemotePath != "usb:/docs/a.bin" {
+		t.Errorf("unexpected upload result: %+v", result)
+	}
+	// Progress notifications arrive before the response
+	if len(progress) < 2 || progress[len(progress)-1] != 1.0 {
+		t.Errorf("expected streamed progress ending at 1.0, got %v", progress)
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i] < progress[i-1] {
+			t.Errorf("progress went backwards: %v", progress)
+			break
+		}
+	}
+
+	if v, err := p.Verify(ctx, "usb:/docs/a.bin"); err != nil || !v.IsValid || v.ContentHash != hash {
+		t.Errorf("unexpected verify result: %+v (%v)", v, err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out", "a.bin")
+	download, err := p.Download(ctx, "usb:/docs/a.bin", out, nil)
+	if err != nil || download.ContentHash != hash {
+		t.Fatalf("unexpected download: %+v (%v)", download, err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, content) {
+		t.Error("downloaded content differs")
+	}
+
+	var lister provider.Lister = p
+	objects, err := lister.List(ctx, "")
+	if err != nil || len(objects) != 1 || objects[0].Path != "usb:docs/a.bin" {
+		t.Errorf("unexpected listing: %+v (%v)", objects, err)
+	}
+	usage, err := p.GetUsage(ctx)
+	if err != nil || usage.UsedBytes != int64(len(content)) || usage.TotalBytes != 1000000 {
+		t.Errorf("unexpected usage: %+v (%v)", usage, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := p.Delete(ctx, "usb:/docs/a.bin"); err != nil {
+			t.Errorf("delete %d failed: %v", i, err)
+		}
+	}
+	if v, err := p.Verify(ctx, "usb:/docs/a.bin"); err != nil || v.IsValid {
+		t.Errorf("expected deleted object to be invalid: %+v (%v)", v, err)
+	}
+	if p.CheckHealth(ctx) != provider.HealthStateHealthy {
+		t.Error("expected healthy plugin")
+	}
+}
+
+func TestHost_Cancel(t *testing.T) {
+	p := newFakeProvider(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var progress []provider.Progress
+	start := time.Now()
+	_, err := p.Upload(ctx, "/dev/null", "fake:/a", func(pr provider.Progress) { progress = append(progress, pr) })
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Fatalf("expected deadline error, got %v after %s", err, time.Since(start))
+	}
+	want := provider.Progress{Bytes: 50, Total: 100, Speed: 10, ETA: 5 * time.Second}
+	if len(progress) != 1 || progress[0] != want {
+		t.Errorf("expected progress before cancellation, got %v", progress)
+	}
+
+	// The plugin stays up and sees the cancel notification
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		v, err := p.Verify(context.Background(), "fake:/a")
+		if err != nil {
+			t.Fatalf("plugin unusable after cancellation: %v", err)
+		}
+		if v.ContentHash == "1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("plugin never observed the cancellation")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHost_Restart(t *testing.T) {
+	p := newFakeProvider(t, nil)
+	ctx := context.Background()
+
+	if err := p.Delete(ctx, "crash"); err == nil {
+		t.Fatal("expected error when the plugin dies mid-call")
+	}
+	// The next call starts a fresh process
+	usage, err := p.GetUsage(ctx)
+	if err != nil || usage.AvailableBytes != 60 {
+		t.Errorf("expected restarted plugin, got %+v (%v)", usage, err)
+	}
+}
+
+func TestHost_Errors(t *testing.T) {
+	ctx := context.Background()
+	p := newFakeProvider(t, nil)
+
+	_, err := p.Download(ctx, "fake:/cold", filepath.Join(t.TempDir(), "x"), nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeProvider || rpcErr.Message != "object fake:/cold is archived" {
+		t.Errorf("expected provider error, got %v", err)
+	}
+	// The fake does not implement list
+	if _, err := p.List(ctx, ""); !errors.As(err, &rpcErr) || rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("expected method not found, got %v", err)
+	}
+	if p.CheckHealth(ctx) != provider.HealthStateDegraded {
+		t.Error("expected health state passed through")
+	}
+
+	failing := newFakeProvider(t, map[string]string{"fail": "bad credentials"})
+	if err := failing.Init(ctx, nil); err == nil || !errors.As(err, &rpcErr) || rpcErr.Message != "bad credentials" {
+		t.Errorf("expected init failure, got %v", err)
+	}
+	if failing.CheckHealth(ctx) != provider.HealthStateUnavailable {
+		t.Error("expected plugin failing init to be unavailable")
+	}
+
+	if _, err := Find("does-not-exist"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	t.Setenv("PATH", filepath.Dir(referencePlugin))
+	if path, err := Find("local"); err != nil || path != referencePlugin {
+		t.Errorf("expected reference plugin on PATH, got %s (%v)", path, err)
+	}
+}
+
+func TestReferencePlugin_ProviderConformance(t *testing.T) {
+	p := NewProvider("usb", "USB disk", "local", referencePlugin, map[string]string{
+		"remote":   "usb:",
+		"location": t.TempDir(),
+	})
+	defer p.Close()
+	providertest.Run(t, p, providertest.Options{Prefix: "usb:"})
+}
@@ -0,0 +1,141 @@
+// Package plugin runs storage providers as separate executables.
+// Based on design.txt Section 8: Providers are PLUGINS.
+//
+// A plugin is any executable named cloudfs-provider-<type> on PATH. The host
+// talks JSON-RPC 2.0 to it over stdin/stdout, one JSON object per line; the
+// plugin's stderr is passed through. docs/provider_plugins.md documents the
+// protocol for plugins not written in Go.
+//
+// INVARIANTS:
+// - Methods mirror provider.Provider; results reuse its JSON types
+// - Progress is streamed as notifications tagged with the request id
+// - A cancelled context sends a cancel notification; the call returns at once
+// - A crashed plugin fails in-flight calls and is restarted on the next call
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// ProtocolVersion is the protocol revision spoken by this host.
+// Plugins must answer the handshake with the same version.
+const ProtocolVersion = 1
+
+// ExecutablePrefix is prepended to the provider type to find a plugin on PATH.
+const ExecutablePrefix = "cloudfs-provider-"
+
+// Method names. Optional methods are advertised in the init result.
+const (
+	MethodHandshake    = "handshake"
+	MethodInit         = "init"
+	MethodCapabilities = "capabilities"
+	MethodGetUsage     = "get_usage"
+	MethodUpload       = "upload"
+	MethodDownload     = "download"
+	MethodDelete       = "delete"
+	MethodVerify       = "verify"
+	MethodCheckHealth  = "check_health"
+	MethodList         = "list" // Optional
+
+	// Notifications (no id, no response)
+	MethodProgress = "progress" // plugin → host
+	MethodCancel   = "cancel"   // host → plugin
+)
+
+// Error codes. The JSON-RPC reserved range is used for protocol errors;
+// CodeProvider carries any error returned by the provider itself.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeProvider       = -32000
+	CodeNotInitialized = -32002
+	CodeCancelled      = -32800
+)
+
+// Error is a JSON-RPC error object. Calls through the host adapter
+// return it for errors reported by the plugin.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", e.Code, e.Message)
+}
+
+// message is any JSON-RPC 2.0 request, response or notification.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// HandshakeParams is sent first, before init.
+type HandshakeParams struct {
+	ProtocolVersion int `json:"protocol_version"`
+}
+
+// HandshakeResult identifies the plugin.
+type HandshakeResult struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	Type            string `json:"type"`
+}
+
+// InitParams carries the provider's stored provider_config values.
+// "remote" is the placement prefix ("<name>:"); "location" is the
+// <remote> argument given to `cloudfs provider add`.
+type InitParams struct {
+	Config map[string]string `json:"config"`
+}
+
+// InitResult lists the optional methods the plugin implements.
+type InitResult struct {
+	Methods []string `json:"methods,omitempty"`
+}
+
+// TransferParams is used by upload and download. With Progress set the
+// plugin sends progress notifications for this request.
+type TransferParams struct {
+	LocalPath  string `json:"local_path"`
+	RemotePath string `json:"remote_path"`
+	Progress   bool   `json:"progress,omitempty"`
+	BWLimit    string `json:"bwlimit,omitempty"` // Bandwidth timetable, see bwlimit.Schedule
+}
+
+// PathParams is used by delete and verify.
+type PathParams struct {
+	RemotePath string `json:"remote_path"`
+}
+
+// ListParams is used by list.
+type ListParams struct {
+	Prefix string `json:"prefix"`
+}
+
+// HealthResult is the check_health result.
+type HealthResult struct {
+	State provider.HealthState `json:"state"`
+}
+
+// ProgressParams reports transfer progress of request ID. Total, Speed
+// (bytes per second) and ETA (seconds) are omitted when unknown.
+type ProgressParams struct {
+	ID    int64   `json:"id"`
+	Bytes int64   `json:"bytes"`
+	Total int64   `json:"total,omitempty"`
+	Speed float64 `json:"speed,omitempty"`
+	ETA   float64 `json:"eta,omitempty"`
+}
+
+// CancelParams asks the plugin to abandon request ID.
+type CancelParams struct {
+	ID int64 `json:"id"`
+}
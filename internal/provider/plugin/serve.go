@@ -0,0 +1,260 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+)
+
+// Factory builds the plugin's provider from the init config.
+type Factory func(ctx context.Context, config map[string]string) (provider.Provider, error)
+
+// Main serves the protocol on stdin/stdout and exits. Go plugins call it
+// from main.
+func Main(provType string, factory Factory) {
+	if err := Serve(context.Background(), provType, factory, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s: %v\n", ExecutablePrefix, provType, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Serve answers requests from r on w until r reaches EOF. Requests run
+// concurrently; a cancel notification cancels the request's context.
+func Serve(ctx context.Context, provType string, factory Factory, r io.Reader, w io.Writer) error {
+	s := &server{
+		provType: provType,
+		factory:  factory,
+		w:        w,
+		cancels:  make(map[int64]context.CancelFunc),
+	}
+	ctx, cancelAll := context.WithCancel(ctx)
+	defer cancelAll()
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg message
+		if err := dec.Decode(&msg); err != nil {
+			cancelAll()
+			s.wg.Wait()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			s.reply(nil, nil, &Error{Code: CodeParseError, Message: err.Error()})
+			return err
+		}
+		s.dispatch(ctx, &msg)
+	}
+}
+
+// server is the plugin side of one session.
+type server struct {
+	provType string
+	factory  Factory
+
+	wmu sync.Mutex
+	w   io.Writer
+
+	mu      sync.Mutex
+	prov    provider.Provider
+	cancels map[int64]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// dispatch handles one incoming message. handshake and init run inline
+// so later requests see their effect; everything else runs concurrently.
+func (s *server) dispatch(ctx context.Context, msg *message) {
+	if msg.ID == nil {
+		if msg.Method == MethodCancel {
+			var params CancelParams
+			if json.Unmarshal(msg.Params, &params) == nil {
+				s.mu.Lock()
+				if cancel := s.cancels[params.ID]; cancel != nil {
+					cancel()
+				}
+				s.mu.Unlock()
+			}
+		}
+		return
+	}
+	id := *msg.ID
+
+	switch msg.Method {
+	case MethodHandshake:
+		s.reply(&id, HandshakeResult{ProtocolVersion: ProtocolVersion, Type: s.provType}, nil)
+		return
+	case MethodInit:
+		result, err := s.init(ctx, msg.Params)
+		s.reply(&id, result, err)
+		return
+	}
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancels[id] = cancel
+	prov := s.prov
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.cancels, id)
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		if prov == nil {
+			s.reply(&id, nil, &Error{Code: CodeNotInitialized, Message: "init has not completed"})
+			return
+		}
+		result, err := s.handle(reqCtx, id, prov, msg)
+		if err == nil && reqCtx.Err() != nil {
+			err = &Error{Code: CodeCancelled, Message: "request cancelled"}
+		}
+		s.reply(&id, result, err)
+	}()
+}
+
+// init builds the provider from the supplied config.
+func (s *server) init(ctx context.Context, raw json.RawMessage) (interface{}, *Error) {
+	var params InitParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	prov, err := s.factory(ctx, params.Config)
+	if err != nil {
+		return nil, &Error{Code: CodeProvider, Message: err.Error()}
+	}
+	s.mu.Lock()
+	s.prov = prov
+	s.mu.Unlock()
+
+	var result InitResult
+	if _, ok := prov.(provider.Lister); ok {
+		result.Methods = append(result.Methods, MethodList)
+	}
+	return result, nil
+}
+
+// handle calls the provider method named by msg.
+func (s *server) handle(ctx context.Context, id int64, prov provider.Provider, msg *message) (interface{}, *Error) {
+	var (
+		result interface{}
+		err    error
+	)
+	switch msg.Method {
+	case MethodCapabilities:
+		result, err = prov.Capabilities(ctx)
+	case MethodGetUsage:
+		result, err = prov.GetUsage(ctx)
+	case MethodCheckHealth:
+		result = HealthResult{State: prov.CheckHealth(ctx)}
+
+	case MethodUpload, MethodDownload:
+		var params TransferParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		var progress provider.ProgressFunc
+		if params.Progress {
+			progress = s.progressFunc(id)
+		}
+		if params.BWLimit != "" {
+			schedule, err := bwlimit.ParseSchedule(params.BWLimit)
+			if err != nil {
+				return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+			}
+			ctx = bwlimit.WithLimiter(ctx, bwlimit.NewLimiter(schedule))
+		}
+		if msg.Method == MethodUpload {
+			result, err = prov.Upload(ctx, params.LocalPath, params.RemotePath, progress)
+		} else {
+			result, err = prov.Download(ctx, params.RemotePath, params.LocalPath, progress)
+		}
+
+	case MethodDelete, MethodVerify:
+		var params PathParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		if msg.Method == MethodDelete {
+			err = prov.Delete(ctx, params.RemotePath)
+		} else {
+			result, err = prov.Verify(ctx, params.RemotePath)
+		}
+
+	case MethodList:
+		lister, ok := prov.(provider.Lister)
+		if !ok {
+			return nil, &Error{Code: CodeMethodNotFound, Message: "list not supported"}
+		}
+		var params ListParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		objects, listErr := lister.List(ctx, params.Prefix)
+		if objects == nil {
+			objects = []provider.RemoteObject{}
+		}
+		result, err = objects, listErr
+
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: "unknown method " + msg.Method}
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, &Error{Code: CodeCancelled, Message: err.Error()}
+		}
+		return nil, &Error{Code: CodeProvider, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// progressFunc returns a callback that sends progress notifications for id.
+func (s *server) progressFunc(id int64) provider.ProgressFunc {
+	return func(progress provider.Progress) {
+		params, _ := json.Marshal(ProgressParams{
+			ID:    id,
+			Bytes: progress.Bytes,
+			Total: progress.Total,
+			Speed: progress.Speed,
+			ETA:   progress.ETA.Seconds(),
+		})
+		s.send(&message{JSONRPC: "2.0", Method: MethodProgress, Params: params})
+	}
+}
+
+// reply sends the response for id.
+func (s *server) reply(id *int64, result interface{}, rpcErr *Error) {
+	msg := &message{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = &Error{Code: CodeProvider, Message: err.Error()}
+		} else {
+			msg.Result = data
+		}
+	}
+	s.send(msg)
+}
+
+// send writes one message as a line of JSON.
+func (s *server) send(msg *message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.w.Write(append(data, '\n'))
+}
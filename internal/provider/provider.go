@@ -5,6 +5,7 @@ package provider
 
 import (
 	"context"
+	"io"
 	"time"
 )
 
@@ -61,8 +62,28 @@ const (
 	HealthStateUnavailable HealthState = "unavailable"
 )
 
-// ProgressFunc callback for upload/download progress (0.0 to 1.0).
-type ProgressFunc func(progress float64)
+// Progress describes a transfer in flight.
+// Total, Speed and ETA are zero when the provider does not know them.
+type Progress struct {
+	Bytes int64
+	Total int64
+	Speed float64 // bytes per second
+	ETA   time.Duration
+}
+
+// Fraction returns the share of Total transferred (0.0 to 1.0).
+func (p Progress) Fraction() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	if p.Bytes >= p.Total {
+		return 1
+	}
+	return float64(p.Bytes) / float64(p.Total)
+}
+
+// ProgressFunc callback for upload/download progress.
+type ProgressFunc func(progress Progress)
 
 // Provider interface - all storage providers must implement this.
 // Based on design.txt Section 8.
@@ -104,6 +125,79 @@ type Provider interface {
 	CheckHealth(ctx context.Context) HealthState
 }
 
+// RangeDownloader is an optional extension for providers that can fetch
+// a byte range of a remote object without downloading the whole file.
+// Callers detect support with AsRangeDownloader.
+type RangeDownloader interface {
+	// DownloadRange writes length bytes starting at offset to w.
+	// A negative length reads to the end of the object.
+	// Returns the number of bytes written.
+	DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error)
+}
+
+// RemoteObject describes an object stored on a provider.
+type RemoteObject struct {
+	Path    string    `json:"path"` // Same form as placements.remote_path
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Lister is an optional extension for providers that can enumerate
+// stored objects. Used by garbage collection to find orphans.
+// Callers detect support with AsLister.
+type Lister interface {
+	// List returns every object below prefix, recursively.
+	// An empty prefix lists the provider's CloudFS root.
+	List(ctx context.Context, prefix string) ([]RemoteObject, error)
+}
+
+// Wrapper is implemented by decorators such as retries and bandwidth
+// limits. They define every optional method, so a type assertion on them
+// alone does not tell whether the wrapped provider supports it.
+type Wrapper interface {
+	Inner() Provider
+}
+
+// AsRangeDownloader returns p as a RangeDownloader if p and every
+// provider it wraps support ranged downloads.
+func AsRangeDownloader(p Provider) (RangeDownloader, bool) {
+	ranged, ok := p.(RangeDownloader)
+	if !ok || !supportedByInner(p, func(inner Provider) bool {
+		_, ok := inner.(RangeDownloader)
+		return ok
+	}) {
+		return nil, false
+	}
+	return ranged, true
+}
+
+// AsLister returns p as a Lister if p and every provider it wraps
+// support listing.
+func AsLister(p Provider) (Lister, bool) {
+	lister, ok := p.(Lister)
+	if !ok || !supportedByInner(p, func(inner Provider) bool {
+		_, ok := inner.(Lister)
+		return ok
+	}) {
+		return nil, false
+	}
+	return lister, true
+}
+
+// supportedByInner checks supports against each provider wrapped by p.
+func supportedByInner(p Provider, supports func(Provider) bool) bool {
+	for {
+		w, ok := p.(Wrapper)
+		if !ok {
+			return true
+		}
+		p = w.Inner()
+		if !supports(p) {
+			return false
+		}
+	}
+}
+
 // Registry manages provider instances.
 type Registry interface {
 	// Register adds a new provider.
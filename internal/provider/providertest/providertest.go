@@ -0,0 +1,422 @@
+// Package providertest is a conformance suite for provider.Provider
+// implementations. A provider's own tests call Run against a scratch
+// instance (fake server, temp directory, test bucket):
+//
+//	func TestConformance(t *testing.T) {
+//		providertest.Run(t, newTestProvider(t), providertest.Options{Prefix: "test:"})
+//	}
+//
+// Every case writes below <Prefix>/conformance/ and deletes what it wrote.
+package providertest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// Options configures a conformance run.
+type Options struct {
+	// Prefix is prepended to remote paths, e.g. "test:" for providers whose
+	// placements look like "test:/dir/file". Empty for none.
+	Prefix string
+
+	// LargeSize is the size of the large-file case. Default 9 MiB, which
+	// crosses the default multipart and chunk sizes.
+	LargeSize int64
+
+	// MaxConcurrency caps the concurrent case. Default 8.
+	MaxConcurrency int
+
+	// Seed makes generated content reproducible. Default 1.
+	Seed int64
+}
+
+// Run executes every conformance case as a subtest of t.
+func Run(t *testing.T, p provider.Provider, opts Options) {
+	if opts.LargeSize <= 0 {
+		opts.LargeSize = 9 << 20
+	}
+	if opts.MaxConcurrency <= 0 {
+		opts.MaxConcurrency = 8
+	}
+	if opts.Seed == 0 {
+		opts.Seed = 1
+	}
+	s := &suite{p: p, opts: opts, rng: rand.New(rand.NewSource(opts.Seed))}
+
+	t.Run("Roundtrip", s.testRoundtrip)
+	t.Run("ZeroByte", s.testZeroByte)
+	t.Run("LargeFile", s.testLargeFile)
+	t.Run("Paths", s.testPaths)
+	t.Run("Overwrite", s.testOverwrite)
+	t.Run("DeleteIdempotent", s.testDeleteIdempotent)
+	t.Run("VerifyMissing", s.testVerifyMissing)
+	t.Run("Progress", s.testProgress)
+	t.Run("Cancel", s.testCancel)
+	t.Run("Concurrent", s.testConcurrent)
+	if _, ok := provider.AsLister(p); ok {
+		t.Run("List", s.testList)
+	}
+}
+
+// suite carries state shared by the cases.
+type suite struct {
+	p    provider.Provider
+	opts Options
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// remote returns the remote path for rel below the conformance directory.
+func (s *suite) remote(rel string) string {
+	return s.opts.Prefix + "/conformance/" + rel
+}
+
+// content returns n reproducible pseudo-random bytes.
+func (s *suite) content(n int64) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := make([]byte, n)
+	s.rng.Read(b)
+	return b
+}
+
+// writeLocal writes data to a temp file and returns its path.
+func writeLocal(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "src.bin")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write local file: %v", err)
+	}
+	return path
+}
+
+// hashOf returns the hex SHA-256 of data.
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// upload uploads data to remotePath, checks the result and schedules deletion.
+func (s *suite) upload(t *testing.T, data []byte, remotePath string) {
+	t.Helper()
+	result, err := s.p.Upload(context.Background(), writeLocal(t, data), remotePath, nil)
+	if err != nil {
+		t.Fatalf("upload %s failed: %v", remotePath, err)
+	}
+	t.Cleanup(func() { s.p.Delete(context.Background(), remotePath) })
+	if result.Size != int64(len(data)) {
+		t.Errorf("upload %s: size %d, want %d", remotePath, result.Size, len(data))
+	}
+	// Providers without remote hashing leave ContentHash empty
+	if result.ContentHash != "" && result.ContentHash != hashOf(data) {
+		t.Errorf("upload %s: hash %s, want %s", remotePath, result.ContentHash, hashOf(data))
+	}
+}
+
+// expectContent downloads remotePath and compares it with want.
+func (s *suite) expectContent(t *testing.T, remotePath string, want []byte) {
+	t.Helper()
+	local := filepath.Join(t.TempDir(), "nested", "dst.bin")
+	result, err := s.p.Download(context.Background(), remotePath, local, nil)
+	if err != nil {
+		t.Fatalf("download %s failed: %v", remotePath, err)
+	}
+	got, err := os.ReadFile(local)
+	if err != nil {
+		t.Fatalf("downloaded file missing: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("download %s: got %d bytes (%s), want %d bytes (%s)", remotePath, len(got), hashOf(got), len(want), hashOf(want))
+	}
+	if result.Size != int64(len(want)) || result.ContentHash != hashOf(want) {
+		t.Errorf("download %s: result %+v, want size %d hash %s", remotePath, result, len(want), hashOf(want))
+	}
+}
+
+// expectValid checks Verify reports remotePath present with want's hash, if any.
+func (s *suite) expectValid(t *testing.T, remotePath string, want []byte) {
+	t.Helper()
+	v, err := s.p.Verify(context.Background(), remotePath)
+	if err != nil {
+		t.Fatalf("verify %s failed: %v", remotePath, err)
+	}
+	if !v.IsValid {
+		t.Errorf("verify %s: not valid (%s)", remotePath, v.ErrorMessage)
+	}
+	if v.ContentHash != "" && v.ContentHash != hashOf(want) {
+		t.Errorf("verify %s: hash %s, want %s", remotePath, v.ContentHash, hashOf(want))
+	}
+}
+
+// expectMissing checks Verify reports remotePath absent without an error.
+func (s *suite) expectMissing(t *testing.T, remotePath string) {
+	t.Helper()
+	v, err := s.p.Verify(context.Background(), remotePath)
+	if err != nil {
+		t.Fatalf("verify %s on a missing object returned an error: %v", remotePath, err)
+	}
+	if v.IsValid {
+		t.Errorf("verify %s: missing object reported valid", remotePath)
+	}
+}
+
+func (s *suite) testRoundtrip(t *testing.T) {
+	data := s.content(64 << 10)
+	remote := s.remote("roundtrip/file.bin")
+	s.upload(t, data, remote)
+	s.expectValid(t, remote, data)
+	s.expectContent(t, remote, data)
+}
+
+func (s *suite) testZeroByte(t *testing.T) {
+	remote := s.remote("zero/empty.bin")
+	s.upload(t, nil, remote)
+	s.expectValid(t, remote, nil)
+	s.expectContent(t, remote, nil)
+}
+
+func (s *suite) testLargeFile(t *testing.T) {
+	data := s.content(s.opts.LargeSize)
+	remote := s.remote("large/file.bin")
+	s.upload(t, data, remote)
+	s.expectContent(t, remote, data)
+}
+
+func (s *suite) testPaths(t *testing.T) {
+	for _, rel := range []string{
+		"paths/ünïcødé/文件 名.txt",
+		"paths/emoji 🚀/naïve café.md",
+		"paths/a/b/c/d/e/f/g/h/i/j/deep.txt",
+		"paths/spaces and (parens) & 'quotes'/x+y=z.txt",
+	} {
+		data := []byte("content of " + rel)
+		remote := s.remote(rel)
+		s.upload(t, data, remote)
+		s.expectContent(t, remote, data)
+	}
+}
+
+func (s *suite) testOverwrite(t *testing.T) {
+	remote := s.remote("overwrite/file.txt")
+	first := bytes.Repeat([]byte("first version "), 1000)
+	second := []byte("second, shorter version")
+
+	s.upload(t, first, remote)
+	s.upload(t, second, remote)
+	s.expectValid(t, remote, second)
+	s.expectContent(t, remote, second)
+}
+
+func (s *suite) testDeleteIdempotent(t *testing.T) {
+	ctx := context.Background()
+	remote := s.remote("delete/file.txt")
+	s.upload(t, []byte("to be deleted"), remote)
+
+	for i := 1; i <= 2; i++ {
+		if err := s.p.Delete(ctx, remote); err != nil {
+			t.Fatalf("delete #%d failed: %v", i, err)
+		}
+	}
+	s.expectMissing(t, remote)
+}
+
+func (s *suite) testVerifyMissing(t *testing.T) {
+	s.expectMissing(t, s.remote("missing/never-uploaded.txt"))
+	s.expectMissing(t, s.remote("missing-dir/never/uploaded.txt"))
+}
+
+func (s *suite) testProgress(t *testing.T) {
+	ctx := context.Background()
+	data := s.content(s.opts.LargeSize)
+	remote := s.remote("progress/file.bin")
+
+	var mu sync.Mutex
+	var values []float64
+	record := func(p provider.Progress) {
+		mu.Lock()
+		values = append(values, p.Fraction())
+		mu.Unlock()
+	}
+	check := func(op string) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Providers may not report progress at all
+		for i, v := range values {
+			if v < 0 || v > 1 {
+				t.Errorf("%s progress %v out of range: %v", op, v, values)
+				return
+			}
+			if i > 0 && v < values[i-1] {
+				t.Errorf("%s progress went backwards: %v", op, values)
+				return
+			}
+		}
+		if len(values) > 0 && values[len(values)-1] != 1.0 {
+			t.Errorf("%s progress ended at %v, want 1.0", op, values[len(values)-1])
+		}
+		values = nil
+	}
+
+	if _, err := s.p.Upload(ctx, writeLocal(t, data), remote, record); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	t.Cleanup(func() { s.p.Delete(context.Background(), remote) })
+	check("upload")
+
+	if _, err := s.p.Download(ctx, remote, filepath.Join(t.TempDir(), "dst.bin"), record); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	check("download")
+}
+
+func (s *suite) testCancel(t *testing.T) {
+	data := s.content(s.opts.LargeSize)
+	remote := s.remote("cancel/file.bin")
+	local := writeLocal(t, data)
+	t.Cleanup(func() { s.p.Delete(context.Background(), remote) })
+
+	// Cancel on the first mid-transfer progress report; providers without
+	// one (or that finish first) see a context that is already cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	var once sync.Once
+	var reported bool
+	_, err := s.p.Upload(ctx, local, remote, func(pr provider.Progress) {
+		if pr.Total > 0 && pr.Bytes >= pr.Total {
+			return
+		}
+		once.Do(func() {
+			reported = true
+			cancel()
+		})
+	})
+	if !reported {
+		cancel()
+		_, err = s.p.Upload(ctx, local, remote, nil)
+	}
+	cancel()
+	if err == nil {
+		t.Fatal("upload with a cancelled context succeeded")
+	}
+
+	// A cancelled upload must not leave a partial object behind
+	if v, err := s.p.Verify(context.Background(), remote); err == nil && v.IsValid {
+		if v.ContentHash != "" && v.ContentHash != hashOf(data) {
+			t.Errorf("cancelled upload left a partial object (hash %s)", v.ContentHash)
+		}
+		if lister, ok := provider.AsLister(s.p); ok {
+			objects, _ := lister.List(context.Background(), s.opts.Prefix+"/conformance/cancel")
+			for _, obj := range objects {
+				if strings.HasSuffix(obj.Path, "cancel/file.bin") && obj.Size != int64(len(data)) {
+					t.Errorf("cancelled upload left a partial object (%d of %d bytes)", obj.Size, len(data))
+				}
+			}
+		}
+	}
+
+	// The same path can be uploaded again afterwards
+	s.upload(t, data, remote)
+	s.expectContent(t, remote, data)
+}
+
+func (s *suite) testConcurrent(t *testing.T) {
+	caps, err := s.p.Capabilities(context.Background())
+	if err != nil {
+		t.Fatalf("capabilities failed: %v", err)
+	}
+	n := caps.ConcurrentUploads
+	if n < 1 {
+		n = 1
+	}
+	if n > s.opts.MaxConcurrency {
+		n = s.opts.MaxConcurrency
+	}
+
+	files := make([][]byte, n)
+	locals := make([]string, n)
+	remotes := make([]string, n)
+	for i := range files {
+		files[i] = s.content(int64(256<<10 + i))
+		locals[i] = writeLocal(t, files[i])
+		remotes[i] = s.remote(fmt.Sprintf("concurrent/file-%d.bin", i))
+		remote := remotes[i]
+		t.Cleanup(func() { s.p.Delete(context.Background(), remote) })
+	}
+
+	run := func(op string, fn func(i int) error) {
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = fn(i)
+			}(i)
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				t.Errorf("concurrent %s #%d failed: %v", op, i, err)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	run("upload", func(i int) error {
+		_, err := s.p.Upload(ctx, locals[i], remotes[i], nil)
+		return err
+	})
+	dir := t.TempDir()
+	run("download", func(i int) error {
+		local := filepath.Join(dir, fmt.Sprintf("dst-%d.bin", i))
+		result, err := s.p.Download(ctx, remotes[i], local, nil)
+		if err != nil {
+			return err
+		}
+		if result.ContentHash != hashOf(files[i]) {
+			return fmt.Errorf("hash %s, want %s", result.ContentHash, hashOf(files[i]))
+		}
+		return nil
+	})
+}
+
+func (s *suite) testList(t *testing.T) {
+	lister, _ := provider.AsLister(s.p)
+	want := map[string]int{"list/a.txt": 1, "list/sub/b.txt": 22, "list/sub/deeper/c.txt": 333}
+	for rel, size := range want {
+		s.upload(t, bytes.Repeat([]byte("x"), size), s.remote(rel))
+	}
+
+	objects, err := lister.List(context.Background(), s.opts.Prefix+"/conformance/list")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	found := make(map[string]int64)
+	for _, obj := range objects {
+		for rel := range want {
+			if strings.HasSuffix(obj.Path, "conformance/"+rel) {
+				found[rel] = obj.Size
+			}
+		}
+	}
+	for rel, size := range want {
+		if found[rel] != int64(size) {
+			t.Errorf("list: %s has size %d, want %d (listing %+v)", rel, found[rel], size, objects)
+		}
+	}
+	if len(objects) != len(want) {
+		t.Errorf("list returned %d objects, want %d: %+v", len(objects), len(want), objects)
+	}
+}
@@ -0,0 +1,120 @@
+package providertest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// memProvider is the smallest provider that passes the suite.
+type memProvider struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *memProvider) ID() string          { return "mem" }
+func (m *memProvider) Type() string        { return "mem" }
+func (m *memProvider) DisplayName() string { return "Memory" }
+func (m *memProvider) Init(ctx context.Context, config map[string]interface{}) error {
+	return nil
+}
+func (m *memProvider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{ConcurrentUploads: 4}, nil
+}
+func (m *memProvider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	return &provider.Usage{}, nil
+}
+func (m *memProvider) CheckHealth(ctx context.Context) provider.HealthState {
+	return provider.HealthStateHealthy
+}
+
+func (m *memProvider) Upload(ctx context.Context, localPath, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, err
+	}
+	// Report progress in steps so cancellation can land mid-transfer
+	for i := 1; i <= 4; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(provider.Progress{Bytes: int64(len(data)) * int64(i) / 4, Total: int64(len(data))})
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.objects[remotePath] = data
+	m.mu.Unlock()
+	sum := sha256.Sum256(data)
+	return &provider.UploadResult{RemotePath: remotePath, ContentHash: hex.EncodeToString(sum[:]), UploadedAt: time.Now(), Size: int64(len(data))}, nil
+}
+
+func (m *memProvider) Download(ctx context.Context, remotePath, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	m.mu.Lock()
+	data, ok := m.objects[remotePath]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%s not found", remotePath)
+	}
+	os.MkdirAll(filepath.Dir(localPath), 0700)
+	if err := os.WriteFile(localPath, data, 0600); err != nil {
+		return nil, err
+	}
+	if progress != nil {
+		progress(provider.Progress{Bytes: int64(len(data)), Total: int64(len(data))})
+	}
+	sum := sha256.Sum256(data)
+	return &provider.DownloadResult{LocalPath: localPath, ContentHash: hex.EncodeToString(sum[:]), DownloadedAt: time.Now(), Size: int64(len(data))}, nil
+}
+
+func (m *memProvider) Delete(ctx context.Context, remotePath string) error {
+	m.mu.Lock()
+	delete(m.objects, remotePath)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memProvider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	m.mu.Lock()
+	data, ok := m.objects[remotePath]
+	m.mu.Unlock()
+	if !ok {
+		return &provider.VerifyResult{IsValid: false, ErrorMessage: "not found"}, nil
+	}
+	sum := sha256.Sum256(data)
+	return &provider.VerifyResult{IsValid: true, ContentHash: hex.EncodeToString(sum[:])}, nil
+}
+
+func (m *memProvider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var objects []provider.RemoteObject
+	for path, data := range m.objects {
+		if strings.HasPrefix(path, prefix) {
+			objects = append(objects, provider.RemoteObject{Path: path, Size: int64(len(data))})
+		}
+	}
+	return objects, nil
+}
+
+func TestRun_MemoryProvider(t *testing.T) {
+	p := &memProvider{objects: make(map[string][]byte)}
+	Run(t, p, Options{Prefix: "mem:", LargeSize: 1 << 20})
+
+	// Every case cleans up after itself
+	if len(p.objects) != 0 {
+		t.Errorf("suite left %d objects behind", len(p.objects))
+	}
+}
@@ -0,0 +1,279 @@
+package rclone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// daemonStartTimeout bounds how long rclone rcd may take to listen.
+	daemonStartTimeout = 10 * time.Second
+	// daemonStopTimeout bounds how long Close waits after core/quit.
+	daemonStopTimeout = 5 * time.Second
+)
+
+// Daemon is a private rclone rcd serving the rc API on a unix socket.
+// It starts on first use, restarts if rclone exits, and is stopped by
+// Close. Providers share it with UseDaemon instead of running one rclone
+// process per call. Its methods are safe for concurrent use.
+type Daemon struct {
+	configPath string
+	client     *http.Client
+	sock       atomic.Value // string; read by the dialer without mu
+
+	mu     sync.Mutex
+	dir    string // Holds the socket; removed by Close
+	cmd    *exec.Cmd
+	exited chan struct{}
+	rate   string // Last rate passed to core/bwlimit
+}
+
+// NewDaemon creates a daemon using the given rclone config file ("" for
+// rclone's default). No process is started until the daemon is used.
+func NewDaemon(configPath string) *Daemon {
+	d := &Daemon{configPath: configPath}
+	d.client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			sock, _ := d.sock.Load().(string)
+			return dialer.DialContext(ctx, "unix", sock)
+		},
+	}}
+	return d
+}
+
+// Error is an error returned by the rc API.
+type Error struct {
+	Method  string
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rc %s: %s", e.Method, e.Message)
+}
+
+// HTTPStatus returns the status rclone answered with.
+func (e *Error) HTTPStatus() int {
+	return e.Status
+}
+
+// start launches rclone rcd unless it is already running and waits until
+// it answers.
+func (d *Daemon) start(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cmd != nil {
+		select {
+		case <-d.exited:
+			// rclone died; start a new one below
+		default:
+			return nil
+		}
+	}
+	if d.dir == "" {
+		// Socket paths are limited to ~100 bytes, so keep this short
+		dir, err := os.MkdirTemp("", "cloudfs-rcd-")
+		if err != nil {
+			return fmt.Errorf("failed to create rcd socket directory: %w", err)
+		}
+		d.dir = dir
+	}
+	sock := filepath.Join(d.dir, "rc.sock")
+	os.Remove(sock)
+	d.sock.Store(sock)
+
+	args := []string{"rcd", "--rc-addr", "unix://" + sock, "--rc-no-auth", "--rc-serve"}
+	if d.configPath != "" {
+		args = append(args, "--config", d.configPath)
+	}
+	// Not tied to ctx: the daemon outlives the call that started it
+	cmd := exec.Command("rclone", args...)
+	cmd.SysProcAttr = daemonSysProcAttr()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start rclone rcd: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	d.cmd, d.exited, d.rate = cmd, exited, ""
+
+	// Other callers wait on mu until rclone is listening
+	deadline := time.Now().Add(daemonStartTimeout)
+	for d.post(ctx, "rc/noop", nil, nil) != nil {
+		select {
+		case <-exited:
+			return fmt.Errorf("rclone rcd exited during startup")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("rclone rcd did not start within %s", daemonStartTimeout)
+		}
+	}
+	return nil
+}
+
+// call invokes an rc method, starting the daemon if needed. in is encoded
+// as the JSON request body and the response is decoded into out.
+func (d *Daemon) call(ctx context.Context, method string, in, out interface{}) error {
+	if err := d.start(ctx); err != nil {
+		return err
+	}
+	return d.post(ctx, method, in, out)
+}
+
+func (d *Daemon) post(ctx context.Context, method string, in, out interface{}) error {
+	if in == nil {
+		in = struct{}{}
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://rcd/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(method, resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("rc %s: failed to parse response: %w", method, err)
+	}
+	return nil
+}
+
+// responseError converts a failed rc response, which carries the message
+// in its JSON body.
+func responseError(method string, resp *http.Response) error {
+	var failure struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(data, &failure) != nil || failure.Error == "" {
+		failure.Error = strings.TrimSpace(string(data))
+	}
+	if failure.Error == "" {
+		failure.Error = resp.Status
+	}
+	return &Error{Method: method, Status: resp.StatusCode, Message: failure.Error}
+}
+
+// setBandwidth applies rate (rclone's --bwlimit syntax, "" for none).
+// The limit is global to the daemon and throttles every provider using it,
+// so providers with different limits need separate daemons. It is only
+// changed when it differs.
+func (d *Daemon) setBandwidth(ctx context.Context, rate string) error {
+	if rate == "" {
+		rate = "off"
+	}
+	if err := d.start(ctx); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.rate == rate {
+		return nil
+	}
+	if err := d.post(ctx, "core/bwlimit", map[string]string{"rate": rate}, nil); err != nil {
+		return err
+	}
+	d.rate = rate
+	return nil
+}
+
+// get streams an object served by --rc-serve to w. A negative length
+// reads to the end of the object.
+func (d *Daemon) get(ctx context.Context, fs, remote string, offset, length int64, w io.Writer) (int64, error) {
+	if length == 0 {
+		return 0, nil
+	}
+	if err := d.start(ctx); err != nil {
+		return 0, err
+	}
+	u := url.URL{Scheme: "http", Host: "rcd", Path: "/[" + fs + "]/" + remote}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if length >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && offset == 0:
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Reading at or past the end yields nothing
+		return 0, nil
+	default:
+		return 0, responseError("serve", resp)
+	}
+	if length < 0 {
+		return io.Copy(w, resp.Body)
+	}
+	n, err := io.CopyN(w, resp.Body, length)
+	if err == io.EOF {
+		// The object ends before offset+length
+		err = nil
+	}
+	return n, err
+}
+
+// Close stops rclone and removes the socket. The daemon may be used again
+// afterwards, starting a new process.
+func (d *Daemon) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cmd != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), daemonStopTimeout)
+		d.post(ctx, "core/quit", nil, nil)
+		cancel()
+		select {
+		case <-d.exited:
+		case <-time.After(daemonStopTimeout):
+			d.cmd.Process.Kill()
+			<-d.exited
+		}
+		d.cmd, d.exited = nil, nil
+	}
+	if d.dir != "" {
+		os.RemoveAll(d.dir)
+		d.dir = ""
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package rclone
+
+import "syscall"
+
+// daemonSysProcAttr makes the kernel kill rclone rcd if cloudfs dies
+// without calling Close.
+func daemonSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package rclone
+
+import "syscall"
+
+// daemonSysProcAttr returns nil; outside Linux a crashed cloudfs may leave
+// rclone rcd running until its socket directory is cleaned up.
+func daemonSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,281 @@
+package rclone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+)
+
+// rcStatsInterval is how often core/stats is polled during a transfer,
+// matching statsInterval of the process-per-call mode.
+const rcStatsInterval = time.Second
+
+// rcGroups numbers the stats groups of transfers through a daemon.
+var rcGroups atomic.Int64
+
+// UseDaemon routes the provider's operations through d instead of running
+// one rclone process per call. Transfers are limited to the provider's
+// ConcurrentUploads. Init still runs rclone directly.
+// The daemon applies one bandwidth limit to all of its transfers; share it
+// only between providers with the same limit.
+func (p *Provider) UseDaemon(d *Daemon) {
+	p.rcd = d
+}
+
+// acquire takes one of ConcurrentUploads transfer slots.
+func (p *Provider) acquire(ctx context.Context) (release func(), err error) {
+	p.semOnce.Do(func() {
+		n := 4
+		if caps, err := p.Capabilities(ctx); err == nil && caps.ConcurrentUploads > 0 {
+			n = caps.ConcurrentUploads
+		}
+		p.sem = make(chan struct{}, n)
+	})
+	select {
+	case p.sem <- struct{}{}:
+		return func() { <-p.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// rcPath splits an rclone path into the fs and remote the rc API takes.
+// Paths below the provider's root keep it as their fs; local paths are
+// split into directory and file name.
+func (p *Provider) rcPath(full string) (fs, remote string) {
+	if rest, ok := strings.CutPrefix(full, p.remoteName); ok {
+		if strings.HasSuffix(p.remoteName, ":") || strings.HasSuffix(p.remoteName, "/") ||
+			rest == "" || strings.HasPrefix(rest, "/") {
+			return p.remoteName, strings.TrimPrefix(rest, "/")
+		}
+	}
+	// rclone reads "name:" as a remote unless a slash comes first
+	if i := strings.Index(full, ":"); i > 0 && !strings.Contains(full[:i], "/") {
+		return full[:i+1], full[i+1:]
+	}
+	return filepath.Dir(full), filepath.Base(full)
+}
+
+// rcCopy copies src to dst with operations/copyfile, polling core/stats
+// for progress while it runs.
+func (p *Provider) rcCopy(ctx context.Context, src, dst string, progress provider.ProgressFunc) error {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := p.rcd.setBandwidth(ctx, bwlimit.Flag(ctx)); err != nil {
+		return err
+	}
+
+	group := fmt.Sprintf("cloudfs/%d", rcGroups.Add(1))
+	defer p.rcd.call(context.Background(), "core/stats-delete", map[string]string{"group": group}, nil)
+
+	srcFs, srcRemote := p.rcPath(src)
+	dstFs, dstRemote := p.rcPath(dst)
+	done := make(chan error, 1)
+	go func() {
+		// rclone cancels the copy when the request is cancelled
+		done <- p.rcd.call(ctx, "operations/copyfile", map[string]string{
+			"srcFs": srcFs, "srcRemote": srcRemote,
+			"dstFs": dstFs, "dstRemote": dstRemote,
+			"_group": group,
+		}, nil)
+	}()
+
+	var reported int64 = -1
+	report := func() {
+		var stats transferStats
+		if progress == nil || p.rcd.call(ctx, "core/stats", map[string]string{"group": group}, &stats) != nil {
+			return
+		}
+		pr := stats.progress()
+		if pr.Bytes < reported || (pr.Total == 0 && pr.Bytes == 0) {
+			return
+		}
+		reported = pr.Bytes
+		progress(pr)
+	}
+
+	ticker := time.NewTicker(rcStatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+			// Like a killed rclone, a copy cancelled during its last
+			// report fails even though the object is complete
+			report()
+			return ctx.Err()
+		case <-ticker.C:
+			report()
+		}
+	}
+}
+
+// rcItem is an object as described by operations/stat.
+type rcItem struct {
+	Size   int64             `json:"Size"`
+	Hashes map[string]string `json:"Hashes"`
+}
+
+// rcStat returns the object at remotePath with its SHA-256, or nil when
+// it does not exist.
+func (p *Provider) rcStat(ctx context.Context, remotePath string) (*rcItem, error) {
+	fs, remote := p.rcPath(p.fullPath(remotePath))
+	var out struct {
+		Item *rcItem `json:"item"`
+	}
+	err := p.rcd.call(ctx, "operations/stat", map[string]interface{}{
+		"fs": fs, "remote": remote,
+		"opt": map[string]interface{}{"showHash": true, "hashTypes": []string{"sha256"}},
+	}, &out)
+	return out.Item, err
+}
+
+func (p *Provider) rcCapabilities(ctx context.Context) (*provider.Capabilities, error) {
+	var info struct {
+		Features map[string]interface{} `json:"Features"`
+	}
+	// Same defaults as the process-per-call mode
+	versioning := false
+	if err := p.rcd.call(ctx, "operations/fsinfo", map[string]string{"fs": p.remoteName}, &info); err == nil {
+		versioning = info.Features["BucketBased"] == true
+	}
+	return &provider.Capabilities{
+		MaxChunkSize:         100 * 1024 * 1024,
+		SupportsVersioning:   versioning,
+		SupportsDirectUpload: true,
+		RequiresEncryption:   false,
+		SupportsResume:       true,
+		ConcurrentUploads:    4,
+	}, nil
+}
+
+func (p *Provider) rcGetUsage(ctx context.Context) (*provider.Usage, error) {
+	var about struct {
+		Total int64 `json:"total"`
+		Used  int64 `json:"used"`
+		Free  int64 `json:"free"`
+	}
+	if err := p.rcd.call(ctx, "operations/about", map[string]string{"fs": p.remoteName}, &about); err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return &provider.Usage{
+		TotalBytes:     about.Total,
+		UsedBytes:      about.Used,
+		AvailableBytes: about.Free,
+	}, nil
+}
+
+func (p *Provider) rcDownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	if err := p.rcd.setBandwidth(ctx, bwlimit.Flag(ctx)); err != nil {
+		return 0, err
+	}
+
+	fs, remote := p.rcPath(p.fullPath(remotePath))
+	n, err := p.rcd.get(ctx, fs, remote, offset, length, w)
+	if err != nil {
+		return n, fmt.Errorf("ranged download failed: %w", err)
+	}
+	return n, nil
+}
+
+func (p *Provider) rcList(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	fs, remote := p.remoteName, ""
+	if prefix != "" {
+		fs, remote = p.rcPath(p.fullPath(prefix))
+	}
+
+	var out struct {
+		List []struct {
+			Path    string    `json:"Path"`
+			Size    int64     `json:"Size"`
+			ModTime time.Time `json:"ModTime"`
+		} `json:"list"`
+	}
+	err := p.rcd.call(ctx, "operations/list", map[string]interface{}{
+		"fs": fs, "remote": remote,
+		"opt": map[string]interface{}{"recurse": true, "filesOnly": true},
+	}, &out)
+	if err != nil {
+		return nil, fmt.Errorf("list failed: %w", err)
+	}
+
+	// Listed paths are relative to fs, not to remote
+	objects := make([]provider.RemoteObject, 0, len(out.List))
+	for _, f := range out.List {
+		full := fs + "/" + f.Path
+		if strings.HasSuffix(fs, ":") || strings.HasSuffix(fs, "/") {
+			full = fs + f.Path
+		}
+		objects = append(objects, provider.RemoteObject{
+			Path:    full,
+			Size:    f.Size,
+			ModTime: f.ModTime,
+		})
+	}
+	return objects, nil
+}
+
+func (p *Provider) rcDelete(ctx context.Context, remotePath string) error {
+	fs, remote := p.rcPath(p.fullPath(remotePath))
+	err := p.rcd.call(ctx, "operations/deletefile", map[string]string{"fs": fs, "remote": remote}, nil)
+	var rcErr *Error
+	if errors.As(err, &rcErr) && rcErr.Status == http.StatusNotFound {
+		// Deleting an object that is already gone is not an error
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	return nil
+}
+
+func (p *Provider) rcVerify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	item, err := p.rcStat(ctx, remotePath)
+	var rcErr *Error
+	if errors.As(err, &rcErr) && rcErr.Status == http.StatusNotFound {
+		item, err = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("verify failed: %w", err)
+	}
+	if item == nil {
+		return &provider.VerifyResult{
+			IsValid:      false,
+			ErrorMessage: "file not found or inaccessible",
+		}, nil
+	}
+	return &provider.VerifyResult{
+		IsValid:     true,
+		ContentHash: item.Hashes["sha256"],
+	}, nil
+}
+
+func (p *Provider) rcCheckHealth(ctx context.Context) provider.HealthState {
+	err := p.rcd.call(ctx, "operations/list", map[string]interface{}{
+		"fs": p.remoteName, "remote": "",
+		"opt": map[string]interface{}{"dirsOnly": true},
+	}, nil)
+	if err != nil {
+		return provider.HealthStateUnavailable
+	}
+	return provider.HealthStateHealthy
+}
@@ -5,14 +5,18 @@ package rclone
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
 )
 
 // Provider implements the storage provider interface using rclone.
@@ -22,6 +26,10 @@ type Provider struct {
 	displayName string
 	remoteName  string // rclone remote name (e.g., "gdrive:", "s3:")
 	configPath  string
+
+	rcd     *Daemon // Set by UseDaemon; nil runs rclone per call
+	semOnce sync.Once
+	sem     chan struct{} // Transfer slots when using the daemon
 }
 
 // NewProvider creates a new rclone-based provider.
@@ -72,6 +80,10 @@ func (p *Provider) Init(ctx context.Context, config map[string]interface{}) erro
 
 // Capabilities returns what this provider supports.
 func (p *Provider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	if p.rcd != nil {
+		return p.rcCapabilities(ctx)
+	}
+
 	// Get provider features from rclone
 	cmd := p.rcloneCmd(ctx, "backend", "features", p.remoteName)
 	output, err := cmd.Output()
@@ -104,6 +116,10 @@ func (p *Provider) Capabilities(ctx context.Context) (*provider.Capabilities, er
 // GetUsage returns current usage statistics.
 // This is AUTHORITATIVE for quota enforcement.
 func (p *Provider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	if p.rcd != nil {
+		return p.rcGetUsage(ctx)
+	}
+
 	cmd := p.rcloneCmd(ctx, "about", p.remoteName, "--json")
 	output, err := cmd.Output()
 	if err != nil {
@@ -135,11 +151,10 @@ func (p *Provider) Upload(ctx context.Context, localPath string, remotePath stri
 	}
 
 	// Build remote path
-	fullRemotePath := p.remoteName + remotePath
+	fullRemotePath := p.fullPath(remotePath)
 
-	// Execute rclone copy
-	cmd := p.rcloneCmd(ctx, "copyto", localPath, fullRemotePath, "--progress")
-	if err := cmd.Run(); err != nil {
+	// Execute rclone copy, reporting its stats as progress
+	if err := p.copyto(ctx, localPath, fullRemotePath, progress); err != nil {
 		return nil, fmt.Errorf("upload failed: %w", err)
 	}
 
@@ -166,11 +181,10 @@ func (p *Provider) Download(ctx context.Context, remotePath string, localPath st
 	}
 
 	// Build remote path
-	fullRemotePath := p.remoteName + remotePath
+	fullRemotePath := p.fullPath(remotePath)
 
-	// Execute rclone copy
-	cmd := p.rcloneCmd(ctx, "copyto", fullRemotePath, localPath, "--progress")
-	if err := cmd.Run(); err != nil {
+	// Execute rclone copy, reporting its stats as progress
+	if err := p.copyto(ctx, fullRemotePath, localPath, progress); err != nil {
 		return nil, fmt.Errorf("download failed: %w", err)
 	}
 
@@ -191,14 +205,85 @@ func (p *Provider) Download(ctx context.Context, remotePath string, localPath st
 	}, nil
 }
 
+// DownloadRange streams a byte range of a remote file using rclone cat.
+func (p *Provider) DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	if p.rcd != nil {
+		return p.rcDownloadRange(ctx, remotePath, offset, length, w)
+	}
+
+	args := []string{"cat", p.fullPath(remotePath), "--offset", fmt.Sprintf("%d", offset)}
+	if length >= 0 {
+		args = append(args, "--count", fmt.Sprintf("%d", length))
+	}
+
+	cw := &countingWriter{w: w}
+	cmd := p.rcloneCmd(ctx, args...)
+	cmd.Stdout = cw
+	if err := cmd.Run(); err != nil {
+		return cw.n, fmt.Errorf("ranged download failed: %w", err)
+	}
+
+	return cw.n, nil
+}
+
+// List returns every object below prefix using rclone lsjson.
+// Returned paths are fully qualified, matching placements.remote_path.
+func (p *Provider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	if p.rcd != nil {
+		return p.rcList(ctx, prefix)
+	}
+
+	base := p.remoteName
+	if prefix != "" {
+		base = p.fullPath(prefix)
+	}
+
+	cmd := p.rcloneCmd(ctx, "lsjson", "-R", "--files-only", base)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("list failed: %w", err)
+	}
+
+	var files []struct {
+		Path    string    `json:"Path"`
+		Size    int64     `json:"Size"`
+		ModTime time.Time `json:"ModTime"`
+	}
+	if err := json.Unmarshal(output, &files); err != nil {
+		return nil, fmt.Errorf("failed to parse listing: %w", err)
+	}
+
+	objects := make([]provider.RemoteObject, 0, len(files))
+	for _, f := range files {
+		full := base + "/" + f.Path
+		if strings.HasSuffix(base, ":") || strings.HasSuffix(base, "/") {
+			full = base + f.Path
+		}
+		objects = append(objects, provider.RemoteObject{
+			Path:    full,
+			Size:    f.Size,
+			ModTime: f.ModTime,
+		})
+	}
+	return objects, nil
+}
+
 // Delete removes a file from the provider.
 // NOTE: Only invoked during explicit purge or trash eviction after user confirmation.
 func (p *Provider) Delete(ctx context.Context, remotePath string) error {
-	fullRemotePath := p.remoteName + remotePath
+	if p.rcd != nil {
+		return p.rcDelete(ctx, remotePath)
+	}
 
-	cmd := p.rcloneCmd(ctx, "delete", fullRemotePath)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("delete failed: %w", err)
+	fullRemotePath := p.fullPath(remotePath)
+
+	cmd := p.rcloneCmd(ctx, "deletefile", fullRemotePath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		// Deleting an object that is already gone is not an error
+		if strings.Contains(string(output), "not found") {
+			return nil
+		}
+		return fmt.Errorf("delete failed: %w (%s)", err, strings.TrimSpace(string(output)))
 	}
 
 	return nil
@@ -206,26 +291,39 @@ func (p *Provider) Delete(ctx context.Context, remotePath string) error {
 
 // Verify checks data integrity on provider.
 func (p *Provider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
-	fullRemotePath := p.remoteName + remotePath
+	if p.rcd != nil {
+		return p.rcVerify(ctx, remotePath)
+	}
+
+	fullRemotePath := p.fullPath(remotePath)
 
 	// Check if file exists
 	cmd := p.rcloneCmd(ctx, "lsjson", fullRemotePath)
 	output, err := cmd.Output()
 	if err != nil {
-		return &provider.VerifyResult{
-			IsValid:      false,
-			ErrorMessage: "file not found or inaccessible",
-		}, nil
+		// rclone exits 3 (directory not found) or 4 (file not found) when
+		// the object is absent; anything else says nothing about it
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && (exitErr.ExitCode() == 3 || exitErr.ExitCode() == 4) {
+			return &provider.VerifyResult{
+				IsValid:      false,
+				ErrorMessage: "file not found or inaccessible",
+			}, nil
+		}
+		return nil, fmt.Errorf("verify failed: %w", err)
 	}
 
 	var files []struct {
 		Name string `json:"Name"`
 		Size int64  `json:"Size"`
 	}
-	if err := json.Unmarshal(output, &files); err != nil || len(files) == 0 {
+	if err := json.Unmarshal(output, &files); err != nil {
+		return nil, fmt.Errorf("failed to parse file info: %w", err)
+	}
+	if len(files) == 0 {
 		return &provider.VerifyResult{
 			IsValid:      false,
-			ErrorMessage: "failed to parse file info",
+			ErrorMessage: "file not found or inaccessible",
 		}, nil
 	}
 
@@ -241,6 +339,10 @@ func (p *Provider) Verify(ctx context.Context, remotePath string) (*provider.Ver
 // CheckHealth returns current health state.
 // NOTE: Health is observational, not decision authority.
 func (p *Provider) CheckHealth(ctx context.Context) provider.HealthState {
+	if p.rcd != nil {
+		return p.rcCheckHealth(ctx)
+	}
+
 	// Try a simple operation to check health
 	cmd := p.rcloneCmd(ctx, "lsd", p.remoteName, "--max-depth", "0")
 	if err := cmd.Run(); err != nil {
@@ -250,11 +352,40 @@ func (p *Provider) CheckHealth(ctx context.Context) provider.HealthState {
 	return provider.HealthStateHealthy
 }
 
+// fullPath builds the rclone path for a remote object.
+// Placements store fully qualified paths (e.g. "gdrive:backup/file.txt");
+// those are passed through unchanged.
+func (p *Provider) fullPath(remotePath string) string {
+	if strings.HasPrefix(remotePath, p.remoteName) {
+		return remotePath
+	}
+	if strings.HasSuffix(p.remoteName, ":") {
+		return p.remoteName + strings.TrimPrefix(remotePath, "/")
+	}
+	return p.remoteName + "/" + strings.TrimPrefix(remotePath, "/")
+}
+
+// countingWriter counts bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
 // rcloneCmd creates an rclone command with common flags.
 func (p *Provider) rcloneCmd(ctx context.Context, args ...string) *exec.Cmd {
 	allArgs := args
+	if limit := bwlimit.Flag(ctx); limit != "" {
+		// rclone applies the same timetable syntax itself
+		allArgs = append([]string{"--bwlimit", limit}, allArgs...)
+	}
 	if p.configPath != "" {
-		allArgs = append([]string{"--config", p.configPath}, args...)
+		allArgs = append([]string{"--config", p.configPath}, allArgs...)
 	}
 	cmd := exec.CommandContext(ctx, "rclone", allArgs...)
 	return cmd
@@ -262,7 +393,15 @@ func (p *Provider) rcloneCmd(ctx context.Context, args ...string) *exec.Cmd {
 
 // getRemoteHash gets the hash of a remote file.
 func (p *Provider) getRemoteHash(ctx context.Context, remotePath string) (string, error) {
-	fullRemotePath := p.remoteName + remotePath
+	if p.rcd != nil {
+		item, err := p.rcStat(ctx, remotePath)
+		if err != nil || item == nil || item.Hashes["sha256"] == "" {
+			return "", fmt.Errorf("no hash for %s", remotePath)
+		}
+		return item.Hashes["sha256"], nil
+	}
+
+	fullRemotePath := p.fullPath(remotePath)
 	cmd := p.rcloneCmd(ctx, "hashsum", "SHA-256", fullRemotePath)
 	output, err := cmd.Output()
 	if err != nil {
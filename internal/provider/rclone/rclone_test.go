@@ -0,0 +1,283 @@
+package rclone
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+	"github.com/cloudfs/cloudfs/internal/provider/providertest"
+)
+
+// TestProvider_Conformance runs against a local directory, which rclone
+// accepts as a remote without any configuration.
+func TestProvider_Conformance(t *testing.T) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Skip("rclone not installed")
+	}
+	p := NewProvider("local", "Local", t.TempDir(), "")
+	providertest.Run(t, p, providertest.Options{})
+}
+
+// TestProvider_ConformanceDaemon runs the same suite through rclone rcd.
+func TestProvider_ConformanceDaemon(t *testing.T) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Skip("rclone not installed")
+	}
+	d := NewDaemon("")
+	t.Cleanup(func() { d.Close() })
+	p := NewProvider("local", "Local", t.TempDir(), "")
+	p.UseDaemon(d)
+	providertest.Run(t, p, providertest.Options{})
+}
+
+func TestProvider_RcPath(t *testing.T) {
+	tests := []struct {
+		root, full string
+		fs, remote string
+	}{
+		{"gdrive:", "gdrive:backup/a.txt", "gdrive:", "backup/a.txt"},
+		{"gdrive:cloudfs", "gdrive:cloudfs/a.txt", "gdrive:cloudfs", "a.txt"},
+		{"gdrive:cloudfs", "gdrive:cloudfs2/a.txt", "gdrive:", "cloudfs2/a.txt"},
+		{"/srv/store", "/srv/store/x/a.txt", "/srv/store", "x/a.txt"},
+		{"/srv/store", "/tmp/cache/a:b.txt", "/tmp/cache", "a:b.txt"},
+	}
+	for _, tt := range tests {
+		p := NewProvider("p", "P", tt.root, "")
+		fs, remote := p.rcPath(tt.full)
+		if fs != tt.fs || remote != tt.remote {
+			t.Errorf("rcPath(%q) with root %q: expected (%q, %q), got (%q, %q)",
+				tt.full, tt.root, tt.fs, tt.remote, fs, remote)
+		}
+	}
+}
+
+func TestDaemon_CloseAndRestart(t *testing.T) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Skip("rclone not installed")
+	}
+	d := NewDaemon("")
+	ctx := context.Background()
+	if err := d.call(ctx, "rc/noop", nil, nil); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	dir := d.dir
+	d.Close()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected socket directory %s to be removed", dir)
+	}
+
+	// A closed daemon starts again on use
+	if err := d.call(ctx, "rc/noop", nil, nil); err != nil {
+		t.Fatalf("call after close failed: %v", err)
+	}
+	err := d.call(ctx, "operations/deletefile", map[string]string{"fs": t.TempDir(), "remote": "missing"}, nil)
+	var rcErr *Error
+	if !errors.As(err, &rcErr) || rcErr.HTTPStatus() != 404 {
+		t.Errorf("expected rc error with status 404, got %v", err)
+	}
+	d.Close()
+}
+
+func TestScanLog(t *testing.T) {
+	log := strings.Join([]string{
+		`{"level":"warning","msg":"Config file not found - using defaults"}`,
+		`{"level":"warning","msg":"stats","stats":{"bytes":0,"totalBytes":0,"speed":0,"eta":null}}`,
+		`{"level":"warning","msg":"stats","stats":{"bytes":4194304,"totalBytes":10485760,"speed":0,"eta":null,"transferring":[{"speed":2097152}]}}`,
+		`not json`,
+		`{"level":"warning","msg":"stats","stats":{"bytes":8388608,"totalBytes":10485760,"speed":2097152,"eta":1}}`,
+		`{"level":"warning","msg":"stats","stats":{"bytes":1048576,"totalBytes":10485760,"speed":2097152,"eta":4}}`,
+		`{"level":"error","msg":"Attempt 1/3 failed with 1 errors and: directory not found"}`,
+		`{"level":"warning","msg":"stats","stats":{"bytes":10485760,"totalBytes":10485760,"speed":2097152,"eta":0}}`,
+	}, "\n")
+
+	var got []provider.Progress
+	lastError := scanLog(strings.NewReader(log), func(p provider.Progress) { got = append(got, p) })
+
+	want := []provider.Progress{
+		{Bytes: 4194304, Total: 10485760, Speed: 2097152, ETA: 3 * time.Second},
+		{Bytes: 8388608, Total: 10485760, Speed: 2097152, ETA: time.Second},
+		{Bytes: 10485760, Total: 10485760, Speed: 2097152},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d reports, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("report %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	if lastError != "Attempt 1/3 failed with 1 errors and: directory not found" {
+		t.Errorf("unexpected last error %q", lastError)
+	}
+}
+
+// TestProvider_Progress throttles a copy so rclone reports stats mid-transfer.
+func TestProvider_Progress(t *testing.T) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Skip("rclone not installed")
+	}
+	if testing.Short() {
+		t.Skip("slow: waits for rclone stats")
+	}
+	dir := t.TempDir()
+	local := filepath.Join(dir, "big.bin")
+	os.WriteFile(local, bytes.Repeat([]byte("cloudfs "), 3<<17), 0600) // 3 MiB
+
+	sched, _ := bwlimit.ParseSchedule("1M")
+	ctx := bwlimit.WithLimiter(context.Background(), bwlimit.NewLimiter(sched))
+	p := NewProvider("local", "Local", filepath.Join(dir, "remote"), "")
+
+	var got []provider.Progress
+	if _, err := p.Upload(ctx, local, "big.bin", func(pr provider.Progress) { got = append(got, pr) }); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if len(got) < 2 {
+		t.Fatalf("expected several progress reports, got %+v", got)
+	}
+	last := got[len(got)-1]
+	if last.Bytes != 3<<20 || last.Total != 3<<20 {
+		t.Errorf("expected final report of the whole file, got %+v", last)
+	}
+	if got[0].Speed <= 0 || got[0].Bytes >= 3<<20 {
+		t.Errorf("expected a mid-transfer report with speed, got %+v", got[0])
+	}
+}
+
+func TestProvider_ErrorIncludesLog(t *testing.T) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Skip("rclone not installed")
+	}
+	p := NewProvider("local", "Local", t.TempDir(), "")
+	_, err := p.Download(context.Background(), "missing.bin", filepath.Join(t.TempDir(), "x"), nil)
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected exit error carrying rclone's message, got %v", err)
+	}
+}
+
+// TestProvider_VerifyMissing checks that only an absent object is invalid;
+// a remote that cannot be reached is an error.
+func TestProvider_VerifyMissing(t *testing.T) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Skip("rclone not installed")
+	}
+	ctx := context.Background()
+	config := filepath.Join(t.TempDir(), "rclone.conf")
+	os.WriteFile(config, nil, 0600)
+	d := NewDaemon(config)
+	t.Cleanup(func() { d.Close() })
+
+	for _, daemon := range []bool{false, true} {
+		local := NewProvider("local", "Local", t.TempDir(), config)
+		gone := NewProvider("gone", "Gone", "nosuchremote:", config)
+		if daemon {
+			local.UseDaemon(d)
+			gone.UseDaemon(d)
+		}
+
+		vr, err := local.Verify(ctx, "missing.bin")
+		if err != nil || vr.IsValid {
+			t.Errorf("daemon=%v: expected missing object to be invalid, got %+v (%v)", daemon, vr, err)
+		}
+		if vr, err := gone.Verify(ctx, "missing.bin"); err == nil {
+			t.Errorf("daemon=%v: expected unknown remote to fail, got %+v", daemon, vr)
+		}
+	}
+}
+
+// TestProvider_ProgressDaemon checks that core/stats is reported while
+// a throttled copy runs through the daemon.
+func TestProvider_ProgressDaemon(t *testing.T) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Skip("rclone not installed")
+	}
+	if testing.Short() {
+		t.Skip("slow: waits for rclone stats")
+	}
+	dir := t.TempDir()
+	local := filepath.Join(dir, "big.bin")
+	os.WriteFile(local, bytes.Repeat([]byte("cloudfs "), 3<<17), 0600) // 3 MiB
+
+	d := NewDaemon("")
+	defer d.Close()
+	sched, _ := bwlimit.ParseSchedule("1M")
+	ctx := bwlimit.WithLimiter(context.Background(), bwlimit.NewLimiter(sched))
+	p := NewProvider("local", "Local", filepath.Join(dir, "remote"), "")
+	p.UseDaemon(d)
+
+	var got []provider.Progress
+	if _, err := p.Upload(ctx, local, "big.bin", func(pr provider.Progress) { got = append(got, pr) }); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if len(got) < 2 {
+		t.Fatalf("expected several progress reports, got %+v", got)
+	}
+	if last := got[len(got)-1]; last.Bytes != 3<<20 || last.Total != 3<<20 {
+		t.Errorf("expected final report of the whole file, got %+v", last)
+	}
+
+	var buf bytes.Buffer
+	n, err := p.DownloadRange(ctx, "big.bin", 8, 7, &buf)
+	if err != nil || n != 7 || buf.String() != "cloudfs" {
+		t.Errorf("expected ranged read of %q, got %q (%d, %v)", "cloudfs", buf.String(), n, err)
+	}
+}
+
+// BenchmarkProvider_SmallFiles compares one rclone process per call with
+// a shared rclone rcd for uploads of small files.
+func BenchmarkProvider_SmallFiles(b *testing.B) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		b.Skip("rclone not installed")
+	}
+	local := filepath.Join(b.TempDir(), "small.bin")
+	os.WriteFile(local, bytes.Repeat([]byte("x"), 4<<10), 0600)
+	ctx := context.Background()
+
+	upload := func(b *testing.B, p *Provider, i int64) {
+		if _, err := p.Upload(ctx, local, fmt.Sprintf("f%d.bin", i), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("exec", func(b *testing.B) {
+		p := NewProvider("local", "Local", b.TempDir(), "")
+		for i := 0; i < b.N; i++ {
+			upload(b, p, int64(i))
+		}
+	})
+	b.Run("rcd", func(b *testing.B) {
+		d := NewDaemon("")
+		defer d.Close()
+		p := NewProvider("local", "Local", b.TempDir(), "")
+		p.UseDaemon(d)
+		d.call(ctx, "rc/noop", nil, nil) // Start outside the timing
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			upload(b, p, int64(i))
+		}
+	})
+	b.Run("rcd-parallel", func(b *testing.B) {
+		d := NewDaemon("")
+		defer d.Close()
+		p := NewProvider("local", "Local", b.TempDir(), "")
+		p.UseDaemon(d)
+		d.call(ctx, "rc/noop", nil, nil)
+		var n atomic.Int64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				upload(b, p, n.Add(1))
+			}
+		})
+	})
+}
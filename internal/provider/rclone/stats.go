@@ -0,0 +1,118 @@
+package rclone
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// statsInterval is how often rclone reports transfer stats.
+const statsInterval = "1s"
+
+// transferStats holds the numbers rclone prints for --progress, as found in
+// --use-json-log stats lines and core/stats responses.
+type transferStats struct {
+	Bytes        int64    `json:"bytes"`
+	TotalBytes   int64    `json:"totalBytes"`
+	Speed        float64  `json:"speed"`
+	ETA          *float64 `json:"eta"`
+	Transferring []struct {
+		Speed float64 `json:"speed"`
+	} `json:"transferring"`
+}
+
+// logLine is one line of rclone's --use-json-log output.
+type logLine struct {
+	Level string         `json:"level"`
+	Msg   string         `json:"msg"`
+	Stats *transferStats `json:"stats"`
+}
+
+// parseLogLine decodes a --use-json-log line. ok is false for lines that
+// are not JSON, e.g. from rclone versions without JSON logging.
+func parseLogLine(b []byte) (line logLine, ok bool) {
+	if json.Unmarshal(b, &line) != nil {
+		return logLine{}, false
+	}
+	return line, true
+}
+
+// progress converts stats. rclone averages its global speed and reports 0
+// for the first seconds, so the in-flight transfer's speed is used until
+// then; the ETA is derived when rclone has none.
+func (s *transferStats) progress() provider.Progress {
+	p := provider.Progress{Bytes: s.Bytes, Total: s.TotalBytes, Speed: s.Speed}
+	if p.Speed == 0 {
+		for _, t := range s.Transferring {
+			p.Speed += t.Speed
+		}
+	}
+	switch {
+	case s.ETA != nil:
+		p.ETA = time.Duration(*s.ETA * float64(time.Second))
+	case p.Speed > 0 && p.Total > p.Bytes:
+		p.ETA = time.Duration(float64(p.Total-p.Bytes) / p.Speed * float64(time.Second))
+	}
+	return p
+}
+
+// copyto runs rclone copyto with JSON logging, passing stats to progress
+// as they arrive. On failure the last error rclone logged is added to the
+// exit error, which stays inspectable with errors.As.
+func (p *Provider) copyto(ctx context.Context, src, dst string, progress provider.ProgressFunc) error {
+	if p.rcd != nil {
+		return p.rcCopy(ctx, src, dst, progress)
+	}
+	cmd := p.rcloneCmd(ctx, "copyto", src, dst,
+		"--use-json-log", "--stats", statsInterval, "--stats-log-level", "NOTICE")
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	lastError := scanLog(stderr, progress)
+	if err := cmd.Wait(); err != nil {
+		if lastError != "" {
+			return fmt.Errorf("%w: %s", err, lastError)
+		}
+		return err
+	}
+	return nil
+}
+
+// scanLog reads rclone's log until EOF and returns the last error message.
+// Progress never goes backwards, even when rclone restarts a transfer.
+func scanLog(r io.Reader, progress provider.ProgressFunc) string {
+	var lastError string
+	var reported int64 = -1
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line, ok := parseLogLine(sc.Bytes())
+		if !ok {
+			continue
+		}
+		if line.Level == "error" || line.Level == "critical" {
+			lastError = line.Msg
+		}
+		if line.Stats == nil || progress == nil {
+			continue
+		}
+		pr := line.Stats.progress()
+		if pr.Bytes < reported || (pr.Total == 0 && pr.Bytes == 0) {
+			continue
+		}
+		reported = pr.Bytes
+		progress(pr)
+	}
+	// Keep draining so rclone never blocks on a full pipe
+	io.Copy(io.Discard, r)
+	return lastError
+}
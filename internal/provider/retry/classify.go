@@ -0,0 +1,140 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Class is the kind of a provider failure, deciding whether it is retried.
+type Class string
+
+const (
+	// ClassTransient failures (timeouts, resets, throttling, 5xx) are retried.
+	ClassTransient Class = "transient"
+	// ClassQuota failures mean the provider is full; retrying will not help.
+	ClassQuota Class = "quota"
+	// ClassAuth failures mean credentials or permissions are wrong.
+	ClassAuth Class = "auth"
+	// ClassNotFound failures mean the object or path does not exist.
+	ClassNotFound Class = "not_found"
+	// ClassPermanent failures are rejected requests that fail the same way again.
+	ClassPermanent Class = "permanent"
+)
+
+// Retryable reports whether a failure of this class is worth another attempt.
+func (c Class) Retryable() bool {
+	return c == ClassTransient
+}
+
+// httpStatus is implemented by provider errors carrying an HTTP status,
+// such as s3.Error and webdav.Error.
+type httpStatus interface {
+	HTTPStatus() int
+}
+
+// Classify sorts err into a Class. Typed errors (HTTP status, rclone exit
+// codes, os and net errors) are checked first, then the message text.
+// Errors that match nothing are assumed transient.
+func Classify(err error) Class {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return ClassPermanent
+	}
+
+	var status httpStatus
+	if errors.As(err, &status) {
+		class := classifyStatus(status.HTTPStatus())
+		// Some services answer 403 for an exhausted quota
+		if class == ClassAuth && classifyText(err.Error()) == ClassQuota {
+			return ClassQuota
+		}
+		return class
+	}
+
+	// rclone documents its exit codes; see "rclone help" under Exit Code
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		switch exit.ExitCode() {
+		case 2:
+			return ClassPermanent
+		case 3, 4:
+			return ClassNotFound
+		case 5:
+			return ClassTransient
+		case 7:
+			return ClassAuth
+		case 8:
+			return ClassQuota
+		}
+	}
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return ClassNotFound
+	case errors.Is(err, os.ErrPermission):
+		return ClassAuth
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return ClassQuota
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED):
+		return ClassTransient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ClassTransient
+	}
+
+	if class := classifyText(err.Error()); class != "" {
+		return class
+	}
+	return ClassTransient
+}
+
+// classifyStatus maps an HTTP status code to a Class.
+func classifyStatus(code int) Class {
+	switch {
+	case code == http.StatusNotFound || code == http.StatusGone:
+		return ClassNotFound
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ClassAuth
+	case code == http.StatusInsufficientStorage:
+		return ClassQuota
+	case code == http.StatusRequestTimeout || code == http.StatusLocked || code == http.StatusTooManyRequests:
+		return ClassTransient
+	case code >= 500:
+		return ClassTransient
+	default:
+		return ClassPermanent
+	}
+}
+
+// Message fragments, lowercased, checked in order.
+var textClasses = []struct {
+	class     Class
+	fragments []string
+}{
+	{ClassQuota, []string{"quota", "insufficient storage", "no space left", "storage full"}},
+	{ClassAuth, []string{"unauthorized", "forbidden", "permission denied", "access denied", "unable to authenticate", "authentication failed", "invalid credentials", "invalidaccesskeyid", "signaturedoesnotmatch", "expiredtoken"}},
+	{ClassNotFound, []string{"not found", "no such file", "does not exist", "nosuchkey", "nosuchbucket"}},
+}
+
+// classifyText classifies an error message, or returns "" if nothing matches.
+func classifyText(msg string) Class {
+	msg = strings.ToLower(msg)
+	for _, tc := range textClasses {
+		for _, f := range tc.fragments {
+			if strings.Contains(msg, f) {
+				return tc.class
+			}
+		}
+	}
+	return ""
+}
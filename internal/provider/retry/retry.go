@@ -0,0 +1,395 @@
+// Package retry wraps a provider with retries, exponential backoff and a
+// circuit breaker, so one dropped connection does not fail a push or a
+// hydration and a dead provider is not hammered.
+//
+// INVARIANTS:
+// - Only transient failures are retried; quota, auth and not-found fail at once
+// - A cancelled caller context ends the call without another attempt
+// - Retries draw from a per-provider budget refilled by successful calls
+// - An open circuit fails calls fast with ErrCircuitOpen until one probe succeeds
+// - Ranged downloads are only retried while nothing has been written
+// - Health changes are reported on transitions only
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// ErrCircuitOpen is returned without calling the provider while its
+// circuit is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Default policy values.
+const (
+	DefaultMaxAttempts      = 4
+	DefaultBaseDelay        = 500 * time.Millisecond
+	DefaultMaxDelay         = 30 * time.Second
+	DefaultBudget           = 10
+	DefaultBudgetRefill     = 0.2
+	DefaultFailureThreshold = 5
+	DefaultOpenTimeout      = 30 * time.Second
+)
+
+// Policy controls retries and the circuit breaker. Zero fields take the
+// defaults above.
+type Policy struct {
+	MaxAttempts      int           // Attempts per call, including the first
+	BaseDelay        time.Duration // Backoff before the first retry, doubled after each
+	MaxDelay         time.Duration // Backoff cap
+	Budget           float64       // Retries banked per provider
+	BudgetRefill     float64       // Retries earned back by each successful call
+	FailureThreshold int           // Consecutive failed calls that open the circuit
+	OpenTimeout      time.Duration // How long the circuit stays open before a probe
+
+	// OnHealthChange is called when the observed health of the provider
+	// changes, e.g. to update providers.health_state.
+	OnHealthChange func(providerID string, state provider.HealthState)
+}
+
+func (pol *Policy) setDefaults() {
+	if pol.MaxAttempts <= 0 {
+		pol.MaxAttempts = DefaultMaxAttempts
+	}
+	if pol.BaseDelay <= 0 {
+		pol.BaseDelay = DefaultBaseDelay
+	}
+	if pol.MaxDelay <= 0 {
+		pol.MaxDelay = DefaultMaxDelay
+	}
+	if pol.Budget <= 0 {
+		pol.Budget = DefaultBudget
+	}
+	if pol.BudgetRefill <= 0 {
+		pol.BudgetRefill = DefaultBudgetRefill
+	}
+	if pol.FailureThreshold <= 0 {
+		pol.FailureThreshold = DefaultFailureThreshold
+	}
+	if pol.OpenTimeout <= 0 {
+		pol.OpenTimeout = DefaultOpenTimeout
+	}
+}
+
+// Circuit states.
+const (
+	stateClosed   = "closed"
+	stateOpen     = "open"
+	stateHalfOpen = "half-open"
+)
+
+// Provider decorates another provider with retries and a circuit breaker.
+type Provider struct {
+	inner  provider.Provider
+	policy Policy
+
+	mu       sync.Mutex
+	state    string
+	failures int       // Consecutive failed calls
+	openedAt time.Time // When the circuit last opened
+	probing  bool      // A half-open probe is in flight
+	budget   float64   // Retries left
+	health   provider.HealthState
+}
+
+// NewProvider wraps inner. The wrapper keeps inner's ID, type and name.
+func NewProvider(inner provider.Provider, policy Policy) *Provider {
+	policy.setDefaults()
+	return &Provider{
+		inner:  inner,
+		policy: policy,
+		state:  stateClosed,
+		budget: policy.Budget,
+	}
+}
+
+// Inner returns the wrapped provider.
+func (p *Provider) Inner() provider.Provider {
+	return p.inner
+}
+
+// Circuit returns the circuit state: closed, open or half-open.
+func (p *Provider) Circuit() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state == stateOpen && time.Since(p.openedAt) >= p.policy.OpenTimeout {
+		return stateHalfOpen
+	}
+	return p.state
+}
+
+// ID returns the wrapped provider's ID.
+func (p *Provider) ID() string {
+	return p.inner.ID()
+}
+
+// Type returns the wrapped provider's type.
+func (p *Provider) Type() string {
+	return p.inner.Type()
+}
+
+// DisplayName returns the wrapped provider's name.
+func (p *Provider) DisplayName() string {
+	return p.inner.DisplayName()
+}
+
+// Init initializes the wrapped provider, retrying transient failures.
+func (p *Provider) Init(ctx context.Context, config map[string]interface{}) error {
+	return p.do(ctx, "init", func() error {
+		return p.inner.Init(ctx, config)
+	})
+}
+
+// Capabilities returns the wrapped provider's capabilities.
+func (p *Provider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	var caps *provider.Capabilities
+	err := p.do(ctx, "capabilities", func() (err error) {
+		caps, err = p.inner.Capabilities(ctx)
+		return err
+	})
+	return caps, err
+}
+
+// GetUsage returns the wrapped provider's usage.
+func (p *Provider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	var usage *provider.Usage
+	err := p.do(ctx, "usage", func() (err error) {
+		usage, err = p.inner.GetUsage(ctx)
+		return err
+	})
+	return usage, err
+}
+
+// Upload retries the whole upload; providers resume or replace partial
+// uploads themselves.
+func (p *Provider) Upload(ctx context.Context, localPath string, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	var result *provider.UploadResult
+	err := p.do(ctx, "upload "+remotePath, func() (err error) {
+		result, err = p.inner.Upload(ctx, localPath, remotePath, progress)
+		return err
+	})
+	return result, err
+}
+
+// Download retries the whole download; localPath is rewritten each time.
+func (p *Provider) Download(ctx context.Context, remotePath string, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	var result *provider.DownloadResult
+	err := p.do(ctx, "download "+remotePath, func() (err error) {
+		result, err = p.inner.Download(ctx, remotePath, localPath, progress)
+		return err
+	})
+	return result, err
+}
+
+// DownloadRange retries only while no bytes have reached w.
+func (p *Provider) DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	ranged, ok := provider.AsRangeDownloader(p.inner)
+	if !ok {
+		return 0, fmt.Errorf("provider %s does not support ranged downloads", p.inner.ID())
+	}
+	var n int64
+	err := p.do(ctx, "ranged download "+remotePath, func() (err error) {
+		n, err = ranged.DownloadRange(ctx, remotePath, offset, length, w)
+		if err != nil && n > 0 {
+			return &partialError{err}
+		}
+		return err
+	})
+	var partial *partialError
+	if errors.As(err, &partial) {
+		err = partial.err
+	}
+	return n, err
+}
+
+// Delete retries transient failures.
+func (p *Provider) Delete(ctx context.Context, remotePath string) error {
+	return p.do(ctx, "delete "+remotePath, func() error {
+		return p.inner.Delete(ctx, remotePath)
+	})
+}
+
+// Verify retries transient failures.
+func (p *Provider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	var result *provider.VerifyResult
+	err := p.do(ctx, "verify "+remotePath, func() (err error) {
+		result, err = p.inner.Verify(ctx, remotePath)
+		return err
+	})
+	return result, err
+}
+
+// List retries transient failures.
+func (p *Provider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	lister, ok := provider.AsLister(p.inner)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support listing", p.inner.ID())
+	}
+	var objects []provider.RemoteObject
+	err := p.do(ctx, "list", func() (err error) {
+		objects, err = lister.List(ctx, prefix)
+		return err
+	})
+	return objects, err
+}
+
+// CheckHealth reports unavailable while the circuit is open, and the
+// wrapped provider's own check otherwise.
+// NOTE: Health is observational, not decision authority.
+func (p *Provider) CheckHealth(ctx context.Context) provider.HealthState {
+	if p.Circuit() == stateOpen {
+		return provider.HealthStateUnavailable
+	}
+	return p.inner.CheckHealth(ctx)
+}
+
+// partialError marks a ranged download failure after data was written,
+// which must not be retried.
+type partialError struct {
+	err error
+}
+
+func (e *partialError) Error() string { return e.err.Error() }
+func (e *partialError) Unwrap() error { return e.err }
+
+// do runs call under the retry policy and circuit breaker.
+func (p *Provider) do(ctx context.Context, op string, call func() error) error {
+	probe, err := p.admit()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", p.inner.ID(), op, err)
+	}
+
+	attempts := 0
+	for {
+		attempts++
+		err = call()
+		if err == nil {
+			p.record(probe, attempts, "")
+			return nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; says nothing about the provider
+			p.release(probe)
+			return err
+		}
+
+		class := Classify(err)
+		var partial *partialError
+		retry := class.Retryable() && !probe && !errors.As(err, &partial) &&
+			attempts < p.policy.MaxAttempts && p.spend()
+		if !retry {
+			p.record(probe, attempts, class)
+			if attempts > 1 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempts)
+			}
+			return err
+		}
+
+		select {
+		case <-time.After(p.backoff(attempts)):
+		case <-ctx.Done():
+			p.release(probe)
+			return err
+		}
+	}
+}
+
+// admit decides whether a call may reach the provider. probe is true for
+// the single call let through a half-open circuit.
+func (p *Provider) admit() (probe bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state == stateClosed {
+		return false, nil
+	}
+	if p.probing || time.Since(p.openedAt) < p.policy.OpenTimeout {
+		return false, ErrCircuitOpen
+	}
+	p.state = stateHalfOpen
+	p.probing = true
+	return true, nil
+}
+
+// release ends a call that produced no verdict on the provider.
+func (p *Provider) release(probe bool) {
+	if !probe {
+		return
+	}
+	p.mu.Lock()
+	p.probing = false
+	p.mu.Unlock()
+}
+
+// spend takes one retry from the budget, reporting false if none is left.
+func (p *Provider) spend() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.budget < 1 {
+		return false
+	}
+	p.budget--
+	return true
+}
+
+// backoff returns the delay before retry number attempt: the base delay
+// doubled per attempt, capped, with the upper half jittered.
+func (p *Provider) backoff(attempt int) time.Duration {
+	d := p.policy.BaseDelay << (attempt - 1)
+	if d > p.policy.MaxDelay || d <= 0 {
+		d = p.policy.MaxDelay
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// record updates the circuit and health after a call. class is empty on
+// success. Transient and auth failures count against the circuit; quota,
+// not-found and permanent failures show the provider is answering.
+func (p *Provider) record(probe bool, attempts int, class Class) {
+	p.mu.Lock()
+	if probe {
+		p.probing = false
+	}
+
+	failed := class == ClassTransient || class == ClassAuth
+	if failed {
+		p.failures++
+		if probe || p.failures >= p.policy.FailureThreshold {
+			p.state = stateOpen
+			p.openedAt = time.Now()
+		}
+	} else {
+		p.failures = 0
+		p.state = stateClosed
+	}
+	if class == "" {
+		p.budget += p.policy.BudgetRefill
+		if p.budget > p.policy.Budget {
+			p.budget = p.policy.Budget
+		}
+	}
+
+	var health provider.HealthState
+	switch {
+	case p.state == stateOpen || class == ClassAuth:
+		health = provider.HealthStateUnavailable
+	case p.failures > 0 || attempts > 1 || class == ClassQuota:
+		health = provider.HealthStateDegraded
+	default:
+		health = provider.HealthStateHealthy
+	}
+	changed := health != p.health
+	p.health = health
+	p.mu.Unlock()
+
+	if changed && p.policy.OnHealthChange != nil {
+		p.policy.OnHealthChange(p.inner.ID(), health)
+	}
+}
@@ -0,0 +1,401 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/faulty"
+	"github.com/cloudfs/cloudfs/internal/provider/providertest"
+	"github.com/cloudfs/cloudfs/internal/provider/s3"
+	"github.com/cloudfs/cloudfs/internal/provider/webdav"
+)
+
+// memProvider stores objects in memory. Calls fail with the queued
+// errors first, one per call.
+type memProvider struct {
+	mu    sync.Mutex
+	data  map[string][]byte
+	fail  []error
+	calls int
+}
+
+func newMemProvider(fail ...error) *memProvider {
+	return &memProvider{data: make(map[string][]byte), fail: fail}
+}
+
+// next counts a call and returns its queued error, if any.
+func (m *memProvider) next() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if len(m.fail) == 0 {
+		return nil
+	}
+	err := m.fail[0]
+	m.fail = m.fail[1:]
+	return err
+}
+
+func (m *memProvider) ID() string          { return "mem" }
+func (m *memProvider) Type() string        { return "memory" }
+func (m *memProvider) DisplayName() string { return "Memory" }
+func (m *memProvider) Init(ctx context.Context, config map[string]interface{}) error {
+	return m.next()
+}
+func (m *memProvider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{ConcurrentUploads: 2}, m.next()
+}
+func (m *memProvider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	return &provider.Usage{}, m.next()
+}
+func (m *memProvider) CheckHealth(ctx context.Context) provider.HealthState {
+	return provider.HealthStateHealthy
+}
+func (m *memProvider) Upload(ctx context.Context, localPath, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	if err := m.next(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.data[remotePath] = b
+	m.mu.Unlock()
+	return &provider.UploadResult{RemotePath: remotePath, ContentHash: hashOf(b), Size: int64(len(b))}, nil
+}
+func (m *memProvider) Download(ctx context.Context, remotePath, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	if err := m.next(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	b, ok := m.data[remotePath]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", remotePath, os.ErrNotExist)
+	}
+	os.MkdirAll(filepath.Dir(localPath), 0700)
+	if err := os.WriteFile(localPath, b, 0600); err != nil {
+		return nil, err
+	}
+	return &provider.DownloadResult{LocalPath: localPath, ContentHash: hashOf(b), Size: int64(len(b))}, nil
+}
+func (m *memProvider) DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	m.mu.Lock()
+	b := m.data[remotePath]
+	m.mu.Unlock()
+	if err := m.next(); err != nil {
+		// Fail halfway through the range
+		n, _ := w.Write(b[offset : offset+length/2])
+		return int64(n), err
+	}
+	n, err := w.Write(b[offset : offset+length])
+	return int64(n), err
+}
+func (m *memProvider) Delete(ctx context.Context, remotePath string) error {
+	if err := m.next(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.data, remotePath)
+	m.mu.Unlock()
+	return nil
+}
+func (m *memProvider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	if err := m.next(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	b, ok := m.data[remotePath]
+	m.mu.Unlock()
+	if !ok {
+		return &provider.VerifyResult{IsValid: false}, nil
+	}
+	return &provider.VerifyResult{IsValid: true, ContentHash: hashOf(b)}, nil
+}
+func (m *memProvider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	if err := m.next(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var objects []provider.RemoteObject
+	for path, b := range m.data {
+		if strings.HasPrefix(path, prefix) {
+			objects = append(objects, provider.RemoteObject{Path: path, Size: int64(len(b))})
+		}
+	}
+	return objects, nil
+}
+
+func hashOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// healthLog records OnHealthChange transitions.
+type healthLog struct {
+	mu     sync.Mutex
+	states []provider.HealthState
+}
+
+func (h *healthLog) record(id string, state provider.HealthState) {
+	h.mu.Lock()
+	h.states = append(h.states, state)
+	h.mu.Unlock()
+}
+
+func (h *healthLog) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var parts []string
+	for _, s := range h.states {
+		parts = append(parts, string(s))
+	}
+	return strings.Join(parts, ",")
+}
+
+var errReset = &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+func testPolicy(log *healthLog) Policy {
+	return Policy{
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         5 * time.Millisecond,
+		FailureThreshold: 3,
+		OpenTimeout:      50 * time.Millisecond,
+		OnHealthChange:   log.record,
+	}
+}
+
+func TestClassify(t *testing.T) {
+	exitErr := func(code int) error {
+		return fmt.Errorf("upload failed: %w", exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run())
+	}
+	tests := []struct {
+		err  error
+		want Class
+	}{
+		{&s3.Error{StatusCode: 503, Code: "SlowDown"}, ClassTransient},
+		{&s3.Error{StatusCode: 403, Code: "InvalidAccessKeyId"}, ClassAuth},
+		{&s3.Error{StatusCode: 403, Code: "QuotaExceeded"}, ClassQuota},
+		{fmt.Errorf("download failed: %w", &webdav.Error{Method: "GET", StatusCode: 404}), ClassNotFound},
+		{&webdav.Error{Method: "PUT", StatusCode: 507}, ClassQuota},
+		{&webdav.Error{Method: "PUT", StatusCode: 429}, ClassTransient},
+		{&webdav.Error{Method: "PUT", StatusCode: 400}, ClassPermanent},
+		{exitErr(4), ClassNotFound},
+		{exitErr(5), ClassTransient},
+		{exitErr(7), ClassAuth},
+		{exitErr(8), ClassQuota},
+		{fmt.Errorf("open: %w", os.ErrNotExist), ClassNotFound},
+		{fmt.Errorf("open: %w", os.ErrPermission), ClassAuth},
+		{errReset, ClassTransient},
+		{io.ErrUnexpectedEOF, ClassTransient},
+		{errors.New("ssh: handshake failed: ssh: unable to authenticate"), ClassAuth},
+		{fmt.Errorf("upload: %w", faulty.ErrQuotaExceeded), ClassQuota},
+		{faulty.ErrInjected, ClassTransient},
+		{context.Canceled, ClassPermanent},
+		{errors.New("something odd"), ClassTransient},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestProvider_RetriesTransient(t *testing.T) {
+	var log healthLog
+	inner := newMemProvider(errReset, errReset)
+	p := NewProvider(inner, testPolicy(&log))
+	ctx := context.Background()
+
+	if err := p.Delete(ctx, "mem:/a"); err != nil {
+		t.Fatalf("expected delete to succeed after retries, got %v", err)
+	}
+	if inner.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", inner.calls)
+	}
+	// A call that needed retries degrades health; a clean one restores it
+	p.Delete(ctx, "mem:/a")
+	if log.String() != "degraded,healthy" {
+		t.Errorf("unexpected health transitions %s", log.String())
+	}
+
+	// Not-found is final after one attempt
+	inner = newMemProvider(fmt.Errorf("stat: %w", os.ErrNotExist))
+	p = NewProvider(inner, testPolicy(&log))
+	if _, err := p.Verify(ctx, "mem:/a"); !errors.Is(err, os.ErrNotExist) || inner.calls != 1 {
+		t.Errorf("expected one not-found attempt, got %v after %d", err, inner.calls)
+	}
+
+	// Attempts are capped
+	inner = newMemProvider(errReset, errReset, errReset, errReset, errReset)
+	p = NewProvider(inner, testPolicy(&log))
+	if _, err := p.GetUsage(ctx); !errors.Is(err, errReset) || !strings.Contains(err.Error(), "after 4 attempts") {
+		t.Errorf("expected failure after 4 attempts, got %v", err)
+	}
+}
+
+func TestProvider_Budget(t *testing.T) {
+	var log healthLog
+	policy := testPolicy(&log)
+	policy.MaxAttempts = 10
+	policy.Budget = 2
+	policy.FailureThreshold = 100
+	fail := make([]error, 20)
+	for i := range fail {
+		fail[i] = errReset
+	}
+	inner := newMemProvider(fail...)
+	p := NewProvider(inner, policy)
+
+	p.Delete(context.Background(), "mem:/a")
+	if inner.calls != 3 {
+		t.Errorf("expected the budget to allow 2 retries, got %d attempts", inner.calls)
+	}
+	// With the budget spent, the next call gets a single attempt
+	p.Delete(context.Background(), "mem:/a")
+	if inner.calls != 4 {
+		t.Errorf("expected no retries without budget, got %d attempts", inner.calls)
+	}
+}
+
+func TestProvider_Circuit(t *testing.T) {
+	var log healthLog
+	policy := testPolicy(&log)
+	policy.MaxAttempts = 1
+	inner := newMemProvider(errReset, errReset, errReset, errReset)
+	p := NewProvider(inner, policy)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		p.Delete(ctx, "mem:/a")
+	}
+	if p.Circuit() != stateOpen || p.CheckHealth(ctx) != provider.HealthStateUnavailable {
+		t.Fatalf("expected open circuit after 3 failures, got %s", p.Circuit())
+	}
+	if err := p.Delete(ctx, "mem:/a"); !errors.Is(err, ErrCircuitOpen) || inner.calls != 3 {
+		t.Errorf("expected fast failure without a call, got %v after %d calls", err, inner.calls)
+	}
+
+	// A failed probe reopens the circuit
+	time.Sleep(policy.OpenTimeout)
+	if err := p.Delete(ctx, "mem:/a"); !errors.Is(err, errReset) || p.Circuit() != stateOpen {
+		t.Errorf("expected failed probe to reopen, got %v (%s)", err, p.Circuit())
+	}
+	// A successful probe closes it
+	time.Sleep(policy.OpenTimeout)
+	if err := p.Delete(ctx, "mem:/a"); err != nil || p.Circuit() != stateClosed {
+		t.Errorf("expected successful probe to close, got %v (%s)", err, p.Circuit())
+	}
+	if log.String() != "degraded,unavailable,healthy" {
+		t.Errorf("unexpected health transitions %s", log.String())
+	}
+
+	// Not-found answers show the provider is up
+	inner = newMemProvider(os.ErrNotExist, os.ErrNotExist, os.ErrNotExist, os.ErrNotExist)
+	p = NewProvider(inner, policy)
+	for i := 0; i < 4; i++ {
+		p.Delete(ctx, "mem:/a")
+	}
+	if p.Circuit() != stateClosed {
+		t.Error("expected not-found failures to leave the circuit closed")
+	}
+}
+
+func TestProvider_Cancel(t *testing.T) {
+	var log healthLog
+	policy := testPolicy(&log)
+	policy.BaseDelay = time.Hour
+	policy.MaxDelay = time.Hour
+	p := NewProvider(newMemProvider(errReset), policy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := p.Delete(ctx, "mem:/a"); !errors.Is(err, errReset) {
+		t.Errorf("expected the last error when cancelled during backoff, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected cancellation to cut the backoff short")
+	}
+	if log.String() != "" {
+		t.Errorf("expected a cancelled call to leave health alone, got %s", log.String())
+	}
+}
+
+func TestProvider_RangeNotRetriedAfterData(t *testing.T) {
+	var log healthLog
+	inner := newMemProvider(errReset)
+	inner.data["mem:/a"] = []byte("0123456789")
+	p := NewProvider(inner, testPolicy(&log))
+
+	var buf bytes.Buffer
+	n, err := p.DownloadRange(context.Background(), "mem:/a", 2, 6, &buf)
+	if !errors.Is(err, errReset) || n != 3 || buf.String() != "234" || inner.calls != 1 {
+		t.Errorf("expected a single partial attempt, got %q (%d, %v) after %d calls", buf.String(), n, err, inner.calls)
+	}
+}
+
+func TestProvider_Conformance(t *testing.T) {
+	var log healthLog
+	p := NewProvider(newMemProvider(), testPolicy(&log))
+	providertest.Run(t, p, providertest.Options{Prefix: "mem:", LargeSize: 1 << 20})
+}
+
+func TestProvider_FaultyConformance(t *testing.T) {
+	// Retries hide a provider that fails a fifth of its calls
+	var log healthLog
+	policy := testPolicy(&log)
+	policy.MaxAttempts = 8
+	policy.Budget = 1000
+	policy.FailureThreshold = 1000
+	flaky, err := faulty.NewProvider("flaky", newMemProvider(), faulty.Config{Seed: 7, ErrorRate: 0.2})
+	if err != nil {
+		t.Fatalf("failed to create faulty provider: %v", err)
+	}
+	providertest.Run(t, NewProvider(flaky, policy), providertest.Options{Prefix: "mem:", LargeSize: 1 << 20})
+	if flaky.Stats()[faulty.FaultError] == 0 {
+		t.Error("expected injected failures to have been retried")
+	}
+}
+
+func TestProvider_OptionalMethods(t *testing.T) {
+	var log healthLog
+	full := NewProvider(newMemProvider(), testPolicy(&log))
+	if _, ok := provider.AsLister(full); !ok {
+		t.Error("expected listing through a provider that lists")
+	}
+	if _, ok := provider.AsRangeDownloader(full); !ok {
+		t.Error("expected ranged downloads through a provider that supports them")
+	}
+
+	// Only the required methods, also hidden below another wrapper
+	basic := struct{ provider.Provider }{newMemProvider()}
+	flaky, _ := faulty.NewProvider("flaky", basic, faulty.Config{Seed: 1})
+	for _, p := range []provider.Provider{NewProvider(basic, testPolicy(&log)), NewProvider(flaky, testPolicy(&log))} {
+		if _, ok := provider.AsLister(p); ok {
+			t.Error("listing must not be offered when the wrapped provider cannot list")
+		}
+		if _, ok := provider.AsRangeDownloader(p); ok {
+			t.Error("ranged downloads must not be offered when the wrapped provider cannot")
+		}
+	}
+}
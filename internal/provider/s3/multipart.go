@@ -0,0 +1,225 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+)
+
+// uploadState is persisted between attempts so an interrupted multipart
+// upload can be resumed. It is only reused for identical content.
+type uploadState struct {
+	UploadID    string `json:"upload_id"`
+	Key         string `json:"key"`
+	ContentHash string `json:"content_hash"`
+	Size        int64  `json:"size"`
+	PartSize    int64  `json:"part_size"`
+}
+
+// uploadedPart is a part the service already holds.
+type uploadedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+	Size       int64  `xml:"Size"`
+}
+
+// uploadMultipart uploads f in parts, skipping parts a previous attempt
+// already completed, and completes the upload.
+func (p *Provider) uploadMultipart(ctx context.Context, f *os.File, size int64, key, hash string, progress provider.ProgressFunc) error {
+	state, done, err := p.resumeUpload(ctx, key, hash, size)
+	if err != nil {
+		return err
+	}
+
+	partCount := int((size + state.PartSize - 1) / state.PartSize)
+	completed := make([]uploadedPart, 0, partCount)
+	buf := make([]byte, state.PartSize)
+
+	for n := 1; n <= partCount; n++ {
+		offset := int64(n-1) * state.PartSize
+		length := state.PartSize
+		if offset+length > size {
+			length = size - offset
+		}
+		part := buf[:length]
+		if _, err := f.ReadAt(part, offset); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read part %d: %w", n, err)
+		}
+		sum := md5.Sum(part)
+		etag := hex.EncodeToString(sum[:])
+
+		// A part already held with the same digest is not sent again
+		if prev, ok := done[n]; ok && strings.Trim(prev.ETag, `"`) == etag && prev.Size == length {
+			completed = append(completed, uploadedPart{PartNumber: n, ETag: prev.ETag})
+		} else {
+			query := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {state.UploadID}}
+			resp, err := p.do(ctx, http.MethodPut, key, query, part, map[string]string{"Content-MD5": contentMD5(part)})
+			if err != nil {
+				return fmt.Errorf("upload of part %d/%d failed: %w", n, partCount, err)
+			}
+			resp.Body.Close()
+
+			returned := strings.Trim(resp.Header.Get("ETag"), `"`)
+			if returned != "" && returned != etag {
+				return fmt.Errorf("checksum mismatch on part %d: expected %s, got %s", n, etag, returned)
+			}
+			completed = append(completed, uploadedPart{PartNumber: n, ETag: `"` + etag + `"`})
+		}
+
+		if progress != nil {
+			progress(provider.Progress{Bytes: offset + length, Total: size})
+		}
+	}
+
+	if err := p.completeUpload(ctx, key, state.UploadID, completed); err != nil {
+		return err
+	}
+	os.Remove(p.statePath(key))
+	return nil
+}
+
+// resumeUpload returns the upload to continue and the parts it already
+// holds. A saved upload for different content is aborted and replaced.
+func (p *Provider) resumeUpload(ctx context.Context, key, hash string, size int64) (*uploadState, map[int]uploadedPart, error) {
+	statePath := p.statePath(key)
+	if data, err := os.ReadFile(statePath); err == nil {
+		var state uploadState
+		if json.Unmarshal(data, &state) == nil && state.UploadID != "" {
+			if state.Key == key && state.ContentHash == hash && state.Size == size && state.PartSize == p.cfg.PartSize {
+				parts, err := p.listParts(ctx, key, state.UploadID)
+				if err == nil {
+					return &state, parts, nil
+				}
+				if !isNotFound(err) {
+					return nil, nil, err
+				}
+				// The service expired the upload; start over
+			} else {
+				p.abortUpload(ctx, state.Key, state.UploadID)
+			}
+		}
+	}
+
+	resp, err := p.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, map[string]string{metaSHA256: hash})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if err != nil || result.UploadID == "" {
+		return nil, nil, fmt.Errorf("failed to parse multipart upload id: %v", err)
+	}
+
+	state := &uploadState{UploadID: result.UploadID, Key: key, ContentHash: hash, Size: size, PartSize: p.cfg.PartSize}
+	if err := os.MkdirAll(filepath.Dir(statePath), 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create upload state directory: %w", err)
+	}
+	data, _ := json.Marshal(state)
+	if err := os.WriteFile(statePath, data, 0600); err != nil {
+		return nil, nil, fmt.Errorf("failed to save upload state: %w", err)
+	}
+	return state, map[int]uploadedPart{}, nil
+}
+
+// listParts returns the parts the service holds for an upload.
+func (p *Provider) listParts(ctx context.Context, key, uploadID string) (map[int]uploadedPart, error) {
+	parts := make(map[int]uploadedPart)
+	marker := ""
+	for {
+		query := url.Values{"uploadId": {uploadID}}
+		if marker != "" {
+			query.Set("part-number-marker", marker)
+		}
+		resp, err := p.do(ctx, http.MethodGet, key, query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Parts                []uploadedPart `xml:"Part"`
+			IsTruncated          bool           `xml:"IsTruncated"`
+			NextPartNumberMarker string         `xml:"NextPartNumberMarker"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse part listing: %w", err)
+		}
+
+		for _, part := range result.Parts {
+			parts[part.PartNumber] = part
+		}
+		if !result.IsTruncated || result.NextPartNumberMarker == "" {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// completeUpload assembles the uploaded parts into the final object.
+func (p *Provider) completeUpload(ctx context.Context, key, uploadID string, parts []uploadedPart) error {
+	type completePart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	body := struct {
+		XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+		Parts   []completePart `xml:"Part"`
+	}{}
+	for _, part := range parts {
+		body.Parts = append(body.Parts, completePart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode part list: %w", err)
+	}
+
+	resp, err := p.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, data, nil)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// S3 may report a failure inside a 200 response
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.NewDecoder(resp.Body).Decode(&result) == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("failed to complete multipart upload: %w", &Error{StatusCode: resp.StatusCode, Code: result.Code, Message: result.Message})
+	}
+	return nil
+}
+
+// abortUpload discards an upload and its state. Failures are ignored;
+// the service expires abandoned uploads on its own.
+func (p *Provider) abortUpload(ctx context.Context, key, uploadID string) {
+	if resp, err := p.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil); err == nil {
+		resp.Body.Close()
+	}
+	os.Remove(p.statePath(key))
+}
+
+// statePath returns where the resume state for a key is kept.
+func (p *Provider) statePath(key string) string {
+	dir := p.cfg.StateDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "cloudfs-s3")
+	}
+	return filepath.Join(dir, hexSHA256([]byte(p.cfg.Endpoint+"/"+p.cfg.Bucket+"/"+key))+".json")
+}
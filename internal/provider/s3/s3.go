@@ -0,0 +1,555 @@
+// Package s3 provides a native S3-compatible storage provider.
+// Works against AWS S3 and S3-compatible services (MinIO, Backblaze B2,
+// Wasabi) without rclone, signing requests with AWS Signature Version 4.
+//
+// INVARIANTS:
+// - Every object carries its SHA-256 in x-amz-meta-sha256
+// - An upload is reported only after the stored size and hash are confirmed
+// - Multipart uploads resume only when the local file is unchanged
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+)
+
+const (
+	// DefaultPartSize is the multipart part size when none is configured.
+	DefaultPartSize = 8 * 1024 * 1024
+
+	// MinPartSize is the smallest part size S3 accepts (except the last part).
+	MinPartSize = 5 * 1024 * 1024
+
+	metaSHA256 = "X-Amz-Meta-Sha256"
+)
+
+// Config holds the connection settings for an S3-compatible bucket.
+type Config struct {
+	Endpoint  string // e.g. https://s3.eu-central-003.backblazeb2.com; empty = AWS for Region
+	Region    string // Signing region, default us-east-1
+	Bucket    string
+	Prefix    string // Key prefix for every object, e.g. "cloudfs"
+	AccessKey string
+	SecretKey string
+	PathStyle bool  // Address the bucket in the path (MinIO) instead of the host name
+	PartSize  int64 // Files larger than this use multipart uploads
+	Quota     int64 // Capacity reported by GetUsage (0 = unknown)
+	StateDir  string
+}
+
+// ParseConfig builds a Config from provider_config key/value pairs.
+// Credentials fall back to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func ParseConfig(cfg map[string]string) (Config, error) {
+	c := Config{
+		Endpoint:  cfg["endpoint"],
+		Region:    cfg["region"],
+		Bucket:    cfg["bucket"],
+		Prefix:    strings.Trim(cfg["prefix"], "/"),
+		AccessKey: cfg["access_key"],
+		SecretKey: cfg["secret_key"],
+		PathStyle: cfg["path_style"] == "true",
+	}
+	if c.AccessKey == "" {
+		c.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if c.SecretKey == "" {
+		c.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	for key, dst := range map[string]*int64{"part_size": &c.PartSize, "quota": &c.Quota} {
+		if v := cfg[key]; v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return c, fmt.Errorf("invalid s3 %s: %s", key, v)
+			}
+			*dst = n
+		}
+	}
+	return c, c.validate()
+}
+
+// validate checks required fields and applies defaults.
+func (c *Config) validate() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("s3 bucket not configured")
+	}
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = "https://s3." + c.Region + ".amazonaws.com"
+	}
+	if _, err := url.Parse(c.Endpoint); err != nil {
+		return fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if c.PartSize == 0 {
+		c.PartSize = DefaultPartSize
+	}
+	if c.PartSize < MinPartSize {
+		return fmt.Errorf("s3 part size must be at least %d bytes", MinPartSize)
+	}
+	return nil
+}
+
+// Error is an error response returned by the S3 service.
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s (HTTP %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// HTTPStatus returns the response status code.
+func (e *Error) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// isNotFound reports whether err is a missing object or upload.
+func isNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && (e.StatusCode == http.StatusNotFound || e.Code == "NoSuchKey" || e.Code == "NoSuchUpload")
+}
+
+// Provider implements the storage provider interface for S3-compatible services.
+type Provider struct {
+	id          string
+	displayName string
+	remoteName  string // Prefix of placements.remote_path, e.g. "wasabi:"
+	cfg         Config
+	client      *http.Client
+	signer      *signer
+}
+
+// NewProvider creates a new S3 provider.
+// remoteName is stripped from remote paths to form object keys.
+func NewProvider(id, displayName, remoteName string, cfg Config) (*Provider, error) {
+	p := &Provider{
+		id:          id,
+		displayName: displayName,
+		remoteName:  remoteName,
+		cfg:         cfg,
+		client:      &http.Client{},
+	}
+	if err := p.cfg.validate(); err != nil {
+		return nil, err
+	}
+	p.signer = &signer{accessKey: cfg.AccessKey, secretKey: cfg.SecretKey, region: p.cfg.Region, now: time.Now}
+	return p, nil
+}
+
+// ID returns the unique identifier for this provider instance.
+func (p *Provider) ID() string {
+	return p.id
+}
+
+// Type returns the provider type.
+func (p *Provider) Type() string {
+	return "s3"
+}
+
+// DisplayName returns the human-readable name.
+func (p *Provider) DisplayName() string {
+	return p.displayName
+}
+
+// Init verifies the bucket is reachable with the configured credentials.
+func (p *Provider) Init(ctx context.Context, config map[string]interface{}) error {
+	resp, err := p.do(ctx, http.MethodHead, "", nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("bucket %s not reachable: %w", p.cfg.Bucket, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Capabilities returns what this provider supports.
+func (p *Provider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{
+		MaxChunkSize:         p.cfg.PartSize,
+		SupportsVersioning:   false,
+		SupportsDirectUpload: true,
+		RequiresEncryption:   false,
+		SupportsResume:       true,
+		ConcurrentUploads:    1,
+	}, nil
+}
+
+// GetUsage sums object sizes below the prefix. S3 has no capacity, so
+// TotalBytes is the configured quota (0 when none).
+func (p *Provider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	objects, err := p.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	usage := &provider.Usage{TotalBytes: p.cfg.Quota}
+	for _, obj := range objects {
+		usage.UsedBytes += obj.Size
+	}
+	if usage.TotalBytes > usage.UsedBytes {
+		usage.AvailableBytes = usage.TotalBytes - usage.UsedBytes
+	}
+	return usage, nil
+}
+
+// Upload uploads a file, using a resumable multipart upload above the part size.
+func (p *Provider) Upload(ctx context.Context, localPath string, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("local file not found: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat local file: %w", err)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("failed to hash local file: %w", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	key := p.objectKey(remotePath)
+	if info.Size() <= p.cfg.PartSize {
+		body, err := os.ReadFile(localPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read local file: %w", err)
+		}
+		headers := map[string]string{
+			metaSHA256:    hash,
+			"Content-MD5": contentMD5(body),
+		}
+		resp, err := p.do(ctx, http.MethodPut, key, nil, body, headers)
+		if err != nil {
+			return nil, fmt.Errorf("upload failed: %w", err)
+		}
+		resp.Body.Close()
+		if progress != nil {
+			progress(provider.Progress{Bytes: info.Size(), Total: info.Size()})
+		}
+	} else if err := p.uploadMultipart(ctx, f, info.Size(), key, hash, progress); err != nil {
+		return nil, err
+	}
+
+	// Confirm what the service stored before reporting success
+	size, stored, err := p.head(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm upload: %w", err)
+	}
+	if size != info.Size() || stored != hash {
+		return nil, fmt.Errorf("checksum mismatch after upload of %s: stored %d bytes (%s), expected %d bytes (%s)",
+			remotePath, size, stored, info.Size(), hash)
+	}
+
+	return &provider.UploadResult{
+		RemotePath:  remotePath,
+		ContentHash: hash,
+		UploadedAt:  time.Now(),
+		Size:        info.Size(),
+	}, nil
+}
+
+// Download downloads an object, rejecting content that does not match its stored hash.
+func (p *Provider) Download(ctx context.Context, remotePath string, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	resp, err := p.do(ctx, http.MethodGet, p.objectKey(remotePath), nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	out, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local file: %w", err)
+	}
+
+	h := sha256.New()
+	var body io.Reader = resp.Body
+	if progress != nil && resp.ContentLength > 0 {
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, fn: progress}
+	}
+	size, err := io.Copy(io.MultiWriter(out, h), body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(localPath)
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	if stored := resp.Header.Get(metaSHA256); stored != "" && stored != hash {
+		os.Remove(localPath)
+		return nil, fmt.Errorf("checksum mismatch downloading %s: expected %s, got %s", remotePath, stored, hash)
+	}
+
+	return &provider.DownloadResult{
+		LocalPath:    localPath,
+		ContentHash:  hash,
+		DownloadedAt: time.Now(),
+		Size:         size,
+	}, nil
+}
+
+// DownloadRange streams a byte range of an object using an HTTP Range request.
+func (p *Provider) DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		if length == 0 {
+			return 0, nil
+		}
+		rng = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	resp, err := p.do(ctx, http.MethodGet, p.objectKey(remotePath), nil, nil, map[string]string{"Range": rng})
+	if err != nil {
+		return 0, fmt.Errorf("ranged download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("ranged download failed: %w", err)
+	}
+	return n, nil
+}
+
+// List returns every object below prefix using ListObjectsV2.
+// Returned paths are fully qualified, matching placements.remote_path.
+func (p *Provider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	keyPrefix := p.objectKey(prefix)
+	if keyPrefix != "" && !strings.HasSuffix(keyPrefix, "/") {
+		keyPrefix += "/"
+	}
+
+	var objects []provider.RemoteObject
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {keyPrefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := p.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("list failed: %w", err)
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse listing: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, provider.RemoteObject{
+				Path:    p.remotePath(c.Key),
+				Size:    c.Size,
+				ModTime: c.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	return objects, nil
+}
+
+// Delete removes an object from the bucket.
+// NOTE: Only invoked during explicit purge or trash eviction after user confirmation.
+func (p *Provider) Delete(ctx context.Context, remotePath string) error {
+	resp, err := p.do(ctx, http.MethodDelete, p.objectKey(remotePath), nil, nil, nil)
+	if err != nil {
+		// Deleting an object that is already gone is not an error
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Verify checks that the object exists and returns its stored SHA-256.
+func (p *Provider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	_, hash, err := p.head(ctx, p.objectKey(remotePath))
+	if err != nil {
+		if isNotFound(err) {
+			return &provider.VerifyResult{
+				IsValid:      false,
+				ErrorMessage: "file not found or inaccessible",
+			}, nil
+		}
+		return nil, fmt.Errorf("verify failed: %w", err)
+	}
+
+	return &provider.VerifyResult{
+		IsValid:     true,
+		ContentHash: hash,
+	}, nil
+}
+
+// CheckHealth returns current health state.
+// NOTE: Health is observational, not decision authority.
+func (p *Provider) CheckHealth(ctx context.Context) provider.HealthState {
+	resp, err := p.do(ctx, http.MethodHead, "", nil, nil, nil)
+	if err != nil {
+		return provider.HealthStateUnavailable
+	}
+	resp.Body.Close()
+	return provider.HealthStateHealthy
+}
+
+// head returns an object's size and stored SHA-256.
+func (p *Provider) head(ctx context.Context, key string) (int64, string, error) {
+	resp, err := p.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, resp.Header.Get(metaSHA256), nil
+}
+
+// objectKey maps a remote path ("wasabi:/docs/a.pdf") to an object key
+// below the configured prefix ("cloudfs/docs/a.pdf").
+func (p *Provider) objectKey(remotePath string) string {
+	rel := strings.TrimPrefix(remotePath, p.remoteName)
+	var parts []string
+	for _, part := range strings.Split(p.cfg.Prefix+"/"+rel, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// remotePath maps an object key back to a fully qualified remote path.
+func (p *Provider) remotePath(key string) string {
+	rel := key
+	if p.cfg.Prefix != "" {
+		rel = strings.TrimPrefix(key, p.cfg.Prefix+"/")
+	}
+	return p.remoteName + rel
+}
+
+// objectURL returns the URL of a key (or the bucket when key is empty).
+func (p *Provider) objectURL(key string, query url.Values) *url.URL {
+	u, _ := url.Parse(p.cfg.Endpoint)
+	path := "/" + key
+	if p.cfg.PathStyle {
+		path = "/" + p.cfg.Bucket + path
+	} else {
+		u.Host = p.cfg.Bucket + "." + u.Host
+	}
+
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg)
+	}
+	u.Path = path
+	u.RawPath = strings.Join(segments, "/")
+	u.RawQuery = canonicalQuery(query)
+	return u
+}
+
+// do sends a signed request. Responses with an error status are returned
+// as *Error with the body consumed.
+func (p *Provider) do(ctx context.Context, method, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, p.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if len(body) > 0 {
+		// Throttle the payload, also when the transport replays it
+		req.Body = io.NopCloser(bwlimit.Reader(ctx, bwlimit.Upload, bytes.NewReader(body)))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bwlimit.Reader(ctx, bwlimit.Upload, bytes.NewReader(body))), nil
+		}
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	payloadHash := emptyPayloadSHA
+	if len(body) > 0 {
+		payloadHash = hexSHA256(body)
+	}
+	p.signer.sign(req, payloadHash)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		s3err := &Error{StatusCode: resp.StatusCode}
+		if data, _ := io.ReadAll(resp.Body); len(data) > 0 {
+			xml.Unmarshal(data, s3err)
+		}
+		return nil, s3err
+	}
+	if method == http.MethodGet && key != "" {
+		resp.Body = bwlimit.ReadCloser(ctx, bwlimit.Download, resp.Body)
+	}
+	return resp, nil
+}
+
+// contentMD5 returns the base64 MD5 digest S3 uses to check a request body.
+func contentMD5(b []byte) string {
+	sum := md5.Sum(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// progressReader reports the bytes read so far out of total.
+type progressReader struct {
+	r     io.Reader
+	total int64
+	n     int64
+	fn    provider.ProgressFunc
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.n += int64(n)
+	pr.fn(provider.Progress{Bytes: pr.n, Total: pr.total})
+	return n, err
+}
+
+// listBucketResult is the ListObjectsV2 response body.
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
@@ -0,0 +1,159 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat   = "20060102T150405Z"
+	shortDateFormat = "20060102"
+	signAlgorithm   = "AWS4-HMAC-SHA256"
+	emptyPayloadSHA = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// signer signs requests with AWS Signature Version 4.
+type signer struct {
+	accessKey string
+	secretKey string
+	region    string
+	now       func() time.Time
+}
+
+// sign adds the x-amz-date, x-amz-content-sha256 and Authorization headers.
+// payloadHash is the hex SHA-256 of the request body.
+func (s *signer) sign(req *http.Request, payloadHash string) {
+	t := s.now().UTC()
+	amzDate := t.Format(amzDateFormat)
+	shortDate := t.Format(shortDateFormat)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers, signed := canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := shortDate + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonical)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), shortDate)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", signAlgorithm+
+		" Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signed+
+		", Signature="+signature)
+}
+
+// canonicalURI returns the URI-encoded path, each segment encoded once.
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		raw, err := url.PathUnescape(seg)
+		if err != nil {
+			raw = seg
+		}
+		segments[i] = uriEncode(raw)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery returns the query string sorted by key then value.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// canonicalHeaders returns the canonical header block and the signed header
+// list. Host, Content-MD5, Content-Type and every x-amz-* header are signed.
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		values["host"] = req.Host
+	}
+	for name, v := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-md5" || lower == "content-type" {
+			values[lower] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(values[name])
+		b.WriteByte('\n')
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// uriEncode percent-encodes everything except the RFC 3986 unreserved set.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,611 @@
+// Package sftp provides a native SFTP storage provider for self-hosted
+// storage (storage boxes, NAS) reachable only over SSH.
+//
+// INVARIANTS:
+// - Authentication is key-based only (ssh-agent or key file)
+// - Host keys are checked against known_hosts; unknown hosts are rejected
+// - Uploads write to a temporary name and rename into place
+// - A partial upload is resumed only for identical content (hash in its name)
+package sftp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+	pkgsftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const partSuffix = ".cloudfs-part"
+
+// Config holds the connection settings for an SFTP server.
+type Config struct {
+	Host       string // host or host:port (default port 22)
+	Username   string
+	Root       string // Directory holding CloudFS objects
+	KeyFile    string // Private key; empty = ssh-agent only
+	UseAgent   bool   // Offer keys from SSH_AUTH_SOCK
+	KnownHosts string // Default ~/.ssh/known_hosts
+	Quota      int64  // Capacity reported when the server lacks statvfs (0 = unknown)
+}
+
+// ParseConfig builds a Config from provider_config key/value pairs.
+// An encrypted key file is unlocked with CLOUDFS_SFTP_KEY_PASSPHRASE.
+func ParseConfig(cfg map[string]string) (Config, error) {
+	c := Config{
+		Host:       cfg["host"],
+		Username:   cfg["username"],
+		Root:       cfg["root"],
+		KeyFile:    cfg["key_file"],
+		UseAgent:   cfg["use_agent"] == "true",
+		KnownHosts: cfg["known_hosts"],
+	}
+	if v := cfg["quota"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return c, fmt.Errorf("invalid sftp quota: %s", v)
+		}
+		c.Quota = n
+	}
+	return c, c.validate()
+}
+
+// validate checks required fields and applies defaults.
+func (c *Config) validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("sftp host not configured")
+	}
+	if _, _, err := net.SplitHostPort(c.Host); err != nil {
+		c.Host = net.JoinHostPort(c.Host, "22")
+	}
+	if c.Username == "" {
+		return fmt.Errorf("sftp user not configured")
+	}
+	if c.KeyFile == "" && !c.UseAgent {
+		return fmt.Errorf("sftp requires a key file or ssh-agent")
+	}
+	if c.Root == "" {
+		c.Root = "."
+	}
+	if c.KnownHosts == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to locate known_hosts: %w", err)
+		}
+		c.KnownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	return nil
+}
+
+// Provider implements the storage provider interface over SFTP.
+type Provider struct {
+	id          string
+	displayName string
+	remoteName  string // Prefix of placements.remote_path, e.g. "nas:"
+	cfg         Config
+
+	mu     sync.Mutex
+	ssh    *ssh.Client
+	client *pkgsftp.Client
+}
+
+// NewProvider creates a new SFTP provider. The connection is opened on first use.
+// remoteName is stripped from remote paths to form server paths.
+func NewProvider(id, displayName, remoteName string, cfg Config) (*Provider, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &Provider{
+		id:          id,
+		displayName: displayName,
+		remoteName:  remoteName,
+		cfg:         cfg,
+	}, nil
+}
+
+// ID returns the unique identifier for this provider instance.
+func (p *Provider) ID() string {
+	return p.id
+}
+
+// Type returns the provider type.
+func (p *Provider) Type() string {
+	return "sftp"
+}
+
+// DisplayName returns the human-readable name.
+func (p *Provider) DisplayName() string {
+	return p.displayName
+}
+
+// Init connects and verifies the root directory exists.
+func (p *Provider) Init(ctx context.Context, config map[string]interface{}) error {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	info, err := client.Stat(p.cfg.Root)
+	if err != nil {
+		return fmt.Errorf("root %s not reachable: %w", p.cfg.Root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("root %s is not a directory", p.cfg.Root)
+	}
+	return nil
+}
+
+// Close releases the SSH connection.
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil {
+		p.client.Close()
+		p.client = nil
+	}
+	if p.ssh != nil {
+		err := p.ssh.Close()
+		p.ssh = nil
+		return err
+	}
+	return nil
+}
+
+// Capabilities returns what this provider supports.
+func (p *Provider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{
+		MaxChunkSize:         0,
+		SupportsVersioning:   false,
+		SupportsDirectUpload: true,
+		RequiresEncryption:   false,
+		SupportsResume:       true,
+		ConcurrentUploads:    1,
+	}, nil
+}
+
+// GetUsage returns filesystem usage via the statvfs@openssh.com extension.
+// Servers without it report the summed object sizes against the configured quota.
+func (p *Provider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	if vfs, err := client.StatVFS(p.cfg.Root); err == nil {
+		total := int64(vfs.Frsize * vfs.Blocks)
+		return &provider.Usage{
+			TotalBytes:     total,
+			UsedBytes:      total - int64(vfs.Frsize*vfs.Bfree),
+			AvailableBytes: int64(vfs.Frsize * vfs.Bavail),
+		}, nil
+	}
+
+	objects, err := p.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	usage := &provider.Usage{TotalBytes: p.cfg.Quota}
+	for _, obj := range objects {
+		usage.UsedBytes += obj.Size
+	}
+	if usage.TotalBytes > usage.UsedBytes {
+		usage.AvailableBytes = usage.TotalBytes - usage.UsedBytes
+	}
+	return usage, nil
+}
+
+// Upload writes to a temporary file named after the content hash, resuming
+// from its current size when a previous attempt was interrupted, then
+// renames it into place.
+func (p *Provider) Upload(ctx context.Context, localPath string, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("local file not found: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat local file: %w", err)
+	}
+	size := info.Size()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("failed to hash local file: %w", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	client, err := p.connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+
+	target := p.serverPath(remotePath)
+	if err := client.MkdirAll(path.Dir(target)); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	part := p.partPath(target, hash)
+	var offset int64
+	if existing, err := client.Stat(part); err == nil && existing.Size() <= size {
+		offset = existing.Size()
+	}
+
+	out, err := client.OpenFile(part, os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		out.Close()
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		out.Close()
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+
+	var body io.Reader = f
+	if progress != nil && size > 0 {
+		body = &progressReader{r: f, total: size, n: offset, fn: progress}
+	}
+	_, err = io.Copy(out, &contextReader{ctx: ctx, r: bwlimit.Reader(ctx, bwlimit.Upload, body)})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// The partial file is kept so the next attempt resumes from it
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+
+	stored, err := client.Stat(part)
+	if err != nil || stored.Size() != size {
+		client.Remove(part)
+		return nil, fmt.Errorf("size mismatch after upload of %s: expected %d bytes", remotePath, size)
+	}
+	if err := p.rename(client, part, target); err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+
+	return &provider.UploadResult{
+		RemotePath:  remotePath,
+		ContentHash: hash,
+		UploadedAt:  time.Now(),
+		Size:        size,
+	}, nil
+}
+
+// Download downloads a file from the server.
+func (p *Provider) Download(ctx context.Context, remotePath string, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	client, err := p.connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	in, err := client.Open(p.serverPath(remotePath))
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local file: %w", err)
+	}
+
+	h := sha256.New()
+	var body io.Reader = in
+	if progress != nil {
+		if info, err := in.Stat(); err == nil && info.Size() > 0 {
+			body = &progressReader{r: in, total: info.Size(), fn: progress}
+		}
+	}
+	size, err := io.Copy(io.MultiWriter(out, h), &contextReader{ctx: ctx, r: bwlimit.Reader(ctx, bwlimit.Download, body)})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(localPath)
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+
+	return &provider.DownloadResult{
+		LocalPath:    localPath,
+		ContentHash:  hex.EncodeToString(h.Sum(nil)),
+		DownloadedAt: time.Now(),
+		Size:         size,
+	}, nil
+}
+
+// DownloadRange streams a byte range of a remote file.
+func (p *Provider) DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ranged download failed: %w", err)
+	}
+	in, err := client.Open(p.serverPath(remotePath))
+	if err != nil {
+		return 0, fmt.Errorf("ranged download failed: %w", err)
+	}
+	defer in.Close()
+
+	if _, err := in.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("ranged download failed: %w", err)
+	}
+	var body io.Reader = in
+	if length >= 0 {
+		body = io.LimitReader(in, length)
+	}
+	n, err := io.Copy(w, &contextReader{ctx: ctx, r: bwlimit.Reader(ctx, bwlimit.Download, body)})
+	if err != nil {
+		return n, fmt.Errorf("ranged download failed: %w", err)
+	}
+	return n, nil
+}
+
+// List returns every file below prefix, skipping partial uploads.
+// Returned paths are fully qualified, matching placements.remote_path.
+func (p *Provider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list failed: %w", err)
+	}
+
+	root := path.Clean(p.cfg.Root)
+	var objects []provider.RemoteObject
+	walker := client.Walk(p.serverPath(prefix))
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if errors.Is(err, os.ErrNotExist) && walker.Path() == p.serverPath(prefix) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("list failed: %w", err)
+		}
+		info := walker.Stat()
+		if info.IsDir() || strings.HasSuffix(info.Name(), partSuffix) {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), root), "/")
+		objects = append(objects, provider.RemoteObject{
+			Path:    p.remoteName + rel,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+	return objects, nil
+}
+
+// Delete removes a file from the server.
+// NOTE: Only invoked during explicit purge or trash eviction after user confirmation.
+func (p *Provider) Delete(ctx context.Context, remotePath string) error {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	if err := client.Remove(p.serverPath(remotePath)); err != nil {
+		// Deleting a file that is already gone is not an error
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	return nil
+}
+
+// Verify checks that the file exists. The SHA-256 is reported when the
+// server allows running sha256sum; otherwise callers rehash a download.
+func (p *Provider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("verify failed: %w", err)
+	}
+
+	target := p.serverPath(remotePath)
+	info, err := client.Stat(target)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &provider.VerifyResult{
+				IsValid:      false,
+				ErrorMessage: "file not found or inaccessible",
+			}, nil
+		}
+		return nil, fmt.Errorf("verify failed: %w", err)
+	}
+	if info.IsDir() {
+		return &provider.VerifyResult{
+			IsValid:      false,
+			ErrorMessage: "not a file",
+		}, nil
+	}
+
+	hash, _ := p.remoteHash(target)
+	return &provider.VerifyResult{
+		IsValid:     true,
+		ContentHash: hash,
+	}, nil
+}
+
+// CheckHealth returns current health state.
+// NOTE: Health is observational, not decision authority.
+func (p *Provider) CheckHealth(ctx context.Context) provider.HealthState {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return provider.HealthStateUnavailable
+	}
+	if _, err := client.Stat(p.cfg.Root); err != nil {
+		return provider.HealthStateDegraded
+	}
+	return provider.HealthStateHealthy
+}
+
+// connect returns the SFTP client, dialing on first use.
+func (p *Provider) connect(ctx context.Context) (*pkgsftp.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil {
+		return p.client, nil
+	}
+
+	auth, err := p.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(p.cfg.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts %s: %w", p.cfg.KnownHosts, err)
+	}
+	config := &ssh.ClientConfig{
+		User:            p.cfg.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         30 * time.Second,
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.cfg.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", p.cfg.Host, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, p.cfg.Host, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh handshake with %s failed: %w", p.cfg.Host, err)
+	}
+	p.ssh = ssh.NewClient(sshConn, chans, reqs)
+
+	p.client, err = pkgsftp.NewClient(p.ssh)
+	if err != nil {
+		p.ssh.Close()
+		p.ssh = nil
+		return nil, fmt.Errorf("failed to start sftp subsystem: %w", err)
+	}
+	return p.client, nil
+}
+
+// authMethods returns the configured key-based auth methods.
+func (p *Provider) authMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if p.cfg.UseAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, fmt.Errorf("ssh-agent requested but SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reach ssh-agent: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if p.cfg.KeyFile != "" {
+		pem, err := os.ReadFile(p.cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(os.Getenv("CLOUDFS_SFTP_KEY_PASSPHRASE")))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key file: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	return methods, nil
+}
+
+// rename moves src over dst, replacing it. posix-rename@openssh.com is
+// atomic; servers without it get remove-then-rename.
+func (p *Provider) rename(client *pkgsftp.Client, src, dst string) error {
+	if err := client.PosixRename(src, dst); err == nil {
+		return nil
+	}
+	if err := client.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return client.Rename(src, dst)
+}
+
+// remoteHash runs sha256sum on the server. Restricted shells may refuse it.
+func (p *Provider) remoteHash(target string) (string, error) {
+	p.mu.Lock()
+	conn := p.ssh
+	p.mu.Unlock()
+	if conn == nil {
+		return "", fmt.Errorf("not connected")
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	output, err := session.Output("sha256sum '" + strings.ReplaceAll(target, "'", `'\''`) + "'")
+	if err != nil {
+		return "", err
+	}
+	// Output format: "hash  filename"
+	parts := strings.Fields(string(output))
+	if len(parts) >= 1 && len(parts[0]) == 64 {
+		return parts[0], nil
+	}
+	return "", fmt.Errorf("no hash in output")
+}
+
+// serverPath maps a remote path ("nas:/docs/a.pdf") to a server path below root.
+func (p *Provider) serverPath(remotePath string) string {
+	rel := strings.TrimPrefix(remotePath, p.remoteName)
+	return path.Join(p.cfg.Root, rel)
+}
+
+// partPath returns the temporary upload name for content with hash.
+func (p *Provider) partPath(target, hash string) string {
+	return target + "." + hash[:16] + partSuffix
+}
+
+// contextReader stops a copy when ctx is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+// progressReader reports the bytes read so far out of total.
+type progressReader struct {
+	r     io.Reader
+	total int64
+	n     int64
+	fn    provider.ProgressFunc
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.n += int64(n)
+	pr.fn(provider.Progress{Bytes: pr.n, Total: pr.total})
+	return n, err
+}
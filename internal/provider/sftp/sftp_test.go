@@ -0,0 +1,296 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/providertest"
+	pkgsftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testServer is an in-process SSH server offering only the sftp subsystem,
+// serving files below root. Only the generated client key is accepted.
+type testServer struct {
+	addr       string
+	root       string
+	keyFile    string
+	knownHosts string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	dir := t.TempDir()
+	ts := &testServer{root: filepath.Join(dir, "box")}
+	os.MkdirAll(ts.root, 0700)
+
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, _ := ssh.NewSignerFromKey(hostPriv)
+	clientPub, clientPriv, _ := ed25519.GenerateKey(rand.Reader)
+	authorized, _ := ssh.NewPublicKey(clientPub)
+
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatalf("failed to marshal client key: %v", err)
+	}
+	ts.keyFile = filepath.Join(dir, "id_ed25519")
+	os.WriteFile(ts.keyFile, pem.EncodeToMemory(block), 0600)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "box" && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ts.addr = ln.Addr().String()
+
+	ts.knownHosts = filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(ts.addr)}, hostSigner.PublicKey())
+	os.WriteFile(ts.knownHosts, []byte(line+"\n"), 0600)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go ts.serve(conn, config)
+		}
+	}()
+	return ts
+}
+
+func (ts *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, requests, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				// Payload is a length-prefixed subsystem name
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server, err := pkgsftp.NewServer(ch, pkgsftp.WithServerWorkingDirectory(ts.root))
+					if err == nil {
+						server.Serve()
+						server.Close()
+					}
+					ch.Close()
+				}
+			}
+		}()
+	}
+}
+
+func newTestProvider(t *testing.T, ts *testServer) *Provider {
+	t.Helper()
+	p, err := NewProvider("box", "Storage Box", "box:", Config{
+		Host:       ts.addr,
+		Username:   "box",
+		Root:       ts.root,
+		KeyFile:    ts.keyFile,
+		KnownHosts: ts.knownHosts,
+	})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestProvider_Roundtrip(t *testing.T) {
+	ts := newTestServer(t)
+	p := newTestProvider(t, ts)
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	if err := p.Init(ctx, nil); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+
+	content := []byte("hello over sftp")
+	local := filepath.Join(tmpDir, "a.txt")
+	os.WriteFile(local, content, 0600)
+	sum := sha256.Sum256(content)
+
+	for _, remote := range []string{"box:/docs/2024/a b.txt", "box:/docs/c.txt"} {
+		result, err := p.Upload(ctx, local, remote, nil)
+		if err != nil {
+			t.Fatalf("failed to upload %s: %v", remote, err)
+		}
+		if result.ContentHash != hex.EncodeToString(sum[:]) || result.Size != int64(len(content)) {
+			t.Errorf("unexpected upload result: %+v", result)
+		}
+	}
+	if got, _ := os.ReadFile(filepath.Join(ts.root, "docs", "2024", "a b.txt")); !bytes.Equal(got, content) {
+		t.Errorf("unexpected stored content %q", got)
+	}
+
+	if v, err := p.Verify(ctx, "box:/docs/c.txt"); err != nil || !v.IsValid {
+		t.Errorf("unexpected verify result: %+v (%v)", v, err)
+	}
+	if v, _ := p.Verify(ctx, "box:/docs"); v.IsValid {
+		t.Error("expected a directory to fail verification")
+	}
+
+	out := filepath.Join(tmpDir, "out", "a.txt")
+	download, err := p.Download(ctx, "box:/docs/2024/a b.txt", out, nil)
+	if err != nil {
+		t.Fatalf("failed to download: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, content) || download.ContentHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected download: %q %+v", got, download)
+	}
+
+	var buf bytes.Buffer
+	if n, err := p.DownloadRange(ctx, "box:/docs/2024/a b.txt", 6, 4, &buf); err != nil || n != 4 || buf.String() != "over" {
+		t.Errorf("unexpected range %q (%d, %v)", buf.String(), n, err)
+	}
+
+	objects, err := p.List(ctx, "")
+	if err != nil || len(objects) != 2 {
+		t.Fatalf("unexpected listing: %+v (%v)", objects, err)
+	}
+	paths := map[string]int64{}
+	for _, obj := range objects {
+		paths[obj.Path] = obj.Size
+	}
+	if paths["box:docs/2024/a b.txt"] != int64(len(content)) || paths["box:docs/c.txt"] != int64(len(content)) {
+		t.Errorf("unexpected listing paths: %v", paths)
+	}
+
+	if err := p.Delete(ctx, "box:/docs/c.txt"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if v, _ := p.Verify(ctx, "box:/docs/c.txt"); v.IsValid {
+		t.Error("expected deleted file to be invalid")
+	}
+	if err := p.Delete(ctx, "box:/docs/c.txt"); err != nil {
+		t.Errorf("expected deleting a missing file to succeed, got %v", err)
+	}
+	if p.CheckHealth(ctx) != "healthy" {
+		t.Error("expected provider to be healthy")
+	}
+}
+
+func TestProvider_ResumeUpload(t *testing.T) {
+	ts := newTestServer(t)
+	p := newTestProvider(t, ts)
+	ctx := context.Background()
+
+	content := bytes.Repeat([]byte("0123456789"), 2500) // 25000 bytes
+	local := filepath.Join(t.TempDir(), "big.bin")
+	os.WriteFile(local, content, 0600)
+	sum := sha256.Sum256(content)
+
+	// An interrupted attempt left the first 10000 bytes behind
+	target := filepath.Join(ts.root, "big.bin")
+	part := p.partPath(target, hex.EncodeToString(sum[:]))
+	os.WriteFile(part, content[:10000], 0600)
+	// A stale part of other content must not be picked up
+	stale := p.partPath(target, hex.EncodeToString(make([]byte, 32)))
+	os.WriteFile(stale, []byte("other"), 0600)
+
+	var progress []float64
+	if _, err := p.Upload(ctx, local, "box:/big.bin", func(pr provider.Progress) { progress = append(progress, pr.Fraction()) }); err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+
+	if got, _ := os.ReadFile(target); !bytes.Equal(got, content) {
+		t.Errorf("stored content differs (%d bytes)", len(got))
+	}
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Error("expected partial upload renamed into place")
+	}
+	// Progress starts from the resumed offset, not from zero
+	if len(progress) == 0 || progress[0] <= 0.4 || progress[len(progress)-1] != 1.0 {
+		t.Errorf("expected progress resuming past 0.4 and ending at 1.0, got %v", progress)
+	}
+
+	objects, _ := p.List(ctx, "")
+	if len(objects) != 1 {
+		t.Errorf("expected part files hidden from listing, got %+v", objects)
+	}
+}
+
+func TestProvider_Usage(t *testing.T) {
+	ts := newTestServer(t)
+	p := newTestProvider(t, ts)
+
+	usage, err := p.GetUsage(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get usage: %v", err)
+	}
+	// The in-process server answers statvfs@openssh.com from the local filesystem
+	if usage.TotalBytes <= 0 || usage.AvailableBytes <= 0 || usage.AvailableBytes > usage.TotalBytes {
+		t.Errorf("unexpected statvfs usage: %+v", usage)
+	}
+}
+
+func TestProvider_Auth(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+
+	// A host key missing from known_hosts is rejected
+	empty := filepath.Join(t.TempDir(), "known_hosts")
+	os.WriteFile(empty, nil, 0600)
+	p, _ := NewProvider("box", "Storage Box", "box:", Config{
+		Host: ts.addr, Username: "box", Root: ts.root, KeyFile: ts.keyFile, KnownHosts: empty,
+	})
+	if err := p.Init(ctx, nil); err == nil {
+		t.Error("expected unknown host key to be rejected")
+	}
+
+	// Another user is refused
+	p, _ = NewProvider("box", "Storage Box", "box:", Config{
+		Host: ts.addr, Username: "mallory", Root: ts.root, KeyFile: ts.keyFile, KnownHosts: ts.knownHosts,
+	})
+	if p.CheckHealth(ctx) != "unavailable" {
+		t.Error("expected refused login to be unavailable")
+	}
+
+	if _, err := ParseConfig(map[string]string{"host": "example.com", "username": "box"}); err == nil {
+		t.Error("expected config without key file or agent to be rejected")
+	}
+	cfg, err := ParseConfig(map[string]string{"host": "example.com", "username": "box", "use_agent": "true", "known_hosts": empty})
+	if err != nil || cfg.Host != "example.com:22" || cfg.Root != "." {
+		t.Errorf("unexpected defaults: %+v (%v)", cfg, err)
+	}
+}
+
+func TestProvider_Conformance(t *testing.T) {
+	ts := newTestServer(t)
+	providertest.Run(t, newTestProvider(t, ts), providertest.Options{Prefix: "box:"})
+}
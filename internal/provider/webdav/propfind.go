@@ -0,0 +1,118 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PROPFIND request bodies.
+const (
+	propsResource = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+	propsQuota = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:quota-used-bytes/><d:quota-available-bytes/></d:prop></d:propfind>`
+)
+
+// resource is one PROPFIND response entry.
+type resource struct {
+	path           string // Relative to the base collection
+	collection     bool
+	size           int64
+	modTime        time.Time
+	quotaUsed      int64 // -1 when not reported
+	quotaAvailable int64 // -1 when not reported
+}
+
+// multistatus is the RFC 4918 207 response body.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength  string `xml:"getcontentlength"`
+				LastModified   string `xml:"getlastmodified"`
+				QuotaUsed      string `xml:"quota-used-bytes"`
+				QuotaAvailable string `xml:"quota-available-bytes"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// propfind queries properties of rel (and its members for depth "1").
+func (p *Provider) propfind(ctx context.Context, rel, depth, body string) ([]resource, error) {
+	headers := map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml; charset=utf-8",
+	}
+	resp, err := p.do(ctx, "PROPFIND", rel, strings.NewReader(body), int64(len(body)), headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, &Error{Method: "PROPFIND", Path: rel, StatusCode: resp.StatusCode}
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("failed to parse PROPFIND response: %w", err)
+	}
+
+	resources := make([]resource, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		res := resource{path: p.hrefPath(r.Href), quotaUsed: -1, quotaAvailable: -1}
+		for _, ps := range r.Propstat {
+			// Properties the server does not have come back in a 404 propstat
+			if !strings.Contains(ps.Status, " 200") {
+				continue
+			}
+			prop := ps.Prop
+			if prop.ResourceType.Collection != nil {
+				res.collection = true
+			}
+			if prop.ContentLength != "" {
+				res.size, _ = strconv.ParseInt(strings.TrimSpace(prop.ContentLength), 10, 64)
+			}
+			if prop.LastModified != "" {
+				res.modTime, _ = http.ParseTime(prop.LastModified)
+			}
+			if n, err := strconv.ParseInt(strings.TrimSpace(prop.QuotaUsed), 10, 64); err == nil {
+				res.quotaUsed = n
+			}
+			if n, err := strconv.ParseInt(strings.TrimSpace(prop.QuotaAvailable), 10, 64); err == nil {
+				res.quotaAvailable = n
+			}
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// hrefPath converts a response href (absolute URL or path) to a path
+// relative to the base collection.
+func (p *Provider) hrefPath(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	base := strings.Trim(p.base.Path, "/")
+	rel := strings.Trim(u.Path, "/")
+	if base != "" {
+		if rel == base {
+			return ""
+		}
+		rel = strings.TrimPrefix(rel, base+"/")
+	}
+	return rel
+}
@@ -0,0 +1,624 @@
+// Package webdav provides a native WebDAV storage provider.
+// Works against any RFC 4918 server (Nextcloud, ownCloud, Apache mod_dav,
+// nginx dav) without rclone.
+//
+// INVARIANTS:
+// - Parent collections are created with MKCOL before an object is written
+// - An upload is reported only after the stored size is confirmed
+// - Uploads write to a temporary name and MOVE into place
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/bwlimit"
+)
+
+const (
+	// DefaultChunkSize is the chunk size for partial-update uploads.
+	DefaultChunkSize = 10 * 1024 * 1024
+
+	// partialUpdateCap is the DAV compliance class advertised by servers
+	// that accept PATCH with X-Update-Range (SabreDAV partial updates).
+	partialUpdateCap = "sabredav-partialupdate"
+
+	partSuffix = ".cloudfs-part"
+)
+
+// Config holds the connection settings for a WebDAV collection.
+type Config struct {
+	URL       string // Base collection, e.g. https://cloud.example.com/remote.php/dav/files/me/cloudfs
+	Username  string
+	Password  string
+	ChunkSize int64 // Files larger than this are uploaded in chunks when the server supports it
+	Quota     int64 // Capacity reported by GetUsage when the server has no RFC 4331 quota (0 = unknown)
+}
+
+// ParseConfig builds a Config from provider_config key/value pairs.
+// The password falls back to CLOUDFS_WEBDAV_PASSWORD.
+func ParseConfig(cfg map[string]string) (Config, error) {
+	c := Config{
+		URL:      cfg["url"],
+		Username: cfg["username"],
+		Password: cfg["password"],
+	}
+	if c.Password == "" {
+		c.Password = os.Getenv("CLOUDFS_WEBDAV_PASSWORD")
+	}
+	for key, dst := range map[string]*int64{"part_size": &c.ChunkSize, "quota": &c.Quota} {
+		if v := cfg[key]; v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return c, fmt.Errorf("invalid webdav %s: %s", key, v)
+			}
+			*dst = n
+		}
+	}
+	return c, c.validate()
+}
+
+// validate checks required fields and applies defaults.
+func (c *Config) validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webdav url: %q", c.URL)
+	}
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	if c.ChunkSize == 0 {
+		c.ChunkSize = DefaultChunkSize
+	}
+	return nil
+}
+
+// Error is an unexpected HTTP status from the server.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("webdav: %s %s: HTTP %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// HTTPStatus returns the response status code.
+func (e *Error) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// isNotFound reports whether err is a missing resource.
+func isNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// Provider implements the storage provider interface for WebDAV servers.
+type Provider struct {
+	id          string
+	displayName string
+	remoteName  string // Prefix of placements.remote_path, e.g. "nextcloud:"
+	cfg         Config
+	base        *url.URL
+	client      *http.Client
+
+	mu            sync.Mutex
+	collections   map[string]bool // Collections known to exist
+	partialUpdate *bool           // Server capability, probed once
+}
+
+// NewProvider creates a new WebDAV provider.
+// remoteName is stripped from remote paths to form resource paths.
+func NewProvider(id, displayName, remoteName string, cfg Config) (*Provider, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	base, _ := url.Parse(cfg.URL)
+	return &Provider{
+		id:          id,
+		displayName: displayName,
+		remoteName:  remoteName,
+		cfg:         cfg,
+		base:        base,
+		client:      &http.Client{},
+		collections: map[string]bool{"": true},
+	}, nil
+}
+
+// ID returns the unique identifier for this provider instance.
+func (p *Provider) ID() string {
+	return p.id
+}
+
+// Type returns the provider type.
+func (p *Provider) Type() string {
+	return "webdav"
+}
+
+// DisplayName returns the human-readable name.
+func (p *Provider) DisplayName() string {
+	return p.displayName
+}
+
+// Init verifies the base collection is reachable with the configured credentials.
+func (p *Provider) Init(ctx context.Context, config map[string]interface{}) error {
+	if _, err := p.propfind(ctx, "", "0", propsResource); err != nil {
+		return fmt.Errorf("collection %s not reachable: %w", p.cfg.URL, err)
+	}
+	return nil
+}
+
+// Capabilities returns what this provider supports.
+func (p *Provider) Capabilities(ctx context.Context) (*provider.Capabilities, error) {
+	return &provider.Capabilities{
+		MaxChunkSize:         p.cfg.ChunkSize,
+		SupportsVersioning:   false,
+		SupportsDirectUpload: true,
+		RequiresEncryption:   false,
+		SupportsResume:       false,
+		ConcurrentUploads:    1,
+	}, nil
+}
+
+// GetUsage returns the RFC 4331 quota of the base collection. Servers
+// without quota properties report the summed object sizes against the
+// configured quota instead.
+func (p *Provider) GetUsage(ctx context.Context) (*provider.Usage, error) {
+	resources, err := p.propfind(ctx, "", "0", propsQuota)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	if len(resources) > 0 && resources[0].quotaUsed >= 0 && resources[0].quotaAvailable >= 0 {
+		r := resources[0]
+		return &provider.Usage{
+			TotalBytes:     r.quotaUsed + r.quotaAvailable,
+			UsedBytes:      r.quotaUsed,
+			AvailableBytes: r.quotaAvailable,
+		}, nil
+	}
+
+	objects, err := p.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	usage := &provider.Usage{TotalBytes: p.cfg.Quota}
+	for _, obj := range objects {
+		usage.UsedBytes += obj.Size
+	}
+	if usage.TotalBytes > usage.UsedBytes {
+		usage.AvailableBytes = usage.TotalBytes - usage.UsedBytes
+	}
+	return usage, nil
+}
+
+// Upload uploads a file, in chunks when it is larger than the chunk size
+// and the server supports partial updates.
+func (p *Provider) Upload(ctx context.Context, localPath string, remotePath string, progress provider.ProgressFunc) (*provider.UploadResult, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("local file not found: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat local file: %w", err)
+	}
+
+	rel := p.resourcePath(remotePath)
+	if err := p.mkcolAll(ctx, path.Dir(rel)); err != nil {
+		return nil, err
+	}
+
+	// Servers write PUT bodies as they arrive, so an interrupted upload
+	// must never target the destination itself
+	part := rel + partSuffix
+	h := sha256.New()
+	body := io.TeeReader(f, h)
+	if info.Size() > p.cfg.ChunkSize && p.supportsPartialUpdate(ctx) {
+		err = p.uploadChunked(ctx, body, info.Size(), part, progress)
+	} else {
+		if progress != nil && info.Size() > 0 {
+			body = &progressReader{r: body, total: info.Size(), fn: progress}
+		}
+		err = p.put(ctx, part, body, info.Size())
+	}
+	if err == nil {
+		err = p.move(ctx, part, rel)
+	}
+	if err != nil {
+		// Best effort; List skips leftover part files anyway
+		p.delete(context.WithoutCancel(ctx), part)
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+
+	// Confirm what the server stored before reporting success
+	resources, err := p.propfind(ctx, rel, "0", propsResource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm upload: %w", err)
+	}
+	if len(resources) == 0 || resources[0].size != info.Size() {
+		return nil, fmt.Errorf("size mismatch after upload of %s: expected %d bytes", remotePath, info.Size())
+	}
+
+	return &provider.UploadResult{
+		RemotePath:  remotePath,
+		ContentHash: hex.EncodeToString(h.Sum(nil)),
+		UploadedAt:  time.Now(),
+		Size:        info.Size(),
+	}, nil
+}
+
+// uploadChunked writes body to the temporary resource part with PATCH
+// X-Update-Range requests.
+func (p *Provider) uploadChunked(ctx context.Context, body io.Reader, size int64, part string, progress provider.ProgressFunc) error {
+	if err := p.put(ctx, part, bytes.NewReader(nil), 0); err != nil {
+		return err
+	}
+
+	buf := make([]byte, p.cfg.ChunkSize)
+	for offset := int64(0); offset < size; {
+		n, err := io.ReadFull(body, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read chunk at %d: %w", offset, err)
+		}
+
+		headers := map[string]string{
+			"Content-Type":   "application/x-sabredav-partialupdate",
+			"X-Update-Range": fmt.Sprintf("bytes=%d-%d", offset, offset+int64(n)-1),
+		}
+		resp, err := p.do(ctx, "PATCH", part, bytes.NewReader(buf[:n]), int64(n), headers)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return &Error{Method: "PATCH", Path: part, StatusCode: resp.StatusCode}
+		}
+
+		offset += int64(n)
+		if progress != nil {
+			progress(provider.Progress{Bytes: offset, Total: size})
+		}
+	}
+	return nil
+}
+
+// move renames the resource src over dst.
+func (p *Provider) move(ctx context.Context, src, dst string) error {
+	headers := map[string]string{
+		"Destination": p.resourceURL(dst).String(),
+		"Overwrite":   "T",
+	}
+	resp, err := p.do(ctx, "MOVE", src, nil, 0, headers)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return &Error{Method: "MOVE", Path: src, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// Download downloads a resource, rejecting short reads.
+func (p *Provider) Download(ctx context.Context, remotePath string, localPath string, progress provider.ProgressFunc) (*provider.DownloadResult, error) {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	rel := p.resourcePath(remotePath)
+	resp, err := p.do(ctx, http.MethodGet, rel, nil, 0, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %w", &Error{Method: http.MethodGet, Path: rel, StatusCode: resp.StatusCode})
+	}
+
+	out, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local file: %w", err)
+	}
+
+	h := sha256.New()
+	var body io.Reader = resp.Body
+	if progress != nil && resp.ContentLength > 0 {
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, fn: progress}
+	}
+	size, err := io.Copy(io.MultiWriter(out, h), body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && resp.ContentLength >= 0 && size != resp.ContentLength {
+		err = fmt.Errorf("short read: got %d of %d bytes", size, resp.ContentLength)
+	}
+	if err != nil {
+		os.Remove(localPath)
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+
+	return &provider.DownloadResult{
+		LocalPath:    localPath,
+		ContentHash:  hex.EncodeToString(h.Sum(nil)),
+		DownloadedAt: time.Now(),
+		Size:         size,
+	}, nil
+}
+
+// DownloadRange streams a byte range of a resource using an HTTP Range
+// request. Servers that ignore Range are handled by skipping the prefix.
+func (p *Provider) DownloadRange(ctx context.Context, remotePath string, offset, length int64, w io.Writer) (int64, error) {
+	if length == 0 {
+		return 0, nil
+	}
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		rng = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	rel := p.resourcePath(remotePath)
+	resp, err := p.do(ctx, http.MethodGet, rel, nil, 0, map[string]string{"Range": rng})
+	if err != nil {
+		return 0, fmt.Errorf("ranged download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			return 0, fmt.Errorf("ranged download failed: %w", err)
+		}
+	default:
+		return 0, fmt.Errorf("ranged download failed: %w", &Error{Method: http.MethodGet, Path: rel, StatusCode: resp.StatusCode})
+	}
+	if length > 0 {
+		body = io.LimitReader(body, length)
+	}
+
+	n, err := io.Copy(w, body)
+	if err != nil {
+		return n, fmt.Errorf("ranged download failed: %w", err)
+	}
+	return n, nil
+}
+
+// List returns every resource below prefix, walking collections with
+// Depth: 1 PROPFIND (many servers refuse Depth: infinity).
+// Returned paths are fully qualified, matching placements.remote_path.
+func (p *Provider) List(ctx context.Context, prefix string) ([]provider.RemoteObject, error) {
+	var objects []provider.RemoteObject
+	pending := []string{p.resourcePath(prefix)}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		resources, err := p.propfind(ctx, dir, "1", propsResource)
+		if err != nil {
+			if isNotFound(err) && dir == p.resourcePath(prefix) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("list failed: %w", err)
+		}
+		for _, r := range resources {
+			if r.path == dir {
+				continue
+			}
+			if r.collection {
+				pending = append(pending, r.path)
+				continue
+			}
+			if strings.HasSuffix(r.path, partSuffix) {
+				continue
+			}
+			objects = append(objects, provider.RemoteObject{
+				Path:    p.remoteName + r.path,
+				Size:    r.size,
+				ModTime: r.modTime,
+			})
+		}
+	}
+	return objects, nil
+}
+
+// Delete removes a resource from the server.
+// NOTE: Only invoked during explicit purge or trash eviction after user confirmation.
+func (p *Provider) Delete(ctx context.Context, remotePath string) error {
+	if err := p.delete(ctx, p.resourcePath(remotePath)); err != nil {
+		// Deleting a resource that is already gone is not an error
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	return nil
+}
+
+// Verify checks that the resource exists. WebDAV has no standard content
+// hash, so ContentHash is empty and callers rehash a download.
+func (p *Provider) Verify(ctx context.Context, remotePath string) (*provider.VerifyResult, error) {
+	resources, err := p.propfind(ctx, p.resourcePath(remotePath), "0", propsResource)
+	if err != nil {
+		if isNotFound(err) {
+			return &provider.VerifyResult{
+				IsValid:      false,
+				ErrorMessage: "file not found or inaccessible",
+			}, nil
+		}
+		return nil, fmt.Errorf("verify failed: %w", err)
+	}
+	if len(resources) == 0 || resources[0].collection {
+		return &provider.VerifyResult{
+			IsValid:      false,
+			ErrorMessage: "not a file",
+		}, nil
+	}
+
+	return &provider.VerifyResult{IsValid: true}, nil
+}
+
+// CheckHealth returns current health state.
+// NOTE: Health is observational, not decision authority.
+func (p *Provider) CheckHealth(ctx context.Context) provider.HealthState {
+	if _, err := p.propfind(ctx, "", "0", propsResource); err != nil {
+		return provider.HealthStateUnavailable
+	}
+	return provider.HealthStateHealthy
+}
+
+// put writes body to a resource.
+func (p *Provider) put(ctx context.Context, rel string, body io.Reader, size int64) error {
+	resp, err := p.do(ctx, http.MethodPut, rel, body, size, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return &Error{Method: http.MethodPut, Path: rel, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// delete removes a resource.
+func (p *Provider) delete(ctx context.Context, rel string) error {
+	resp, err := p.do(ctx, http.MethodDelete, rel, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return &Error{Method: http.MethodDelete, Path: rel, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// mkcolAll creates dir and any missing parent collections.
+func (p *Provider) mkcolAll(ctx context.Context, dir string) error {
+	if dir == "." || dir == "/" {
+		dir = ""
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := ""
+	for _, seg := range strings.Split(dir, "/") {
+		if seg == "" {
+			continue
+		}
+		current = path.Join(current, seg)
+		if p.collections[current] {
+			continue
+		}
+		resp, err := p.do(ctx, "MKCOL", current, nil, 0, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create collection %s: %w", current, err)
+		}
+		resp.Body.Close()
+		// 405 Method Not Allowed: the collection already exists
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("failed to create collection %s: %w", current, &Error{Method: "MKCOL", Path: current, StatusCode: resp.StatusCode})
+		}
+		p.collections[current] = true
+	}
+	return nil
+}
+
+// supportsPartialUpdate probes the server's DAV header once.
+func (p *Provider) supportsPartialUpdate(ctx context.Context) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.partialUpdate != nil {
+		return *p.partialUpdate
+	}
+
+	supported := false
+	if resp, err := p.do(ctx, http.MethodOptions, "", nil, 0, nil); err == nil {
+		resp.Body.Close()
+		for _, class := range strings.Split(strings.Join(resp.Header.Values("DAV"), ","), ",") {
+			if strings.TrimSpace(class) == partialUpdateCap {
+				supported = true
+			}
+		}
+	}
+	p.partialUpdate = &supported
+	return supported
+}
+
+// resourcePath maps a remote path ("nextcloud:/docs/a.pdf") to a path
+// relative to the base collection ("docs/a.pdf").
+func (p *Provider) resourcePath(remotePath string) string {
+	rel := strings.TrimPrefix(remotePath, p.remoteName)
+	var parts []string
+	for _, part := range strings.Split(rel, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// resourceURL returns the URL of a path relative to the base collection.
+func (p *Provider) resourceURL(rel string) *url.URL {
+	u := *p.base
+	u.Path = strings.TrimSuffix(p.base.Path, "/") + "/" + rel
+	u.RawPath = ""
+	return &u
+}
+
+// do sends an authenticated request. Status handling is left to the caller.
+func (p *Provider) do(ctx context.Context, method, rel string, body io.Reader, size int64, headers map[string]string) (*http.Response, error) {
+	if method == http.MethodPut || method == "PATCH" {
+		body = bwlimit.Reader(ctx, bwlimit.Upload, body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.resourceURL(rel).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if p.cfg.Username != "" || p.cfg.Password != "" {
+		req.SetBasicAuth(p.cfg.Username, p.cfg.Password)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.client.Do(req)
+	if err == nil && method == http.MethodGet {
+		resp.Body = bwlimit.ReadCloser(ctx, bwlimit.Download, resp.Body)
+	}
+	return resp, err
+}
+
+// progressReader reports the bytes read so far out of total.
+type progressReader struct {
+	r     io.Reader
+	total int64
+	n     int64
+	fn    provider.ProgressFunc
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.n += int64(n)
+	pr.fn(provider.Progress{Bytes: pr.n, Total: pr.total})
+	return n, err
+}
@@ -0,0 +1,299 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudfs/cloudfs/internal/provider"
+	"github.com/cloudfs/cloudfs/internal/provider/providertest"
+	xwebdav "golang.org/x/net/webdav"
+)
+
+// testServer runs golang.org/x/net/webdav in-process behind basic auth.
+// With partialUpdate it also accepts SabreDAV-style PATCH requests, which
+// x/net/webdav does not implement itself.
+type testServer struct {
+	*httptest.Server
+	fs      xwebdav.FileSystem
+	mu      sync.Mutex
+	patches int
+	puts    int
+}
+
+func newTestServer(t *testing.T, partialUpdate bool) *testServer {
+	t.Helper()
+	ts := &testServer{fs: xwebdav.NewMemFS()}
+	dav := &xwebdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: ts.fs,
+		LockSystem: xwebdav.NewMemLS(),
+	}
+
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/dav")
+
+		switch {
+		case partialUpdate && r.Method == http.MethodOptions:
+			w.Header().Set("DAV", "1, 2, "+partialUpdateCap)
+			w.WriteHeader(http.StatusOK)
+
+		case partialUpdate && r.Method == "PATCH":
+			var start, end int64
+			if _, err := fmt.Sscanf(r.Header.Get("X-Update-Range"), "bytes=%d-%d", &start, &end); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			f, err := ts.fs.OpenFile(r.Context(), name, os.O_RDWR, 0)
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			defer f.Close()
+			data, _ := io.ReadAll(r.Body)
+			if int64(len(data)) != end-start+1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			f.Seek(start, io.SeekStart)
+			f.Write(data)
+			ts.mu.Lock()
+			ts.patches++
+			ts.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			if r.Method == http.MethodPut {
+				ts.mu.Lock()
+				ts.puts++
+				ts.mu.Unlock()
+			}
+			dav.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTestProvider(t *testing.T, ts *testServer, chunkSize int64) *Provider {
+	t.Helper()
+	// The base collection must exist before the provider is used
+	ts.fs.Mkdir(context.Background(), "/cloudfs", 0700)
+	p, err := NewProvider("dav", "DAV", "dav:", Config{
+		URL:       ts.URL + "/dav/cloudfs/",
+		Username:  "alice",
+		Password:  "secret",
+		ChunkSize: chunkSize,
+		Quota:     1 << 20,
+	})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	return p
+}
+
+func TestProvider_Roundtrip(t *testing.T) {
+	ts := newTestServer(t, false)
+	p := newTestProvider(t, ts, 0)
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	if err := p.Init(ctx, nil); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+
+	content := []byte("hello over webdav")
+	local := filepath.Join(tmpDir, "a.txt")
+	os.WriteFile(local, content, 0600)
+	sum := sha256.Sum256(content)
+
+	// Parent collections are created on demand
+	for _, remote := range []string{"dav:/docs/2024/a b.txt", "dav:/docs/c.txt"} {
+		result, err := p.Upload(ctx, local, remote, nil)
+		if err != nil {
+			t.Fatalf("failed to upload %s: %v", remote, err)
+		}
+		if result.ContentHash != hex.EncodeToString(sum[:]) || result.Size != int64(len(content)) {
+			t.Errorf("unexpected upload result: %+v", result)
+		}
+	}
+
+	if v, err := p.Verify(ctx, "dav:/docs/2024/a b.txt"); err != nil || !v.IsValid {
+		t.Errorf("unexpected verify result: %+v (%v)", v, err)
+	}
+	if v, _ := p.Verify(ctx, "dav:/docs"); v.IsValid {
+		t.Error("expected a collection to fail verification")
+	}
+
+	out := filepath.Join(tmpDir, "out", "a.txt")
+	download, err := p.Download(ctx, "dav:/docs/2024/a b.txt", out, nil)
+	if err != nil {
+		t.Fatalf("failed to download: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, content) || download.ContentHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected download: %q %+v", got, download)
+	}
+
+	var buf bytes.Buffer
+	if n, err := p.DownloadRange(ctx, "dav:/docs/2024/a b.txt", 6, 4, &buf); err != nil || n != 4 || buf.String() != "over" {
+		t.Errorf("unexpected range %q (%d, %v)", buf.String(), n, err)
+	}
+
+	objects, err := p.List(ctx, "")
+	if err != nil || len(objects) != 2 {
+		t.Fatalf("unexpected listing: %+v (%v)", objects, err)
+	}
+	paths := map[string]int64{}
+	for _, obj := range objects {
+		paths[obj.Path] = obj.Size
+	}
+	if paths["dav:docs/2024/a b.txt"] != int64(len(content)) || paths["dav:docs/c.txt"] != int64(len(content)) {
+		t.Errorf("unexpected listing paths: %v", paths)
+	}
+
+	// x/net/webdav has no RFC 4331 quota, so usage falls back to the listing
+	usage, err := p.GetUsage(ctx)
+	if err != nil || usage.UsedBytes != int64(2*len(content)) || usage.TotalBytes != 1<<20 {
+		t.Errorf("unexpected usage: %+v (%v)", usage, err)
+	}
+
+	if err := p.Delete(ctx, "dav:/docs/c.txt"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if v, _ := p.Verify(ctx, "dav:/docs/c.txt"); v.IsValid {
+		t.Error("expected deleted resource to be invalid")
+	}
+	if err := p.Delete(ctx, "dav:/docs/c.txt"); err != nil {
+		t.Errorf("expected deleting a missing resource to succeed, got %v", err)
+	}
+
+	bad, _ := NewProvider("dav", "DAV", "dav:", Config{URL: ts.URL + "/dav/cloudfs", Username: "alice", Password: "wrong"})
+	if bad.CheckHealth(ctx) != "unavailable" {
+		t.Error("expected wrong credentials to be unavailable")
+	}
+}
+
+func TestProvider_ChunkedUpload(t *testing.T) {
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("0123456789"), 2500) // 25000 bytes
+	local := filepath.Join(t.TempDir(), "big.bin")
+	os.WriteFile(local, content, 0600)
+
+	for _, partial := range []bool{true, false} {
+		ts := newTestServer(t, partial)
+		p := newTestProvider(t, ts, 10000)
+
+		var progress []float64
+		if _, err := p.Upload(ctx, local, "dav:/big.bin", func(pr provider.Progress) { progress = append(progress, pr.Fraction()) }); err != nil {
+			t.Fatalf("partial=%v: failed to upload: %v", partial, err)
+		}
+
+		f, err := ts.fs.OpenFile(ctx, "/cloudfs/big.bin", os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatalf("partial=%v: expected uploaded file: %v", partial, err)
+		}
+		got, _ := io.ReadAll(f)
+		f.Close()
+		if !bytes.Equal(got, content) {
+			t.Errorf("partial=%v: stored content differs (%d bytes)", partial, len(got))
+		}
+		if len(progress) == 0 || progress[len(progress)-1] != 1.0 {
+			t.Errorf("partial=%v: expected progress ending at 1.0, got %v", partial, progress)
+		}
+
+		if partial {
+			// One empty PUT for the temporary resource, then three chunks
+			if ts.patches != 3 || ts.puts != 1 {
+				t.Errorf("expected 3 PATCH chunks and 1 PUT, got %d/%d", ts.patches, ts.puts)
+			}
+			if _, err := ts.fs.Stat(ctx, "/cloudfs/big.bin"+partSuffix); !os.IsNotExist(err) {
+				t.Error("expected temporary resource moved into place")
+			}
+		} else if ts.patches != 0 || ts.puts != 1 {
+			t.Errorf("expected a single PUT without partial updates, got %d/%d", ts.patches, ts.puts)
+		}
+	}
+}
+
+// TestProvider_InterruptedUpload checks that a PUT cut off mid-body does
+// not replace an existing object; servers write the body as it arrives.
+func TestProvider_InterruptedUpload(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t, false)
+	p := newTestProvider(t, ts, 64<<20)
+
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.bin")
+	os.WriteFile(old, []byte("previous version"), 0600)
+	if _, err := p.Upload(ctx, old, "dav:/report.bin", nil); err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+
+	local := filepath.Join(dir, "new.bin")
+	os.WriteFile(local, bytes.Repeat([]byte("cloudfs "), 1<<19), 0600) // 4 MiB
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if _, err := p.Upload(cctx, local, "dav:/report.bin", func(pr provider.Progress) {
+		if pr.Bytes >= 1<<20 {
+			cancel()
+		}
+	}); err == nil {
+		t.Fatal("expected cancelled upload to fail")
+	}
+
+	// Close waits for the server to finish handling the cut-off PUT
+	ts.Close()
+	f, err := ts.fs.OpenFile(ctx, "/cloudfs/report.bin", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("expected previous object to survive: %v", err)
+	}
+	got, _ := io.ReadAll(f)
+	f.Close()
+	if string(got) != "previous version" {
+		t.Errorf("interrupted upload replaced the object (%d bytes)", len(got))
+	}
+}
+
+func TestProvider_Quota(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?>
+<d:multistatus xmlns:d="DAV:"><d:response><d:href>/files/me/</d:href>
+<d:propstat><d:prop><d:quota-used-bytes>300</d:quota-used-bytes><d:quota-available-bytes>700</d:quota-available-bytes></d:prop>
+<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`)
+	}))
+	defer srv.Close()
+
+	p, _ := NewProvider("dav", "DAV", "dav:", Config{URL: srv.URL + "/files/me"})
+	usage, err := p.GetUsage(context.Background())
+	if err != nil || usage.UsedBytes != 300 || usage.AvailableBytes != 700 || usage.TotalBytes != 1000 {
+		t.Errorf("unexpected RFC 4331 usage: %+v (%v)", usage, err)
+	}
+
+	if _, err := ParseConfig(map[string]string{"url": "ftp://example.com"}); err == nil {
+		t.Error("expected non-HTTP url to be rejected")
+	}
+}
+
+func TestProvider_Conformance(t *testing.T) {
+	for _, partial := range []bool{false, true} {
+		t.Run(fmt.Sprintf("partial=%v", partial), func(t *testing.T) {
+			ts := newTestServer(t, partial)
+			providertest.Run(t, newTestProvider(t, ts, 4<<20), providertest.Options{Prefix: "dav:"})
+		})
+	}
+}
@@ -365,6 +365,47 @@ func (a *ActionDispatcher) LoadProviders(ctx context.Context) ([]ProviderState,
 	return providers, nil
 }
 
+// --- Task Actions ---
+
+// LoadTasks loads the provider transfers other commands have in flight
+func (a *ActionDispatcher) LoadTasks(ctx context.Context) ([]TaskState, error) {
+	db, err := a.OpenDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	transfers, err := core.NewTransferTracker(db.DB()).Active(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []TaskState
+	for _, t := range transfers {
+		task := TaskState{
+			ID:          fmt.Sprintf("transfer-%d", t.ID),
+			Type:        t.Direction,
+			Description: fmt.Sprintf("%s → %s", t.Name, t.Provider),
+			Progress:    t.Progress.Fraction(),
+			Status:      "running",
+			StartedAt:   t.StartedAt,
+			Bytes:       t.Progress.Bytes,
+			Total:       t.Progress.Total,
+			Speed:       t.Progress.Speed,
+			ETA:         t.Progress.ETA,
+		}
+		if t.Direction == core.TransferDownload {
+			task.Description = fmt.Sprintf("%s ← %s", t.Name, t.Provider)
+		}
+		if t.Stalled {
+			task.Status = "stalled"
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
 // --- Archive Actions ---
 
 // LoadArchives loads all archives
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
@@ -59,6 +60,9 @@ type AppState struct {
 	CacheItems []CacheEntryState
 	QueueItems []QueueItemState
 
+	// Transfers in flight, refreshed every second
+	Tasks []TaskState
+
 	// Navigation state per pane
 	LeftPaneScroll  *ScrollState
 	RightPaneScroll *ScrollState
@@ -87,9 +91,15 @@ type (
 		violations []InvariantViolation
 		err        error
 	}
+	tasksLoadedMsg struct {
+		tasks []TaskState
+	}
 	tickMsg struct{}
 )
 
+// taskRefreshInterval is how often the task panel polls for transfers.
+const taskRefreshInterval = time.Second
+
 // NewApp creates a new TUI application
 func NewApp() *App {
 	s := spinner.New()
@@ -126,6 +136,7 @@ func (a *App) Init() tea.Cmd {
 	return tea.Batch(
 		a.spinner.Tick,
 		a.loadFromDatabase(),
+		a.tick(),
 	)
 }
 
@@ -164,6 +175,12 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, a.loadFromDatabase())
 		}
 
+	case tickMsg:
+		cmds = append(cmds, a.loadTasks(), a.tick())
+
+	case tasksLoadedMsg:
+		a.state.Tasks = msg.tasks
+
 	case invariantCheckMsg:
 		if msg.err != nil {
 			a.statusMsg = fmt.Sprintf("Invariant check failed: %v", msg.err)
@@ -488,10 +505,37 @@ func (a *App) loadFromDatabase() tea.Cmd {
 			state.QueueItems = queue
 		}
 
+		// Load transfers in flight
+		tasks, err := dispatcher.LoadTasks(ctx)
+		if err == nil {
+			state.Tasks = tasks
+		}
+
 		return dbLoadedMsg{state: state}
 	}
 }
 
+// tick schedules the next task panel refresh.
+func (a *App) tick() tea.Cmd {
+	return tea.Tick(taskRefreshInterval, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+// loadTasks reloads only the transfers, which change far more often than
+// the rest of the state.
+func (a *App) loadTasks() tea.Cmd {
+	return func() tea.Msg {
+		dispatcher, err := NewActionDispatcher(GetConfigDir(), os.Getenv("CLOUDFS_PASSPHRASE"))
+		if err != nil {
+			return tasksLoadedMsg{tasks: a.state.Tasks}
+		}
+		tasks, err := dispatcher.LoadTasks(context.Background())
+		if err != nil {
+			return tasksLoadedMsg{tasks: a.state.Tasks}
+		}
+		return tasksLoadedMsg{tasks: tasks}
+	}
+}
+
 // View implements tea.Model - renders the entire UI
 func (a *App) View() string {
 	if !a.ready {
@@ -553,6 +597,9 @@ func (a *App) renderTopBar() string {
 	}
 
 	right := fmt.Sprintf("%s │ %d prov │ Cache: %s", health, providerCount, cacheInfo)
+	if len(a.state.Tasks) > 0 {
+		right = fmt.Sprintf("⇅ %d │ %s", len(a.state.Tasks), right)
+	}
 
 	// Calculate spacing
 	spacing := width - len(repoInfo) - len(viewName) - len(right) - 8
@@ -699,9 +746,44 @@ func (a *App) renderDashboardContent(width, height int) string {
 		lines = append(lines, a.styles.Warning.Render(fmt.Sprintf("⚠ %d invariant violations", len(a.state.Violations))))
 	}
 
+	lines = append(lines, "")
+	lines = append(lines, a.renderTasks(width)...)
+
 	return strings.Join(lines, "\n")
 }
 
+// renderTasks renders the task panel: one progress line per transfer.
+func (a *App) renderTasks(width int) []string {
+	lines := []string{a.styles.Title.Render("Tasks")}
+	if len(a.state.Tasks) == 0 {
+		return append(lines, a.styles.Muted.Render("No transfers running."))
+	}
+
+	for _, t := range a.state.Tasks {
+		lines = append(lines, Truncate(t.Description, width-4))
+
+		const barWidth = 20
+		detail := formatBytes(t.Bytes)
+		if t.Total > 0 {
+			filled := int(t.Progress * barWidth)
+			bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
+			detail = fmt.Sprintf("[%s] %3.0f%%  %s / %s", bar, t.Progress*100, formatBytes(t.Bytes), formatBytes(t.Total))
+		}
+		if t.Speed > 0 {
+			detail += fmt.Sprintf("  %s/s", formatBytes(int64(t.Speed)))
+		}
+		if t.ETA > 0 {
+			detail += "  ETA " + t.ETA.Round(time.Second).String()
+		}
+		if t.Status == "stalled" {
+			lines = append(lines, a.styles.Warning.Render(Truncate("  "+detail+"  (no progress)", width-4)))
+			continue
+		}
+		lines = append(lines, Truncate("  "+detail, width-4))
+	}
+	return lines
+}
+
 func (a *App) renderFilesContent(width, height int) string {
 	if len(a.state.Entries) == 0 {
 		return a.styles.Muted.Render("No files. Use 'cloudfs add <path>' to add files.")
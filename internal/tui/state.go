@@ -203,6 +203,12 @@ type TaskState struct {
 	StartedAt   time.Time
 	Error       string
 	Cancellable bool
+
+	// Transfer details, zero when unknown
+	Bytes int64
+	Total int64
+	Speed float64 // bytes per second
+	ETA   time.Duration
 }
 
 // NewState creates a new TUI state with defaults